@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"errors"
+
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/service"
+	adminService "gitee.com/taoJie_1/mall-agent/service/admin"
+	"github.com/gin-gonic/gin"
+)
+
+type ConflictApi struct{}
+
+// GetReport 检测整个知识库的关键词冲突、语义近似重复和标准问题偏离
+func (a *ConflictApi) GetReport(c *gin.Context) {
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+
+	report, err := service.Service.AdminServiceGroup.ConflictService.Report(ctx)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, report)
+}
+
+// failWithConflicts 保存知识条目失败时响应错误, 冲突导致的失败带上冲突报告, 前端确认后可带 force 重新提交
+func failWithConflicts(c *gin.Context, err error) {
+	var conflictErr *adminService.ConflictError
+	if errors.As(err, &conflictErr) {
+		common.FailWithData(c, err.Error(), conflictErr.Report)
+		return
+	}
+	common.Fail(c, err.Error())
+}
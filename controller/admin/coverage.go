@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"strconv"
+
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/service"
+	"github.com/gin-gonic/gin"
+)
+
+type CoverageApi struct{}
+
+// GetGaps 查询客户问得最多、但知识库无法回答的问题
+func (a *CoverageApi) GetGaps(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		common.Fail(c, "limit 格式错误")
+		return
+	}
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+
+	report, err := service.Service.AdminServiceGroup.CoverageService.GapReport(ctx, limit)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, report)
+}
+
+func (a *CoverageApi) DismissGap(c *gin.Context) {
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+	if err := service.Service.AdminServiceGroup.CoverageService.DismissGap(ctx, c.Param("id")); err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, nil)
+}
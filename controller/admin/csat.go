@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"strconv"
+
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/service"
+	"github.com/gin-gonic/gin"
+)
+
+type CsatApi struct{}
+
+// GetLowRated 查询最近的低分评价, 以及导致低分的知识条目和快捷回复
+func (a *CsatApi) GetLowRated(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		common.Fail(c, "limit 格式错误")
+		return
+	}
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+
+	report, err := service.Service.AdminServiceGroup.CsatService.LowRatedReport(ctx, limit)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, report)
+}
@@ -3,4 +3,14 @@ package admin
 type ApiGroup struct {
 	KeywordApi
 	UploadApi
+	RateLimitApi
+	GuardApi
+	PromptApi
+	ExperimentApi
+	CsatApi
+	SuggestionApi
+	CoverageApi
+	HistoryApi
+	TransferApi
+	ConflictApi
 }
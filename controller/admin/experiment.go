@@ -0,0 +1,23 @@
+package admin
+
+import (
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/service"
+	"github.com/gin-gonic/gin"
+)
+
+type ExperimentApi struct{}
+
+// GetReport 按分组对比当前A/B实验的转人工率、自助解决率和满意度
+func (e *ExperimentApi) GetReport(c *gin.Context) {
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+	report, err := service.Service.UserServiceGroup.ExperimentService.Report(ctx)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, report)
+}
@@ -0,0 +1,27 @@
+package admin
+
+import (
+	"strconv"
+
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/service"
+	"github.com/gin-gonic/gin"
+)
+
+type GuardApi struct{}
+
+// ListDetections 查询最近的提示词注入检测记录, 供人工复核
+func (g *GuardApi) ListDetections(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if err != nil || limit <= 0 {
+		common.Fail(c, "limit 格式错误")
+		return
+	}
+
+	detections, err := service.Service.UserServiceGroup.GuardService.ListDetections(c, limit)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, detections)
+}
@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"strconv"
+
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/service"
+	"github.com/gin-gonic/gin"
+)
+
+type HistoryApi struct{}
+
+// ListHistory 查询知识条目的变更记录, 指定 item_id 时只查询该条目的历史
+func (a *HistoryApi) ListHistory(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		common.Fail(c, "limit 格式错误")
+		return
+	}
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+
+	history, err := service.Service.AdminServiceGroup.HistoryService.ListHistory(ctx, c.Query("item_id"), limit)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, history)
+}
+
+// RestoreVersion 将知识条目恢复为指定变更记录中的版本
+func (a *HistoryApi) RestoreVersion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.Fail(c, "ID 格式错误")
+		return
+	}
+	var req dto.RestoreKnowledgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+
+	if err := service.Service.AdminServiceGroup.HistoryService.RestoreVersion(ctx, uint(id), &req); err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, nil)
+}
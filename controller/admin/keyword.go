@@ -2,17 +2,50 @@ package admin
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
 	"gitee.com/taoJie_1/mall-agent/global"
 	"gitee.com/taoJie_1/mall-agent/model/common"
 	"gitee.com/taoJie_1/mall-agent/model/dto"
 	"gitee.com/taoJie_1/mall-agent/service"
+	adminService "gitee.com/taoJie_1/mall-agent/service/admin"
 	"github.com/gin-gonic/gin"
 )
 
 type KeywordApi struct{}
 
+// maxOperatorLength 请求头中操作人名称的最大长度, 变更记录的操作人字段还需容纳客户端IP
+const maxOperatorLength = 64
+
+// tenantContext 按查询参数 tenant 选择要管理的租户知识库, 未指定时为默认租户;
+// 变更记录的操作人始终包含客户端IP, 请求头 X-Operator(URL编码)未经认证, 只作为自报的名称记录在IP之前
+func tenantContext(c *gin.Context) (context.Context, bool) {
+	tenant, ok := global.Tenants.Get(c.Query("tenant"))
+	if !ok {
+		common.Fail(c, "租户不存在")
+		return nil, false
+	}
+	actor := c.ClientIP()
+	if operator, err := url.QueryUnescape(c.GetHeader("X-Operator")); err == nil {
+		if operator = strings.TrimSpace(operator); operator != "" {
+			if runes := []rune(operator); len(runes) > maxOperatorLength {
+				operator = string(runes[:maxOperatorLength])
+			}
+			actor = fmt.Sprintf("%s(自报) @%s", operator, actor)
+		}
+	}
+	ctx := global.WithTenant(c.Request.Context(), tenant)
+	return adminService.WithActor(ctx, actor), true
+}
+
 func (k *KeywordApi) ListItems(c *gin.Context) {
-	items, err := service.Service.AdminServiceGroup.KeywordService.ListItems(c)
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+	items, err := service.Service.AdminServiceGroup.KeywordService.ListItems(ctx)
 	if err != nil {
 		common.Fail(c, err.Error())
 		return
@@ -26,9 +59,13 @@ func (k *KeywordApi) UpsertItem(c *gin.Context) {
 		common.Fail(c, err.Error())
 		return
 	}
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
 
-	if err := service.Service.AdminServiceGroup.KeywordService.UpsertItem(c, &req); err != nil {
-		common.Fail(c, err.Error())
+	if err := service.Service.AdminServiceGroup.KeywordService.UpsertItem(ctx, &req); err != nil {
+		failWithConflicts(c, err)
 		return
 	}
 	common.Success(c, nil)
@@ -40,8 +77,12 @@ func (k *KeywordApi) DeleteItem(c *gin.Context) {
 		common.Fail(c, "ID 不能为空")
 		return
 	}
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
 
-	if err := service.Service.AdminServiceGroup.KeywordService.DeleteItem(c, itemID); err != nil {
+	if err := service.Service.AdminServiceGroup.KeywordService.DeleteItem(ctx, itemID); err != nil {
 		common.Fail(c, err.Error())
 		return
 	}
@@ -65,7 +106,7 @@ func (k *KeywordApi) GenerateQuestions(c *gin.Context) {
 
 func (k *KeywordApi) ForceSync(c *gin.Context) {
 	go func() {
-		// 异步触发同步任务，避免阻塞请求。
+		// 异步触发同步任务，避免阻塞请求; 同步覆盖所有租户
 		if err := service.Service.AdminServiceGroup.KeywordService.ForceSync(context.Background()); err != nil {
 			global.Log.Errorf("手动触发同步任务失败: %v", err)
 		}
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/service"
+	"github.com/gin-gonic/gin"
+)
+
+type PromptApi struct{}
+
+// ListPrompts 列出所有提示词及其启用的版本
+func (p *PromptApi) ListPrompts(c *gin.Context) {
+	items, err := service.Service.AdminServiceGroup.PromptService.ListPrompts(c)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, items)
+}
+
+// ListVersions 列出提示词的版本记录
+func (p *PromptApi) ListVersions(c *gin.Context) {
+	versions, err := service.Service.AdminServiceGroup.PromptService.ListVersions(c, c.Param("name"))
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, versions)
+}
+
+// CreateVersion 保存提示词的新版本
+func (p *PromptApi) CreateVersion(c *gin.Context) {
+	var req dto.CreatePromptVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+
+	version, err := service.Service.AdminServiceGroup.PromptService.CreateVersion(c, c.Param("name"), &req)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, version)
+}
+
+// Activate 启用或回滚到指定版本
+func (p *PromptApi) Activate(c *gin.Context) {
+	var req dto.ActivatePromptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+
+	if err := service.Service.AdminServiceGroup.PromptService.Activate(c, c.Param("name"), *req.Version); err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, nil)
+}
+
+// Preview 预览提示词替换变量后的内容和校验结果
+func (p *PromptApi) Preview(c *gin.Context) {
+	var req dto.PreviewPromptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+
+	resp, err := service.Service.AdminServiceGroup.PromptService.Preview(c, c.Param("name"), &req)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, resp)
+}
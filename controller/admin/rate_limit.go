@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"strconv"
+
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/service"
+	"github.com/gin-gonic/gin"
+)
+
+type RateLimitApi struct{}
+
+// GetStatus 查询限流配置和剩余令牌, 可通过 contact_id / conversation_id 查询指定联系人或会话, 多租户时需指定 tenant
+func (r *RateLimitApi) GetStatus(c *gin.Context) {
+	contactID, err := strconv.ParseUint(c.DefaultQuery("contact_id", "0"), 10, 64)
+	if err != nil {
+		common.Fail(c, "contact_id 格式错误")
+		return
+	}
+	conversationID, err := strconv.ParseUint(c.DefaultQuery("conversation_id", "0"), 10, 64)
+	if err != nil {
+		common.Fail(c, "conversation_id 格式错误")
+		return
+	}
+
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+
+	status, err := service.Service.UserServiceGroup.RateLimitService.Status(ctx, uint(contactID), uint(conversationID))
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, status)
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/service"
+	"github.com/gin-gonic/gin"
+)
+
+type SuggestionApi struct{}
+
+// ListSuggestions 查询从人工客服回复中挖掘出的知识条目建议, 可按 status 过滤
+func (a *SuggestionApi) ListSuggestions(c *gin.Context) {
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+	suggestions, err := service.Service.AdminServiceGroup.SuggestionService.ListSuggestions(ctx, c.Query("status"))
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, suggestions)
+}
+
+// ApproveSuggestion 审核通过建议, 请求体可以修改建议的问题和答案
+func (a *SuggestionApi) ApproveSuggestion(c *gin.Context) {
+	var req dto.ApproveSuggestionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			common.Fail(c, err.Error())
+			return
+		}
+	}
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+
+	if err := service.Service.AdminServiceGroup.SuggestionService.ApproveSuggestion(ctx, c.Param("id"), &req); err != nil {
+		failWithConflicts(c, err)
+		return
+	}
+	common.Success(c, nil)
+}
+
+func (a *SuggestionApi) RejectSuggestion(c *gin.Context) {
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+	if err := service.Service.AdminServiceGroup.SuggestionService.RejectSuggestion(ctx, c.Param("id")); err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, nil)
+}
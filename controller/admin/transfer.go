@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportFileSize 导入文件的大小上限
+const maxImportFileSize = 10 << 20
+
+var transferContentTypes = map[enum.KnowledgeFileFormat]string{
+	enum.KnowledgeFileCsv:   "text/csv; charset=utf-8",
+	enum.KnowledgeFileXlsx:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	enum.KnowledgeFileJsonl: "application/x-ndjson; charset=utf-8",
+}
+
+type TransferApi struct{}
+
+// Export 导出知识库, 查询参数 format 为 csv(默认)、xlsx 或 jsonl
+func (a *TransferApi) Export(c *gin.Context) {
+	format := enum.KnowledgeFileFormat(c.DefaultQuery("format", string(enum.KnowledgeFileCsv)))
+	contentType, ok := transferContentTypes[format]
+	if !ok {
+		common.Fail(c, "不支持的文件格式")
+		return
+	}
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+
+	data, err := service.Service.AdminServiceGroup.TransferService.Export(ctx, format)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="knowledge.`+string(format)+`"`)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// Import 导入知识库; 查询参数 dry_run=true 时只返回校验结果和变更预览, 否则在后台分批导入并返回导入任务
+func (a *TransferApi) Import(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		common.Fail(c, "获取文件失败: "+err.Error())
+		return
+	}
+	if file.Size > maxImportFileSize {
+		common.Fail(c, "导入文件不能超过10MB")
+		return
+	}
+	// 未指定格式时按文件扩展名判断
+	format := enum.KnowledgeFileFormat(c.Query("format"))
+	if format == "" {
+		format = enum.KnowledgeFileFormat(strings.ToLower(strings.TrimPrefix(filepath.Ext(file.Filename), ".")))
+	}
+	if _, ok := transferContentTypes[format]; !ok {
+		common.Fail(c, "不支持的文件格式, 仅支持 csv、xlsx 和 jsonl")
+		return
+	}
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		common.Fail(c, "读取文件失败: "+err.Error())
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		common.Fail(c, "读取文件失败: "+err.Error())
+		return
+	}
+
+	transfer := service.Service.AdminServiceGroup.TransferService
+	if c.Query("dry_run") == "true" {
+		preview, err := transfer.Preview(ctx, format, data)
+		if err != nil {
+			common.Fail(c, err.Error())
+			return
+		}
+		common.Success(c, preview)
+		return
+	}
+	job, err := transfer.Import(ctx, format, data)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, job)
+}
+
+// ImportProgress 查询最近一次导入任务的进度
+func (a *TransferApi) ImportProgress(c *gin.Context) {
+	ctx, ok := tenantContext(c)
+	if !ok {
+		return
+	}
+	job, err := service.Service.AdminServiceGroup.TransferService.ImportProgress(ctx)
+	if err != nil {
+		common.Fail(c, err.Error())
+		return
+	}
+	common.Success(c, job)
+}
@@ -7,8 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
-	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -25,17 +25,20 @@ import (
 	"gitee.com/taoJie_1/mall-agent/model/common"
 	"gitee.com/taoJie_1/mall-agent/model/enum"
 	"gitee.com/taoJie_1/mall-agent/service"
+	"gitee.com/taoJie_1/mall-agent/service/user"
 )
 
 type ChatApi struct{}
 
+// errToolConfirmationPending 表示工具调用已暂存并发出确认消息，本轮无需再回复用户
+var errToolConfirmationPending = errors.New("工具调用等待用户确认")
+
 func (c *ChatApi) HandleWebhook(ctx *gin.Context) {
 	bodyBytes, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
 		common.Fail(ctx, "参数无效")
 		return
 	}
-	bb := bodyBytes
 
 	ctx.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
@@ -47,7 +50,7 @@ func (c *ChatApi) HandleWebhook(ctx *gin.Context) {
 
 	switch chatwoot.ChatwootEvent(eventFinder.Event) {
 	case chatwoot.EventWebwidgetTriggered:
-		global.Log.Debugln("收到WebWidget触发事件:", string(bb))
+		global.Log.Debugln("收到WebWidget触发事件")
 
 		var req common.WebwidgetTriggeredRequest
 		if err := json.Unmarshal(bodyBytes, &req); err != nil {
@@ -55,12 +58,12 @@ func (c *ChatApi) HandleWebhook(ctx *gin.Context) {
 			return
 		}
 		if req.Contact.ID != 0 {
-			go c.handleWebWidgetTriggered(req.Contact.ID, req.SourceID, req.Contact.CustomAttributes)
+			tenantCtx := global.WithTenant(context.Background(), global.Tenants.Resolve(req.Account.ID, req.Inbox.ID))
+			go c.handleWebWidgetTriggered(tenantCtx, req.Contact.ID, req.SourceID, req.Contact.CustomAttributes)
 		}
 		common.Success(ctx, nil)
 
 	case chatwoot.EventMessageCreated:
-		global.Log.Debugln(string(bb))
 		var req common.ChatRequest
 		if err := json.Unmarshal(bodyBytes, &req); err != nil || req.Conversation.ID == 0 {
 			common.Fail(ctx, "参数无效")
@@ -68,13 +71,39 @@ func (c *ChatApi) HandleWebhook(ctx *gin.Context) {
 		}
 		c.handleMessageCreated(ctx, req)
 
+	case chatwoot.EventMessageUpdated:
+		// Chatwoot 满意度调查回传的 submitted_values 是对象而非数组, 需先按消息类型区分
+		var typeFinder struct {
+			ContentType chatwoot.ContentType `json:"content_type"`
+		}
+		if err := json.Unmarshal(bodyBytes, &typeFinder); err == nil && typeFinder.ContentType == chatwoot.ContentTypeInputCsat {
+			var req common.CsatSurveyRequest
+			if err := json.Unmarshal(bodyBytes, &req); err != nil || req.Conversation.ID == 0 {
+				common.Fail(ctx, "参数无效")
+				return
+			}
+			c.handleCsatSurvey(ctx, req)
+			return
+		}
+
+		var req common.MessageUpdatedRequest
+		if err := json.Unmarshal(bodyBytes, &req); err != nil || req.Conversation.ID == 0 {
+			common.Fail(ctx, "参数无效")
+			return
+		}
+		c.handleMessageUpdated(ctx, req)
+
 	case chatwoot.EventConversationResolved:
 		var req common.ConversationResolvedRequest
 		if err := json.Unmarshal(bodyBytes, &req); err != nil || req.ID == 0 {
 			common.Fail(ctx, "参数无效")
 			return
 		}
-		go c.handleConversationResolved(req.ID)
+		tenantCtx := global.WithTenant(context.Background(), global.Tenants.Resolve(req.AccountID, req.InboxID))
+		go c.handleConversationResolved(tenantCtx, req.ID)
+		go service.Service.UserServiceGroup.ActionService.CancelScheduledReopen(tenantCtx, req.ID)
+		go service.Service.UserServiceGroup.CsatService.AskRating(tenantCtx, req.ID)
+		go service.Service.UserServiceGroup.KnowledgeMiningService.EnqueueResolved(tenantCtx, req.ID)
 		common.Success(ctx, nil)
 
 	default:
@@ -83,8 +112,9 @@ func (c *ChatApi) HandleWebhook(ctx *gin.Context) {
 }
 
 // handleWebWidgetTriggered 复活旧会话或创建新会话，并发送卡片
-func (c *ChatApi) handleWebWidgetTriggered(contactID uint, sourceID string, attrs common.CustomAttributes) {
-	conversations, err := global.ChatwootService.GetContactConversations(contactID)
+func (c *ChatApi) handleWebWidgetTriggered(ctx context.Context, contactID uint, sourceID string, attrs common.CustomAttributes) {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	conversations, err := chatwootClient.GetContactConversations(contactID)
 	if err != nil {
 		global.Log.Errorf("获取联系人 %d 的会话列表失败: %v", contactID, err)
 		return
@@ -99,7 +129,7 @@ func (c *ChatApi) handleWebWidgetTriggered(contactID uint, sourceID string, attr
 			return
 		}
 		global.Log.Debugf("联系人 %d 为新用户，正在主动创建会话...", contactID)
-		newID, err := global.ChatwootService.CreateConversation(sourceID)
+		newID, err := chatwootClient.CreateConversation(sourceID)
 		if err != nil {
 			global.Log.Errorf("为联系人 %d 创建新会话失败: %v", contactID, err)
 			return
@@ -115,7 +145,7 @@ func (c *ChatApi) handleWebWidgetTriggered(contactID uint, sourceID string, attr
 		// 如果会话已解决，强制复活（改为 Open 状态）
 		if lastConv.Status == chatwoot.ConversationStatusResolved {
 			global.Log.Debugf("检测到用户 %d 重返，正在复活旧会话 %d", contactID, targetConversationID)
-			if err := global.ChatwootService.SetConversationStatus(targetConversationID, chatwoot.ConversationStatusOpen); err != nil {
+			if err := chatwootClient.SetConversationStatus(targetConversationID, chatwoot.ConversationStatusOpen); err != nil {
 				global.Log.Errorf("复活会话 %d 失败: %v", targetConversationID, err)
 				return
 			}
@@ -123,20 +153,24 @@ func (c *ChatApi) handleWebWidgetTriggered(contactID uint, sourceID string, attr
 	}
 
 	// 发送卡片 (利用之前加了锁的 ActionService)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	service.Service.UserServiceGroup.ActionService.CheckAndSendProductCard(ctx, targetConversationID, attrs)
 }
 
 // handleMessageCreated 收到消息处理
 func (c *ChatApi) handleMessageCreated(ctx *gin.Context, req common.ChatRequest) {
+	tenantCtx := c.tenantContext(ctx.Request.Context(), req)
+
 	// 处理"人工客服"消息: 将其计入Redis历史,并设置人工宽限期
 	if req.MessageType == chatwoot.MessageTypeOutgoing && req.Sender.Type == chatwoot.SenderUser {
-		service.Service.UserServiceGroup.ActionService.ActivateHumanModeGracePeriod(ctx.Request.Context(), req.Conversation.ID)
+		service.Service.UserServiceGroup.ActionService.ActivateHumanModeGracePeriod(tenantCtx, req.Conversation.ID)
+		service.Service.UserServiceGroup.CsatService.MarkHumanHandled(tenantCtx, req.Conversation.ID)
+		service.Service.UserServiceGroup.KnowledgeMiningService.MarkCandidate(tenantCtx, req.Conversation.ID)
 
 		common.Success(ctx, nil)
 		if req.Content != "" {
-			go service.Service.UserServiceGroup.HistoryService.Append(context.Background(), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: req.Content})
+			go service.Service.UserServiceGroup.HistoryService.Append(context.WithoutCancel(tenantCtx), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: req.Content})
 		}
 		return
 	}
@@ -149,13 +183,13 @@ func (c *ChatApi) handleMessageCreated(ctx *gin.Context, req common.ChatRequest)
 
 	go func() {
 		//理论上发送卡片的操作由webwidget_triggered事件处理，但为了避免不可预见的遗漏，这里再做一次
-		bgCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		bgCtx, cancel := context.WithTimeout(context.WithoutCancel(tenantCtx), 10*time.Second)
 		defer cancel()
 		service.Service.UserServiceGroup.ActionService.CheckAndSendProductCard(bgCtx, req.Conversation.ID, req.Conversation.Meta.Sender.CustomAttributes)
 	}()
 
 	// 收到用户消息时，如果当前处于人工模式宽限期内，刷新宽限期时间
-	service.Service.UserServiceGroup.ActionService.RefreshHumanModeGracePeriod(ctx.Request.Context(), req.Conversation.ID)
+	service.Service.UserServiceGroup.ActionService.RefreshHumanModeGracePeriod(tenantCtx, req.Conversation.ID)
 
 	// 调用验证器验证请求
 	if err := service.Service.UserServiceGroup.Validator.ValidatorChatRequest(&req); err != nil {
@@ -163,18 +197,14 @@ func (c *ChatApi) handleMessageCreated(ctx *gin.Context, req common.ChatRequest)
 		return
 	}
 
-	// 如果消息包含附件（图片、音视频等），则直接转人工
-	if len(req.Attachments) > 0 {
-		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman3, string(enum.ReplyMsgUnsupportedAttachment))
-		common.Fail(ctx, string(enum.ReplyMsgUnsupportedAttachment))
-		return
-	}
+	// 新消息到来时重置路由上下文, 分诊完成前的转人工只按收件箱路由
+	service.Service.UserServiceGroup.RoutingService.SaveContext(tenantCtx, req.Conversation.ID, common.RoutingContext{InboxID: req.Conversation.InboxID, Question: req.Content})
 
 	// 提示词长度校验
 	if utf8.RuneCountInString(req.Content) > int(global.Config.Ai.MaxPromptLength) {
 		global.Log.Warnf("用户 %d 提问内容过长，已转人工", req.Conversation.ID)
 		// 触发转人工
-		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman3, string(enum.ReplyMsgPromptTooLong))
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(tenantCtx, req.Conversation.ID, enum.TransferToHuman3, string(enum.ReplyMsgPromptTooLong))
 		common.Fail(ctx, string(enum.ReplyMsgPromptTooLong))
 		return
 	}
@@ -182,206 +212,524 @@ func (c *ChatApi) handleMessageCreated(ctx *gin.Context, req common.ChatRequest)
 	// 回复消息已接收
 	common.Success(ctx, nil)
 
+	c.dispatchBatched(req)
+}
+
+// handleMessageUpdated 处理用户在选项/表单消息中提交的数据，将其作为结构化输入送入对话流程
+func (c *ChatApi) handleMessageUpdated(ctx *gin.Context, req common.MessageUpdatedRequest) {
+	submitted := req.ContentAttributes.SubmittedValues
+	if len(submitted) == 0 || (req.ContentType != chatwoot.ContentTypeInputSelect && req.ContentType != chatwoot.ContentTypeForm) {
+		common.Success(ctx, nil)
+		return
+	}
+	if req.Conversation.Meta.Sender.Type != chatwoot.SenderContact {
+		common.Success(ctx, nil)
+		return
+	}
+
+	tenantCtx := c.tenantContext(ctx.Request.Context(), req.ChatRequest)
+
+	// 同一条消息在提交后仍可能因其他属性变更再次触发 message_updated，只处理一次
+	if global.RedisClient != nil {
+		key := global.ConversationKey(tenantCtx, redis.KeyPrefixSubmittedMessage, req.ID)
+		ok, err := global.RedisClient.SetNX(tenantCtx, key, "1", 24*time.Hour).Result()
+		if err != nil {
+			global.Log.Warnf("[handleMessageUpdated] 设置会话 %d 消息 %d 提交标记失败: %v", req.Conversation.ID, req.ID, err)
+		} else if !ok {
+			common.Success(ctx, nil)
+			return
+		}
+	}
+
+	// 满意度评分直接记录, 无需AI回复
+	if req.ContentType == chatwoot.ContentTypeInputSelect && service.Service.UserServiceGroup.CsatService.HandleSubmission(tenantCtx, req.Conversation.ID, req.ID, submitted) {
+		common.Success(ctx, nil)
+		return
+	}
+
+	// 非工作时间收集的联系方式直接记录, 无需AI回复
+	if req.ContentType == chatwoot.ContentTypeForm && service.Service.UserServiceGroup.ActionService.SaveContactDetails(tenantCtx, req.Conversation.ID, submitted) {
+		common.Success(ctx, nil)
+		return
+	}
+
+	chatReq := req.ChatRequest
+	chatReq.MessageType = chatwoot.MessageTypeIncoming
+	chatReq.Content = c.formatSubmittedValues(req.ContentType, submitted)
+	chatReq.SubmittedValues = submitted
+	chatReq.Attachments = nil
+	global.Log.Debugf("[handleMessageUpdated] 会话 %d 收到用户提交: %s", chatReq.Conversation.ID, chatReq.Content)
+
+	service.Service.UserServiceGroup.ActionService.RefreshHumanModeGracePeriod(tenantCtx, chatReq.Conversation.ID)
+
+	common.Success(ctx, nil)
+
+	c.dispatchAsync(chatReq)
+}
+
+// handleCsatSurvey 记录用户在 Chatwoot 满意度调查中的评分和反馈
+func (c *ChatApi) handleCsatSurvey(ctx *gin.Context, req common.CsatSurveyRequest) {
+	common.Success(ctx, nil)
+
+	survey := req.ContentAttributes.SubmittedValues.CsatSurveyResponse
+	if survey.Rating == 0 {
+		return
+	}
+	tenantCtx := c.tenantContext(ctx.Request.Context(), req.ChatRequest)
+	go service.Service.UserServiceGroup.CsatService.RecordSurvey(context.WithoutCancel(tenantCtx), req.Conversation.ID, req.ID, survey.Rating, survey.FeedbackMessage)
+}
+
+// formatSubmittedValues 将用户提交的数据转换为LLM和历史记录可读的文本
+func (c *ChatApi) formatSubmittedValues(contentType chatwoot.ContentType, values []common.SubmittedValue) string {
+	if contentType == chatwoot.ContentTypeInputSelect {
+		v := values[0]
+		if v.Title == "" {
+			return v.Value
+		}
+		if v.Value == "" || strings.Contains(v.Title, v.Value) {
+			return v.Title
+		}
+		return fmt.Sprintf("%s（%s）", v.Title, v.Value)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("我已提交以下信息：")
+	for _, v := range values {
+		fmt.Fprintf(&builder, "\n%s: %s", v.Name, v.Value)
+	}
+	return builder.String()
+}
+
+// chatJob 写入任务队列的消息, SubmittedValues 不参与 ChatRequest 的序列化, 需单独携带
+// BatchSeq 不为0时表示聚合窗口结束后取出聚合消息的延迟任务, Request 为窗口内的最后一条消息
+type chatJob struct {
+	Request         common.ChatRequest      `json:"request"`
+	SubmittedValues []common.SubmittedValue `json:"submitted_values,omitempty"`
+	Seq             int64                   `json:"seq,omitempty"`
+	BatchSeq        int64                   `json:"batch_seq,omitempty"`
+}
+
+const (
+	// messageSeqTTL 会话消息序号的保留时间, 需覆盖任务排队和重试的最长时间
+	messageSeqTTL = 24 * time.Hour
+	// jobStepTTL 任务步骤标记和结果的保留时间, 需覆盖任务的全部重试
+	jobStepTTL = time.Hour
+)
+
+// dispatchAsync 为消息分配序号后交给任务队列处理; 队列未启用或写入失败时在本实例后台处理
+func (c *ChatApi) dispatchAsync(req common.ChatRequest) {
+	ctx, cancel := context.WithTimeout(c.tenantContext(context.Background(), req), 3*time.Second)
+	defer cancel()
+	if global.RedisClient != nil {
+		seq, err := global.RedisClient.NextMessageSeq(ctx, global.TenantFrom(ctx).ConversationScope(req.Conversation.ID), messageSeqTTL)
+		if err != nil {
+			global.Log.Warnf("[dispatchAsync] 会话 %d 分配消息序号失败，将不检查任务是否被取代: %v", req.Conversation.ID, err)
+		}
+		req.Seq = seq
+	}
+
+	queue := service.Service.UserServiceGroup.JobQueue
+	if queue.Enabled() {
+		err := queue.Enqueue(ctx, chatJob{Request: req, SubmittedValues: req.SubmittedValues, Seq: req.Seq})
+		if err == nil {
+			return
+		}
+		global.Log.Warnf("[dispatchAsync] 会话 %d 写入任务队列失败，改为本实例处理: %v", req.Conversation.ID, err)
+	}
+
 	// 避免`req`在HTTP返回后可能被Gin回收。
 	reqCopy := req
 
 	go func() {
-		timeout := time.Duration(global.Config.Ai.AsyncJobTimeout) * time.Second
-		asyncCtx, cancel := context.WithTimeout(context.Background(), timeout)
-		defer cancel()
+		_ = c.runTask(context.Background(), reqCopy)
+	}()
+}
+
+// HandleJob 任务队列的处理函数, 临时故障交由队列重试, 重试耗尽后由 HandleDeadJob 转人工
+func (c *ChatApi) HandleJob(ctx context.Context, job user.Job) error {
+	var payload chatJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		global.Log.Errorf("[HandleJob] 任务 %s 解析失败: %v", job.ID, err)
+		return nil
+	}
+	req := payload.Request
+	if payload.BatchSeq != 0 {
+		return c.collectBatch(ctx, req, payload.BatchSeq)
+	}
+	req.SubmittedValues = payload.SubmittedValues
+	req.Seq = payload.Seq
+	req.Retryable = true
+	return c.runTask(ctx, req)
+}
 
-		// 注册任务，以便在会话解决时可以取消
-		c.storeTask(reqCopy.Conversation.ID, cancel)
-		defer c.removeTask(reqCopy.Conversation.ID)
+// HandleDeadJob 任务重试耗尽后转人工, 保证用户的消息有人响应
+func (c *ChatApi) HandleDeadJob(job user.Job, cause error) {
+	var payload chatJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil || payload.Request.Conversation.ID == 0 {
+		return
+	}
+	global.Log.Errorf("[HandleDeadJob] 会话 %d 的消息多次处理失败，转人工: %v", payload.Request.Conversation.ID, cause)
+	tenantCtx := c.tenantContext(context.Background(), payload.Request)
+	_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(tenantCtx, payload.Request.Conversation.ID, enum.TransferToHuman2, string(enum.ReplyMsgLlmError))
+}
 
-		c.processMessageAsync(asyncCtx, reqCopy)
-	}()
+// tenantContext 按消息所属的账户和收件箱识别租户并放入上下文
+func (c *ChatApi) tenantContext(ctx context.Context, req common.ChatRequest) context.Context {
+	return global.WithTenant(ctx, global.Tenants.Resolve(req.Account.ID, req.Conversation.InboxID))
 }
 
-// handleConversationResolved 处理会话解决事件，取消正在进行的AI任务
-func (c *ChatApi) handleConversationResolved(conversationID uint) {
-	global.ActiveLLMTasks.Lock()
-	defer global.ActiveLLMTasks.Unlock()
+// runTask 执行AI处理流程，并注册任务以便在会话解决或有更新的消息时可以取消
+func (c *ChatApi) runTask(ctx context.Context, req common.ChatRequest) error {
+	// 按租户和A/B实验分组准备上下文, 并关联会话历史的个人信息占位符映射
+	ctx = service.Service.UserServiceGroup.ExperimentService.WithVariant(c.tenantContext(ctx, req), req.Conversation.ID)
+	ctx = service.Service.UserServiceGroup.HistoryService.WithVault(ctx, req.Conversation.ID)
 
-	if cancel, exists := global.ActiveLLMTasks.Data[conversationID]; exists {
-		cancel() // 调用取消函数
-		delete(global.ActiveLLMTasks.Data, conversationID)
-		global.Log.Debugf("会话%d已解决，已终止正在进行的AI任务。", conversationID)
+	// 排队或重试期间会话已有更新的消息, 由更新的消息负责回复; 否则注册任务会取消更新消息的处理
+	if c.superseded(ctx, req) {
+		global.Log.Debugf("[runTask] 会话 %d 已有更新的消息，跳过序号为 %d 的消息", req.Conversation.ID, req.Seq)
+		return nil
 	}
+
+	timeout := time.Duration(global.Config.Ai.AsyncJobTimeout) * time.Second
+	asyncCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	registry := service.Service.UserServiceGroup.TaskRegistry
+	token := registry.Register(asyncCtx, req.Conversation.ID, cancel)
+	defer registry.Unregister(ctx, req.Conversation.ID, token)
+
+	return c.processMessageAsync(asyncCtx, req)
 }
 
-func (c *ChatApi) processMessageAsync(ctx context.Context, req common.ChatRequest) {
+// superseded 判断消息是否已被同一会话中更新的消息取代; 没有序号或查询失败时按未取代处理
+func (c *ChatApi) superseded(ctx context.Context, req common.ChatRequest) bool {
+	if req.Seq == 0 || global.RedisClient == nil {
+		return false
+	}
+	latest, err := global.RedisClient.LatestMessageSeq(ctx, global.TenantFrom(ctx).ConversationScope(req.Conversation.ID))
+	if err != nil {
+		global.Log.Warnf("[runTask] 会话 %d 获取最新消息序号失败: %v", req.Conversation.ID, err)
+		return false
+	}
+	return latest > req.Seq
+}
+
+// jobStepKey 同一条消息的任务在重试间共用的步骤Key
+func (c *ChatApi) jobStepKey(ctx context.Context, req common.ChatRequest, step string) string {
+	return fmt.Sprintf("%s:%d:%s", global.ConversationKey(ctx, redis.KeyPrefixJobStep, req.Conversation.ID), req.Seq, step)
+}
+
+// firstAttempt 有副作用的步骤在任务重试时只执行一次, 返回false表示之前的尝试已执行过; 非队列任务或Redis出错时总是执行
+func (c *ChatApi) firstAttempt(ctx context.Context, req common.ChatRequest, step string) bool {
+	if !req.Retryable || req.Seq == 0 || global.RedisClient == nil {
+		return true
+	}
+	ok, err := global.RedisClient.SetNX(ctx, c.jobStepKey(ctx, req, step), 1, jobStepTTL).Result()
+	if err != nil {
+		global.Log.Warnf("[firstAttempt] 会话 %d 标记步骤 %s 失败: %v", req.Conversation.ID, step, err)
+		return true
+	}
+	return ok
+}
+
+// loadStepResult 读取之前的尝试保存的步骤结果, 不存在时返回false
+func (c *ChatApi) loadStepResult(ctx context.Context, req common.ChatRequest, step string, result any) bool {
+	if !req.Retryable || req.Seq == 0 || global.RedisClient == nil {
+		return false
+	}
+	val, err := global.RedisClient.Get(ctx, c.jobStepKey(ctx, req, step)).Result()
+	if err != nil {
+		if err != redis.ErrNil {
+			global.Log.Warnf("[loadStepResult] 会话 %d 读取步骤 %s 的结果失败: %v", req.Conversation.ID, step, err)
+		}
+		return false
+	}
+	return json.Unmarshal([]byte(val), result) == nil
+}
+
+// saveStepResult 保存步骤结果, 任务重试时直接复用, 不再重复执行
+func (c *ChatApi) saveStepResult(ctx context.Context, req common.ChatRequest, step string, result any) {
+	if !req.Retryable || req.Seq == 0 || global.RedisClient == nil {
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	if err := global.RedisClient.Set(ctx, c.jobStepKey(ctx, req, step), data, jobStepTTL).Err(); err != nil {
+		global.Log.Warnf("[saveStepResult] 会话 %d 保存步骤 %s 的结果失败: %v", req.Conversation.ID, step, err)
+	}
+}
+
+// dispatchBatched 在聚合窗口内合并同一会话的连续消息, 窗口结束时由最后一条消息触发一次AI处理
+// 启用任务队列时窗口由延迟任务计时, 实例在窗口内重启也不会丢失消息
+func (c *ChatApi) dispatchBatched(req common.ChatRequest) {
+	batchService := service.Service.UserServiceGroup.BatchService
+	window := batchService.Window()
+	if window <= 0 {
+		c.dispatchAsync(req)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.tenantContext(context.Background(), req), 3*time.Second)
+	defer cancel()
+	seq, err := batchService.Add(ctx, req)
+	if err != nil {
+		global.Log.Warnf("[dispatchBatched] 会话 %d 消息聚合失败，直接处理: %v", req.Conversation.ID, err)
+		c.dispatchAsync(req)
+		return
+	}
+
+	queue := service.Service.UserServiceGroup.JobQueue
+	if queue.Enabled() {
+		err := queue.EnqueueDelayed(ctx, chatJob{Request: req, BatchSeq: seq}, window)
+		if err == nil {
+			return
+		}
+		global.Log.Warnf("[dispatchBatched] 会话 %d 写入延迟任务失败，改为本实例计时: %v", req.Conversation.ID, err)
+	}
+
+	reqCopy := req
+	go func() {
+		time.Sleep(window)
+		if err := c.collectBatch(context.Background(), reqCopy, seq); err != nil {
+			global.Log.Warnf("[dispatchBatched] 会话 %d 取出聚合消息失败，直接处理: %v", reqCopy.Conversation.ID, err)
+			c.dispatchAsync(reqCopy)
+		}
+	}()
+}
+
+// collectBatch 聚合窗口结束后取出合并的消息并派发处理; 窗口内有更新的消息时由其负责处理
+func (c *ChatApi) collectBatch(ctx context.Context, req common.ChatRequest, seq int64) error {
+	collectCtx, cancel := context.WithTimeout(c.tenantContext(ctx, req), 3*time.Second)
+	defer cancel()
+	merged, err := service.Service.UserServiceGroup.BatchService.Collect(collectCtx, req.Conversation.ID, seq)
+	if err != nil {
+		return err
+	}
+	if merged != nil {
+		c.dispatchAsync(*merged)
+	}
+	return nil
+}
+
+// handleConversationResolved 处理会话解决事件，取消正在进行的AI任务(可能在其他实例上)，并丢弃待确认的工具调用
+func (c *ChatApi) handleConversationResolved(ctx context.Context, conversationID uint) {
+	service.Service.UserServiceGroup.TaskRegistry.Cancel(ctx, conversationID)
+	service.Service.UserServiceGroup.ToolService.DiscardPending(ctx, conversationID)
+}
+
+// processMessageAsync 执行AI处理流程; 仅当请求可重试且遇到临时故障时返回错误, 其余情况均在流程内回复或转人工
+func (c *ChatApi) processMessageAsync(ctx context.Context, req common.ChatRequest) (err error) {
 	defer func() {
 		if p := recover(); p != nil {
 			global.Log.Errorf("[processMessageAsync] panic: %v", p)
-			_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman2, string(enum.ReplyMsgLlmError))
+			err = c.transferOnFailure(ctx, req, fmt.Errorf("panic: %v", p))
 		}
 	}()
 
-	var isGracePeriodOverride bool // 标记是否处于宽限期处理模式
+	// 人工客服接管期间, 启用辅助模式的会话只为客服起草回复, 不走后续任何会回复用户的流程
+	if c.shouldAssist(ctx, req) {
+		c.runAssist(ctx, req)
+		return nil
+	}
+
+	// 人工客服近期活跃时AI不介入, 之后的限流计数、语音转写和确认暂存的工具调用都不应发生
+	isGracePeriodOverride, proceed := c.checkHumanMode(ctx, req)
+	if !proceed {
+		return nil
+	}
+
+	// 只统计由AI处理的轮次, 人工接管中的消息不计入实验
+	if c.firstAttempt(ctx, req, "turn") {
+		service.Service.UserServiceGroup.ExperimentService.RecordTurn(ctx, req.Conversation.ID)
+	}
+
+	// 按联系人和会话限流, 避免单个用户刷屏耗尽模型额度; 任务重试不再重复计数
+	if c.firstAttempt(ctx, req, "rate_limit") {
+		if err := service.Service.UserServiceGroup.RateLimitService.AllowMessage(ctx, req.Conversation.Meta.Sender.ID, req.Conversation.ID); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return c.handleRateLimited(ctx, req, err)
+		}
+	}
+
+	// 语音先转写为文字, 之后与普通文本消息走相同的处理流程; 无法转写时转人工
+	if slices.ContainsFunc(req.Attachments, func(a common.Attachment) bool { return a.FileType == "audio" }) {
+		transcript, others, err := c.transcribe(ctx, req)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			global.Log.Warnf("[processMessageAsync] 会话 %d 语音转写失败，转人工: %v", req.Conversation.ID, err)
+			_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman3, string(enum.ReplyMsgUnsupportedAttachment))
+			return nil
+		}
+		req.Content = strings.TrimSpace(req.Content + "\n" + transcript)
+		req.Attachments = others
+		req.IsTranscription = true
+		service.Service.UserServiceGroup.RoutingService.SaveContext(ctx, req.Conversation.ID, common.RoutingContext{InboxID: req.Conversation.InboxID, Question: req.Content})
+	}
+
+	// 语音转写和聚合后的内容未经过接收消息时的长度校验, 需再次校验
+	if utf8.RuneCountInString(req.Content) > int(global.Config.Ai.MaxPromptLength) {
+		global.Log.Warnf("会话 %d 转写或合并后的提问内容过长，已转人工", req.Conversation.ID)
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman3, string(enum.ReplyMsgPromptTooLong))
+		return nil
+	}
+
+	// 0. 上一轮有等待确认的工具调用，本条消息视为对它的答复
+	if c.handlePendingToolCall(ctx, &req) {
+		return nil
+	}
 
 	// 1. 快速路径优先：同步执行关键词匹配
-	cannedAnswer, isAction, err := service.Service.UserServiceGroup.ActionService.MatchCannedResponse(&req)
+	cannedAnswer, isAction, err := service.Service.UserServiceGroup.ActionService.MatchCannedResponse(ctx, &req)
 	if err != nil {
 		global.Log.Errorf("[processMessageAsync] 匹配关键字失败: %v", err)
-		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman2, string(enum.ReplyMsgLlmError))
-		return
+		return c.transferOnFailure(ctx, req, err)
 	}
 
 	// 转人工
 	if isAction {
-		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman1, string(enum.ReplyMsgTransferSuccess))
-		return
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman1, string(enum.ReplyMsgTransferSuccess))
+		return nil
 	}
 
 	// 匹配到快捷回复
 	if cannedAnswer != "" {
-		service.Service.UserServiceGroup.ActionService.SendMessage(req.Conversation.ID, cannedAnswer)
-		go service.Service.UserServiceGroup.HistoryService.Append(context.Background(), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: cannedAnswer})
-		return
+		service.Service.UserServiceGroup.ActionService.SendMessage(ctx, req.Conversation.ID, cannedAnswer)
+		service.Service.UserServiceGroup.CsatService.RecordAnswer(ctx, req.Conversation.ID, common.AnswerRecord{Question: req.Content, Answer: cannedAnswer, Source: enum.AnswerSourceCanned})
+		go service.Service.UserServiceGroup.HistoryService.Append(context.WithoutCancel(ctx), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: cannedAnswer})
+		return nil
 	}
 
-	// 如果会话状态为 "open"，则检查是否需要由AI接管
-	if req.Conversation.Status == chatwoot.ConversationStatusOpen {
-		// 检查1：短时的“转人工宽限期”，用于AI在自动转人工后立即纠正
-		transferGracePeriodKey := fmt.Sprintf("%s%d", redis.KeyPrefixTransferGracePeriod, req.Conversation.ID)
-		err := global.RedisClient.Get(ctx, transferGracePeriodKey).Err()
-
-		if err == nil { // 标志存在，AI可以覆盖转人工决定
-			global.Log.Debugf("会话 %d 处于转人工宽限期，AI将继续处理新消息", req.Conversation.ID)
-			isGracePeriodOverride = true
-		} else if err != redis.ErrNil { // Redis查询出错
-			global.Log.Errorf("检查会话 %d 的转人工宽限期标志失败: %v", req.Conversation.ID, err)
-			return // 为安全起见，交由人工处理
-		} else {
-			// 标志不存在，继续检查长时的“人工模式宽限期”
-			humanModeKey := fmt.Sprintf("%s%d", redis.KeyPrefixHumanModeActive, req.Conversation.ID)
-			err := global.RedisClient.Get(ctx, humanModeKey).Err()
-
-			if err == nil { // 标志存在，说明人工客服近期活跃
-				global.Log.Debugf("会话 %d 处于人工模式宽限期，AI不介入。", req.Conversation.ID)
-				return // 交由人工处理
-			} else if err != redis.ErrNil { // Redis查询出错
-				global.Log.Errorf("检查会话 %d 的人工模式宽限期标志失败: %v", req.Conversation.ID, err)
-				return // 为安全起见，交由人工处理
-			}
-
-			// 如果两个宽限期标志都不存在，说明人工客服已长时间未参与，AI应该接管
-			if err := service.Service.UserServiceGroup.ActionService.SetConversationPending(req.Conversation.ID); err != nil {
-				global.Log.Errorf("尝试接管会话 %d 失败，无法将会话状态设置为 pending: %v", req.Conversation.ID, err)
-				return // 接管失败，终止流程
-			}
-			global.Log.Debugf("会话 %d 状态为 'open' 但人工宽限期已过, 状态已成功切换至 pending，AI已接管。", req.Conversation.ID)
+	// 会话状态为 "open" 且不在转人工宽限期内时, 人工客服已长时间未参与, 由AI接管
+	if req.Conversation.Status == chatwoot.ConversationStatusOpen && !isGracePeriodOverride && c.firstAttempt(ctx, req, "takeover") {
+		if err := service.Service.UserServiceGroup.ActionService.SetConversationPending(ctx, req.Conversation.ID); err != nil {
+			global.Log.Errorf("尝试接管会话 %d 失败，无法将会话状态设置为 pending: %v", req.Conversation.ID, err)
+			return nil // 接管失败，终止流程
 		}
+		global.Log.Debugf("会话 %d 状态为 'open' 但人工宽限期已过, 状态已成功切换至 pending，AI已接管。", req.Conversation.ID)
 	}
 
 	// --- 进入智能处理路径 ---
 
-	go service.Service.UserServiceGroup.ActionService.ToggleTyping(req.Conversation.ID, true)
+	go service.Service.UserServiceGroup.ActionService.ToggleTyping(ctx, req.Conversation.ID, true)
 	defer func() {
-		go service.Service.UserServiceGroup.ActionService.ToggleTyping(req.Conversation.ID, false)
+		go service.Service.UserServiceGroup.ActionService.ToggleTyping(ctx, req.Conversation.ID, false)
 	}()
 
-	// 2. 并发获取向量搜索结果和会话历史
-	var vectorResults []dao.SearchResult
-	var fullHistory []common.LlmMessage
-	var vectorErr error // 使用独立的错误变量，因为向量搜索失败不应中断整个流程
-
-	g, gCtx := errgroup.WithContext(ctx)
-
-	// 向量搜索
-	g.Go(func() error {
-		var searchErr error
-		vectorResults, searchErr = service.Service.UserServiceGroup.VectorService.Search(gCtx, req.Content)
-		if searchErr != nil && !errors.Is(searchErr, context.Canceled) {
-			global.Log.Warnf("[processMessageAsync] 向量数据库搜索失败: %v", searchErr)
-			vectorErr = searchErr
+	// 附件先识别为文字, 作为用户消息的补充上下文参与分诊和检索; 无法识别时才转人工
+	if len(req.Attachments) > 0 {
+		attachmentText, err := service.Service.UserServiceGroup.AttachmentService.Describe(ctx, req.Attachments)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			global.Log.Warnf("[processMessageAsync] 会话 %d 附件识别失败，转人工: %v", req.Conversation.ID, err)
+			_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman3, string(enum.ReplyMsgUnsupportedAttachment))
+			return nil
 		}
-		return nil
-	})
+		req.Content = strings.TrimSpace(req.Content + "\n\n" + attachmentText)
+	}
 
-	// 获取会话历史
-	g.Go(func() error {
-		var historyErr error
-		fullHistory, historyErr = service.Service.UserServiceGroup.HistoryService.GetOrFetch(gCtx, req.Account.ID, req.Conversation.ID, req.Content)
-		if historyErr != nil {
-			global.Log.Warnf("[processMessageAsync] 获取历史记录失败: %v", historyErr)
+	// 提示词注入防护: 在用户内容进入分诊和大模型之前打分, 检测失败时放行
+	verdict, err := service.Service.UserServiceGroup.GuardService.Inspect(ctx, req.Conversation.ID, req.Content)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil
 		}
+		global.Log.Warnf("[processMessageAsync] 会话 %d 注入检测失败，继续处理: %v", req.Conversation.ID, err)
+	}
+	if verdict != nil && verdict.Flagged {
+		c.handleInjection(ctx, req)
 		return nil
-	})
+	}
 
-	if err := g.Wait(); err != nil {
-		global.Log.Errorf("[processMessageAsync] 并发获取数据时发生意外错误: %v", err)
-		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman2, string(enum.ReplyMsgLlmError))
-		return
+	// 2. 并发获取向量搜索结果和会话历史
+	vectorResults, fullHistory, err := c.fetchContext(ctx, req)
+	if err != nil {
+		return c.handleRateLimited(ctx, req, err)
 	}
 
 	// 3. 高相似度直接回答
-	if len(vectorResults) > 0 && vectorResults[0].Similarity >= global.Config.Ai.VectorSimilarityThreshold {
+	if len(vectorResults) > 0 && vectorResults[0].Similarity >= global.VectorSimilarityThreshold(ctx) {
 		chosenVectorAnswer := vectorResults[0].Answer
 		global.Log.Debugf("[processMessageAsync] 向量搜索高相似度匹配，提前响应, 相似度: %.4f, 会话ID: %d", vectorResults[0].Similarity, req.Conversation.ID)
-		service.Service.UserServiceGroup.ActionService.SendMessage(req.Conversation.ID, chosenVectorAnswer)
-		go service.Service.UserServiceGroup.HistoryService.Append(context.Background(), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: chosenVectorAnswer})
-		return
-	}
-
-	// 如果向量搜索失败，清空可能存在的vectorResults，确保后续逻辑正确处理空结果
-	if vectorErr != nil {
-		vectorResults = nil
-	}
-
-	if global.Config.Ai.MaxLlmHistoryMessages > 0 && len(fullHistory) > int(global.Config.Ai.MaxLlmHistoryMessages) {
-		startIndex := len(fullHistory) - int(global.Config.Ai.MaxLlmHistoryMessages)
-		fullHistory = fullHistory[startIndex:]
-		global.Log.Debugf("会话 %d 历史记录已限制为最近 %d 条消息", req.Conversation.ID, global.Config.Ai.MaxLlmHistoryMessages)
+		service.Service.UserServiceGroup.ActionService.SendMessage(ctx, req.Conversation.ID, chosenVectorAnswer)
+		service.Service.UserServiceGroup.CsatService.RecordAnswer(ctx, req.Conversation.ID, common.AnswerRecord{Question: req.Content, Answer: chosenVectorAnswer, Source: enum.AnswerSourceKnowledge, SourceIDs: []int64{vectorResults[0].SourceID}})
+		go service.Service.UserServiceGroup.HistoryService.Append(context.WithoutCancel(ctx), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: chosenVectorAnswer})
+		return nil
 	}
 
-	global.Log.Debugln("会话历史=========", fullHistory)
+	global.Log.Debugf("[processMessageAsync] 会话历史 %d 条, 会话ID: %d", len(fullHistory), req.Conversation.ID)
 
 	// 4. 分诊台 (Triage) & 智能路由
 	processed, err := c.runTriage(ctx, req, fullHistory, vectorResults)
 	if err != nil {
 		global.Log.Errorf("[processMessageAsync] 分诊失败: %v, 会话ID: %d", err, req.Conversation.ID)
-		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman2, string(enum.ReplyMsgLlmError))
-		return
+		return c.transferOnFailure(ctx, req, err)
 	}
 	if processed {
-		return
+		return nil
 	}
 
 	// --- 分诊通过，进入深度处理路径 ---
 
 	// 5. 调用大型LLM服务 (含RAG和工具调用)
-	llmAnswer, err := c.runComplexGeneration(ctx, req, fullHistory, vectorResults)
+	llmAnswer, evidence, err := c.runComplexGeneration(ctx, req, fullHistory, vectorResults, false)
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			global.Log.Debugf("会话 %d 的AI任务被取消。", req.Conversation.ID)
-			return
+			return nil
+		}
+		if errors.Is(err, errToolConfirmationPending) {
+			return nil
 		}
 		global.Log.Errorf("[processMessageAsync] 复杂路径处理失败: %v", err)
-		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman2, string(enum.ReplyMsgLlmError))
-		return
+		return c.transferOnFailure(ctx, req, err)
 	}
 
-	global.Log.Debugln("LLM回答=================", llmAnswer)
+	global.Log.Debugf("[processMessageAsync] LLM回答长度 %d, 会话ID: %d", len([]rune(llmAnswer)), req.Conversation.ID)
 
 	// 6. 最终回复处理
 	if strings.TrimSpace(llmAnswer) == enum.LlmUnsureTransferSignal {
 		global.Log.Debugf("[processMessageAsync] LLM不确定答案，主动转人工, 会话ID: %d", req.Conversation.ID)
-		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman5, "")
-		return
+		service.Service.UserServiceGroup.CoverageGapService.Record(ctx, req.Conversation.ID, req.Content, enum.CoverageGapUnsure)
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman5, "")
+		return nil
 	}
 
 	if llmAnswer == "" {
 		global.Log.Warnf("[processMessageAsync] LLM返回空回复，转人工, 会话ID: %d", req.Conversation.ID)
-		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman5, string(enum.ReplyMsgLlmError))
-		return
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman5, string(enum.ReplyMsgLlmError))
+		return nil
+	}
+
+	// 没有参考资料也没有借助工具生成的回复, 说明知识库缺少相关内容
+	if len(evidence.Docs) == 0 && len(evidence.ToolResults) == 0 {
+		service.Service.UserServiceGroup.CoverageGapService.Record(ctx, req.Conversation.ID, req.Content, enum.CoverageGapLowSimilarity)
+	}
+
+	// 发送前审核回复, 未通过时修改, 仍不通过则已转人工
+	llmAnswer, err = c.reviewAnswer(ctx, req, llmAnswer, evidence)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		global.Log.Errorf("[processMessageAsync] 修改回复失败: %v", err)
+		return c.transferOnFailure(ctx, req, err)
+	}
+	if llmAnswer == "" {
+		return nil
 	}
 
 	// 7. 如果在宽限期内AI成功处理，则异步将会话状态改回“机器人”
 	if isGracePeriodOverride {
 		go func() {
-			gracePeriodKey := fmt.Sprintf("%s%d", redis.KeyPrefixTransferGracePeriod, req.Conversation.ID)
+			gracePeriodKey := global.ConversationKey(ctx, redis.KeyPrefixTransferGracePeriod, req.Conversation.ID)
 			err := global.RedisClient.Get(context.Background(), gracePeriodKey).Err()
 			if err == redis.ErrNil {
 				global.Log.Debugf("会话 %d 宽限期已过，AI不再尝试改回bot状态。", req.Conversation.ID)
@@ -392,7 +740,7 @@ func (c *ChatApi) processMessageAsync(ctx context.Context, req common.ChatReques
 				return
 			}
 			// 宽限期标志仍然存在，可以安全地改回bot状态
-			if err := service.Service.UserServiceGroup.ActionService.SetConversationPending(req.Conversation.ID); err != nil {
+			if err := service.Service.UserServiceGroup.ActionService.SetConversationPending(ctx, req.Conversation.ID); err != nil {
 				global.Log.Warnf("将会话 %d 状态改回机器人失败: %v", req.Conversation.ID, err)
 			} else {
 				global.Log.Debugf("会话 %d 状态成功从open改回bot。", req.Conversation.ID)
@@ -401,8 +749,262 @@ func (c *ChatApi) processMessageAsync(ctx context.Context, req common.ChatReques
 	}
 
 	// 8. 发送消息并更新历史
-	service.Service.UserServiceGroup.ActionService.SendMessage(req.Conversation.ID, llmAnswer)
-	go service.Service.UserServiceGroup.HistoryService.Append(context.Background(), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: llmAnswer})
+	c.sendAnswer(ctx, req, llmAnswer, evidence)
+	return nil
+}
+
+// checkHumanMode 检查会话是否由人工客服处理; proceed为false时AI不介入, gracePeriod为true表示处于转人工宽限期, AI可纠正转人工的决定
+func (c *ChatApi) checkHumanMode(ctx context.Context, req common.ChatRequest) (gracePeriod bool, proceed bool) {
+	if req.Conversation.Status != chatwoot.ConversationStatusOpen {
+		return false, true
+	}
+
+	// 检查1：短时的“转人工宽限期”，用于AI在自动转人工后立即纠正
+	transferGracePeriodKey := global.ConversationKey(ctx, redis.KeyPrefixTransferGracePeriod, req.Conversation.ID)
+	err := global.RedisClient.Get(ctx, transferGracePeriodKey).Err()
+	if err == nil { // 标志存在，AI可以覆盖转人工决定
+		global.Log.Debugf("会话 %d 处于转人工宽限期，AI将继续处理新消息", req.Conversation.ID)
+		return true, true
+	}
+	if err != redis.ErrNil { // Redis查询出错
+		global.Log.Errorf("检查会话 %d 的转人工宽限期标志失败: %v", req.Conversation.ID, err)
+		return false, false // 为安全起见，交由人工处理
+	}
+
+	// 检查2：长时的“人工模式宽限期”
+	humanModeKey := global.ConversationKey(ctx, redis.KeyPrefixHumanModeActive, req.Conversation.ID)
+	err = global.RedisClient.Get(ctx, humanModeKey).Err()
+	if err == nil { // 标志存在，说明人工客服近期活跃
+		global.Log.Debugf("会话 %d 处于人工模式宽限期，AI不介入。", req.Conversation.ID)
+		return false, false
+	}
+	if err != redis.ErrNil { // Redis查询出错
+		global.Log.Errorf("检查会话 %d 的人工模式宽限期标志失败: %v", req.Conversation.ID, err)
+		return false, false
+	}
+	// 两个宽限期标志都不存在，说明人工客服已长时间未参与，AI应该接管
+	return false, true
+}
+
+// transcribe 转写语音消息, 任务重试时复用之前的转写结果, 不重复调用转写模型和发送转写备注
+func (c *ChatApi) transcribe(ctx context.Context, req common.ChatRequest) (string, []common.Attachment, error) {
+	var transcript string
+	if c.loadStepResult(ctx, req, "transcript", &transcript) {
+		others := slices.DeleteFunc(slices.Clone(req.Attachments), func(a common.Attachment) bool { return a.FileType == "audio" })
+		return transcript, others, nil
+	}
+	transcript, others, err := service.Service.UserServiceGroup.AttachmentService.Transcribe(ctx, req.Conversation.ID, req.Attachments)
+	if err != nil {
+		return "", nil, err
+	}
+	c.saveStepResult(ctx, req, "transcript", transcript)
+	return transcript, others, nil
+}
+
+// fetchContext 并发获取向量搜索结果和会话历史; 历史记录按配置截取最近的消息
+// 仅在向量搜索超出限流时返回错误, 其余失败都不中断流程, 向量搜索失败时结果为空
+func (c *ChatApi) fetchContext(ctx context.Context, req common.ChatRequest) ([]dao.SearchResult, []common.LlmMessage, error) {
+	var vectorResults []dao.SearchResult
+	var fullHistory []common.LlmMessage
+	var rateLimitErr error
+
+	g, gCtx := errgroup.WithContext(ctx)
+
+	// 向量搜索
+	g.Go(func() error {
+		var searchErr error
+		vectorResults, searchErr = service.Service.UserServiceGroup.VectorService.Search(gCtx, req.Content)
+		if searchErr != nil {
+			if errors.Is(searchErr, user.ErrRateLimited) {
+				rateLimitErr = searchErr
+			} else if !errors.Is(searchErr, context.Canceled) {
+				global.Log.Warnf("[fetchContext] 向量数据库搜索失败: %v", searchErr)
+			}
+			// 清空可能存在的结果，确保后续逻辑正确处理空结果
+			vectorResults = nil
+		}
+		return nil
+	})
+
+	// 获取会话历史
+	g.Go(func() error {
+		var historyErr error
+		fullHistory, historyErr = service.Service.UserServiceGroup.HistoryService.GetOrFetch(gCtx, req.Account.ID, req.Conversation.ID, req.Content)
+		if historyErr != nil {
+			global.Log.Warnf("[fetchContext] 获取历史记录失败: %v", historyErr)
+		}
+		return nil
+	})
+
+	// 两个任务都不返回错误, 失败时只记录日志
+	_ = g.Wait()
+
+	if global.Config.Ai.MaxLlmHistoryMessages > 0 && len(fullHistory) > int(global.Config.Ai.MaxLlmHistoryMessages) {
+		startIndex := len(fullHistory) - int(global.Config.Ai.MaxLlmHistoryMessages)
+		fullHistory = fullHistory[startIndex:]
+		global.Log.Debugf("会话 %d 历史记录已限制为最近 %d 条消息", req.Conversation.ID, global.Config.Ai.MaxLlmHistoryMessages)
+	}
+	return vectorResults, fullHistory, rateLimitErr
+}
+
+// shouldAssist 会话处于人工模式宽限期(且不在转人工宽限期内)并启用了辅助模式时返回true, Redis出错时返回false, 按原流程处理
+func (c *ChatApi) shouldAssist(ctx context.Context, req common.ChatRequest) bool {
+	if req.Conversation.Status != chatwoot.ConversationStatusOpen || global.RedisClient == nil {
+		return false
+	}
+	var teamID uint
+	if req.Conversation.Meta.Team != nil {
+		teamID = req.Conversation.Meta.Team.ID
+	}
+	if !service.Service.UserServiceGroup.AssistService.Enabled(req.Conversation.InboxID, teamID) {
+		return false
+	}
+
+	// 转人工宽限期内AI仍可纠正转人工的决定, 按原流程处理
+	transferGracePeriodKey := global.ConversationKey(ctx, redis.KeyPrefixTransferGracePeriod, req.Conversation.ID)
+	if global.RedisClient.Get(ctx, transferGracePeriodKey).Err() != redis.ErrNil {
+		return false
+	}
+	humanModeKey := global.ConversationKey(ctx, redis.KeyPrefixHumanModeActive, req.Conversation.ID)
+	return global.RedisClient.Get(ctx, humanModeKey).Err() == nil
+}
+
+// runAssist 辅助模式: 执行检索和只读工具调用, 将起草的回复以私信备注发给人工客服; 任何失败都只记录日志, 不会给用户发送消息或转人工
+func (c *ChatApi) runAssist(ctx context.Context, req common.ChatRequest) {
+	// 任务重试不再重复计数
+	if c.firstAttempt(ctx, req, "rate_limit") {
+		if err := service.Service.UserServiceGroup.RateLimitService.AllowMessage(ctx, req.Conversation.Meta.Sender.ID, req.Conversation.ID); err != nil {
+			global.Log.Debugf("[runAssist] 会话 %d 触发限流，不起草回复: %v", req.Conversation.ID, err)
+			return
+		}
+	}
+
+	if slices.ContainsFunc(req.Attachments, func(a common.Attachment) bool { return a.FileType == "audio" }) {
+		transcript, others, err := service.Service.UserServiceGroup.AttachmentService.Transcribe(ctx, req.Conversation.ID, req.Attachments)
+		if err != nil {
+			global.Log.Warnf("[runAssist] 会话 %d 语音转写失败，不起草回复: %v", req.Conversation.ID, err)
+			return
+		}
+		req.Content = strings.TrimSpace(req.Content + "\n" + transcript)
+		req.Attachments = others
+		req.IsTranscription = true
+	}
+	if len(req.Attachments) > 0 {
+		attachmentText, err := service.Service.UserServiceGroup.AttachmentService.Describe(ctx, req.Attachments)
+		if err != nil {
+			global.Log.Warnf("[runAssist] 会话 %d 附件识别失败，不起草回复: %v", req.Conversation.ID, err)
+			return
+		}
+		req.Content = strings.TrimSpace(req.Content + "\n\n" + attachmentText)
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		return
+	}
+
+	verdict, err := service.Service.UserServiceGroup.GuardService.Inspect(ctx, req.Conversation.ID, req.Content)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		global.Log.Warnf("[runAssist] 会话 %d 注入检测失败，继续处理: %v", req.Conversation.ID, err)
+	}
+	if verdict != nil && verdict.Flagged {
+		global.Log.Debugf("[runAssist] 会话 %d 的消息疑似提示词注入，不起草回复", req.Conversation.ID)
+		return
+	}
+
+	vectorResults, fullHistory, err := c.fetchContext(ctx, req)
+	if err != nil {
+		global.Log.Debugf("[runAssist] 会话 %d 触发限流，不起草回复: %v", req.Conversation.ID, err)
+		return
+	}
+	// 人工模式下用户消息不会写入历史, 这里补上, 之后的起草才有完整的上下文
+	defer func() {
+		go service.Service.UserServiceGroup.HistoryService.Append(context.WithoutCancel(ctx), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content})
+	}()
+
+	draft := &user.AssistDraft{}
+	if len(vectorResults) > 0 && vectorResults[0].Similarity >= global.VectorSimilarityThreshold(ctx) {
+		draft.Answer = vectorResults[0].Answer
+		draft.References = []string{vectorResults[0].Question}
+	} else {
+		llmAnswer, evidence, err := c.runComplexGeneration(ctx, req, fullHistory, vectorResults, true)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				global.Log.Errorf("[runAssist] 会话 %d 起草回复失败: %v", req.Conversation.ID, err)
+			}
+			return
+		}
+		for _, res := range vectorResults {
+			if res.Similarity >= global.VectorSearchMinSimilarity(ctx) {
+				draft.References = append(draft.References, res.Question)
+			}
+		}
+		draft.ToolResults = len(evidence.ToolResults)
+		draft.ToolCalls = evidence.PendingToolCalls
+		if answer := strings.TrimSpace(llmAnswer); answer != enum.LlmUnsureTransferSignal {
+			draft.Answer = answer
+			// 只提示审核发现的问题, 不自动修改, 由人工客服判断
+			if answer != "" {
+				draft.Problems = service.Service.UserServiceGroup.AnswerCheckService.Check(answer, evidence)
+			}
+		}
+	}
+
+	service.Service.UserServiceGroup.AssistService.PostDraft(ctx, req.Conversation.ID, draft)
+	global.Log.Debugf("[runAssist] 已为会话 %d 起草建议回复", req.Conversation.ID)
+}
+
+// transferOnFailure 处理流程中的临时故障: 来自任务队列的请求返回错误交由队列重试, 否则直接转人工
+func (c *ChatApi) transferOnFailure(ctx context.Context, req common.ChatRequest, cause error) error {
+	if errors.Is(cause, user.ErrRateLimited) {
+		return c.handleRateLimited(ctx, req, cause)
+	}
+	if req.Retryable {
+		return fmt.Errorf("会话 %d 处理失败: %w", req.Conversation.ID, cause)
+	}
+	_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman2, string(enum.ReplyMsgLlmError))
+	return nil
+}
+
+// handleRateLimited 按配置处理超出限流的请求: 转人工, 或回复稍后再试(每个会话一分钟内只提示一次)
+func (c *ChatApi) handleRateLimited(ctx context.Context, req common.ChatRequest, cause error) error {
+	global.Log.Warnf("[handleRateLimited] 会话 %d 触发限流: %v", req.Conversation.ID, cause)
+	if enum.RateLimitAction(global.Config.RateLimit.Action) == enum.RateLimitActionTransfer {
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman8, string(enum.ReplyMsgTransferSuccess))
+		return nil
+	}
+
+	notifiedKey := global.ConversationKey(ctx, redis.KeyPrefixRateLimitNotified, req.Conversation.ID)
+	ok, err := global.RedisClient.SetNX(context.Background(), notifiedKey, 1, time.Minute).Result()
+	if err == nil && !ok {
+		return nil
+	}
+	service.Service.UserServiceGroup.ActionService.SendMessage(ctx, req.Conversation.ID, string(enum.ReplyMsgRateLimited))
+	return nil
+}
+
+// handleInjection 按配置处理疑似提示词注入的消息: 转人工或拒绝回答, 该消息不写入对话历史
+func (c *ChatApi) handleInjection(ctx context.Context, req common.ChatRequest) {
+	if enum.GuardAction(global.Config.Guard.Action) == enum.GuardActionTransfer {
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman9, string(enum.ReplyMsgTransferSuccess))
+		return
+	}
+	service.Service.UserServiceGroup.ActionService.SendMessage(ctx, req.Conversation.ID, string(enum.ReplyMsgGuardRefused))
+}
+
+// sendAnswer 发送LLM回复(可能包含交互式组件)并更新历史，发送失败时转人工
+func (c *ChatApi) sendAnswer(ctx context.Context, req common.ChatRequest, llmAnswer string, evidence *user.AnswerEvidence) {
+	sentText, err := service.Service.UserServiceGroup.ActionService.SendReply(ctx, req.Conversation.ID, llmAnswer)
+	if err != nil {
+		global.Log.Errorf("[sendAnswer] 会话 %d 发送回复失败: %v", req.Conversation.ID, err)
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman2, string(enum.ReplyMsgLlmError))
+		return
+	}
+	go service.Service.UserServiceGroup.HistoryService.Append(context.WithoutCancel(ctx), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: sentText})
+
+	record := common.AnswerRecord{Question: req.Content, Answer: sentText, Source: enum.AnswerSourceLlm}
+	if evidence != nil {
+		record.SourceIDs = evidence.SourceIDs
+	}
+	service.Service.UserServiceGroup.CsatService.RecordAnswer(ctx, req.Conversation.ID, record)
 }
 
 // runTriage 执行分诊与智能路由
@@ -439,6 +1041,7 @@ func (c *ChatApi) runTriage(ctx context.Context, req common.ChatRequest, fullHis
 	}
 
 	global.Log.Debugf("=================分诊结果: %+v", triageResult)
+	service.Service.UserServiceGroup.RoutingService.SaveContext(ctx, req.Conversation.ID, common.RoutingContext{InboxID: req.Conversation.InboxID, Question: req.Content, TriageResult: *triageResult})
 
 	// 根据分诊结果执行路由
 	triggerTransferEmotions := []enum.TriageEmotion{
@@ -455,14 +1058,15 @@ func (c *ChatApi) runTriage(ctx context.Context, req common.ChatRequest, fullHis
 		enum.TriageIntent(triageResult.Intent) == enum.TriageIntentRequestHuman ||
 		utils.InSlice(triggerTransferUrgencies, enum.TriageUrgency(triageResult.Urgency)) > -1 {
 		global.Log.Debugf("[Triage] 触发高优先级转人工规则, 意图: %s, 情绪: %s, 紧急度: %s, 会话ID: %d", triageResult.Intent, triageResult.Emotion, triageResult.Urgency, req.Conversation.ID)
-		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(req.Conversation.ID, enum.TransferToHuman3, string(enum.ReplyMsgTransferSuccess))
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman3, string(enum.ReplyMsgTransferSuccess))
 		return true, nil
 	}
 
 	if enum.TriageIntent(triageResult.Intent) == enum.TriageIntentOffTopic {
 		global.Log.Debugf("[Triage] 识别为无关问题，已礼貌拒绝, 会话ID: %d", req.Conversation.ID)
-		service.Service.UserServiceGroup.ActionService.SendMessage(req.Conversation.ID, string(enum.ReplyMsgOffTopic))
-		go service.Service.UserServiceGroup.HistoryService.Append(context.Background(), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: string(enum.ReplyMsgOffTopic)})
+		service.Service.UserServiceGroup.ActionService.SendMessage(ctx, req.Conversation.ID, string(enum.ReplyMsgOffTopic))
+		service.Service.UserServiceGroup.CoverageGapService.Record(ctx, req.Conversation.ID, req.Content, enum.CoverageGapOffTopic)
+		go service.Service.UserServiceGroup.HistoryService.Append(context.WithoutCancel(ctx), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: string(enum.ReplyMsgOffTopic)})
 		return true, nil
 	}
 
@@ -470,24 +1074,31 @@ func (c *ChatApi) runTriage(ctx context.Context, req common.ChatRequest, fullHis
 }
 
 // runComplexGeneration 执行复杂的RAG+LLM生成，并处理工具调用
-func (c *ChatApi) runComplexGeneration(ctx context.Context, req common.ChatRequest, fullHistory []common.LlmMessage, vectorResults []dao.SearchResult) (string, error) {
+// 同时返回生成回复所依据的资料(参考资料、工具结果), 供发送前审核; assist 为true时有副作用的工具调用不执行, 记录在依据中返回
+func (c *ChatApi) runComplexGeneration(ctx context.Context, req common.ChatRequest, fullHistory []common.LlmMessage, vectorResults []dao.SearchResult, assist bool) (string, *user.AnswerEvidence, error) {
 	// 准备给大型LLM的参考资料 (RAG)
 	var llmReferenceDocs []dao.SearchResult
 	if len(vectorResults) > 0 {
 		for _, res := range vectorResults {
 			// 只使用相似度高于配置阈值的文档作为参考
-			if res.Similarity >= global.Config.Ai.VectorSearchMinSimilarity {
+			if res.Similarity >= global.VectorSearchMinSimilarity(ctx) {
 				llmReferenceDocs = append(llmReferenceDocs, res)
 			}
 		}
 	}
 
+	evidence := &user.AnswerEvidence{Question: req.Content, History: fullHistory}
+	for _, doc := range llmReferenceDocs {
+		evidence.Docs = append(evidence.Docs, fmt.Sprintf("[问题]: %s\n[回答]: %s", doc.Question, doc.Answer))
+		evidence.SourceIDs = append(evidence.SourceIDs, doc.SourceID)
+	}
+
 	global.Log.Debugln("=================开始进入大型LLM")
 
 	conversationHistory := fullHistory
 	llmAnswer, err := service.Service.UserServiceGroup.LlmService.GenerateResponseOrToolCall(ctx, &req, llmReferenceDocs, conversationHistory)
 	if err != nil {
-		return "", err // 将错误传递给上层处理
+		return "", nil, err // 将错误传递给上层处理
 	}
 
 	// 检查是否需要调用工具
@@ -495,7 +1106,7 @@ func (c *ChatApi) runComplexGeneration(ctx context.Context, req common.ChatReque
 		global.Log.Debugln("=================需调用Mcp")
 		global.Log.Debugf("[runComplexGeneration] LLM请求调用工具, 会话ID: %d", req.Conversation.ID)
 
-		if global.McpService != nil {
+		if global.TenantFrom(ctx).Mcp() != nil {
 			// 将 toolCodeBlock 的声明和使用都放在这个if块内，避免McpService为nil时出现“声明但未使用”的警告
 			toolCodeBlock := strings.TrimSpace(strings.Split(strings.Split(llmAnswer, "<tool_code>")[1], "</tool_code>")[0])
 
@@ -505,62 +1116,32 @@ func (c *ChatApi) runComplexGeneration(ctx context.Context, req common.ChatReque
 				toolResult := fmt.Sprintf("工具调用格式错误: %v", err)
 				conversationHistory = append(conversationHistory, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: llmAnswer}, common.LlmMessage{Role: openai.ChatMessageRoleTool, Content: toolResult})
 			} else if len(toolCalls) > 0 {
-				// 从MCP服务获取所有工具的描述
-				toolDescriptions := global.McpService.GetToolDescriptions()
+				// 辅助模式下不执行有副作用的工具, 也不向用户确认, 交由人工客服决定
+				if assist && service.Service.UserServiceGroup.ToolService.HasMutating(ctx, toolCalls) {
+					evidence.PendingToolCalls = toolCalls
+					return "", evidence, nil
+				}
+				// 有副作用的工具(如退款)先暂存，等待用户在下一条消息中明确确认
+				if service.Service.UserServiceGroup.ToolService.HasMutating(ctx, toolCalls) {
+					pending := &common.PendingToolCall{Question: req.Content, LlmAnswer: llmAnswer, ToolCalls: toolCalls}
+					confirmText, err := service.Service.UserServiceGroup.ToolService.RequestConfirmation(ctx, req.Conversation.ID, pending)
+					if err != nil {
+						return "", nil, err
+					}
+					global.Log.Debugf("[runComplexGeneration] 工具调用包含有副作用的操作，等待用户确认, 会话ID: %d", req.Conversation.ID)
+					go service.Service.UserServiceGroup.HistoryService.Append(context.WithoutCancel(ctx), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: confirmText})
+					return "", nil, errToolConfirmationPending
+				}
 
-				// 并发执行所有工具调用
+				// 任务重试时复用同一组工具调用的结果, 不重复调用
 				var toolResults []common.LlmMessage
-				var mu sync.Mutex
-				g, gCtx := errgroup.WithContext(ctx)
-				g.SetLimit(5) // 限制并发数为5，防止过多请求冲击MCP服务
-
-				for _, toolCall := range toolCalls {
-					toolCall := toolCall // 避免闭包陷阱
-					g.Go(func() error {
-						var toolResultContent string
-						parts := strings.SplitN(toolCall.Name, ".", 2)
-						if len(parts) != 2 {
-							toolResultContent = fmt.Sprintf("工具名称格式错误，必须为 '客户端名称.工具名称'，实际为: '%s'", toolCall.Name)
-							global.Log.Errorf("[runComplexGeneration] %s", toolResultContent)
-						} else {
-							clientName, toolName := parts[0], parts[1]
-							result, err := global.McpService.ExecuteTool(gCtx, clientName, toolName, toolCall.Arguments)
-							if err != nil {
-								toolResultContent = fmt.Sprintf("工具 '%s' 调用失败: %v", toolCall.Name, err)
-								global.Log.Errorf("[runComplexGeneration] %s", toolResultContent)
-							} else {
-								toolResultContent = result
-								global.Log.Debugf("=================成功获取Mcp数据 for '%s': %s", toolCall.Name, toolResultContent)
-							}
-						}
-
-						// 获取工具描述
-						toolDescription := "未知工具"
-						if desc, ok := toolDescriptions[toolCall.Name]; ok {
-							toolDescription = desc
-						}
-
-						// 为每个工具结果创建一个结构化的消息，并安全地追加到结果切片中
-						finalContent := fmt.Sprintf(
-							"[工具名称]: %s\n[工具作用]: %s\n[返回结果]:\n%s",
-							toolCall.Name,
-							toolDescription,
-							toolResultContent,
-						)
-						mu.Lock()
-						toolResults = append(toolResults, common.LlmMessage{
-							Role:    openai.ChatMessageRoleTool,
-							Content: finalContent,
-						})
-						mu.Unlock()
-						return nil
-					})
+				step := "tools:" + utils.Hash(toolCodeBlock)
+				if !c.loadStepResult(ctx, req, step, &toolResults) {
+					toolResults = service.Service.UserServiceGroup.ToolService.ExecuteToolCalls(ctx, req.Conversation.ID, toolCalls)
+					c.saveStepResult(ctx, req, step, toolResults)
 				}
-
-				// 等待所有工具调用完成
-				if err := g.Wait(); err != nil {
-					// errgroup 本身返回的错误通常是第一个非nil的错误，这里只记录日志
-					global.Log.Errorf("[runComplexGeneration] 执行MCP工具组时发生错误: %v", err)
+				for _, result := range toolResults {
+					evidence.ToolResults = append(evidence.ToolResults, result.Content)
 				}
 
 				// 将用户问题、助手回复（工具调用指令）和所有工具执行结果一起添加到历史记录中
@@ -574,29 +1155,137 @@ func (c *ChatApi) runComplexGeneration(ctx context.Context, req common.ChatReque
 			// 将工具执行结果和历史记录再次发送给LLM进行总结
 			llmAnswer, err = service.Service.UserServiceGroup.LlmService.SynthesizeToolResult(ctx, conversationHistory)
 			if err != nil {
-				return "", fmt.Errorf("工具调用后LLM错误: %w", err)
+				return "", nil, fmt.Errorf("工具调用后LLM错误: %w", err)
 			}
 		}
 	}
 
-	return llmAnswer, nil
+	return llmAnswer, evidence, nil
+}
+
+// reviewAnswer 发送前审核回复, 未通过时要求大模型修改; 修改次数用尽或修改后无法回答时转人工并返回空字符串
+func (c *ChatApi) reviewAnswer(ctx context.Context, req common.ChatRequest, answer string, evidence *user.AnswerEvidence) (string, error) {
+	for attempt := 0; ; attempt++ {
+		problems := service.Service.UserServiceGroup.AnswerCheckService.Check(answer, evidence)
+		if len(problems) == 0 {
+			return answer, nil
+		}
+		global.Log.Warnf("[reviewAnswer] 会话 %d 回复未通过审核(第%d次): %v, 回复: %s", req.Conversation.ID, attempt+1, problems, answer)
+
+		if attempt >= global.Config.AnswerCheck.MaxRegenerations {
+			_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman10, string(enum.ReplyMsgTransferSuccess))
+			return "", nil
+		}
+
+		revised, err := service.Service.UserServiceGroup.LlmService.ReviseAnswer(ctx, answer, problems, evidence)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(revised) == "" || strings.TrimSpace(revised) == enum.LlmUnsureTransferSignal {
+			_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman5, "")
+			return "", nil
+		}
+		answer = revised
+	}
 }
 
-// storeTask 存储一个异步任务的取消函数
-func (c *ChatApi) storeTask(conversationID uint, cancel context.CancelFunc) {
-	global.ActiveLLMTasks.Lock()
-	defer global.ActiveLLMTasks.Unlock()
-	// 如果该会话已有任务在运行，先取消旧的
-	if oldCancel, exists := global.ActiveLLMTasks.Data[conversationID]; exists {
-		oldCancel()
-		global.Log.Debugf("会话 %d 的旧AI任务已被新任务取代并取消。", conversationID)
+// handlePendingToolCall 处理用户对待确认工具调用的答复，返回true表示本条消息已处理完毕
+// 聚合的多条消息中第一条是答复时，先处理答复，其余内容留在 req.Content 中按普通消息继续处理
+func (c *ChatApi) handlePendingToolCall(ctx context.Context, req *common.ChatRequest) bool {
+	pending, err := service.Service.UserServiceGroup.ToolService.TakePending(ctx, req.Conversation.ID)
+	if err != nil {
+		global.Log.Warnf("[handlePendingToolCall] 会话 %d 获取待确认工具调用失败: %v", req.Conversation.ID, err)
+		return false
+	}
+	if pending == nil {
+		return false
+	}
+
+	// 用户点选确认消息中的选项时，直接使用回传的选项值
+	toolService := service.Service.UserServiceGroup.ToolService
+	answer := req.Content
+	if len(req.SubmittedValues) > 0 {
+		answer = req.SubmittedValues[0].Value
 	}
-	global.ActiveLLMTasks.Data[conversationID] = cancel
+	confirmed, recognized := toolService.ParseConfirmation(answer)
+	reply, rest := *req, ""
+	if !recognized && len(req.SubmittedValues) == 0 {
+		if first, others, ok := strings.Cut(req.Content, "\n"); ok {
+			if confirmed, recognized = toolService.ParseConfirmation(first); recognized {
+				reply.Content, rest = first, strings.TrimSpace(others)
+			}
+		}
+	}
+	if !recognized {
+		// 用户没有明确表态，放弃该操作，按普通消息继续处理
+		global.Log.Debugf("[handlePendingToolCall] 会话 %d 未明确确认，已放弃待执行的工具调用", req.Conversation.ID)
+		return false
+	}
+
+	c.resolvePendingToolCall(ctx, reply, pending, confirmed)
+	if rest == "" {
+		return true
+	}
+	req.Content = rest
+	return false
 }
 
-// removeTask 移除一个已完成或已取消的异步任务
-func (c *ChatApi) removeTask(conversationID uint) {
-	global.ActiveLLMTasks.Lock()
-	defer global.ActiveLLMTasks.Unlock()
-	delete(global.ActiveLLMTasks.Data, conversationID)
+// resolvePendingToolCall 按用户的答复执行或取消待确认的工具调用，并回复用户
+func (c *ChatApi) resolvePendingToolCall(ctx context.Context, req common.ChatRequest, pending *common.PendingToolCall, confirmed bool) {
+	if !confirmed {
+		global.Log.Debugf("[resolvePendingToolCall] 会话 %d 用户取消了工具调用", req.Conversation.ID)
+		service.Service.UserServiceGroup.ActionService.SendMessage(ctx, req.Conversation.ID, string(enum.ReplyMsgToolCancelled))
+		go service.Service.UserServiceGroup.HistoryService.Append(context.WithoutCancel(ctx), req.Conversation.ID, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: string(enum.ReplyMsgToolCancelled)})
+		return
+	}
+
+	global.Log.Debugf("[resolvePendingToolCall] 会话 %d 用户已确认，开始执行工具调用: %s", req.Conversation.ID, pending.LlmAnswer)
+
+	go service.Service.UserServiceGroup.ActionService.ToggleTyping(ctx, req.Conversation.ID, true)
+	defer func() {
+		go service.Service.UserServiceGroup.ActionService.ToggleTyping(ctx, req.Conversation.ID, false)
+	}()
+
+	history, err := service.Service.UserServiceGroup.HistoryService.GetOrFetch(ctx, req.Account.ID, req.Conversation.ID, req.Content)
+	if err != nil {
+		global.Log.Warnf("[resolvePendingToolCall] 获取历史记录失败: %v", err)
+	}
+
+	toolResults := service.Service.UserServiceGroup.ToolService.ExecuteToolCalls(ctx, req.Conversation.ID, pending.ToolCalls)
+	conversationHistory := append(history, common.LlmMessage{Role: openai.ChatMessageRoleUser, Content: req.Content}, common.LlmMessage{Role: openai.ChatMessageRoleAssistant, Content: pending.LlmAnswer})
+	conversationHistory = append(conversationHistory, toolResults...)
+
+	llmAnswer, err := service.Service.UserServiceGroup.LlmService.SynthesizeToolResult(ctx, conversationHistory)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		global.Log.Errorf("[resolvePendingToolCall] 工具调用后LLM错误: %v", err)
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman2, string(enum.ReplyMsgLlmError))
+		return
+	}
+
+	if llmAnswer == "" || strings.TrimSpace(llmAnswer) == enum.LlmUnsureTransferSignal {
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman5, "")
+		return
+	}
+
+	evidence := &user.AnswerEvidence{Question: req.Content, History: history}
+	for _, result := range toolResults {
+		evidence.ToolResults = append(evidence.ToolResults, result.Content)
+	}
+	llmAnswer, err = c.reviewAnswer(ctx, req, llmAnswer, evidence)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		global.Log.Errorf("[resolvePendingToolCall] 修改回复失败: %v", err)
+		_ = service.Service.UserServiceGroup.ActionService.TransferToHuman(ctx, req.Conversation.ID, enum.TransferToHuman2, string(enum.ReplyMsgLlmError))
+		return
+	}
+	if llmAnswer == "" {
+		return
+	}
+
+	c.sendAnswer(ctx, req, llmAnswer, evidence)
 }
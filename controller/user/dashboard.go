@@ -3,6 +3,7 @@ package user
 import (
 	"errors"
 
+	"gitee.com/taoJie_1/mall-agent/global"
 	"gitee.com/taoJie_1/mall-agent/model/common"
 	"gitee.com/taoJie_1/mall-agent/service"
 	"github.com/gin-gonic/gin"
@@ -18,7 +19,8 @@ func (p *DashboardApi) GetDashboardDetails(ctx *gin.Context) {
 		return
 	}
 
-	details, err := service.Service.UserServiceGroup.DashboardService.GetDetails(ctx, req.UserID, req.GoodsID, req.OrderID)
+	tenantCtx := global.WithTenant(ctx.Request.Context(), global.Tenants.Resolve(req.AccountID, req.InboxID))
+	details, err := service.Service.UserServiceGroup.DashboardService.GetDetails(tenantCtx, req.UserID, req.GoodsID, req.OrderID)
 	if err != nil {
 		common.Fail(ctx, err.Error())
 		return
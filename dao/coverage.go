@@ -0,0 +1,135 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+)
+
+// maxUnansweredQueue 待聚类问题队列的最大长度, 聚类任务长时间未运行时丢弃最早的问题
+const maxUnansweredQueue = 10000
+
+// CoverageDb 知识库空白存储在Redis中, 按租户隔离
+type CoverageDb struct{}
+
+// PushQuestion 将未能回答的问题加入待聚类队列
+func (d *CoverageDb) PushQuestion(ctx context.Context, question common.UnansweredQuestion) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+
+	jsonBytes, err := json.Marshal(question)
+	if err != nil {
+		return fmt.Errorf("序列化未回答问题失败: %w", err)
+	}
+	key := global.TenantFrom(ctx).Key(redis.KeyUnansweredQuestions)
+	if err := global.RedisClient.LPush(ctx, key, string(jsonBytes)).Err(); err != nil {
+		return fmt.Errorf("保存未回答问题失败: %w", err)
+	}
+	global.RedisClient.LTrim(ctx, key, 0, maxUnansweredQueue-1)
+	return nil
+}
+
+// TakeQuestions 原子地取出队列中最早的至多 n 个问题, 新问题从队头加入
+func (d *CoverageDb) TakeQuestions(ctx context.Context, n int64) ([]common.UnansweredQuestion, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+
+	values, err := global.RedisClient.PopListTail(ctx, global.TenantFrom(ctx).Key(redis.KeyUnansweredQuestions), n)
+	if err != nil {
+		return nil, fmt.Errorf("从Redis取出未回答问题失败: %w", err)
+	}
+
+	questions := make([]common.UnansweredQuestion, 0, len(values))
+	for _, v := range values {
+		var question common.UnansweredQuestion
+		if err := json.Unmarshal([]byte(v), &question); err != nil {
+			global.Log.Warnf("反序列化未回答问题失败: %v", err)
+			continue
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+// ReturnQuestions 将取出但未能处理的问题按原顺序放回队尾, 下次聚类时最先处理
+func (d *CoverageDb) ReturnQuestions(ctx context.Context, questions []common.UnansweredQuestion) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+	if len(questions) == 0 {
+		return nil
+	}
+
+	values := make([]interface{}, 0, len(questions))
+	for _, question := range questions {
+		jsonBytes, err := json.Marshal(question)
+		if err != nil {
+			return fmt.Errorf("序列化未回答问题失败: %w", err)
+		}
+		values = append(values, string(jsonBytes))
+	}
+	if err := global.RedisClient.RPush(ctx, global.TenantFrom(ctx).Key(redis.KeyUnansweredQuestions), values...).Err(); err != nil {
+		return fmt.Errorf("放回未回答问题失败: %w", err)
+	}
+	return nil
+}
+
+// ListGaps 获取所有知识库空白类别
+func (d *CoverageDb) ListGaps(ctx context.Context) ([]*dto.CoverageGap, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+
+	data, err := global.RedisClient.HGetAll(ctx, global.TenantFrom(ctx).Key(redis.KeyCoverageGaps)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("从Redis获取知识库空白失败: %w", err)
+	}
+	gaps := make([]*dto.CoverageGap, 0, len(data))
+	for id, v := range data {
+		var gap dto.CoverageGap
+		if err := json.Unmarshal([]byte(v), &gap); err != nil {
+			global.Log.Warnf("反序列化知识库空白 %s 失败: %v", id, err)
+			continue
+		}
+		gaps = append(gaps, &gap)
+	}
+	return gaps, nil
+}
+
+// SaveGap 新增或更新知识库空白类别
+func (d *CoverageDb) SaveGap(ctx context.Context, gap *dto.CoverageGap) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+
+	jsonBytes, err := json.Marshal(gap)
+	if err != nil {
+		return fmt.Errorf("序列化知识库空白失败: %w", err)
+	}
+	if err := global.RedisClient.HSet(ctx, global.TenantFrom(ctx).Key(redis.KeyCoverageGaps), gap.ID, string(jsonBytes)).Err(); err != nil {
+		return fmt.Errorf("保存知识库空白失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteGaps 删除知识库空白类别
+func (d *CoverageDb) DeleteGaps(ctx context.Context, ids ...string) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := global.RedisClient.HDel(ctx, global.TenantFrom(ctx).Key(redis.KeyCoverageGaps), ids...).Err(); err != nil {
+		return fmt.Errorf("删除知识库空白失败: %w", err)
+	}
+	return nil
+}
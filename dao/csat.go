@@ -0,0 +1,104 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+)
+
+// CsatDb 满意度评价存储在Redis中, 按租户隔离, 只保留最近的若干条
+type CsatDb struct{}
+
+// GetRating 获取指定ID的评价, 不存在时返回nil
+func (d *CsatDb) GetRating(ctx context.Context, id string) (*common.CsatRating, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+
+	val, err := global.RedisClient.HGet(ctx, global.TenantFrom(ctx).Key(redis.KeyCsatRatings), id).Result()
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("从Redis获取满意度评价失败: %w", err)
+	}
+	var rating common.CsatRating
+	if err := json.Unmarshal([]byte(val), &rating); err != nil {
+		return nil, fmt.Errorf("反序列化满意度评价失败: %w", err)
+	}
+	return &rating, nil
+}
+
+// SaveRating 保存评价; isNew 为true时将其加入评价列表, 并清理超出 maxLen 的旧评价
+func (d *CsatDb) SaveRating(ctx context.Context, rating *common.CsatRating, isNew bool, maxLen int64) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+
+	tenant := global.TenantFrom(ctx)
+	ratingsKey := tenant.Key(redis.KeyCsatRatings)
+	idsKey := tenant.Key(redis.KeyCsatRatingIDs)
+
+	jsonBytes, err := json.Marshal(rating)
+	if err != nil {
+		return fmt.Errorf("序列化满意度评价失败: %w", err)
+	}
+	if err := global.RedisClient.HSet(ctx, ratingsKey, rating.ID, string(jsonBytes)).Err(); err != nil {
+		return fmt.Errorf("保存满意度评价失败: %w", err)
+	}
+	if !isNew {
+		return nil
+	}
+
+	if err := global.RedisClient.LPush(ctx, idsKey, rating.ID).Err(); err != nil {
+		return fmt.Errorf("保存满意度评价ID失败: %w", err)
+	}
+	expired, err := global.RedisClient.LRange(ctx, idsKey, maxLen, -1).Result()
+	if err != nil || len(expired) == 0 {
+		return nil
+	}
+	global.RedisClient.HDel(ctx, ratingsKey, expired...)
+	global.RedisClient.LTrim(ctx, idsKey, 0, maxLen-1)
+	return nil
+}
+
+// ListRatings 获取最近的评价, 最新的在前
+func (d *CsatDb) ListRatings(ctx context.Context, limit int64) ([]common.CsatRating, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+
+	tenant := global.TenantFrom(ctx)
+	ids, err := global.RedisClient.LRange(ctx, tenant.Key(redis.KeyCsatRatingIDs), 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("从Redis获取满意度评价ID失败: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	data, err := global.RedisClient.HGetAll(ctx, tenant.Key(redis.KeyCsatRatings)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("从Redis获取满意度评价失败: %w", err)
+	}
+
+	ratings := make([]common.CsatRating, 0, len(ids))
+	for _, id := range ids {
+		val, ok := data[id]
+		if !ok {
+			continue
+		}
+		var rating common.CsatRating
+		if err := json.Unmarshal([]byte(val), &rating); err != nil {
+			global.Log.Warnf("反序列化满意度评价 %s 失败: %v", id, err)
+			continue
+		}
+		ratings = append(ratings, rating)
+	}
+	return ratings, nil
+}
@@ -18,6 +18,12 @@ var (
 type DbGroup struct {
 	KeywordsDb
 	VectorDb
+	PromptsDb
+	CsatDb
+	SuggestionsDb
+	CoverageDb
+	KnowledgeHistoryDb
+	KnowledgeImportDb
 }
 
 func Tx(fc func(tx *sqlx.Tx) error) (err error) {
@@ -14,6 +14,7 @@ import (
 	redisv8 "github.com/go-redis/redis/v8"
 )
 
+// KeywordsDb 的Redis Key按上下文中的租户划分命名空间
 type KeywordsDb struct{}
 
 // LoadAllKeywordsFromRedis 从Redis加载所有快捷回复
@@ -22,7 +23,7 @@ func (d *KeywordsDb) LoadAllKeywordsFromRedis(ctx context.Context) ([]chatwoot.C
 		return nil, errors.New("Redis客户端未初始化")
 	}
 
-	data, err := global.RedisClient.HGetAll(ctx, redis.KeyCannedResponsesHash).Result()
+	data, err := global.RedisClient.HGetAll(ctx, global.TenantFrom(ctx).Key(redis.KeyCannedResponsesHash)).Result()
 	if err != nil {
 		return nil, fmt.Errorf("从Redis获取快捷回复失败: %w", err)
 	}
@@ -75,7 +76,7 @@ func (d *KeywordsDb) SaveKeywordsToRedis(ctx context.Context, responses []chatwo
 		return 0, nil
 	}
 
-	err := global.RedisClient.HSet(ctx, redis.KeyCannedResponsesHash, args...).Err()
+	err := global.RedisClient.HSet(ctx, global.TenantFrom(ctx).Key(redis.KeyCannedResponsesHash), args...).Err()
 	if err != nil {
 		return 0, fmt.Errorf("批量保存快捷回复到Redis失败: %w", err)
 	}
@@ -91,7 +92,7 @@ func (d *KeywordsDb) DeleteKeywordsFromRedis(ctx context.Context, shortCodes []s
 		return 0, nil
 	}
 
-	count, err := global.RedisClient.HDel(ctx, redis.KeyCannedResponsesHash, shortCodes...).Result()
+	count, err := global.RedisClient.HDel(ctx, global.TenantFrom(ctx).Key(redis.KeyCannedResponsesHash), shortCodes...).Result()
 	if err != nil {
 		return 0, fmt.Errorf("从Redis删除快捷回复失败: %w", err)
 	}
@@ -104,7 +105,7 @@ func (d *KeywordsDb) AcquireSyncLock(ctx context.Context, agentID string) (bool,
 		return false, errors.New("Redis客户端未初始化")
 	}
 	expiry := time.Duration(global.Config.Redis.LockExpiry) * time.Second
-	return global.RedisClient.SetNX(ctx, redis.KeySyncCannedResponsesLock, agentID, expiry).Result()
+	return global.RedisClient.SetNX(ctx, global.TenantFrom(ctx).Key(redis.KeySyncCannedResponsesLock), agentID, expiry).Result()
 }
 
 // ReleaseSyncLock 释放Redis分布式锁
@@ -114,12 +115,12 @@ func (d *KeywordsDb) ReleaseSyncLock(ctx context.Context, agentID string) error
 	}
 
 	// 确保只有持有锁的实例才能释放锁
-	val, err := global.RedisClient.Get(ctx, redis.KeySyncCannedResponsesLock).Result()
+	val, err := global.RedisClient.Get(ctx, global.TenantFrom(ctx).Key(redis.KeySyncCannedResponsesLock)).Result()
 	if err != nil && err != redisv8.Nil {
 		return fmt.Errorf("获取锁值失败: %w", err)
 	}
 	if val == agentID {
-		return global.RedisClient.Del(ctx, redis.KeySyncCannedResponsesLock).Err()
+		return global.RedisClient.Del(ctx, global.TenantFrom(ctx).Key(redis.KeySyncCannedResponsesLock)).Err()
 	}
 	return nil // 不是当前实例持有的锁，无需释放
 }
@@ -0,0 +1,70 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/model/db"
+)
+
+type KnowledgeHistoryDb struct{}
+
+// InsertHistory 写入一条知识条目变更记录
+func (d *KnowledgeHistoryDb) InsertHistory(ctx context.Context, h *db.KnowledgeHistory) error {
+	now := time.Now().Unix()
+	query, args, err := utils.getBatchInsertSql(h, []map[string]interface{}{{
+		"tenant":        h.Tenant,
+		"item_id":       h.ItemID,
+		"prev_item_id":  h.PrevItemID,
+		"action":        h.Action,
+		"actor":         h.Actor,
+		"restored_from": h.RestoredFrom,
+		"diff":          h.Diff,
+		"snapshot":      h.Snapshot,
+		"created_at":    now,
+		"updated_at":    now,
+	}})
+	if err != nil {
+		return err
+	}
+	if _, err := DB.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("写入知识条目变更记录失败: %w", err)
+	}
+	return nil
+}
+
+// ListHistoryByItem 按时间倒序获取指定条目ID的变更记录
+func (d *KnowledgeHistoryDb) ListHistoryByItem(ctx context.Context, tenant, itemID string) ([]*db.KnowledgeHistory, error) {
+	var list []*db.KnowledgeHistory
+	query := fmt.Sprintf("SELECT * FROM `%s` WHERE `tenant` = ? AND `item_id` = ? ORDER BY `id` DESC", db.KnowledgeHistory{}.TableName())
+	if err := DB.SelectContext(ctx, &list, query, tenant, itemID); err != nil {
+		return nil, fmt.Errorf("查询知识条目变更记录失败: %w", err)
+	}
+	return list, nil
+}
+
+// ListRecentHistory 按时间倒序获取租户最近的变更记录
+func (d *KnowledgeHistoryDb) ListRecentHistory(ctx context.Context, tenant string, limit int) ([]*db.KnowledgeHistory, error) {
+	var list []*db.KnowledgeHistory
+	query := fmt.Sprintf("SELECT * FROM `%s` WHERE `tenant` = ? ORDER BY `id` DESC LIMIT ?", db.KnowledgeHistory{}.TableName())
+	if err := DB.SelectContext(ctx, &list, query, tenant, limit); err != nil {
+		return nil, fmt.Errorf("查询知识条目变更记录失败: %w", err)
+	}
+	return list, nil
+}
+
+// GetHistory 获取一条变更记录, 不存在时返回nil
+func (d *KnowledgeHistoryDb) GetHistory(ctx context.Context, tenant string, id uint) (*db.KnowledgeHistory, error) {
+	var h db.KnowledgeHistory
+	query := fmt.Sprintf("SELECT * FROM `%s` WHERE `tenant` = ? AND `id` = ?", h.TableName())
+	if err := DB.GetContext(ctx, &h, query, tenant, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询知识条目变更记录失败: %w", err)
+	}
+	return &h, nil
+}
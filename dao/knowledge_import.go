@@ -0,0 +1,53 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+)
+
+// importJobTTL 导入任务进度的保留时间
+const importJobTTL = 24 * time.Hour
+
+// KnowledgeImportDb 知识库导入任务的进度存储在Redis中, 按租户隔离, 只保留最近一次
+type KnowledgeImportDb struct{}
+
+// SaveImportJob 保存导入任务的进度
+func (d *KnowledgeImportDb) SaveImportJob(ctx context.Context, job *dto.ImportJob) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+	jsonBytes, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("序列化导入任务失败: %w", err)
+	}
+	if err := global.RedisClient.Set(ctx, global.TenantFrom(ctx).Key(redis.KeyKnowledgeImportJob), string(jsonBytes), importJobTTL).Err(); err != nil {
+		return fmt.Errorf("保存导入任务进度失败: %w", err)
+	}
+	return nil
+}
+
+// GetImportJob 获取最近一次导入任务的进度, 不存在时返回nil
+func (d *KnowledgeImportDb) GetImportJob(ctx context.Context) (*dto.ImportJob, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+	data, err := global.RedisClient.Get(ctx, global.TenantFrom(ctx).Key(redis.KeyKnowledgeImportJob)).Result()
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("获取导入任务进度失败: %w", err)
+	}
+	var job dto.ImportJob
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, fmt.Errorf("解析导入任务进度失败: %w", err)
+	}
+	return &job, nil
+}
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+)
+
+// PromptsDb 提示词的版本记录和启用状态存储在Redis中, 所有实例共享
+type PromptsDb struct{}
+
+// ListPromptVersions 获取提示词的所有已保存版本(不含内置版本), 按版本号从新到旧排序
+func (d *PromptsDb) ListPromptVersions(ctx context.Context, name enum.PromptName) ([]dto.PromptVersion, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+
+	data, err := global.RedisClient.HGetAll(ctx, redis.KeyPrefixPromptVersions+string(name)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("从Redis获取提示词版本失败: %w", err)
+	}
+
+	versions := make([]dto.PromptVersion, 0, len(data))
+	for _, v := range data {
+		var version dto.PromptVersion
+		if err := json.Unmarshal([]byte(v), &version); err != nil {
+			global.Log.Warnf("反序列化提示词 %s 的版本失败: %v", name, err)
+			continue
+		}
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version > versions[j].Version
+	})
+	return versions, nil
+}
+
+// AddPromptVersion 保存提示词的新版本, 版本号自增
+func (d *PromptsDb) AddPromptVersion(ctx context.Context, name enum.PromptName, content, note string) (*dto.PromptVersion, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+
+	seq, err := global.RedisClient.HIncrBy(ctx, redis.KeyPromptVersionSeq, string(name), 1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("分配提示词版本号失败: %w", err)
+	}
+
+	version := &dto.PromptVersion{Version: int(seq), Content: content, Note: note, CreatedAt: time.Now().Unix()}
+	jsonBytes, err := json.Marshal(version)
+	if err != nil {
+		return nil, fmt.Errorf("序列化提示词版本失败: %w", err)
+	}
+	if err := global.RedisClient.HSet(ctx, redis.KeyPrefixPromptVersions+string(name), strconv.Itoa(version.Version), string(jsonBytes)).Err(); err != nil {
+		return nil, fmt.Errorf("保存提示词版本失败: %w", err)
+	}
+	return version, nil
+}
+
+// GetActivePromptVersions 获取各提示词启用的版本号, 未出现的提示词使用内置版本
+func (d *PromptsDb) GetActivePromptVersions(ctx context.Context) (map[enum.PromptName]int, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+
+	data, err := global.RedisClient.HGetAll(ctx, redis.KeyPromptActive).Result()
+	if err != nil {
+		return nil, fmt.Errorf("从Redis获取启用的提示词版本失败: %w", err)
+	}
+
+	active := make(map[enum.PromptName]int, len(data))
+	for name, v := range data {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			global.Log.Warnf("提示词 %s 的启用版本号无效: %s", name, v)
+			continue
+		}
+		active[enum.PromptName(name)] = version
+	}
+	return active, nil
+}
+
+// SetActivePromptVersion 设置提示词启用的版本, 版本号为0表示恢复内置版本
+func (d *PromptsDb) SetActivePromptVersion(ctx context.Context, name enum.PromptName, version int) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+
+	var err error
+	if version == 0 {
+		err = global.RedisClient.HDel(ctx, redis.KeyPromptActive, string(name)).Err()
+	} else {
+		err = global.RedisClient.HSet(ctx, redis.KeyPromptActive, string(name), version).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("设置提示词启用版本失败: %w", err)
+	}
+	return nil
+}
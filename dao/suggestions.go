@@ -0,0 +1,92 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+)
+
+// SuggestionsDb 知识条目建议存储在Redis中, 按租户隔离
+type SuggestionsDb struct{}
+
+// ListSuggestions 获取所有建议(含已拒绝的), 按出现次数从多到少排序
+func (d *SuggestionsDb) ListSuggestions(ctx context.Context) ([]*dto.KnowledgeSuggestion, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+
+	data, err := global.RedisClient.HGetAll(ctx, global.TenantFrom(ctx).Key(redis.KeyKnowledgeSuggestions)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("从Redis获取知识条目建议失败: %w", err)
+	}
+
+	suggestions := make([]*dto.KnowledgeSuggestion, 0, len(data))
+	for id, v := range data {
+		var suggestion dto.KnowledgeSuggestion
+		if err := json.Unmarshal([]byte(v), &suggestion); err != nil {
+			global.Log.Warnf("反序列化知识条目建议 %s 失败: %v", id, err)
+			continue
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Count != suggestions[j].Count {
+			return suggestions[i].Count > suggestions[j].Count
+		}
+		return suggestions[i].UpdatedAt > suggestions[j].UpdatedAt
+	})
+	return suggestions, nil
+}
+
+// GetSuggestion 获取指定ID的建议, 不存在时返回nil
+func (d *SuggestionsDb) GetSuggestion(ctx context.Context, id string) (*dto.KnowledgeSuggestion, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+
+	val, err := global.RedisClient.HGet(ctx, global.TenantFrom(ctx).Key(redis.KeyKnowledgeSuggestions), id).Result()
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("从Redis获取知识条目建议失败: %w", err)
+	}
+	var suggestion dto.KnowledgeSuggestion
+	if err := json.Unmarshal([]byte(val), &suggestion); err != nil {
+		return nil, fmt.Errorf("反序列化知识条目建议失败: %w", err)
+	}
+	return &suggestion, nil
+}
+
+// SaveSuggestion 新增或更新建议
+func (d *SuggestionsDb) SaveSuggestion(ctx context.Context, suggestion *dto.KnowledgeSuggestion) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+
+	jsonBytes, err := json.Marshal(suggestion)
+	if err != nil {
+		return fmt.Errorf("序列化知识条目建议失败: %w", err)
+	}
+	if err := global.RedisClient.HSet(ctx, global.TenantFrom(ctx).Key(redis.KeyKnowledgeSuggestions), suggestion.ID, string(jsonBytes)).Err(); err != nil {
+		return fmt.Errorf("保存知识条目建议失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteSuggestion 删除建议
+func (d *SuggestionsDb) DeleteSuggestion(ctx context.Context, id string) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+	if err := global.RedisClient.HDel(ctx, global.TenantFrom(ctx).Key(redis.KeyKnowledgeSuggestions), id).Err(); err != nil {
+		return fmt.Errorf("删除知识条目建议失败: %w", err)
+	}
+	return nil
+}
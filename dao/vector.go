@@ -32,8 +32,11 @@ type SearchResult struct {
 	SourceID   int64
 }
 
-type VectorDb struct {
-	CollectionName string
+// VectorDb 的集合名称取自上下文中的租户, 各租户的知识库相互隔离
+type VectorDb struct{}
+
+func (d *VectorDb) collectionName(ctx context.Context) string {
+	return global.TenantFrom(ctx).CollectionName()
 }
 
 // BatchUpsert 将文档批量插入或更新到向量数据库
@@ -45,7 +48,7 @@ func (d *VectorDb) BatchUpsert(ctx context.Context, documents []vector.Document)
 		return 0, nil
 	}
 
-	err := global.VectorDb.Upsert(ctx, d.CollectionName, documents)
+	err := global.VectorDb.Upsert(ctx, d.collectionName(ctx), documents)
 	if err != nil {
 		return 0, fmt.Errorf("批量更新/插入文档到向量数据库失败: %w", err)
 	}
@@ -59,9 +62,9 @@ func (d *VectorDb) PruneStale(ctx context.Context, activeIDs []string) (int, err
 		return 0, fmt.Errorf("向量数据库客户端未初始化")
 	}
 
-	col, err := global.VectorDb.GetOrCreateCollection(ctx, d.CollectionName)
+	col, err := global.VectorDb.GetOrCreateCollection(ctx, d.collectionName(ctx))
 	if err != nil {
-		return 0, fmt.Errorf("获取向量集合 '%s' 失败: %w", d.CollectionName, err)
+		return 0, fmt.Errorf("获取向量集合 '%s' 失败: %w", d.collectionName(ctx), err)
 	}
 
 	results, err := col.Get(ctx, chroma.WithIncludeGet(chroma.IncludeURIs))
@@ -124,9 +127,9 @@ func (d *VectorDb) Search(ctx context.Context, query string, topK int) ([]Search
 	queryEmbedding := embeddings.NewEmbeddingFromFloat32(queryEmbeddings[0])
 
 	// 2. 获取集合
-	col, err := global.VectorDb.GetOrCreateCollection(ctx, d.CollectionName)
+	col, err := global.VectorDb.GetOrCreateCollection(ctx, d.collectionName(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("获取向量集合 '%s' 失败: %w", d.CollectionName, err)
+		return nil, fmt.Errorf("获取向量集合 '%s' 失败: %w", d.collectionName(ctx), err)
 	}
 
 	if topK == 0 {
@@ -214,5 +217,56 @@ func (d *VectorDb) DeleteByIDs(ctx context.Context, ids []string) (int, error) {
 	if global.VectorDb == nil {
 		return 0, fmt.Errorf("向量数据库客户端未初始化")
 	}
-	return global.VectorDb.DeleteByIDs(ctx, d.CollectionName, ids)
+	return global.VectorDb.DeleteByIDs(ctx, d.collectionName(ctx), ids)
+}
+
+// VectorDocument 向量数据库中一条快捷回复文档的内容
+type VectorDocument struct {
+	SourceID  int64
+	Question  string // 生成的标准问题
+	Answer    string
+	Embedding []float32
+}
+
+// ListCannedDocuments 获取集合中所有快捷回复文档及其向量
+func (d *VectorDb) ListCannedDocuments(ctx context.Context) ([]VectorDocument, error) {
+	if global.VectorDb == nil {
+		return nil, fmt.Errorf("向量数据库客户端未初始化")
+	}
+
+	col, err := global.VectorDb.GetOrCreateCollection(ctx, d.collectionName(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("获取向量集合 '%s' 失败: %w", d.collectionName(ctx), err)
+	}
+	results, err := col.Get(ctx, chroma.WithIncludeGet(chroma.IncludeMetadatas, chroma.IncludeEmbeddings))
+	if err != nil {
+		return nil, fmt.Errorf("从向量数据库获取所有文档失败: %w", err)
+	}
+
+	ids := results.GetIDs()
+	metadatas := results.GetMetadatas()
+	vectors := results.GetEmbeddings()
+	if len(metadatas) != len(ids) || len(vectors) != len(ids) {
+		return nil, fmt.Errorf("向量数据库返回的文档数据不完整")
+	}
+
+	documents := make([]VectorDocument, 0, len(ids))
+	for i, id := range ids {
+		if !strings.HasPrefix(string(id), CannedResponseVectorIDPrefix) || metadatas[i] == nil || vectors[i] == nil {
+			continue
+		}
+		sourceID, ok := metadatas[i].GetFloat(VectorMetadataKeySourceID)
+		if !ok {
+			continue
+		}
+		question, _ := metadatas[i].GetString(VectorMetadataKeyQuestion)
+		answer, _ := metadatas[i].GetString(VectorMetadataKeyAnswer)
+		documents = append(documents, VectorDocument{
+			SourceID:  int64(sourceID),
+			Question:  question,
+			Answer:    answer,
+			Embedding: vectors[i].ContentAsFloat32(),
+		})
+	}
+	return documents, nil
 }
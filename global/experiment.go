@@ -0,0 +1,36 @@
+package global
+
+import (
+	"context"
+
+	"gitee.com/taoJie_1/mall-agent/model/config"
+)
+
+type variantCtxKey struct{}
+
+// WithVariant 将会话所在的实验分组放入上下文
+func WithVariant(ctx context.Context, v *config.ExperimentVariant) context.Context {
+	return context.WithValue(ctx, variantCtxKey{}, v)
+}
+
+// VariantFrom 获取上下文中的实验分组, 不在实验中时返回nil
+func VariantFrom(ctx context.Context) *config.ExperimentVariant {
+	v, _ := ctx.Value(variantCtxKey{}).(*config.ExperimentVariant)
+	return v
+}
+
+// VectorSimilarityThreshold 直接采用知识库答案的相似度阈值, 实验分组的覆盖优先于租户配置
+func VectorSimilarityThreshold(ctx context.Context) float32 {
+	if v := VariantFrom(ctx); v != nil && v.VectorSimilarityThreshold > 0 {
+		return v.VectorSimilarityThreshold
+	}
+	return TenantFrom(ctx).VectorSimilarityThreshold()
+}
+
+// VectorSearchMinSimilarity 作为参考资料的最低相似度, 实验分组的覆盖优先于租户配置
+func VectorSearchMinSimilarity(ctx context.Context) float32 {
+	if v := VariantFrom(ctx); v != nil && v.VectorSearchMinSimilarity > 0 {
+		return v.VectorSearchMinSimilarity
+	}
+	return TenantFrom(ctx).VectorSearchMinSimilarity()
+}
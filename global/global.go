@@ -11,7 +11,9 @@ import (
 	"gitee.com/taoJie_1/mall-agent/internal/mcp"
 	"gitee.com/taoJie_1/mall-agent/internal/oss"
 	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/internal/transcription"
 	"gitee.com/taoJie_1/mall-agent/internal/vector"
+	"gitee.com/taoJie_1/mall-agent/internal/vision"
 	"gitee.com/taoJie_1/mall-agent/model/config"
 	"github.com/sirupsen/logrus"
 )
@@ -19,19 +21,21 @@ import (
 // 全局变量
 // 业务逻辑禁止修改
 var (
-	Version          string
-	Config           *config.Config = new(config.Config) //指针类型, 给与其内存空间
-	Log              *logrus.Logger
-	Tz               *time.Location
-	RedisClient      redis.Service
-	CannedResponses  *CannedResponsesMap = &CannedResponsesMap{Data: make(map[string]string)}
-	ChatwootService  chatwoot.Service
-	EmbeddingService embedding.Service
-	LlmService       llm.Service
-	VectorDb         vector.Service
-	McpService       mcp.Service
-	OssService       oss.Service
-	ActiveLLMTasks   *ActiveTasksMap = &ActiveTasksMap{Data: make(map[uint]context.CancelFunc)}
+	Version              string
+	Config               *config.Config = new(config.Config) //指针类型, 给与其内存空间
+	Log                  *logrus.Logger
+	Tz                   *time.Location
+	RedisClient          redis.Service
+	CannedResponses      *CannedResponsesMap = &CannedResponsesMap{Data: make(map[string]string)}
+	ChatwootService      chatwoot.Service
+	EmbeddingService     embedding.Service
+	VisionService        vision.Service
+	TranscriptionService transcription.Service
+	LlmService           llm.Service
+	VectorDb             vector.Service
+	McpService           mcp.Service
+	OssService           oss.Service
+	ActiveLLMTasks       *ActiveTasksMap = &ActiveTasksMap{Data: make(map[string]ActiveTask)}
 )
 
 type CannedResponsesMap struct {
@@ -39,8 +43,14 @@ type CannedResponsesMap struct {
 	Data map[string]string
 }
 
-// ActiveTasksMap 用于存储正在进行的异步任务的取消函数
+// ActiveTasksMap 用于存储本实例正在进行的异步任务, Key 为 Tenant.ConversationScope
 type ActiveTasksMap struct {
 	sync.RWMutex
-	Data map[uint]context.CancelFunc
+	Data map[string]ActiveTask
+}
+
+// ActiveTask 异步任务的令牌和取消函数, 令牌用于跨实例识别任务
+type ActiveTask struct {
+	Token  string
+	Cancel context.CancelFunc
 }
@@ -0,0 +1,55 @@
+package global
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+)
+
+// PromptsMap 管理后台启用的提示词内容, 未启用其他版本的提示词不在其中, 使用内置版本
+// Versions 为A/B实验分组引用的提示词版本
+type PromptsMap struct {
+	sync.RWMutex
+	Data     map[enum.PromptName]string
+	Versions map[enum.PromptName]map[int]string
+}
+
+var Prompts = &PromptsMap{Data: make(map[enum.PromptName]string), Versions: make(map[enum.PromptName]map[int]string)}
+
+// Prompt 返回当前启用的提示词, 模板变量已替换; 上下文中的实验分组指定了版本且该版本存在时使用该版本
+func Prompt(ctx context.Context, name enum.PromptName) enum.SystemPrompt {
+	Prompts.RLock()
+	content, ok := Prompts.Data[name]
+	if v := VariantFrom(ctx); v != nil {
+		// 实验指定的版本不存在(如已删除)时沿用当前启用的版本
+		if version, set := v.Prompts[string(name)]; set {
+			if variantContent, found := Prompts.Versions[name][version]; found {
+				content, ok = variantContent, true
+			}
+		}
+	}
+	Prompts.RUnlock()
+	if !ok {
+		content = string(enum.BuiltinPrompts[name])
+	}
+	return enum.SystemPrompt(RenderPrompt(content))
+}
+
+// RenderPrompt 替换提示词中的模板变量; {tools} 由调用方按实际可用的工具替换
+func RenderPrompt(content string) string {
+	if !strings.Contains(content, "{") {
+		return content
+	}
+	now := time.Now()
+	if Tz != nil {
+		now = now.In(Tz)
+	}
+	return strings.NewReplacer(
+		enum.PromptVarShopName, Config.Prompt.ShopName,
+		enum.PromptVarTone, Config.Prompt.Tone,
+		enum.PromptVarDate, now.Format("2006-01-02"),
+	).Replace(content)
+}
@@ -0,0 +1,195 @@
+package global
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"gitee.com/taoJie_1/mall-agent/internal/chatwoot"
+	"gitee.com/taoJie_1/mall-agent/internal/mcp"
+	"gitee.com/taoJie_1/mall-agent/model/config"
+)
+
+// Tenant 一个租户(品牌)的运行时资源; 未单独配置的客户端沿用全局客户端
+// Name 为空的是默认租户, 即顶层配置本身
+type Tenant struct {
+	Name            string
+	Config          config.Tenant
+	ChatwootClient  chatwoot.Service // 为nil时使用 ChatwootService
+	McpClient       mcp.Service      // 为nil时使用 McpService; 配置了独立MCP服务但初始化失败时不提供工具
+	CannedResponses *CannedResponsesMap
+}
+
+// TenantsMap 已配置的租户, 配置热重载时整体替换
+type TenantsMap struct {
+	sync.RWMutex
+	Data []*Tenant
+}
+
+var (
+	DefaultTenant = &Tenant{CannedResponses: CannedResponses}
+	Tenants       = &TenantsMap{}
+)
+
+type tenantCtxKey struct{}
+
+// WithTenant 将租户放入上下文, 下游服务通过 TenantFrom 取用
+func WithTenant(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, t)
+}
+
+// TenantFrom 获取上下文中的租户, 不存在时返回默认租户
+func TenantFrom(ctx context.Context) *Tenant {
+	if t, ok := ctx.Value(tenantCtxKey{}).(*Tenant); ok && t != nil {
+		return t
+	}
+	return DefaultTenant
+}
+
+// IsDefault 是否为默认租户
+func (t *Tenant) IsDefault() bool {
+	return t.Name == ""
+}
+
+func (t *Tenant) Chatwoot() chatwoot.Service {
+	if t.ChatwootClient != nil {
+		return t.ChatwootClient
+	}
+	return ChatwootService
+}
+
+// Mcp 租户使用的MCP服务, 返回nil表示没有可用的工具
+func (t *Tenant) Mcp() mcp.Service {
+	if t.McpClient != nil {
+		return t.McpClient
+	}
+	if t.mcpUnavailable() {
+		return nil
+	}
+	return McpService
+}
+
+// McpServers 租户使用的MCP服务配置
+func (t *Tenant) McpServers() map[string]config.Mcp {
+	if t.McpClient != nil {
+		return t.Config.McpServers
+	}
+	if t.mcpUnavailable() {
+		return nil
+	}
+	return Config.McpServers
+}
+
+// mcpUnavailable 租户配置了独立的MCP服务但客户端初始化失败
+// 此时不能回退到默认租户的MCP服务, 否则会用其他品牌的工具查询和操作订单
+func (t *Tenant) mcpUnavailable() bool {
+	return !t.IsDefault() && t.McpClient == nil && len(t.Config.McpServers) > 0
+}
+
+// ChatwootUrl 租户的 Chatwoot 部署地址
+func (t *Tenant) ChatwootUrl() string {
+	if t.IsDefault() {
+		return Config.Chatwoot.Url
+	}
+	return t.Config.Chatwoot.Url
+}
+
+func (t *Tenant) AccountID() uint {
+	if t.IsDefault() {
+		return uint(Config.Chatwoot.AccountId)
+	}
+	return uint(t.Config.Chatwoot.AccountId)
+}
+
+func (t *Tenant) CollectionName() string {
+	if t.IsDefault() {
+		return Config.VectorDb.CollectionName
+	}
+	return t.Config.CollectionName
+}
+
+func (t *Tenant) VectorSimilarityThreshold() float32 {
+	if t.IsDefault() {
+		return Config.Ai.VectorSimilarityThreshold
+	}
+	return t.Config.VectorSimilarityThreshold
+}
+
+func (t *Tenant) VectorSearchMinSimilarity() float32 {
+	if t.IsDefault() {
+		return Config.Ai.VectorSearchMinSimilarity
+	}
+	return t.Config.VectorSearchMinSimilarity
+}
+
+// SystemPrompt 追加在系统提示词之后的租户专属说明(品牌、语气等)
+func (t *Tenant) SystemPrompt() string {
+	return t.Config.SystemPrompt
+}
+
+// Key 返回租户命名空间下的Redis Key, 默认租户沿用原Key以兼容已有数据
+func (t *Tenant) Key(key string) string {
+	if t.IsDefault() {
+		return key
+	}
+	return key + ":" + t.Name
+}
+
+// ConversationScope 会话在Redis Key中的标识: 默认租户为会话ID, 其他租户带租户名后缀, 与 Key 的规则一致
+// Chatwoot 的会话ID只在账户内唯一, 所有会话维度的Key都需要带上租户
+func (t *Tenant) ConversationScope(conversationID uint) string {
+	return t.Key(fmt.Sprintf("%d", conversationID))
+}
+
+// ConversationKey 返回上下文所属租户下会话维度的Redis Key
+func ConversationKey(ctx context.Context, prefix string, conversationID uint) string {
+	return prefix + TenantFrom(ctx).ConversationScope(conversationID)
+}
+
+// All 返回默认租户和所有已配置的租户
+func (m *TenantsMap) All() []*Tenant {
+	m.RLock()
+	defer m.RUnlock()
+	return append([]*Tenant{DefaultTenant}, m.Data...)
+}
+
+// Get 按名称获取租户, 名称为空时返回默认租户
+func (m *TenantsMap) Get(name string) (*Tenant, bool) {
+	if name == "" {
+		return DefaultTenant, true
+	}
+	m.RLock()
+	defer m.RUnlock()
+	for _, t := range m.Data {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Resolve 按 webhook 中的账户和收件箱识别租户: 优先匹配配置了该收件箱的租户, 其次匹配未限定收件箱的同账户租户, 都不匹配时为默认租户
+func (m *TenantsMap) Resolve(accountID, inboxID uint) *Tenant {
+	m.RLock()
+	defer m.RUnlock()
+	var byAccount *Tenant
+	for _, t := range m.Data {
+		if accountID != 0 && uint(t.Config.Chatwoot.AccountId) != accountID {
+			continue
+		}
+		if len(t.Config.InboxIds) == 0 {
+			if byAccount == nil {
+				byAccount = t
+			}
+			continue
+		}
+		if inboxID != 0 && slices.Contains(t.Config.InboxIds, inboxID) {
+			return t
+		}
+	}
+	if byAccount != nil {
+		return byAccount
+	}
+	return DefaultTenant
+}
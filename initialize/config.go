@@ -161,6 +161,27 @@ func handleConfig(c *config.Config) {
 	if c.LlmEmbedding.BatchTimeout == 0 {
 		c.LlmEmbedding.BatchTimeout = 60
 	}
+	if c.LlmVision.Timeout == 0 {
+		c.LlmVision.Timeout = 30
+	}
+	if c.LlmVision.MaxFileSize == 0 {
+		c.LlmVision.MaxFileSize = 5 * 1024 * 1024
+	}
+	if c.LlmVision.MaxAttachments == 0 {
+		c.LlmVision.MaxAttachments = 4
+	}
+	if len(c.LlmVision.AllowedTypes) == 0 {
+		c.LlmVision.AllowedTypes = []string{"image/jpeg", "image/png", "image/webp", "image/gif"}
+	}
+	if c.LlmAudio.Timeout == 0 {
+		c.LlmAudio.Timeout = 60
+	}
+	if c.LlmAudio.MaxFileSize == 0 {
+		c.LlmAudio.MaxFileSize = 10 * 1024 * 1024
+	}
+	if len(c.LlmAudio.AllowedTypes) == 0 {
+		c.LlmAudio.AllowedTypes = []string{"audio/mpeg", "audio/mp4", "audio/x-m4a", "audio/aac", "audio/ogg", "audio/wav", "audio/x-wav", "audio/webm", "video/webm"}
+	}
 	if c.VectorDb.CollectionName == "" {
 		c.VectorDb.CollectionName = "chatwoot_keywords"
 	}
@@ -203,6 +224,137 @@ func handleConfig(c *config.Config) {
 	if c.Ai.KeywordReloadDebounce == 0 {
 		c.Ai.KeywordReloadDebounce = 600
 	}
+	if c.Ai.ToolConfirmTimeout == 0 {
+		c.Ai.ToolConfirmTimeout = 120
+	}
+	if c.RateLimit.Action == "" {
+		c.RateLimit.Action = "reply"
+	}
+	if c.RateLimit.MaxWait == 0 {
+		c.RateLimit.MaxWait = 5
+	}
+	if c.JobQueue.Concurrency == 0 {
+		c.JobQueue.Concurrency = 4
+	}
+	if c.JobQueue.MaxRetries == 0 {
+		c.JobQueue.MaxRetries = 2
+	}
+	if c.JobQueue.RetryBackoff == 0 {
+		c.JobQueue.RetryBackoff = 5
+	}
+	if c.JobQueue.ClaimIdle == 0 {
+		c.JobQueue.ClaimIdle = 120
+	}
+	if c.JobQueue.DeadLetterMaxLen == 0 {
+		c.JobQueue.DeadLetterMaxLen = 1000
+	}
+	if c.JobQueue.ShutdownTimeout == 0 {
+		c.JobQueue.ShutdownTimeout = 20
+	}
+	if c.Guard.Threshold == 0 {
+		c.Guard.Threshold = 0.8
+	}
+	if c.Guard.Action == "" {
+		c.Guard.Action = "refuse"
+	}
+	if c.Guard.LogMaxLen == 0 {
+		c.Guard.LogMaxLen = 1000
+	}
+	if len(c.AnswerCheck.PolicyKeywords) == 0 {
+		c.AnswerCheck.PolicyKeywords = []string{"包邮", "无理由", "保修", "质保", "运费险", "价保", "假一赔", "以旧换新"}
+	}
+	if len(c.AnswerCheck.CompensationTerms) == 0 {
+		c.AnswerCheck.CompensationTerms = []string{"退款", "赔偿", "补偿", "赔付", "返现", "红包", "优惠券", "代金券"}
+	}
+	if c.Prompt.ShopName == "" {
+		c.Prompt.ShopName = "本商城"
+	}
+	if c.Prompt.Tone == "" {
+		c.Prompt.Tone = "自然、友好"
+	}
+	if c.Csat.LowScore == 0 {
+		c.Csat.LowScore = 2
+	}
+	if c.Csat.LogMaxLen == 0 {
+		c.Csat.LogMaxLen = 1000
+	}
+	if c.KnowledgeMining.BatchSize == 0 {
+		c.KnowledgeMining.BatchSize = 20
+	}
+	if c.KnowledgeMining.ClusterThreshold == 0 {
+		c.KnowledgeMining.ClusterThreshold = 0.85
+	}
+	if c.KnowledgeMining.MaxSamples == 0 {
+		c.KnowledgeMining.MaxSamples = 10
+	}
+	if c.KnowledgeMining.MaxSuggestions == 0 {
+		c.KnowledgeMining.MaxSuggestions = 200
+	}
+	if c.CoverageGap.BatchSize == 0 {
+		c.CoverageGap.BatchSize = 200
+	}
+	if c.CoverageGap.ClusterThreshold == 0 {
+		c.CoverageGap.ClusterThreshold = 0.85
+	}
+	if c.CoverageGap.MaxSamples == 0 {
+		c.CoverageGap.MaxSamples = 5
+	}
+	if c.CoverageGap.MaxClusters == 0 {
+		c.CoverageGap.MaxClusters = 1000
+	}
+	if c.CoverageGap.RetentionDays == 0 {
+		c.CoverageGap.RetentionDays = 30
+	}
+	if c.KnowledgeConflict.DuplicateThreshold == 0 {
+		c.KnowledgeConflict.DuplicateThreshold = 0.92
+	}
+	if c.KnowledgeConflict.DriftThreshold == 0 {
+		c.KnowledgeConflict.DriftThreshold = 0.6
+	}
+	for i := range c.Experiment.Variants {
+		if c.Experiment.Variants[i].Weight == 0 {
+			c.Experiment.Variants[i].Weight = 1
+		}
+	}
+	for i := range c.Tenants {
+		t := &c.Tenants[i]
+		if t.Chatwoot.Url == "" {
+			t.Chatwoot.Url = c.Chatwoot.Url
+		}
+		if t.Chatwoot.AccountId == 0 {
+			t.Chatwoot.AccountId = c.Chatwoot.AccountId
+		}
+		if t.Chatwoot.Auth == "" {
+			t.Chatwoot.Auth = c.Chatwoot.Auth
+		}
+		if t.Chatwoot.BotAuth == "" {
+			t.Chatwoot.BotAuth = c.Chatwoot.BotAuth
+		}
+		if t.CollectionName == "" {
+			t.CollectionName = c.VectorDb.CollectionName + "_" + t.Name
+		}
+		if t.VectorSimilarityThreshold == 0 {
+			t.VectorSimilarityThreshold = c.Ai.VectorSimilarityThreshold
+		}
+		if t.VectorSearchMinSimilarity == 0 {
+			t.VectorSearchMinSimilarity = c.Ai.VectorSearchMinSimilarity
+		}
+	}
+	if c.Routing.LabelPrefix == "" {
+		c.Routing.LabelPrefix = "ai-"
+	}
+	if len(c.BusinessHours.Weekdays) == 0 {
+		c.BusinessHours.Weekdays = []int{1, 2, 3, 4, 5}
+	}
+	if c.BusinessHours.Start == "" {
+		c.BusinessHours.Start = "09:00"
+	}
+	if c.BusinessHours.End == "" {
+		c.BusinessHours.End = "18:00"
+	}
+	if c.BusinessHours.AgentRecheckInterval == 0 {
+		c.BusinessHours.AgentRecheckInterval = 600
+	}
 	if c.Oss.StoragePath == "" {
 		c.Oss.StoragePath = "agent/"
 	}
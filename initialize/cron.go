@@ -1,7 +1,11 @@
 package initialize
 
 import (
+	"context"
+	"time"
+
 	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/service"
 	"gitee.com/taoJie_1/mall-agent/task"
 	"github.com/robfig/cron/v3"
 )
@@ -23,6 +27,21 @@ func (i *Initializer) timerStart(taskManager *task.Manager) error {
 		return err
 	}
 
+	// 每分钟检查一次到期的预约转人工会话
+	if err := i.startCronJob(reopenScheduledConversations, "* * * * *"); err != nil {
+		return err
+	}
+
+	// 每10分钟从已解决会话中挖掘知识条目建议
+	if err := i.startCronJob(mineResolvedConversations, "*/10 * * * *"); err != nil {
+		return err
+	}
+
+	// 每10分钟聚类一次知识库未能回答的问题
+	if err := i.startCronJob(clusterUnansweredQuestions, "*/10 * * * *"); err != nil {
+		return err
+	}
+
 	i.cron.Start() //已含协程
 	global.Log.Infoln("定时器启动成功")
 	return nil
@@ -37,6 +56,34 @@ func (i *Initializer) timerStop() {
 	global.Log.Infoln("定时器停止成功")
 }
 
+func reopenScheduledConversations() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+	if err := service.Service.UserServiceGroup.ActionService.ReopenScheduledConversations(ctx); err != nil {
+		// 定时任务中的错误会导致panic, 这里只记录日志
+		global.Log.Errorf("处理预约转人工会话失败: %v", err)
+	}
+	return nil
+}
+
+func mineResolvedConversations() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Minute)
+	defer cancel()
+	if err := service.Service.UserServiceGroup.KnowledgeMiningService.MineResolvedConversations(ctx); err != nil {
+		global.Log.Errorf("挖掘知识条目建议失败: %v", err)
+	}
+	return nil
+}
+
+func clusterUnansweredQuestions() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Minute)
+	defer cancel()
+	if err := service.Service.UserServiceGroup.CoverageGapService.ClusterUnanswered(ctx); err != nil {
+		global.Log.Errorf("聚类未回答的问题失败: %v", err)
+	}
+	return nil
+}
+
 // 启动一个新的定时任务
 func (i *Initializer) startCronJob(task func() error, schedule string) error {
 	_, err := i.cron.AddFunc(schedule, func() {
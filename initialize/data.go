@@ -10,5 +10,8 @@ func (i *Initializer) loadData(taskManager *task.Manager) {
 	if err := taskManager.LoadKeywords(); err != nil {
 		global.Log.Errorln("启动时加载Keywords失败, 快捷回复功能将不可用:", err)
 	}
+	if err := taskManager.PromptReloader(); err != nil {
+		global.Log.Errorln("启动时加载提示词失败, 将使用内置提示词:", err)
+	}
 
 }
@@ -6,6 +6,7 @@ import (
 
 	"gitee.com/taoJie_1/mall-agent/dao"
 	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/db"
 	"gitee.com/taoJie_1/mall-agent/model/enum"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
@@ -32,19 +33,27 @@ func (i *Initializer) dbStart() error {
 		dbRes = &sqlite{}
 	}
 
+	// 关键词数据在 Redis 中, SQL 数据库目前只保存知识条目的变更记录; 不可用时不记录变更, 不影响启动
 	if err := dbRes.connect(); err != nil {
+		global.Log.Warnf("数据库不可用, 知识条目的变更记录将不会保存: %v", err)
+		i.resetDb()
 		return err
 	}
-
-	// 如果未来有其他表需要SQL数据库，可以在dbRes.createTable()中添加相应的创建逻辑
-	// 目前只保留通用方法，但其内部不再处理Keywords表
-	// if err := dbRes.createTable(); err != nil {
-	// 	return fmt.Errorf("创建数据表失败: %w", err)
-	// }
+	if err := dbRes.createTable(); err != nil {
+		global.Log.Warnf("创建数据表失败, 知识条目的变更记录将不会保存: %v", err)
+		i.resetDb()
+		return fmt.Errorf("创建数据表失败: %w", err)
+	}
 
 	return nil
 }
 
+// resetDb 关闭未能完成初始化的连接, 使 dao.DB 保持为nil
+func (i *Initializer) resetDb() {
+	_ = i.dbClose()
+	dao.DB = nil
+}
+
 // dbClose 关闭数据库连接
 func (i *Initializer) dbClose() error {
 	if dao.DB != nil {
@@ -118,22 +127,50 @@ func (*mysql) version() (t string) {
 }
 
 func (s *sqlite) createTable() error {
-	// 如果未来有其他表需要SQLite，可以在这里添加创建逻辑
-	// 例如:
-	// tableName := db.OtherTable{}.TableName()
-	// createTableSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (...)`, tableName)
-	// if _, err := dao.DB.Exec(createTableSQL); err != nil {
-	// 	return fmt.Errorf("创建表 '%s' 失败: %w", tableName, err)
-	// }
+	tableName := db.KnowledgeHistory{}.TableName()
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"tenant" TEXT NOT NULL DEFAULT '',
+			"item_id" TEXT NOT NULL,
+			"prev_item_id" TEXT NOT NULL DEFAULT '',
+			"action" TEXT NOT NULL,
+			"actor" TEXT NOT NULL DEFAULT '',
+			"restored_from" INTEGER NOT NULL DEFAULT 0,
+			"diff" TEXT NOT NULL,
+			"snapshot" TEXT NOT NULL,
+			"created_at" INTEGER NOT NULL,
+			"updated_at" INTEGER NOT NULL
+		)`, tableName),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "idx_%[1]s_item" ON "%[1]s" ("tenant", "item_id")`, tableName),
+	}
+	for _, stmt := range statements {
+		if _, err := dao.DB.Exec(stmt); err != nil {
+			return fmt.Errorf("创建表 '%s' 失败: %w", tableName, err)
+		}
+	}
 	return nil
 }
 
 func (m *mysql) createTable() error {
-	// 示例：如果未来有其他表需要MySQL，可以在这里添加创建逻辑
-	// tableName := db.OtherTable{}.TableName()
-	// sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (...)`, "`"+tableName+"`")
-	// if _, err := dao.DB.Exec(sql); err != nil {
-	// 	return fmt.Errorf("创建表 '%s' 失败: %w", tableName, err)
-	// }
+	tableName := db.KnowledgeHistory{}.TableName()
+	sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		`+"`id`"+` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+		`+"`tenant`"+` VARCHAR(64) NOT NULL DEFAULT '',
+		`+"`item_id`"+` VARCHAR(64) NOT NULL,
+		`+"`prev_item_id`"+` VARCHAR(64) NOT NULL DEFAULT '',
+		`+"`action`"+` VARCHAR(16) NOT NULL,
+		`+"`actor`"+` VARCHAR(128) NOT NULL DEFAULT '',
+		`+"`restored_from`"+` INT UNSIGNED NOT NULL DEFAULT 0,
+		`+"`diff`"+` MEDIUMTEXT NOT NULL,
+		`+"`snapshot`"+` MEDIUMTEXT NOT NULL,
+		`+"`created_at`"+` BIGINT NOT NULL,
+		`+"`updated_at`"+` BIGINT NOT NULL,
+		PRIMARY KEY (`+"`id`"+`),
+		KEY `+"`idx_item`"+` (`+"`tenant`"+`, `+"`item_id`"+`)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`, "`"+tableName+"`")
+	if _, err := dao.DB.Exec(sql); err != nil {
+		return fmt.Errorf("创建表 '%s' 失败: %w", tableName, err)
+	}
 	return nil
 }
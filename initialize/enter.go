@@ -24,11 +24,14 @@ func (i *Initializer) Run() error {
 	eg, _ := errgroup.WithContext(context.Background())
 
 	// 关键任务，失败会终止程序
-	// eg.Go(i.dbStart)
 	eg.Go(i.initChatwoot)
 	eg.Go(i.initRedis)
 
 	// 非关键任务，失败只打印日志，不影响启动
+	eg.Go(func() error {
+		_ = i.dbStart()
+		return nil
+	})
 	eg.Go(func() error {
 		_ = i.initVectorDb()
 		return nil
@@ -41,6 +44,14 @@ func (i *Initializer) Run() error {
 		_ = i.initLlmEmbedding()
 		return nil
 	})
+	eg.Go(func() error {
+		_ = i.initVision()
+		return nil
+	})
+	eg.Go(func() error {
+		_ = i.initTranscription()
+		return nil
+	})
 	eg.Go(func() error {
 		_ = i.initMcp()
 		return nil
@@ -49,6 +60,7 @@ func (i *Initializer) Run() error {
 		_ = i.initOss()
 		return nil
 	})
+	eg.Go(i.initTenants)
 
 	return eg.Wait()
 }
@@ -58,6 +70,9 @@ func (i *Initializer) Close() {
 	if i.mcpClose() == nil {
 		global.Log.Info("MCP客户端已关闭")
 	}
+	if i.tenantsClose() == nil {
+		global.Log.Info("租户客户端已关闭")
+	}
 	if i.ossClose() == nil {
 		global.Log.Info("OSS客户端已关闭")
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"reflect"
 	"strings"
+	"time"
 
 	"gitee.com/taoJie_1/mall-agent/global"
 	"gitee.com/taoJie_1/mall-agent/model/config"
@@ -29,6 +30,9 @@ func (i *Initializer) HandleConfigChange(oldConfig, newConfig *config.Config) {
 	if oldConfig.GinLogPath != newConfig.GinLogPath || oldConfig.RunLogPath != newConfig.RunLogPath {
 		restartNeeded = append(restartNeeded, "log_path")
 	}
+	if oldConfig.JobQueue.Concurrency != newConfig.JobQueue.Concurrency {
+		restartNeeded = append(restartNeeded, "job_queue.concurrency")
+	}
 
 	// --- 2. 并发执行可安全热重载的任务 ---
 	eg, _ := errgroup.WithContext(context.Background())
@@ -91,6 +95,20 @@ func (i *Initializer) HandleConfigChange(oldConfig, newConfig *config.Config) {
 		})
 	}
 
+	// 视觉模型服务重载
+	if !reflect.DeepEqual(oldConfig.LlmVision, newConfig.LlmVision) {
+		eg.Go(func() error {
+			return i.initVision()
+		})
+	}
+
+	// 语音转写服务重载
+	if !reflect.DeepEqual(oldConfig.LlmAudio, newConfig.LlmAudio) {
+		eg.Go(func() error {
+			return i.initTranscription()
+		})
+	}
+
 	// 向量数据库客户端重载
 	if !reflect.DeepEqual(oldConfig.VectorDb, newConfig.VectorDb) {
 		eg.Go(func() error {
@@ -126,7 +144,21 @@ func (i *Initializer) HandleConfigChange(oldConfig, newConfig *config.Config) {
 	if !reflect.DeepEqual(oldConfig.Ai, newConfig.Ai) {
 		eg.Go(func() error {
 			// ActionService依赖于Ai.TransferKeywords，需要重新初始化
-			service.Service.UserServiceGroup = user.NewServiceGroup(i.taskManager)
+			group := user.NewServiceGroup(i.taskManager)
+			// 任务注册表和任务队列持有运行中的消费者与任务, 沿用原实例
+			group.TaskRegistry = service.Service.UserServiceGroup.TaskRegistry
+			group.JobQueue = service.Service.UserServiceGroup.JobQueue
+			service.Service.UserServiceGroup = group
+			return nil
+		})
+	}
+
+	// 实验分组引用的提示词版本需重新加载
+	if !reflect.DeepEqual(oldConfig.Experiment, newConfig.Experiment) {
+		eg.Go(func() error {
+			if err := i.taskManager.PromptReloader(); err != nil {
+				global.Log.Errorf("重新加载实验分组的提示词失败: %v", err)
+			}
 			return nil
 		})
 	}
@@ -166,6 +198,18 @@ func (i *Initializer) HandleConfigChange(oldConfig, newConfig *config.Config) {
 		})
 	}
 
+	// 租户重载: 租户未配置的项已由顶层配置补全, 顶层变更同样会触发重建; 重建后按新配置重新同步快捷回复
+	if !reflect.DeepEqual(oldConfig.Tenants, newConfig.Tenants) {
+		eg.Go(func() error {
+			if err := i.initTenants(); err != nil {
+				global.Log.Errorf("热重载租户失败: %v", err)
+				return err
+			}
+			i.taskManager.DebounceKeywordReload(time.Duration(newConfig.Ai.KeywordReloadDebounce) * time.Second)
+			return nil
+		})
+	}
+
 	// OSS 服务重载
 	if !reflect.DeepEqual(oldConfig.Oss, newConfig.Oss) {
 		eg.Go(func() error {
@@ -184,6 +228,11 @@ func (i *Initializer) HandleConfigChange(oldConfig, newConfig *config.Config) {
 		global.Log.Errorf("并发热重载过程中发生错误: %v", err)
 	}
 
+	// 限流、注入防护、脱敏等配置在使用时从 global.Config 读取, 新配置即时生效, 这里统一记录变更的配置项
+	if changed := changedSections(oldConfig, newConfig); len(changed) > 0 {
+		global.Log.Infof("已更新的配置项: [%s]", strings.Join(changed, ", "))
+	}
+
 	// --- 3. 如果有需要重启的变更，发出统一警告 ---
 	if len(restartNeeded) > 0 {
 		global.Log.Warnf("检测到存在需要 重启服务 才能生效的配置变更: [%s]。", strings.Join(restartNeeded, ", "))
@@ -191,3 +240,15 @@ func (i *Initializer) HandleConfigChange(oldConfig, newConfig *config.Config) {
 
 	global.Log.Info("配置变更处理完成")
 }
+
+// changedSections 返回新旧配置中有变化的顶层配置项名称, 不记录配置的值, 避免密钥写入日志
+func changedSections(oldConfig, newConfig *config.Config) []string {
+	var changed []string
+	oldValue, newValue := reflect.ValueOf(oldConfig).Elem(), reflect.ValueOf(newConfig).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changed = append(changed, oldValue.Type().Field(i).Tag.Get("mapstructure"))
+		}
+	}
+	return changed
+}
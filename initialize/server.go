@@ -11,6 +11,7 @@ import (
 	"syscall"
 	"time"
 
+	"gitee.com/taoJie_1/mall-agent/controller"
 	"gitee.com/taoJie_1/mall-agent/global"
 	"gitee.com/taoJie_1/mall-agent/router"
 	"gitee.com/taoJie_1/mall-agent/service"
@@ -31,6 +32,13 @@ func Start(initializer *Initializer, taskManager *task.Manager, startTime time.T
 	service.Service.UserServiceGroup = user.NewServiceGroup(taskManager)
 	service.Service.AdminServiceGroup = admin.NewServiceGroup(taskManager)
 
+	// 订阅跨实例的AI任务取消广播
+	go service.Service.UserServiceGroup.TaskRegistry.Listen(context.Background())
+	// 订阅提示词变更广播, 管理后台启用新版本后各实例即时生效
+	go taskManager.ListenPromptReload(context.Background())
+	// 启动任务队列消费者
+	service.Service.UserServiceGroup.JobQueue.Start(controller.Api.UserApiGroup.ChatApi.HandleJob, controller.Api.UserApiGroup.ChatApi.HandleDeadJob)
+
 	initGinServer()
 
 	go startServer()
@@ -121,5 +129,10 @@ func shutdownServer() {
 	if err := server.Shutdown(timeoutCtx); err != nil {
 		global.Log.Panicln("服务关闭出错[oijojiud]", err)
 	}
+
+	//等待处理中的任务完成, 超时未完成的任务交回队列由其他实例继续处理
+	queueCtx, queueCancel := context.WithTimeout(context.Background(), time.Duration(global.Config.JobQueue.ShutdownTimeout)*time.Second)
+	defer queueCancel()
+	service.Service.UserServiceGroup.JobQueue.Shutdown(queueCtx)
 	global.Log.Infoln("服务退出成功")
 }
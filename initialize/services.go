@@ -8,7 +8,6 @@ import (
 	"os"
 	"time"
 
-	"gitee.com/taoJie_1/mall-agent/dao"
 	"gitee.com/taoJie_1/mall-agent/global"
 	"gitee.com/taoJie_1/mall-agent/internal/chatwoot"
 	"gitee.com/taoJie_1/mall-agent/internal/embedding"
@@ -16,7 +15,9 @@ import (
 	"gitee.com/taoJie_1/mall-agent/internal/mcp"
 	"gitee.com/taoJie_1/mall-agent/internal/oss"
 	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/internal/transcription"
 	"gitee.com/taoJie_1/mall-agent/internal/vector"
+	"gitee.com/taoJie_1/mall-agent/internal/vision"
 	"gitee.com/taoJie_1/mall-agent/model/enum"
 	"gitee.com/taoJie_1/mall-agent/utils"
 	"github.com/sashabaranov/go-openai"
@@ -50,6 +51,14 @@ type CustomJSONFormatter struct {
 
 func (f *CustomJSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	entry.Time = entry.Time.In(global.Tz)
+	if global.Config.Pii.MaskLogs {
+		entry.Message = utils.MaskPII(entry.Message)
+		for k, v := range entry.Data {
+			if str, ok := v.(string); ok {
+				entry.Data[k] = utils.MaskPII(str)
+			}
+		}
+	}
 	return f.JSONFormatter.Format(entry)
 }
 
@@ -131,7 +140,6 @@ func (i *Initializer) initVectorDb() error {
 	}
 
 	global.VectorDb = client
-	dao.App.VectorDb.CollectionName = global.Config.VectorDb.CollectionName
 	global.Log.Info("初始化VectorDb服务成功")
 	return nil
 }
@@ -244,6 +252,38 @@ func (i *Initializer) doInitLlmEmbedding() error {
 	return nil
 }
 
+func (i *Initializer) initVision() error {
+	cfg := global.Config.LlmVision
+	if cfg.Url == "" || cfg.Model == "" {
+		// 未配置视觉模型时置空, 图片消息将直接转人工
+		global.VisionService = nil
+		return nil
+	}
+
+	config := openai.DefaultConfig(cfg.Auth)
+	config.BaseURL = cfg.Url
+	config.HTTPClient = &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second}
+	global.VisionService = vision.NewClient(openai.NewClientWithConfig(config), cfg.Model)
+	global.Log.Info("初始化视觉模型服务成功")
+	return nil
+}
+
+func (i *Initializer) initTranscription() error {
+	cfg := global.Config.LlmAudio
+	if cfg.Url == "" || cfg.Model == "" {
+		// 未配置语音识别模型时置空, 语音消息将直接转人工
+		global.TranscriptionService = nil
+		return nil
+	}
+
+	config := openai.DefaultConfig(cfg.Auth)
+	config.BaseURL = cfg.Url
+	config.HTTPClient = &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second}
+	global.TranscriptionService = transcription.NewClient(openai.NewClientWithConfig(config), cfg.Model, cfg.Language)
+	global.Log.Info("初始化语音转写服务成功")
+	return nil
+}
+
 func (i *Initializer) initMcp() error {
 	if len(global.Config.McpServers) == 0 {
 		return nil
@@ -290,3 +330,78 @@ func (i *Initializer) ossClose() error {
 	}
 	return nil
 }
+
+// initTenants 按配置创建各租户的Chatwoot和MCP客户端; 与顶层配置相同的Chatwoot账户、未配置的MCP服务沿用全局客户端
+// 重建时保留同名租户的快捷回复缓存, 避免同步完成前出现空窗
+func (i *Initializer) initTenants() error {
+	previous := make(map[string]*global.Tenant)
+	global.Tenants.RLock()
+	for _, t := range global.Tenants.Data {
+		previous[t.Name] = t
+	}
+	global.Tenants.RUnlock()
+
+	seen := make(map[string]struct{})
+	tenants := make([]*global.Tenant, 0, len(global.Config.Tenants))
+	for _, cfg := range global.Config.Tenants {
+		if cfg.Name == "" {
+			global.Log.Errorf("租户配置缺少 name, 已忽略 (account_id: %d)", cfg.Chatwoot.AccountId)
+			continue
+		}
+		if _, ok := seen[cfg.Name]; ok {
+			global.Log.Errorf("租户名称 '%s' 重复, 已忽略", cfg.Name)
+			continue
+		}
+		seen[cfg.Name] = struct{}{}
+
+		t := &global.Tenant{Name: cfg.Name, Config: cfg, CannedResponses: &global.CannedResponsesMap{Data: make(map[string]string)}}
+		if old, ok := previous[cfg.Name]; ok {
+			t.CannedResponses = old.CannedResponses
+		}
+
+		if cfg.Chatwoot != global.Config.Chatwoot {
+			client := chatwoot.NewClient(cfg.Chatwoot.Url, int(cfg.Chatwoot.AccountId), cfg.Chatwoot.Auth, cfg.Chatwoot.BotAuth, global.Log)
+			// 连接失败也注册租户, 避免其消息被当作默认租户处理
+			if _, err := client.GetAccountDetails(); err != nil {
+				global.Log.Warnf("租户 '%s' 无法连接到Chatwoot服务 (url: %s, account_id: %d): %v", cfg.Name, cfg.Chatwoot.Url, cfg.Chatwoot.AccountId, err)
+			}
+			t.ChatwootClient = client
+		}
+
+		if len(cfg.McpServers) > 0 {
+			client, err := mcp.NewClient(global.Log, cfg.McpServers, global.Version, global.Config.ProjectName)
+			if err != nil {
+				global.Log.Warnf("租户 '%s' 的MCP服务初始化失败, 该租户暂不提供工具: %v", cfg.Name, err)
+			} else {
+				t.McpClient = client
+			}
+		}
+		tenants = append(tenants, t)
+	}
+
+	global.Tenants.Lock()
+	global.Tenants.Data = tenants
+	global.Tenants.Unlock()
+
+	for _, old := range previous {
+		if old.McpClient != nil {
+			_ = old.McpClient.Close()
+		}
+	}
+	if len(tenants) > 0 {
+		global.Log.Infof("初始化 %d 个租户成功", len(tenants))
+	}
+	return nil
+}
+
+func (i *Initializer) tenantsClose() error {
+	global.Tenants.Lock()
+	defer global.Tenants.Unlock()
+	for _, t := range global.Tenants.Data {
+		if t.McpClient != nil {
+			_ = t.McpClient.Close()
+		}
+	}
+	global.Tenants.Data = nil
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -46,6 +47,16 @@ const (
 	ConversationStatusSnoozed ConversationStatus = "snoozed"
 )
 
+// ConversationPriority 会话优先级
+type ConversationPriority string
+
+const (
+	ConversationPriorityUrgent ConversationPriority = "urgent"
+	ConversationPriorityHigh   ConversationPriority = "high"
+	ConversationPriorityMedium ConversationPriority = "medium"
+	ConversationPriorityLow    ConversationPriority = "low"
+)
+
 type ChatwootEvent string
 
 const (
@@ -81,6 +92,14 @@ const (
 	ContentTypeText ContentType = "text"
 	// ContentTypeCards 表示卡片类型消息
 	ContentTypeCards ContentType = "cards"
+	// ContentTypeInputSelect 表示选项类型消息, 用户点选后通过 message_updated 事件回传
+	ContentTypeInputSelect ContentType = "input_select"
+	// ContentTypeForm 表示表单类型消息, 用户提交后通过 message_updated 事件回传
+	ContentTypeForm ContentType = "form"
+	// ContentTypeArticle 表示文章(帮助文档链接)类型消息
+	ContentTypeArticle ContentType = "article"
+	// ContentTypeInputCsat 表示 Chatwoot 自带的满意度调查消息, 用户评分后通过 message_updated 事件回传
+	ContentTypeInputCsat ContentType = "input_csat"
 )
 
 type AccountDetails struct {
@@ -113,9 +132,10 @@ type CreateMessageRequest struct {
 
 // CardAction 定义了卡片中的动作按钮
 type CardAction struct {
-	Type string `json:"type"` // e.g., "link", "buy"
-	Text string `json:"text"`
-	URI  string `json:"uri"`
+	Type    string `json:"type"` // e.g., "link", "postback"
+	Text    string `json:"text"`
+	URI     string `json:"uri,omitempty"`
+	Payload string `json:"payload,omitempty"` // postback 类型按钮点击后以用户身份发送的内容
 }
 
 // CardItem 定义了单个卡片的内容
@@ -131,6 +151,42 @@ type CardContentAttributes struct {
 	Items []CardItem `json:"items"`
 }
 
+// SelectOption 定义了选项消息中的单个选项
+type SelectOption struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// InputSelectContentAttributes 定义了选项消息的 content_attributes 结构
+type InputSelectContentAttributes struct {
+	Items []SelectOption `json:"items"`
+}
+
+// FormItem 定义了表单消息中的单个输入项
+type FormItem struct {
+	Name        string `json:"name"`
+	Label       string `json:"label"`
+	Type        string `json:"type"` // "text", "text_area", "email"
+	Placeholder string `json:"placeholder,omitempty"`
+}
+
+// FormContentAttributes 定义了表单消息的 content_attributes 结构
+type FormContentAttributes struct {
+	Items []FormItem `json:"items"`
+}
+
+// ArticleItem 定义了文章消息中的单篇文章
+type ArticleItem struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Link        string `json:"link"`
+}
+
+// ArticleContentAttributes 定义了文章消息的 content_attributes 结构
+type ArticleContentAttributes struct {
+	Items []ArticleItem `json:"items"`
+}
+
 // 定义了创建私信备注的请求体
 type CreatePrivateNoteRequest struct {
 	Content     string          `json:"content"`
@@ -210,17 +266,62 @@ type Service interface {
 	CreateMessage(conversationID uint, content string) error
 	// 在指定对话中创建一条卡片消息
 	CreateCardMessage(conversationID uint, content string, cardItems []CardItem) error
+	// 在指定对话中创建一条选项消息
+	CreateInputSelectMessage(conversationID uint, content string, options []SelectOption) error
+	// 在指定对话中创建一条表单消息
+	CreateFormMessage(conversationID uint, content string, items []FormItem) error
+	// 在指定对话中创建一条文章消息
+	CreateArticleMessage(conversationID uint, content string, articles []ArticleItem) error
 	// 从Chatwoot API获取指定会话的历史消息
 	GetConversationMessages(accountID, conversationID uint) ([]Message, error)
 	// 获取指定联系人的所有会话
 	GetContactConversations(contactID uint) ([]ConversationSummary, error)
+	// 将会话分配给团队和/或客服, 为0的参数不分配
+	AssignConversation(conversationID uint, teamID, assigneeID uint) error
+	// 为会话追加标签(保留已有标签)
+	AddConversationLabels(conversationID uint, labels []string) error
+	// 设置会话优先级
+	SetConversationPriority(conversationID uint, priority ConversationPriority) error
+	// 获取客服列表, inboxID不为0时只返回该收件箱的成员
+	ListAgents(inboxID uint) ([]Agent, error)
 }
 
 // TransferToHumanRequest 定义了转人工API的请求体
 type TransferToHumanRequest struct {
 	Status ConversationStatus `json:"status"` // "open" 表示转为人工处理
-	// 还可以增加 AssigneeID 或 TeamID 来指定客服或团队
-	// TeamID int `json:"team_id,omitempty"`
+}
+
+// AssignmentRequest 定义了会话分配API的请求体, Chatwoot 优先处理 assignee_id
+type AssignmentRequest struct {
+	TeamID     uint `json:"team_id,omitempty"`
+	AssigneeID uint `json:"assignee_id,omitempty"`
+}
+
+// ConversationLabelsRequest 定义了会话标签API的请求体, 会覆盖会话原有标签
+type ConversationLabelsRequest struct {
+	Labels []string `json:"labels"`
+}
+
+// ConversationLabelsResponse 定义了获取会话标签的响应
+type ConversationLabelsResponse struct {
+	Payload []string `json:"payload"`
+}
+
+// Agent 定义了客服的基本信息
+type Agent struct {
+	ID                 uint   `json:"id"`
+	Name               string `json:"name"`
+	AvailabilityStatus string `json:"availability_status"` // "online", "busy", "offline"
+}
+
+// InboxMembersResponse 定义了获取收件箱成员的响应
+type InboxMembersResponse struct {
+	Payload []Agent `json:"payload"`
+}
+
+// ConversationPriorityRequest 定义了设置会话优先级的请求体
+type ConversationPriorityRequest struct {
+	Priority ConversationPriority `json:"priority"`
 }
 
 // Client 是Chatwoot API的客户端
@@ -384,6 +485,62 @@ func (c *Client) SetConversationStatus(conversationID uint, status ConversationS
 	return c.sendRequest("POST", path, botToken, payload, nil)
 }
 
+func (c *Client) AssignConversation(conversationID uint, teamID, assigneeID uint) error {
+	path := fmt.Sprintf("/api/v1/accounts/%d/conversations/%d/assignments", c.AccountID, conversationID)
+	// 同时指定时需分两次请求, 否则 Chatwoot 只会处理 assignee_id
+	if teamID != 0 {
+		if err := c.sendRequest("POST", path, agentToken, AssignmentRequest{TeamID: teamID}, nil); err != nil {
+			return err
+		}
+	}
+	if assigneeID != 0 {
+		return c.sendRequest("POST", path, agentToken, AssignmentRequest{AssigneeID: assigneeID}, nil)
+	}
+	return nil
+}
+
+func (c *Client) AddConversationLabels(conversationID uint, labels []string) error {
+	path := fmt.Sprintf("/api/v1/accounts/%d/conversations/%d/labels", c.AccountID, conversationID)
+	var existing ConversationLabelsResponse
+	if err := c.sendRequest("GET", path, agentToken, nil, &existing); err != nil {
+		return err
+	}
+
+	merged := existing.Payload
+	for _, label := range labels {
+		if !slices.Contains(merged, label) {
+			merged = append(merged, label)
+		}
+	}
+	if len(merged) == len(existing.Payload) {
+		return nil
+	}
+	return c.sendRequest("POST", path, agentToken, ConversationLabelsRequest{Labels: merged}, nil)
+}
+
+func (c *Client) SetConversationPriority(conversationID uint, priority ConversationPriority) error {
+	path := fmt.Sprintf("/api/v1/accounts/%d/conversations/%d/toggle_priority", c.AccountID, conversationID)
+	return c.sendRequest("POST", path, agentToken, ConversationPriorityRequest{Priority: priority}, nil)
+}
+
+func (c *Client) ListAgents(inboxID uint) ([]Agent, error) {
+	if inboxID == 0 {
+		path := fmt.Sprintf("/api/v1/accounts/%d/agents", c.AccountID)
+		var agents []Agent
+		if err := c.sendRequest("GET", path, agentToken, nil, &agents); err != nil {
+			return nil, err
+		}
+		return agents, nil
+	}
+
+	path := fmt.Sprintf("/api/v1/accounts/%d/inbox_members/%d", c.AccountID, inboxID)
+	var response InboxMembersResponse
+	if err := c.sendRequest("GET", path, agentToken, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Payload, nil
+}
+
 func (c *Client) ToggleTypingStatus(conversationID uint, status string) error {
 	if status != "on" && status != "off" {
 		return fmt.Errorf("无效的输入状态: %s", status)
@@ -409,15 +566,32 @@ func (c *Client) CreateMessage(conversationID uint, content string) error {
 // content 给客服看的文本
 // cardItems 给客户看的卡片
 func (c *Client) CreateCardMessage(conversationID uint, content string, cardItems []CardItem) error {
+	return c.createRichMessage(conversationID, content, ContentTypeCards, CardContentAttributes{Items: cardItems})
+}
+
+// content 选项上方的提示文本
+// options 给客户点选的选项
+func (c *Client) CreateInputSelectMessage(conversationID uint, content string, options []SelectOption) error {
+	return c.createRichMessage(conversationID, content, ContentTypeInputSelect, InputSelectContentAttributes{Items: options})
+}
+
+func (c *Client) CreateFormMessage(conversationID uint, content string, items []FormItem) error {
+	return c.createRichMessage(conversationID, content, ContentTypeForm, FormContentAttributes{Items: items})
+}
+
+func (c *Client) CreateArticleMessage(conversationID uint, content string, articles []ArticleItem) error {
+	return c.createRichMessage(conversationID, content, ContentTypeArticle, ArticleContentAttributes{Items: articles})
+}
+
+// createRichMessage 发送带 content_attributes 的交互式消息
+func (c *Client) createRichMessage(conversationID uint, content string, contentType ContentType, attributes interface{}) error {
 	path := fmt.Sprintf("/api/v1/accounts/%d/conversations/%d/messages", c.AccountID, conversationID)
 	payload := CreateMessageRequest{
-		Content:     content,
-		MessageType: MessageTypeOutgoing,
-		Private:     false,
-		ContentType: ContentTypeCards,
-		ContentAttributes: CardContentAttributes{
-			Items: cardItems,
-		},
+		Content:           content,
+		MessageType:       MessageTypeOutgoing,
+		Private:           false,
+		ContentType:       contentType,
+		ContentAttributes: attributes,
 	}
 	return c.sendRequest("POST", path, botToken, payload, nil)
 }
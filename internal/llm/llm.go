@@ -39,6 +39,24 @@ func NewClient(log *logrus.Logger, clients map[enum.LlmSize]*openai.Client, conf
 	}
 }
 
+type modelsCtxKey struct{}
+
+// WithModels 在上下文中按模型大小覆盖模型名称(如A/B实验), 仍使用该大小模型配置的地址和密钥
+func WithModels(ctx context.Context, models map[string]string) context.Context {
+	if len(models) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, modelsCtxKey{}, models)
+}
+
+// modelName 返回本次请求使用的模型名称, 上下文中有覆盖时优先使用
+func (c *client) modelName(ctx context.Context, size enum.LlmSize, llmConfig *config.Llm) string {
+	if models, ok := ctx.Value(modelsCtxKey{}).(map[string]string); ok && models[string(size)] != "" {
+		return models[string(size)]
+	}
+	return llmConfig.Model
+}
+
 // getLlmConfig 是一个内部辅助函数，用于根据大小获取模型配置
 func (c *client) getLlmConfig(size enum.LlmSize) *config.Llm {
 	for i := range c.llmConfigs {
@@ -102,7 +120,7 @@ func (c *client) ChatCompletionWithHistory(ctx context.Context, size enum.LlmSiz
 	}
 
 	req := openai.ChatCompletionRequest{
-		Model:    llmConfig.Model,
+		Model:    c.modelName(ctx, size, llmConfig),
 		Messages: messages,
 	}
 
@@ -155,7 +173,7 @@ func (c *client) GetCompletion(ctx context.Context, size enum.LlmSize, systemPro
 	}
 
 	req := openai.ChatCompletionRequest{
-		Model:    llmConfig.Model,
+		Model:    c.modelName(ctx, size, llmConfig),
 		Messages: messages,
 	}
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,6 +27,8 @@ type Service interface {
 	GetAvailableToolsWithClient() map[string][]mcp.Tool
 	// GetToolDescriptions 返回一个从工具全名到其描述的映射
 	GetToolDescriptions() map[string]string
+	// IsMutatingTool 判断工具是否有副作用(如退款、取消订单), 有副作用的工具执行前需要用户确认
+	IsMutatingTool(clientName string, toolName string) bool
 	// ExecuteTool 解析并执行来自LLM的工具调用请求
 	ExecuteTool(ctx context.Context, clientName string, toolName string, arguments json.RawMessage) (string, error)
 	// AddOrUpdateClient 添加或更新一个MCP客户端配置，并执行一次性连接以发现工具
@@ -193,6 +196,32 @@ func (c *client) GetToolDescriptions() map[string]string {
 	return descriptions
 }
 
+// 优先使用配置中的显式声明，其次使用MCP工具注解(readOnlyHint)；两者都没有时视为只读
+func (c *client) IsMutatingTool(clientName string, toolName string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if cfg, ok := c.configs[clientName]; ok {
+		if slices.Contains(cfg.MutatingTools, toolName) {
+			return true
+		}
+		if slices.Contains(cfg.ReadOnlyTools, toolName) {
+			return false
+		}
+	}
+
+	// 未知或未声明注解的工具无法判断是否有副作用, 一律按有副作用处理, 宁可多确认一次
+	clientTools, ok := c.tools[clientName]
+	if !ok {
+		return true
+	}
+	tool, ok := clientTools[toolName]
+	if !ok || tool.Annotations == nil {
+		return true
+	}
+	return !tool.Annotations.ReadOnlyHint
+}
+
 // coerceArguments 尝试根据工具的 schema 转换参数类型。
 // 例如，如果 schema 要求一个整数，它会将字符串 "123" 转换为数字 123。
 func (c *client) coerceArguments(arguments json.RawMessage, schema *jsonschema.Schema) (json.RawMessage, error) {
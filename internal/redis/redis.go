@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"gitee.com/taoJie_1/mall-agent/model/common"
@@ -16,34 +17,132 @@ const (
 	KeyLastSyncCannedResponses   = "agent:last_sync_time:canned_responses" // 上次同步快捷回复的时间戳
 	KeyPrefixConversationHistory = "conversation:history:"                 // Redis中存储聊天记录的Key前缀
 	KeyPrefixHistoryLock         = "agent:lock:history:"                   // 获取历史记录的锁,防止缓存击穿
+	KeyPrefixPIIVault            = "agent:pii_vault:"                      // 会话历史中个人信息占位符对应的真实值(哈希: 占位符 -> 真实值), 与会话历史同时过期
 	KeyPrefixTransferGracePeriod = "agent:transfer_grace_period:"          // AI自动转人工后的宽限期Key前缀
 	KeyPrefixHumanModeActive     = "agent:human_mode_active:"              // 人工客服活跃宽限期Key前缀
 	KeyPrefixProductCardSent     = "agent:product_card_sent:"              // 标记商品卡片是否已发送的Key前缀
 	KeyPrefixLastProductSent     = "agent:last_product_sent:"              // 记录会话最后发送的商品ID
 	KeyPrefixLastOrderSent       = "agent:last_order_sent:"                // 记录会话最后发送的订单ID
 	KeyPrefixProductCardLock     = "agent:lock:product_card_sent:"         // 发送卡片的分布式锁
+	KeyPrefixPendingToolCall     = "agent:pending_tool_call:"              // 等待用户确认的工具调用
+	KeyPrefixSubmittedMessage    = "agent:submitted_message:"              // 标记选项/表单消息的提交是否已处理
+	KeyPrefixRoutingContext      = "agent:routing_context:"                // 转人工路由所需的收件箱与分诊结果
+	KeyPrefixToolCallLog         = "agent:tool_call_log:"                  // 会话中已执行的工具调用记录, 用于转人工交接摘要
+	KeyPrefixMessageBatch        = "agent:message_batch:"                  // 聚合窗口内尚未处理的用户消息(列表)
+	KeyPrefixMessageBatchSeq     = "agent:message_batch_seq:"              // 聚合窗口内最新一条消息的序号
+	KeyPrefixTaskOwner           = "agent:task_owner:"                     // 会话当前AI任务的持有者令牌(带租约, 实例宕机后自动过期)
+	KeyPrefixMessageSeq          = "agent:message_seq:"                    // 会话最近一次派发处理的消息序号, 用于丢弃被更新消息取代的任务
+	KeyPrefixJobStep             = "agent:job_step:"                       // 任务重试时复用的步骤标记和结果, 后接 会话:消息序号:步骤
+	KeyJobStream                 = "agent:jobs"                            // 待处理的webhook任务(Stream)
+	KeyJobDelayed                = "agent:jobs:delayed"                    // 延迟执行或等待退避后重试的任务(有序集合, score为到期的毫秒时间戳)
+	KeyJobDeadLetter             = "agent:jobs:dead"                       // 重试耗尽的任务(列表)
+	KeyPrefixRateLimit           = "agent:rate_limit:"                     // 令牌桶限流(哈希: tokens, ts), 后接 contact:/conversation:/model: 维度
+	KeyPrefixRateLimitNotified   = "agent:rate_limit_notified:"            // 限流提示已发送的标记, 避免刷屏
+	KeyGuardDetections           = "agent:guard_detections"                // 提示词注入检测记录(列表), 供人工复核
+	ChannelTaskCancel            = "agent:task_cancel"                     // 跨实例取消AI任务的广播频道
+	KeyScheduledReopen           = "agent:scheduled_reopen"                // 非工作时间转人工、等待上班后重新打开的会话(有序集合, score为时间戳)
+	KeyPrefixPromptVersions      = "agent:prompt_versions:"                // 提示词的所有版本(哈希: 版本号 -> 版本JSON), 后接提示词名称
+	KeyPromptVersionSeq          = "agent:prompt_version_seq"              // 各提示词最新的版本号(哈希: 名称 -> 版本号)
+	KeyPromptActive              = "agent:prompt_active"                   // 各提示词启用的版本号(哈希: 名称 -> 版本号), 不存在表示使用内置版本
+	ChannelPromptReload          = "agent:prompt_reload"                   // 提示词启用版本变更的广播频道, 各实例收到后重新加载
+	KeyPrefixExperimentStats     = "agent:experiment_stats:"               // A/B实验分组的计数(哈希: turns, csat_count, csat_sum), 后接 实验名称:分组名称
+	KeyPrefixExperimentConvs     = "agent:experiment_conversations:"       // A/B实验分组处理过的会话(集合), 后接 实验名称:分组名称
+	KeyPrefixExperimentTransfers = "agent:experiment_transferred:"         // A/B实验分组中转人工的会话(集合), 后接 实验名称:分组名称
+	KeyPrefixAnswerLog           = "agent:answer_log:"                     // 会话中AI发出的回复及其依据的快捷回复, 评价后清空
+	KeyPrefixCsatHumanHandled    = "agent:csat_human_handled:"             // 标记会话在本次评价前经过人工客服处理
+	KeyPrefixCsatAsked           = "agent:csat_asked:"                     // 标记已向会话发送评分选项, 评价后清除
+	KeyCsatRatings               = "agent:csat_ratings"                    // 满意度评价(哈希: 评价消息ID -> 评价JSON), 多租户时后接 :租户名称
+	KeyCsatRatingIDs             = "agent:csat_rating_ids"                 // 满意度评价ID(列表, 最新的在前), 多租户时后接 :租户名称
+	KeyPrefixMiningCandidate     = "agent:mining_candidate:"               // 标记会话中有人工客服回复, 解决后进入挖掘队列
+	KeyMiningQueue               = "agent:mining_queue"                    // 待挖掘的已解决会话(有序集合, score为解决时间戳)
+	KeyKnowledgeSuggestions      = "agent:knowledge_suggestions"           // 知识条目建议(哈希: 建议ID -> 建议JSON), 多租户时后接 :租户名称
+	KeyUnansweredQuestions       = "agent:unanswered_questions"            // 待聚类的未能回答的问题(列表), 多租户时后接 :租户名称
+	KeyCoverageGaps              = "agent:coverage_gaps"                   // 知识库空白(哈希: 类别ID -> 类别JSON), 多租户时后接 :租户名称
+	KeyCoverageGapLock           = "agent:lock:coverage_gaps"              // 知识库空白聚类任务的分布式锁
+	KeyKnowledgeMiningLock       = "agent:lock:knowledge_mining"           // 知识挖掘任务的分布式锁, 避免多实例同时更新建议
+	KeyKnowledgeImportJob        = "agent:knowledge_import"                // 最近一次知识库导入任务的进度(JSON), 多租户时后接 :租户名称
+	KeyKnowledgeImportLock       = "agent:lock:knowledge_import"           // 知识库导入任务的分布式锁, 同一租户同时只允许一个导入, 多租户时后接 :租户名称
 )
 
 var ErrNil = redis.Nil
 
+// Z 和 ZRangeBy 供调用方构造有序集合操作参数, 避免直接依赖 go-redis
+type (
+	Z        = redis.Z
+	ZRangeBy = redis.ZRangeBy
+	PubSub   = redis.PubSub
+
+	XAddArgs       = redis.XAddArgs
+	XReadGroupArgs = redis.XReadGroupArgs
+	XAutoClaimArgs = redis.XAutoClaimArgs
+	XMessage       = redis.XMessage
+)
+
 // Service 定义了Redis操作的接口
 type Service interface {
 	Close() error
 	Get(ctx context.Context, key string) *redis.StringCmd
+	GetDel(ctx context.Context, key string) *redis.StringCmd
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	HGet(ctx context.Context, key, field string) *redis.StringCmd
 	HGetAll(ctx context.Context, key string) *redis.StringStringMapCmd
 	HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
 	HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd
+	HIncrBy(ctx context.Context, key, field string, incr int64) *redis.IntCmd
+	SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	SCard(ctx context.Context, key string) *redis.IntCmd
 	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
 	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	ZAddNX(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd
+	ZAddXX(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd
+	ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.StringSliceCmd
+	ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	LPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
+	LTrim(ctx context.Context, key string, start, stop int64) *redis.StatusCmd
+	LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	RPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
+	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+	XDel(ctx context.Context, stream string, ids ...string) *redis.IntCmd
+	XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redis.StatusCmd
+	XReadGroup(ctx context.Context, a *redis.XReadGroupArgs) *redis.XStreamSliceCmd
+	XAck(ctx context.Context, stream, group string, ids ...string) *redis.IntCmd
+	XAutoClaim(ctx context.Context, a *redis.XAutoClaimArgs) *redis.XAutoClaimCmd
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
 	Ping(ctx context.Context) *redis.StatusCmd
+	// 以下会话维度的方法中, conversation 为带租户的会话标识, 见 global.Tenant.ConversationScope
+
 	// 从Redis获取指定会话的聊天记录
-	GetConversationHistory(ctx context.Context, conversationID uint) ([]common.LlmMessage, error)
+	GetConversationHistory(ctx context.Context, conversation string) ([]common.LlmMessage, error)
 	// 将聊天记录保存到Redis，并设置过期时间
-	SetConversationHistory(ctx context.Context, conversationID uint, history []common.LlmMessage, ttl time.Duration) error
+	SetConversationHistory(ctx context.Context, conversation string, history []common.LlmMessage, ttl time.Duration) error
 	// 向Redis中指定会话的聊天记录追加一条或多条新消息，并重置过期时间
-	AppendToConversationHistory(ctx context.Context, conversationID uint, ttl time.Duration, newMessages ...common.LlmMessage) error
+	AppendToConversationHistory(ctx context.Context, conversation string, ttl time.Duration, newMessages ...common.LlmMessage) error
+	// 获取会话历史的个人信息占位符映射(占位符 -> 真实值)
+	GetPIIVault(ctx context.Context, conversation string) (map[string]string, error)
+	// 写入新增的占位符并刷新过期时间; 占位符已对应其他值时不写入并返回 false
+	SavePIIVault(ctx context.Context, conversation string, values map[string]string, ttl time.Duration) (bool, error)
+	// 将一条消息放入会话的聚合列表, 返回该消息的序号
+	PushMessageBatch(ctx context.Context, conversation string, message string, ttl time.Duration) (int64, error)
+	// 仅当 seq 仍是最新序号时取出并清空聚合列表, 否则返回 nil
+	PopMessageBatch(ctx context.Context, conversation string, seq int64) ([]string, error)
+	// 原子地取出并移除列表末尾(最早加入)的至多 n 个元素
+	PopListTail(ctx context.Context, key string, n int64) ([]string, error)
+	// 原子地将有序集合中的成员移入Stream(作为 field 字段的值), 成员已被其他实例移走时返回 false
+	MoveToStream(ctx context.Context, zset, member, stream, field string, maxLen int64) (bool, error)
+	// 为会话分配下一个消息序号, 并刷新过期时间
+	NextMessageSeq(ctx context.Context, conversation string, ttl time.Duration) (int64, error)
+	// 获取会话最近分配的消息序号, 不存在时返回0
+	LatestMessageSeq(ctx context.Context, conversation string) (int64, error)
+	// 将会话的AI任务租约交给 token, 返回被取代的旧令牌(没有则为空)
+	AcquireTaskLease(ctx context.Context, conversation string, token string, ttl time.Duration) (string, error)
+	// 仅当租约仍属于 token 时续期, 返回是否续期成功
+	RenewTaskLease(ctx context.Context, conversation string, token string, ttl time.Duration) (bool, error)
+	// 仅当租约仍属于 token 时释放
+	ReleaseTaskLease(ctx context.Context, conversation string, token string) error
+	// 从令牌桶中取一个令牌, rate 为每秒补充的令牌数, burst 为桶容量; 不足时返回需等待的时间
+	TakeToken(ctx context.Context, key string, rate float64, burst int64) (allowed bool, remaining float64, retryAfter time.Duration, err error)
 }
 
 type client struct {
@@ -78,6 +177,10 @@ func (c *client) Get(ctx context.Context, key string) *redis.StringCmd {
 	return c.rdb.Get(ctx, key)
 }
 
+func (c *client) GetDel(ctx context.Context, key string) *redis.StringCmd {
+	return c.rdb.GetDel(ctx, key)
+}
+
 func (c *client) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
 	return c.rdb.Set(ctx, key, value, expiration)
 }
@@ -86,6 +189,10 @@ func (c *client) Del(ctx context.Context, keys ...string) *redis.IntCmd {
 	return c.rdb.Del(ctx, keys...)
 }
 
+func (c *client) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	return c.rdb.HGet(ctx, key, field)
+}
+
 func (c *client) HGetAll(ctx context.Context, key string) *redis.StringStringMapCmd {
 	return c.rdb.HGetAll(ctx, key)
 }
@@ -106,12 +213,88 @@ func (c *client) Expire(ctx context.Context, key string, expiration time.Duratio
 	return c.rdb.Expire(ctx, key, expiration)
 }
 
+func (c *client) ZAddNX(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd {
+	return c.rdb.ZAddNX(ctx, key, members...)
+}
+
+func (c *client) ZAddXX(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd {
+	return c.rdb.ZAddXX(ctx, key, members...)
+}
+
+func (c *client) ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.StringSliceCmd {
+	return c.rdb.ZRangeByScore(ctx, key, opt)
+}
+
+func (c *client) ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	return c.rdb.ZRem(ctx, key, members...)
+}
+
+func (c *client) LPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	return c.rdb.LPush(ctx, key, values...)
+}
+
+func (c *client) LTrim(ctx context.Context, key string, start, stop int64) *redis.StatusCmd {
+	return c.rdb.LTrim(ctx, key, start, stop)
+}
+
+func (c *client) HIncrBy(ctx context.Context, key, field string, incr int64) *redis.IntCmd {
+	return c.rdb.HIncrBy(ctx, key, field, incr)
+}
+
+func (c *client) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	return c.rdb.SAdd(ctx, key, members...)
+}
+
+func (c *client) SCard(ctx context.Context, key string) *redis.IntCmd {
+	return c.rdb.SCard(ctx, key)
+}
+
+func (c *client) LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	return c.rdb.LRange(ctx, key, start, stop)
+}
+
+func (c *client) RPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	return c.rdb.RPush(ctx, key, values...)
+}
+
+func (c *client) XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd {
+	return c.rdb.XAdd(ctx, a)
+}
+
+func (c *client) XDel(ctx context.Context, stream string, ids ...string) *redis.IntCmd {
+	return c.rdb.XDel(ctx, stream, ids...)
+}
+
+func (c *client) XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redis.StatusCmd {
+	return c.rdb.XGroupCreateMkStream(ctx, stream, group, start)
+}
+
+func (c *client) XReadGroup(ctx context.Context, a *redis.XReadGroupArgs) *redis.XStreamSliceCmd {
+	return c.rdb.XReadGroup(ctx, a)
+}
+
+func (c *client) XAck(ctx context.Context, stream, group string, ids ...string) *redis.IntCmd {
+	return c.rdb.XAck(ctx, stream, group, ids...)
+}
+
+func (c *client) XAutoClaim(ctx context.Context, a *redis.XAutoClaimArgs) *redis.XAutoClaimCmd {
+	return c.rdb.XAutoClaim(ctx, a)
+}
+
+func (c *client) Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd {
+	return c.rdb.Publish(ctx, channel, message)
+}
+
+func (c *client) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return c.rdb.Subscribe(ctx, channels...)
+}
+
 func (c *client) Ping(ctx context.Context) *redis.StatusCmd {
 	return c.rdb.Ping(ctx)
 }
 
-func (c *client) GetConversationHistory(ctx context.Context, conversationID uint) ([]common.LlmMessage, error) {
-	key := fmt.Sprintf("%s%d", KeyPrefixConversationHistory, conversationID)
+func (c *client) GetConversationHistory(ctx context.Context, conversation string) ([]common.LlmMessage, error) {
+	key := KeyPrefixConversationHistory + conversation
 	val, err := c.rdb.Get(ctx, key).Result()
 	if err == redis.Nil {
 		return nil, nil // 缓存未命中
@@ -127,8 +310,8 @@ func (c *client) GetConversationHistory(ctx context.Context, conversationID uint
 	return history, nil
 }
 
-func (c *client) SetConversationHistory(ctx context.Context, conversationID uint, history []common.LlmMessage, ttl time.Duration) error {
-	key := fmt.Sprintf("%s%d", KeyPrefixConversationHistory, conversationID)
+func (c *client) SetConversationHistory(ctx context.Context, conversation string, history []common.LlmMessage, ttl time.Duration) error {
+	key := KeyPrefixConversationHistory + conversation
 	jsonBytes, err := json.Marshal(history)
 	if err != nil {
 		return fmt.Errorf("序列化聊天记录失败: %w", err)
@@ -136,8 +319,8 @@ func (c *client) SetConversationHistory(ctx context.Context, conversationID uint
 	return c.rdb.Set(ctx, key, jsonBytes, ttl).Err()
 }
 
-func (c *client) AppendToConversationHistory(ctx context.Context, conversationID uint, ttl time.Duration, newMessages ...common.LlmMessage) error {
-	key := fmt.Sprintf("%s%d", KeyPrefixConversationHistory, conversationID)
+func (c *client) AppendToConversationHistory(ctx context.Context, conversation string, ttl time.Duration, newMessages ...common.LlmMessage) error {
+	key := KeyPrefixConversationHistory + conversation
 
 	// 使用事务确保原子性
 	err := c.rdb.Watch(ctx, func(tx *redis.Tx) error {
@@ -168,3 +351,225 @@ func (c *client) AppendToConversationHistory(ctx context.Context, conversationID
 	}
 	return nil
 }
+
+func (c *client) GetPIIVault(ctx context.Context, conversation string) (map[string]string, error) {
+	values, err := c.rdb.HGetAll(ctx, KeyPrefixPIIVault+conversation).Result()
+	if err != nil {
+		return nil, fmt.Errorf("获取个人信息占位符映射失败: %w", err)
+	}
+	return values, nil
+}
+
+// savePIIVaultScript 并发写入时同一占位符可能被分配给不同的值, 检查与写入必须原子执行; 最后一个参数为过期毫秒数
+var savePIIVaultScript = redis.NewScript(`
+for i = 1, #ARGV - 1, 2 do
+	local current = redis.call('HGET', KEYS[1], ARGV[i])
+	if current and current ~= ARGV[i + 1] then
+		return 0
+	end
+end
+if #ARGV > 1 then
+	redis.call('HSET', KEYS[1], unpack(ARGV, 1, #ARGV - 1))
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[#ARGV])
+return 1
+`)
+
+func (c *client) SavePIIVault(ctx context.Context, conversation string, values map[string]string, ttl time.Duration) (bool, error) {
+	args := make([]interface{}, 0, len(values)*2+1)
+	for holder, value := range values {
+		args = append(args, holder, value)
+	}
+	args = append(args, ttl.Milliseconds())
+	saved, err := savePIIVaultScript.Run(ctx, c.rdb, []string{KeyPrefixPIIVault + conversation}, args...).Int()
+	if err != nil {
+		return false, fmt.Errorf("保存个人信息占位符映射失败: %w", err)
+	}
+	return saved == 1, nil
+}
+
+// popMessageBatchScript 校验序号与取出列表必须原子执行, 避免多实例重复处理同一批消息
+var popMessageBatchScript = redis.NewScript(`
+if redis.call('GET', KEYS[2]) ~= ARGV[1] then
+	return false
+end
+local messages = redis.call('LRANGE', KEYS[1], 0, -1)
+redis.call('DEL', KEYS[1])
+return messages
+`)
+
+func (c *client) PushMessageBatch(ctx context.Context, conversation string, message string, ttl time.Duration) (int64, error) {
+	listKey := KeyPrefixMessageBatch + conversation
+	seqKey := KeyPrefixMessageBatchSeq + conversation
+
+	var seqCmd *redis.IntCmd
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, listKey, message)
+		pipe.Expire(ctx, listKey, ttl)
+		seqCmd = pipe.Incr(ctx, seqKey)
+		pipe.Expire(ctx, seqKey, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("写入消息聚合列表失败: %w", err)
+	}
+	return seqCmd.Val(), nil
+}
+
+func (c *client) PopMessageBatch(ctx context.Context, conversation string, seq int64) ([]string, error) {
+	listKey := KeyPrefixMessageBatch + conversation
+	seqKey := KeyPrefixMessageBatchSeq + conversation
+
+	messages, err := popMessageBatchScript.Run(ctx, c.rdb, []string{listKey, seqKey}, seq).StringSlice()
+	if err == redis.Nil {
+		return nil, nil // 窗口内有更新的消息
+	}
+	if err != nil {
+		return nil, fmt.Errorf("取出消息聚合列表失败: %w", err)
+	}
+	return messages, nil
+}
+
+// popListTailScript 读取与移除列表末尾的元素必须原子执行, 避免与并发的 LPush/LTrim 交错时丢失或重复取出
+var popListTailScript = redis.NewScript(`
+local values = redis.call('LRANGE', KEYS[1], -tonumber(ARGV[1]), -1)
+if #values > 0 then
+	redis.call('LTRIM', KEYS[1], 0, -#values - 1)
+end
+return values
+`)
+
+func (c *client) PopListTail(ctx context.Context, key string, n int64) ([]string, error) {
+	values, err := popListTailScript.Run(ctx, c.rdb, []string{key}, n).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("取出列表 %s 末尾元素失败: %w", key, err)
+	}
+	return values, nil
+}
+
+// moveToStreamScript 移出有序集合与写入Stream必须原子执行, 避免写入失败时丢失成员或多实例重复写入
+var moveToStreamScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[3], '*', ARGV[2], ARGV[1])
+return 1
+`)
+
+func (c *client) MoveToStream(ctx context.Context, zset, member, stream, field string, maxLen int64) (bool, error) {
+	moved, err := moveToStreamScript.Run(ctx, c.rdb, []string{zset, stream}, member, field, maxLen).Int()
+	if err != nil {
+		return false, fmt.Errorf("将 %s 的成员移入 %s 失败: %w", zset, stream, err)
+	}
+	return moved == 1, nil
+}
+
+func (c *client) NextMessageSeq(ctx context.Context, conversation string, ttl time.Duration) (int64, error) {
+	key := KeyPrefixMessageSeq + conversation
+	var seqCmd *redis.IntCmd
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		seqCmd = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("分配消息序号失败: %w", err)
+	}
+	return seqCmd.Val(), nil
+}
+
+func (c *client) LatestMessageSeq(ctx context.Context, conversation string) (int64, error) {
+	seq, err := c.rdb.Get(ctx, KeyPrefixMessageSeq+conversation).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("获取消息序号失败: %w", err)
+	}
+	return seq, nil
+}
+
+var (
+	acquireTaskLeaseScript = redis.NewScript(`
+local old = redis.call('GET', KEYS[1])
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return old
+`)
+	renewTaskLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+	releaseTaskLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+func (c *client) AcquireTaskLease(ctx context.Context, conversation string, token string, ttl time.Duration) (string, error) {
+	key := KeyPrefixTaskOwner + conversation
+	old, err := acquireTaskLeaseScript.Run(ctx, c.rdb, []string{key}, token, ttl.Milliseconds()).Text()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("获取AI任务租约失败: %w", err)
+	}
+	return old, nil
+}
+
+func (c *client) RenewTaskLease(ctx context.Context, conversation string, token string, ttl time.Duration) (bool, error) {
+	key := KeyPrefixTaskOwner + conversation
+	renewed, err := renewTaskLeaseScript.Run(ctx, c.rdb, []string{key}, token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("续期AI任务租约失败: %w", err)
+	}
+	return renewed == 1, nil
+}
+
+func (c *client) ReleaseTaskLease(ctx context.Context, conversation string, token string) error {
+	key := KeyPrefixTaskOwner + conversation
+	if err := releaseTaskLeaseScript.Run(ctx, c.rdb, []string{key}, token).Err(); err != nil {
+		return fmt.Errorf("释放AI任务租约失败: %w", err)
+	}
+	return nil
+}
+
+// takeTokenScript 按距上次取令牌的时间补充令牌后尝试扣减一个, 返回 {是否成功, 剩余令牌, 需等待毫秒}
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens), wait}
+`)
+
+func (c *client) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, float64, time.Duration, error) {
+	res, err := takeTokenScript.Run(ctx, c.rdb, []string{key}, rate, burst, time.Now().UnixMilli()).Slice()
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("令牌桶限流失败: %w", err)
+	}
+	if len(res) != 3 {
+		return false, 0, 0, fmt.Errorf("令牌桶返回格式错误: %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	remaining, _ := strconv.ParseFloat(fmt.Sprint(res[1]), 64)
+	wait, _ := res[2].(int64)
+	return allowed == 1, remaining, time.Duration(wait) * time.Millisecond, nil
+}
@@ -0,0 +1,151 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestClient 连接 TEST_REDIS_ADDR 指定的Redis, 未设置时跳过需要Redis的测试
+func newTestClient(t *testing.T) *client {
+	t.Helper()
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("未设置 TEST_REDIS_ADDR, 跳过需要Redis的测试")
+	}
+	service, err := NewClient(addr, os.Getenv("TEST_REDIS_PASSWORD"), 0)
+	if err != nil {
+		t.Fatalf("连接Redis失败: %v", err)
+	}
+	t.Cleanup(func() { _ = service.Close() })
+	return service.(*client)
+}
+
+func TestTakeToken(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	key := fmt.Sprintf("test:rate_limit:%d", time.Now().UnixNano())
+	t.Cleanup(func() { c.Del(context.Background(), key) })
+
+	// 桶容量为3, 每秒补充2个令牌: 前3次立即通过, 第4次需要等待约500毫秒
+	for i := 0; i < 3; i++ {
+		allowed, remaining, _, err := c.TakeToken(ctx, key, 2, 3)
+		if err != nil {
+			t.Fatalf("第 %d 次取令牌失败: %v", i+1, err)
+		}
+		if !allowed {
+			t.Fatalf("第 %d 次取令牌应通过", i+1)
+		}
+		if want := float64(2 - i); remaining < want-0.1 || remaining > want+0.1 {
+			t.Errorf("第 %d 次取令牌后剩余 %.2f, 应约为 %.0f", i+1, remaining, want)
+		}
+	}
+
+	allowed, _, retryAfter, err := c.TakeToken(ctx, key, 2, 3)
+	if err != nil {
+		t.Fatalf("取令牌失败: %v", err)
+	}
+	if allowed {
+		t.Fatal("令牌耗尽后应被限流")
+	}
+	if retryAfter <= 0 || retryAfter > 500*time.Millisecond {
+		t.Errorf("需等待时间 %v 应在 (0, 500ms] 内", retryAfter)
+	}
+
+	time.Sleep(retryAfter + 50*time.Millisecond)
+	if allowed, _, _, err := c.TakeToken(ctx, key, 2, 3); err != nil || !allowed {
+		t.Errorf("补充令牌后应通过, allowed=%v err=%v", allowed, err)
+	}
+
+	// 令牌桶的过期时间覆盖补满所需的时间, 空闲的桶会自动清理
+	if ttl := c.rdb.PTTL(ctx, key).Val(); ttl <= 0 || ttl > 2500*time.Millisecond {
+		t.Errorf("令牌桶过期时间 %v 应在 (0, 2.5s] 内", ttl)
+	}
+}
+
+func TestPopListTail(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	key := fmt.Sprintf("test:list:%d", time.Now().UnixNano())
+	t.Cleanup(func() { c.Del(context.Background(), key) })
+
+	// 新元素从队头加入, 队尾为最早加入的元素
+	c.LPush(ctx, key, "a", "b", "c", "d")
+	values, err := c.PopListTail(ctx, key, 3)
+	if err != nil {
+		t.Fatalf("取出列表末尾元素失败: %v", err)
+	}
+	if fmt.Sprint(values) != "[c b a]" {
+		t.Errorf("取出 %v, 应为 [c b a]", values)
+	}
+	if rest := c.LRange(ctx, key, 0, -1).Val(); fmt.Sprint(rest) != "[d]" {
+		t.Errorf("剩余 %v, 应为 [d]", rest)
+	}
+
+	// 放回队尾后按原顺序再次取出
+	c.RPush(ctx, key, "c", "b", "a")
+	if values, _ := c.PopListTail(ctx, key, 10); fmt.Sprint(values) != "[d c b a]" {
+		t.Errorf("取出 %v, 应为 [d c b a]", values)
+	}
+	if values, err := c.PopListTail(ctx, key, 10); err != nil || len(values) != 0 {
+		t.Errorf("空列表取出 %v, err=%v", values, err)
+	}
+}
+
+func TestSavePIIVault(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	conversation := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	t.Cleanup(func() { c.Del(context.Background(), KeyPrefixPIIVault+conversation) })
+
+	if saved, err := c.SavePIIVault(ctx, conversation, map[string]string{"[手机号_1]": "13812345678"}, time.Minute); err != nil || !saved {
+		t.Fatalf("保存占位符映射 saved=%v err=%v", saved, err)
+	}
+	// 已有占位符对应相同的值时可以重复写入, 对应其他值时整体不写入
+	if saved, _ := c.SavePIIVault(ctx, conversation, map[string]string{"[手机号_1]": "13812345678", "[邮箱_1]": "a@b.com"}, time.Minute); !saved {
+		t.Error("写入相同映射应成功")
+	}
+	if saved, _ := c.SavePIIVault(ctx, conversation, map[string]string{"[手机号_1]": "13987654321", "[邮箱_2]": "c@d.com"}, time.Minute); saved {
+		t.Error("占位符对应其他值时不应写入")
+	}
+
+	values, err := c.GetPIIVault(ctx, conversation)
+	if err != nil {
+		t.Fatalf("读取占位符映射失败: %v", err)
+	}
+	if want := map[string]string{"[手机号_1]": "13812345678", "[邮箱_1]": "a@b.com"}; fmt.Sprint(values) != fmt.Sprint(want) {
+		t.Errorf("映射为 %v, 应为 %v", values, want)
+	}
+	if ttl := c.rdb.PTTL(ctx, KeyPrefixPIIVault+conversation).Val(); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("映射过期时间 %v 应在 (0, 1m] 内", ttl)
+	}
+}
+
+func TestMoveToStream(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	suffix := time.Now().UnixNano()
+	zset, stream := fmt.Sprintf("test:delayed:%d", suffix), fmt.Sprintf("test:stream:%d", suffix)
+	t.Cleanup(func() { c.Del(context.Background(), zset, stream) })
+
+	c.ZAddNX(ctx, zset, &redis.Z{Score: 1, Member: `{"id":1}`})
+	if moved, err := c.MoveToStream(ctx, zset, `{"id":1}`, stream, "job", 100); err != nil || !moved {
+		t.Fatalf("移入Stream moved=%v err=%v", moved, err)
+	}
+	// 已被移走的成员不会重复写入
+	if moved, _ := c.MoveToStream(ctx, zset, `{"id":1}`, stream, "job", 100); moved {
+		t.Error("重复移入应返回 false")
+	}
+
+	messages := c.rdb.XRange(ctx, stream, "-", "+").Val()
+	if len(messages) != 1 || messages[0].Values["job"] != `{"id":1}` {
+		t.Errorf("Stream 内容为 %v, 应只有一条任务", messages)
+	}
+	if n := c.rdb.ZCard(ctx, zset).Val(); n != 0 {
+		t.Errorf("有序集合剩余 %d 个成员, 应为0", n)
+	}
+}
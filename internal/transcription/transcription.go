@@ -0,0 +1,45 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+type client struct {
+	openAIClient *openai.Client
+	modelName    string
+	language     string
+}
+
+type Service interface {
+	// 调用语音识别模型将音频转写为文字, fileName 用于服务端判断音频格式
+	Transcribe(ctx context.Context, fileName string, data []byte) (string, error)
+}
+
+func NewClient(openAIClient *openai.Client, modelName, language string) Service {
+	return &client{
+		openAIClient: openAIClient,
+		modelName:    modelName,
+		language:     language,
+	}
+}
+
+func (c *client) Transcribe(ctx context.Context, fileName string, data []byte) (string, error) {
+	req := openai.AudioRequest{
+		Model:    c.modelName,
+		FilePath: fileName,
+		Reader:   bytes.NewReader(data),
+		Language: c.language,
+		Format:   openai.AudioResponseFormatJSON,
+	}
+
+	resp, err := c.openAIClient.CreateTranscription(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("请求语音识别模型失败: %w", err)
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
@@ -0,0 +1,61 @@
+package vision
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+type client struct {
+	openAIClient *openai.Client
+	modelName    string
+}
+
+type Service interface {
+	// 调用视觉模型描述图片内容(含文字识别)
+	DescribeImage(ctx context.Context, prompt, mimeType string, data []byte) (string, error)
+}
+
+func NewClient(openAIClient *openai.Client, modelName string) Service {
+	return &client{
+		openAIClient: openAIClient,
+		modelName:    modelName,
+	}
+}
+
+func (c *client) DescribeImage(ctx context.Context, prompt, mimeType string, data []byte) (string, error) {
+	dataURL := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
+
+	req := openai.ChatCompletionRequest{
+		Model: c.modelName,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleUser,
+				MultiContent: []openai.ChatMessagePart{
+					{Type: openai.ChatMessagePartTypeText, Text: prompt},
+					{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: dataURL, Detail: openai.ImageURLDetailAuto}},
+				},
+			},
+		},
+		Temperature: 0.2,
+	}
+
+	resp, err := c.openAIClient.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("请求视觉模型失败: %w", err)
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+		return "", errors.New("视觉模型返回了空结果")
+	}
+
+	content := resp.Choices[0].Message.Content
+	// 剥离思考过程标签
+	if parts := strings.SplitN(content, "</think>", 2); len(parts) > 1 {
+		content = parts[1]
+	}
+	return strings.TrimSpace(content), nil
+}
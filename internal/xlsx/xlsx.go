@@ -0,0 +1,321 @@
+// Package xlsx 提供 Excel(.xlsx) 单个工作表的简单读写, 只处理文本内容, 不处理样式和公式
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MaxColumns 读取时允许的最大列数
+const MaxColumns = 64
+
+// maxPartSize 读取时单个XML部件解压后的最大字节数, 防止压缩炸弹
+var maxPartSize int64 = 64 << 20
+
+const (
+	contentTypesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	rootRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	workbookRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	workbookXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+)
+
+// Write 将所有行写入一个只有单个工作表的 xlsx 文件, 单元格均为文本
+func Write(w io.Writer, sheetName string, rows [][]string) error {
+	zw := zip.NewWriter(w)
+
+	var name bytes.Buffer
+	if err := xml.EscapeText(&name, []byte(sheetName)); err != nil {
+		return err
+	}
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypesXML},
+		{"_rels/.rels", rootRelsXML},
+		{"xl/workbook.xml", fmt.Sprintf(workbookXML, name.String())},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+
+	fw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	var sheet bytes.Buffer
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, value := range row {
+			fmt.Fprintf(&sheet, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, columnName(j), i+1)
+			if err := xml.EscapeText(&sheet, []byte(value)); err != nil {
+				return err
+			}
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+	if _, err := fw.Write(sheet.Bytes()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+type richText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (r richText) String() string {
+	if len(r.Runs) == 0 {
+		return r.T
+	}
+	var b strings.Builder
+	for _, run := range r.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+type sheetRow struct {
+	Ref   int `xml:"r,attr"`
+	Cells []struct {
+		Ref    string   `xml:"r,attr"`
+		Type   string   `xml:"t,attr"`
+		Value  string   `xml:"v"`
+		Inline richText `xml:"is"`
+	} `xml:"c"`
+}
+
+// Read 读取 xlsx 文件第一个工作表的所有行, 空行保留为空切片, 行号与 Excel 中的行号一致(第1行下标为0);
+// 行数超过 maxRows 或列数超过 MaxColumns 时返回错误
+func Read(r io.ReaderAt, size int64, maxRows int) ([][]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的xlsx文件: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var sharedStrings []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []richText `xml:"si"`
+		}
+		if err := decodeFile(f, &sst); err != nil {
+			return nil, err
+		}
+		sharedStrings = make([]string, len(sst.Items))
+		for i, item := range sst.Items {
+			sharedStrings[i] = item.String()
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("工作表 %s 不存在", sheetPath)
+	}
+	return readRows(f, sharedStrings, maxRows)
+}
+
+// readRows 逐行解析工作表, 超过行数限制时立即停止, 不会将整个工作表载入内存
+func readRows(f *zip.File, sharedStrings []string, maxRows int) ([][]string, error) {
+	rc, err := openPart(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var rows [][]string
+	decoder := xml.NewDecoder(rc)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", f.Name, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row sheetRow
+		if err := decoder.DecodeElement(&row, &start); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", f.Name, err)
+		}
+
+		index := row.Ref - 1
+		if index < len(rows) {
+			index = len(rows)
+		}
+		if index >= maxRows {
+			return nil, fmt.Errorf("工作表最多 %d 行", maxRows)
+		}
+		for len(rows) <= index {
+			rows = append(rows, nil)
+		}
+		var cells []string
+		for i, cell := range row.Cells {
+			col := i
+			if cell.Ref != "" {
+				col = columnIndex(cell.Ref)
+			}
+			if col < 0 || col >= MaxColumns {
+				return nil, fmt.Errorf("单元格 %q 的列无效, 工作表最多 %d 列", cell.Ref, MaxColumns)
+			}
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(cell.Value)
+				if err != nil || n < 0 || n >= len(sharedStrings) {
+					return nil, fmt.Errorf("单元格 %s 引用的共享字符串无效", cell.Ref)
+				}
+				cells[col] = sharedStrings[n]
+			case "inlineStr":
+				cells[col] = cell.Inline.String()
+			default:
+				cells[col] = cell.Value
+			}
+		}
+		rows[index] = cells
+	}
+}
+
+// firstSheetPath 根据工作簿及其关系文件找到第一个工作表的路径
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var wb struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	wbFile, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", errors.New("不是有效的xlsx文件: 缺少工作簿")
+	}
+	if err := decodeFile(wbFile, &wb); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", errors.New("xlsx文件中没有工作表")
+	}
+	if relsFile, ok := files["xl/_rels/workbook.xml.rels"]; ok {
+		if err := decodeFile(relsFile, &rels); err != nil {
+			return "", err
+		}
+	}
+	for _, rel := range rels.Items {
+		if rel.ID != wb.Sheets[0].ID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "xl/worksheets/sheet1.xml", nil
+}
+
+// decodeFile 解析压缩包中的XML部件
+func decodeFile(f *zip.File, v any) error {
+	rc, err := openPart(f)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", f.Name, err)
+	}
+	return nil
+}
+
+// openPart 打开压缩包中的部件, 解压后超过 maxPartSize 时读取会返回错误, 防止压缩炸弹
+func openPart(f *zip.File) (io.ReadCloser, error) {
+	if f.UncompressedSize64 > uint64(maxPartSize) {
+		return nil, fmt.Errorf("%s 过大", f.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	// 压缩包中记录的大小可能被篡改, 读取时再限制一次
+	return &limitedPart{ReadCloser: rc, name: f.Name, remaining: maxPartSize}, nil
+}
+
+type limitedPart struct {
+	io.ReadCloser
+	name      string
+	remaining int64
+}
+
+func (p *limitedPart) Read(b []byte) (int, error) {
+	if p.remaining <= 0 {
+		return 0, fmt.Errorf("%s 过大", p.name)
+	}
+	if int64(len(b)) > p.remaining {
+		b = b[:p.remaining]
+	}
+	n, err := p.ReadCloser.Read(b)
+	p.remaining -= int64(n)
+	return n, err
+}
+
+// columnName 将从0开始的列号转换为列名, 如 0 -> A, 26 -> AA
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// columnIndex 从单元格引用(如 AB12)中解析出从0开始的列号, 引用中没有列名或列号超过 MaxColumns 时返回-1
+func columnIndex(ref string) int {
+	index := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		index = index*26 + int(ch-'A'+1)
+		if index > MaxColumns {
+			return -1
+		}
+	}
+	return index - 1
+}
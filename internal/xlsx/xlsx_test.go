@@ -0,0 +1,122 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteRead(t *testing.T) {
+	rows := [][]string{
+		{"item_id", "answer", "question", "type"},
+		{"", "7天无理由退货 <含运费> & 说明", "怎么退货", "EXACT"},
+		{"abc", "  保留首尾空格  ", "多行\n问题", ""},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, "知识库", rows); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 10)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("Read() = %q, want %q", got, rows)
+	}
+
+	if _, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 2); err == nil {
+		t.Error("超过行数限制时应返回错误")
+	}
+}
+
+func TestReadSharedStringsAndGaps(t *testing.T) {
+	sheet := `<worksheet><sheetData>` +
+		`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>` +
+		`<row r="3"><c r="B3"><v>42</v></c></row>` +
+		`</sheetData></worksheet>`
+	sst := `<sst><si><t>问题</t></si><si><r><t>答</t></r><r><t>案</t></r></si></sst>`
+	data := buildXlsx(t, map[string]string{"xl/worksheets/sheet1.xml": sheet, "xl/sharedStrings.xml": sst})
+
+	got, err := Read(bytes.NewReader(data), int64(len(data)), 10)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := [][]string{{"问题", "", "答案"}, nil, {"", "42"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %q, want %q", got, want)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts map[string]string
+	}{
+		{"缺少工作簿", map[string]string{"xl/workbook.xml": ""}},
+		{"工作表不是XML", map[string]string{"xl/worksheets/sheet1.xml": "<worksheet><sheetData><row>"}},
+		{"共享字符串越界", map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="s"><v>5</v></c></row></sheetData></worksheet>`}},
+		{"列引用没有列名", map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="12"><v>1</v></c></row></sheetData></worksheet>`}},
+		{"列号过大", map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="ZZZZZZZZZZZZZZ1"><v>1</v></c></row></sheetData></worksheet>`}},
+		{"行号过大", map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1048576"><c r="A1048576"><v>1</v></c></row></sheetData></worksheet>`}},
+		{"没有引用的单元格过多", map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row>` + strings.Repeat(`<c><v>1</v></c>`, MaxColumns+1) + `</row></sheetData></worksheet>`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildXlsx(t, tt.parts)
+			if _, err := Read(bytes.NewReader(data), int64(len(data)), 100); err == nil {
+				t.Error("Read() 应返回错误")
+			}
+		})
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("not a zip")), 9, 100); err == nil {
+		t.Error("非zip文件应返回错误")
+	}
+}
+
+func TestReadPartTooLarge(t *testing.T) {
+	defer func(old int64) { maxPartSize = old }(maxPartSize)
+	maxPartSize = 1024
+
+	sheet := `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>` + strings.Repeat("a", 4096) + `</t></is></c></row></sheetData></worksheet>`
+	data := buildXlsx(t, map[string]string{"xl/worksheets/sheet1.xml": sheet})
+	_, err := Read(bytes.NewReader(data), int64(len(data)), 10)
+	if err == nil || !strings.Contains(err.Error(), "过大") {
+		t.Errorf("Read() error = %v, 应提示部件过大", err)
+	}
+}
+
+// buildXlsx 生成最小的 xlsx 文件, parts 覆盖默认的部件, 内容为空表示删除该部件
+func buildXlsx(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	files := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="s" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": workbookRelsXML,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData/></worksheet>`,
+	}
+	for name, content := range parts {
+		if content == "" {
+			delete(files, name)
+		} else {
+			files[name] = content
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
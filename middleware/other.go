@@ -13,7 +13,7 @@ func CorsHandle() gin.HandlerFunc {
 	config := cors.DefaultConfig()
 	config.AllowOrigins = global.Config.Cors
 	config.AllowMethods = []string{"OPTIONS", "POST", "GET"}
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "authorization", "X-Operator"}
 	return cors.New(config)
 }
 
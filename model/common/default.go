@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"gitee.com/taoJie_1/mall-agent/internal/chatwoot"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
 )
 
 type KeywordsList struct {
@@ -31,6 +32,75 @@ type TriageResult struct {
 	Urgency string `json:"urgency"`
 }
 
+// GuardClassification 定义了注入分类器LLM返回的JSON格式
+// 注意：此结构体的定义必须与 model/enum/enum.go 中的 SystemPromptGuard 提示词所描述的JSON格式保持同步。
+type GuardClassification struct {
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+// KnowledgeDraft 小模型根据人工客服回复起草的知识条目
+type KnowledgeDraft struct {
+	Questions []string `json:"questions"`
+	Answer    string   `json:"answer"`
+}
+
+// GuardDetection 一次提示词注入检测记录, 供人工复核
+type GuardDetection struct {
+	ConversationID uint     `json:"conversation_id"`
+	Source         string   `json:"source"` // "message" 或 "tool:工具名称"
+	Score          float64  `json:"score"`
+	Rules          []string `json:"rules"`  // 命中的启发式规则
+	Reason         string   `json:"reason"` // 分类器给出的判断依据
+	Action         string   `json:"action"`
+	Content        string   `json:"content"`
+	CreatedAt      int64    `json:"created_at"`
+}
+
+// RoutingContext 转人工路由与交接摘要所依据的会话上下文, 分诊前转人工时分诊字段为空
+type RoutingContext struct {
+	InboxID  uint   `json:"inbox_id"`
+	Question string `json:"question"` // 用户最新一条消息, 转人工时可能尚未写入历史记录
+	TriageResult
+}
+
+// ToolCallRecord 会话中一次工具调用的记录
+type ToolCallRecord struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+	Result    string          `json:"result"`
+	CreatedAt int64           `json:"created_at"`
+}
+
+// AnswerRecord 会话中一条发送给用户的回复及其依据的快捷回复, 用于关联满意度评价
+type AnswerRecord struct {
+	Question  string            `json:"question"`
+	Answer    string            `json:"answer"`
+	Source    enum.AnswerSource `json:"source"`
+	SourceIDs []int64           `json:"source_ids,omitempty"` // 知识库检索命中的快捷回复ID
+	CreatedAt int64             `json:"created_at"`
+}
+
+// UnansweredQuestion 一个知识库未能回答的用户问题, 等待聚类
+type UnansweredQuestion struct {
+	ConversationID uint                   `json:"conversation_id"`
+	Question       string                 `json:"question"`
+	Reason         enum.CoverageGapReason `json:"reason"`
+	CreatedAt      int64                  `json:"created_at"`
+}
+
+// CsatRating 一次满意度评价, 附带评价前AI发出的回复
+type CsatRating struct {
+	ID             string           `json:"id"` // 评价消息的ID
+	ConversationID uint             `json:"conversation_id"`
+	Score          int              `json:"score"`
+	Feedback       string           `json:"feedback,omitempty"`
+	Channel        enum.CsatChannel `json:"channel"`
+	HumanHandled   bool             `json:"human_handled"` // 会话是否经过人工客服处理
+	Answers        []AnswerRecord   `json:"answers"`
+	CreatedAt      int64            `json:"created_at"`
+}
+
 // ToolCallParams 定义了LLM返回的工具调用JSON的结构。
 // 注意：此结构体的定义必须与 model/enum/enum.go 中的 SystemPromptToolUser 提示词所描述的JSON格式保持同步。
 type ToolCallParams struct {
@@ -40,3 +110,19 @@ type ToolCallParams struct {
 
 // ToolCalls 定义了一个ToolCallParams的切片，用于表示多个工具调用
 type ToolCalls []ToolCallParams
+
+// PendingToolCall 暂存在Redis中、等待用户确认后才执行的工具调用
+type PendingToolCall struct {
+	Question  string    `json:"question"`   // 触发工具调用的用户问题
+	LlmAnswer string    `json:"llm_answer"` // LLM返回的包含 <tool_code> 的原始回复
+	ToolCalls ToolCalls `json:"tool_calls"`
+	CreatedAt int64     `json:"created_at"`
+}
+
+// InteractiveReply 定义了LLM通过 <interactive> 标签请求发送的交互式消息。
+// 注意：此结构体的定义必须与 model/enum/enum.go 中的 SystemPromptInteractive 提示词所描述的JSON格式保持同步。
+type InteractiveReply struct {
+	Type    chatwoot.ContentType `json:"type"`    // "input_select", "form", "article", "order_card"
+	Content string               `json:"content"` // 显示在交互组件上方的文本
+	Items   json.RawMessage      `json:"items"`   // 按 Type 解析为选项、表单项、文章或订单列表
+}
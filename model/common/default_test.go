@@ -0,0 +1,59 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+)
+
+// interactiveTypes service/user/action.go 中 sendInteractive 支持的交互类型
+var interactiveTypes = []string{"input_select", "form", "article", "order_card"}
+
+// TestInteractivePromptConsistency 单元测试，确保 SystemPromptInteractive 描述的JSON格式与 InteractiveReply 保持同步。
+func TestInteractivePromptConsistency(t *testing.T) {
+	prompt := string(enum.SystemPromptInteractive)
+
+	// 结构体的每个JSON字段都必须在提示词中说明
+	replyType := reflect.TypeOf(InteractiveReply{})
+	for i := 0; i < replyType.NumField(); i++ {
+		tag := strings.Split(replyType.Field(i).Tag.Get("json"), ",")[0]
+		if !strings.Contains(prompt, `"`+tag+`"`) {
+			t.Errorf("SystemPromptInteractive缺少字段 %q 的说明", tag)
+		}
+	}
+
+	// 提示词列出的类型必须与代码支持的类型一致
+	listed := regexp.MustCompile(`(?m)^- "([a-z_]+)":`).FindAllStringSubmatch(prompt, -1)
+	var types []string
+	for _, m := range listed {
+		types = append(types, m[1])
+	}
+	slices.Sort(types)
+	expected := slices.Clone(interactiveTypes)
+	slices.Sort(expected)
+	if !slices.Equal(types, expected) {
+		t.Errorf("SystemPromptInteractive列出的类型 %v 与支持的类型 %v 不一致", types, expected)
+	}
+
+	// 提示词中的示例必须能解析为 InteractiveReply
+	example := regexp.MustCompile(`(?s)<interactive>\s*(\{.*?\})\s*</interactive>`).FindStringSubmatch(prompt)
+	if example == nil {
+		t.Fatal("SystemPromptInteractive缺少 <interactive> 示例")
+	}
+	var reply InteractiveReply
+	if err := json.Unmarshal([]byte(example[1]), &reply); err != nil {
+		t.Fatalf("示例无法解析为InteractiveReply: %v", err)
+	}
+	if !slices.Contains(interactiveTypes, string(reply.Type)) || reply.Content == "" || len(reply.Items) == 0 {
+		t.Errorf("示例解析结果不完整: %+v", reply)
+	}
+	var items []map[string]string
+	if err := json.Unmarshal(reply.Items, &items); err != nil || len(items) == 0 {
+		t.Errorf("示例的items应为对象数组: %v", err)
+	}
+}
@@ -20,6 +20,45 @@ type ChatRequest struct {
 	Sender       Sender                   `json:"sender"`
 	Account      Account                  `json:"account"`
 	Attachments  []Attachment             `json:"attachments"`
+
+	// SubmittedValues 用户通过选项/表单消息提交的结构化数据，仅由 message_updated 事件填充
+	SubmittedValues []SubmittedValue `json:"-"`
+	// IsTranscription 标记 Content 由语音消息转写得到, 可能含有同音字等识别错误
+	IsTranscription bool `json:"-"`
+	// Retryable 标记本次处理来自任务队列, 临时故障时交由队列重试而不是直接转人工
+	Retryable bool `json:"-"`
+	// Seq 派发处理时分配的会话内消息序号, 用于识别被更新消息取代的任务, 以及任务重试时只执行一次的步骤
+	Seq int64 `json:"-"`
+}
+
+// 对应 Chatwoot webhook 的事件 'message_updated' 消息体
+type MessageUpdatedRequest struct {
+	ChatRequest
+	ContentType       chatwoot.ContentType `json:"content_type"`
+	ContentAttributes struct {
+		SubmittedValues []SubmittedValue `json:"submitted_values"`
+	} `json:"content_attributes"`
+}
+
+// CsatSurveyRequest 对应 Chatwoot 满意度调查(input_csat)消息的 'message_updated' 消息体
+type CsatSurveyRequest struct {
+	ChatRequest
+	ContentAttributes struct {
+		SubmittedValues struct {
+			CsatSurveyResponse struct {
+				Rating          int    `json:"rating"`
+				FeedbackMessage string `json:"feedback_message"`
+			} `json:"csat_survey_response"`
+		} `json:"submitted_values"`
+	} `json:"content_attributes"`
+}
+
+// SubmittedValue 代表用户在选项或表单消息中提交的一项数据
+// 选项消息回传 title/value，表单消息回传 name/value
+type SubmittedValue struct {
+	Title string `json:"title,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value"`
 }
 
 // 对应 Chatwoot webhook 的事件 'conversation_created' 消息体
@@ -39,12 +78,18 @@ type WebwidgetTriggeredRequest struct {
 		ID               uint             `json:"id"`
 		CustomAttributes CustomAttributes `json:"custom_attributes"`
 	} `json:"contact"`
+	Account Account `json:"account"`
+	Inbox   struct {
+		ID uint `json:"id"`
+	} `json:"inbox"`
 }
 
 // 对应 Chatwoot webhook 的事件 'conversation_resolved' 消息体
 type ConversationResolvedRequest struct {
 	Event
-	ID uint `json:"id"`
+	ID        uint `json:"id"`
+	AccountID uint `json:"account_id"`
+	InboxID   uint `json:"inbox_id"`
 }
 
 type Event struct {
@@ -69,14 +114,22 @@ type Attachment struct {
 
 // Conversation 代表会话信息
 type Conversation struct {
-	ID     uint                        `json:"id"`
-	Status chatwoot.ConversationStatus `json:"status"`
-	Meta   Meta                        `json:"meta"`
+	ID      uint                        `json:"id"`
+	InboxID uint                        `json:"inbox_id"`
+	Status  chatwoot.ConversationStatus `json:"status"`
+	Meta    Meta                        `json:"meta"`
 }
 
 // Meta 存放会话的元数据
 type Meta struct {
 	Sender Sender `json:"sender"`
+	Team   *Team  `json:"team"` // 会话所属团队, 未分配时为空
+}
+
+// Team 代表元数据中的团队信息
+type Team struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
 }
 
 // Sender 代表元数据中的发送者信息
@@ -113,4 +166,6 @@ type DashboardDetailsRequest struct {
 	UserID    string `json:"user_id,omitempty"`
 	GoodsID   string `json:"goods_id,omitempty"`
 	OrderID   string `json:"order_id,omitempty"`
+	AccountID uint   `json:"account_id,omitempty"` // 会话所属账户, 用于识别租户
+	InboxID   uint   `json:"inbox_id,omitempty"`
 }
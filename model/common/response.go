@@ -66,6 +66,11 @@ func Fail(ctx *gin.Context, message string) {
 	result(ctx, enum.ErrorCode, enum.Msg(message), map[string]interface{}{})
 }
 
+// 带msg和data, 用于需要调用方处理的失败, 如保存知识条目时的冲突报告
+func FailWithData(ctx *gin.Context, message string, data interface{}) {
+	result(ctx, enum.ErrorCode, enum.Msg(message), data)
+}
+
 func FailWs(c chan *Response, message string) {
 	resultWs(c, enum.ErrorCode, enum.Msg(message), map[string]interface{}{})
 }
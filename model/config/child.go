@@ -1,8 +1,10 @@
 package config
 
 type Mcp struct {
-	Url  string `mapstructure:"url" json:"url" yaml:"url"`
-	Auth string `mapstructure:"auth" json:"auth" yaml:"auth"`
+	Url           string   `mapstructure:"url" json:"url" yaml:"url"`
+	Auth          string   `mapstructure:"auth" json:"auth" yaml:"auth"`
+	ReadOnlyTools []string `mapstructure:"read_only_tools" json:"read_only_tools" yaml:"read_only_tools"`
+	MutatingTools []string `mapstructure:"mutating_tools" json:"mutating_tools" yaml:"mutating_tools"`
 }
 
 type Database struct {
@@ -31,6 +33,18 @@ type Chatwoot struct {
 	BotAuth   string `mapstructure:"bot_auth" json:"bot_auth" yaml:"bot_auth"`
 }
 
+// Tenant 一个租户(品牌)的独立配置, 按 webhook 中的账户和收件箱识别; 未配置的项沿用顶层配置
+type Tenant struct {
+	Name                      string         `mapstructure:"name" json:"name" yaml:"name"`
+	InboxIds                  []uint         `mapstructure:"inbox_ids" json:"inbox_ids" yaml:"inbox_ids"`
+	Chatwoot                  Chatwoot       `mapstructure:"chatwoot" json:"chatwoot" yaml:"chatwoot"`
+	CollectionName            string         `mapstructure:"collection_name" json:"collection_name" yaml:"collection_name"`
+	SystemPrompt              string         `mapstructure:"system_prompt" json:"system_prompt" yaml:"system_prompt"`
+	McpServers                map[string]Mcp `mapstructure:"mcp_servers" json:"mcp_servers" yaml:"mcp_servers"`
+	VectorSimilarityThreshold float32        `mapstructure:"vector_similarity_threshold" json:"vector_similarity_threshold" yaml:"vector_similarity_threshold"`
+	VectorSearchMinSimilarity float32        `mapstructure:"vector_search_min_similarity" json:"vector_search_min_similarity" yaml:"vector_search_min_similarity"`
+}
+
 type modelConfig struct {
 	Url     string `mapstructure:"url" json:"url" yaml:"url"`
 	Model   string `mapstructure:"model" json:"model" yaml:"model"`
@@ -49,6 +63,20 @@ type LlmEmbedding struct {
 	BatchTimeout int64 `mapstructure:"batch_timeout" json:"batch_timeout" yaml:"batch_timeout"`
 }
 
+type LlmVision struct {
+	modelConfig    `mapstructure:",squash"`
+	MaxFileSize    int64    `mapstructure:"max_file_size" json:"max_file_size" yaml:"max_file_size"`
+	MaxAttachments int      `mapstructure:"max_attachments" json:"max_attachments" yaml:"max_attachments"`
+	AllowedTypes   []string `mapstructure:"allowed_types" json:"allowed_types" yaml:"allowed_types"`
+}
+
+type LlmAudio struct {
+	modelConfig  `mapstructure:",squash"`
+	Language     string   `mapstructure:"language" json:"language" yaml:"language"`
+	MaxFileSize  int64    `mapstructure:"max_file_size" json:"max_file_size" yaml:"max_file_size"`
+	AllowedTypes []string `mapstructure:"allowed_types" json:"allowed_types" yaml:"allowed_types"`
+}
+
 type VectorDb struct {
 	Url            string `mapstructure:"url" json:"url" yaml:"url"`
 	Auth           string `mapstructure:"auth" json:"auth" yaml:"auth"`
@@ -71,6 +99,156 @@ type Ai struct {
 	KeywordSyncInterval       uint     `mapstructure:"keyword_sync_interval" json:"keyword_sync_interval" yaml:"keyword_sync_interval"`
 	KeywordReloadDebounce     uint     `mapstructure:"keyword_reload_debounce" json:"keyword_reload_debounce" yaml:"keyword_reload_debounce"`
 	TransferKeywords          []string `mapstructure:"transfer_keywords" json:"transfer_keywords" yaml:"transfer_keywords"`
+	ToolConfirmTimeout        int64    `mapstructure:"tool_confirm_timeout" json:"tool_confirm_timeout" yaml:"tool_confirm_timeout"`
+	MessageBatchWindow        int64    `mapstructure:"message_batch_window" json:"message_batch_window" yaml:"message_batch_window"`
+}
+
+type JobQueue struct {
+	Enable           bool  `mapstructure:"enable" json:"enable" yaml:"enable"`
+	Concurrency      int   `mapstructure:"concurrency" json:"concurrency" yaml:"concurrency"`
+	MaxRetries       int   `mapstructure:"max_retries" json:"max_retries" yaml:"max_retries"`
+	RetryBackoff     int64 `mapstructure:"retry_backoff" json:"retry_backoff" yaml:"retry_backoff"`
+	ClaimIdle        int64 `mapstructure:"claim_idle" json:"claim_idle" yaml:"claim_idle"`
+	DeadLetterMaxLen int64 `mapstructure:"dead_letter_max_len" json:"dead_letter_max_len" yaml:"dead_letter_max_len"`
+	ShutdownTimeout  int64 `mapstructure:"shutdown_timeout" json:"shutdown_timeout" yaml:"shutdown_timeout"`
+}
+
+type RateLimit struct {
+	Enable       bool                   `mapstructure:"enable" json:"enable" yaml:"enable"`
+	Action       string                 `mapstructure:"action" json:"action" yaml:"action"`
+	MaxWait      int64                  `mapstructure:"max_wait" json:"max_wait" yaml:"max_wait"`
+	Contact      TokenBucket            `mapstructure:"contact" json:"contact" yaml:"contact"`
+	Conversation TokenBucket            `mapstructure:"conversation" json:"conversation" yaml:"conversation"`
+	Models       map[string]TokenBucket `mapstructure:"models" json:"models" yaml:"models"`
+}
+
+type Guard struct {
+	Enable     bool     `mapstructure:"enable" json:"enable" yaml:"enable"`
+	Classifier bool     `mapstructure:"classifier" json:"classifier" yaml:"classifier"`
+	Threshold  float64  `mapstructure:"threshold" json:"threshold" yaml:"threshold"`
+	Action     string   `mapstructure:"action" json:"action" yaml:"action"`
+	Patterns   []string `mapstructure:"patterns" json:"patterns" yaml:"patterns"`
+	LogMaxLen  int64    `mapstructure:"log_max_len" json:"log_max_len" yaml:"log_max_len"`
+}
+
+// Experiment A/B实验, 会话按ID的稳定哈希分配到各分组
+type Experiment struct {
+	Enable   bool                `mapstructure:"enable" json:"enable" yaml:"enable"`
+	Name     string              `mapstructure:"name" json:"name" yaml:"name"`
+	Variants []ExperimentVariant `mapstructure:"variants" json:"variants" yaml:"variants"`
+}
+
+// ExperimentVariant 实验分组, 未配置的项沿用正常配置
+type ExperimentVariant struct {
+	Name                      string            `mapstructure:"name" json:"name" yaml:"name"`
+	Weight                    uint              `mapstructure:"weight" json:"weight" yaml:"weight"`
+	Prompts                   map[string]int    `mapstructure:"prompts" json:"prompts" yaml:"prompts"`
+	VectorSimilarityThreshold float32           `mapstructure:"vector_similarity_threshold" json:"vector_similarity_threshold" yaml:"vector_similarity_threshold"`
+	VectorSearchMinSimilarity float32           `mapstructure:"vector_search_min_similarity" json:"vector_search_min_similarity" yaml:"vector_search_min_similarity"`
+	Models                    map[string]string `mapstructure:"models" json:"models" yaml:"models"`
+}
+
+// Csat 满意度评价的收集与低分回复统计
+type Csat struct {
+	Enable              bool  `mapstructure:"enable" json:"enable" yaml:"enable"`
+	AskOnResolved       bool  `mapstructure:"ask_on_resolved" json:"ask_on_resolved" yaml:"ask_on_resolved"`
+	IncludeHumanHandled bool  `mapstructure:"include_human_handled" json:"include_human_handled" yaml:"include_human_handled"`
+	LowScore            int   `mapstructure:"low_score" json:"low_score" yaml:"low_score"`
+	LogMaxLen           int64 `mapstructure:"log_max_len" json:"log_max_len" yaml:"log_max_len"`
+}
+
+// KnowledgeMining 从人工客服的回复中挖掘知识库未覆盖的问题, 生成待审核的知识条目建议
+type KnowledgeMining struct {
+	Enable           bool    `mapstructure:"enable" json:"enable" yaml:"enable"`
+	BatchSize        int64   `mapstructure:"batch_size" json:"batch_size" yaml:"batch_size"`
+	ClusterThreshold float32 `mapstructure:"cluster_threshold" json:"cluster_threshold" yaml:"cluster_threshold"`
+	MaxSamples       int     `mapstructure:"max_samples" json:"max_samples" yaml:"max_samples"`
+	MaxSuggestions   int     `mapstructure:"max_suggestions" json:"max_suggestions" yaml:"max_suggestions"`
+}
+
+// Prompt 提示词模板变量的取值, 提示词本身在管理后台维护
+type Prompt struct {
+	ShopName string `mapstructure:"shop_name" json:"shop_name" yaml:"shop_name"`
+	Tone     string `mapstructure:"tone" json:"tone" yaml:"tone"`
+}
+
+type AnswerCheck struct {
+	Enable            bool     `mapstructure:"enable" json:"enable" yaml:"enable"`
+	MaxRegenerations  int      `mapstructure:"max_regenerations" json:"max_regenerations" yaml:"max_regenerations"`
+	BannedPhrases     []string `mapstructure:"banned_phrases" json:"banned_phrases" yaml:"banned_phrases"`
+	PolicyKeywords    []string `mapstructure:"policy_keywords" json:"policy_keywords" yaml:"policy_keywords"`
+	CompensationTerms []string `mapstructure:"compensation_terms" json:"compensation_terms" yaml:"compensation_terms"`
+}
+
+type Pii struct {
+	MaskLogs    bool `mapstructure:"mask_logs" json:"mask_logs" yaml:"mask_logs"`
+	MaskRecords bool `mapstructure:"mask_records" json:"mask_records" yaml:"mask_records"`
+	MaskPrompts bool `mapstructure:"mask_prompts" json:"mask_prompts" yaml:"mask_prompts"`
+}
+
+// TokenBucket 令牌桶参数, Rate 或 Burst 为0表示不限制
+type TokenBucket struct {
+	Rate  float64 `mapstructure:"rate" json:"rate" yaml:"rate"`
+	Burst int64   `mapstructure:"burst" json:"burst" yaml:"burst"`
+}
+
+type Routing struct {
+	Enable         bool          `mapstructure:"enable" json:"enable" yaml:"enable"`
+	FallbackTeamID uint          `mapstructure:"fallback_team_id" json:"fallback_team_id" yaml:"fallback_team_id"`
+	LabelPrefix    string        `mapstructure:"label_prefix" json:"label_prefix" yaml:"label_prefix"`
+	Rules          []RoutingRule `mapstructure:"rules" json:"rules" yaml:"rules"`
+}
+
+type RoutingRule struct {
+	Name       string   `mapstructure:"name" json:"name" yaml:"name"`
+	Intents    []string `mapstructure:"intents" json:"intents" yaml:"intents"`
+	Emotions   []string `mapstructure:"emotions" json:"emotions" yaml:"emotions"`
+	Urgencies  []string `mapstructure:"urgencies" json:"urgencies" yaml:"urgencies"`
+	InboxIDs   []uint   `mapstructure:"inbox_ids" json:"inbox_ids" yaml:"inbox_ids"`
+	TeamID     uint     `mapstructure:"team_id" json:"team_id" yaml:"team_id"`
+	AssigneeID uint     `mapstructure:"assignee_id" json:"assignee_id" yaml:"assignee_id"`
+	Labels     []string `mapstructure:"labels" json:"labels" yaml:"labels"`
+	Priority   string   `mapstructure:"priority" json:"priority" yaml:"priority"`
+}
+
+type BusinessHours struct {
+	Enable                 bool  `mapstructure:"enable" json:"enable" yaml:"enable"`
+	CheckAgentAvailability bool  `mapstructure:"check_agent_availability" json:"check_agent_availability" yaml:"check_agent_availability"`
+	AgentRecheckInterval   int64 `mapstructure:"agent_recheck_interval" json:"agent_recheck_interval" yaml:"agent_recheck_interval"`
+	Schedule               `mapstructure:",squash" yaml:",inline"`
+	Inboxes                map[string]Schedule `mapstructure:"inboxes" json:"inboxes" yaml:"inboxes"`
+}
+
+type Schedule struct {
+	Weekdays []int    `mapstructure:"weekdays" json:"weekdays" yaml:"weekdays"`
+	Start    string   `mapstructure:"start" json:"start" yaml:"start"`
+	End      string   `mapstructure:"end" json:"end" yaml:"end"`
+	Holidays []string `mapstructure:"holidays" json:"holidays" yaml:"holidays"`
+	Workdays []string `mapstructure:"workdays" json:"workdays" yaml:"workdays"`
+}
+
+// CoverageGap 收集知识库无法回答的问题(AI无法回答、无关问题、检索相似度过低), 聚类后按出现次数列出知识库的空白
+type CoverageGap struct {
+	Enable           bool    `mapstructure:"enable" json:"enable" yaml:"enable"`
+	BatchSize        int64   `mapstructure:"batch_size" json:"batch_size" yaml:"batch_size"`
+	ClusterThreshold float32 `mapstructure:"cluster_threshold" json:"cluster_threshold" yaml:"cluster_threshold"`
+	MaxSamples       int     `mapstructure:"max_samples" json:"max_samples" yaml:"max_samples"`
+	MaxClusters      int     `mapstructure:"max_clusters" json:"max_clusters" yaml:"max_clusters"`
+	RetentionDays    int     `mapstructure:"retention_days" json:"retention_days" yaml:"retention_days"`
+}
+
+// KnowledgeConflict 知识条目的冲突检测: 精确匹配关键词重复、标准问题相近但答案不同的语义条目、标准问题偏离种子问题
+type KnowledgeConflict struct {
+	CheckOnUpsert      bool    `mapstructure:"check_on_upsert" json:"check_on_upsert" yaml:"check_on_upsert"`
+	DuplicateThreshold float32 `mapstructure:"duplicate_threshold" json:"duplicate_threshold" yaml:"duplicate_threshold"`
+	DriftThreshold     float32 `mapstructure:"drift_threshold" json:"drift_threshold" yaml:"drift_threshold"`
+}
+
+// AgentAssist 人工客服接管会话期间, AI为客户的每条消息起草回复并以私信备注发给人工客服, 不会发送给客户
+type AgentAssist struct {
+	Enable   bool   `mapstructure:"enable" json:"enable" yaml:"enable"`
+	InboxIDs []uint `mapstructure:"inbox_ids" json:"inbox_ids" yaml:"inbox_ids"`
+	TeamIDs  []uint `mapstructure:"team_ids" json:"team_ids" yaml:"team_ids"`
 }
 
 type Oss struct {
@@ -3,25 +3,43 @@ package config
 import "encoding/json"
 
 type Config struct {
-	Debug            bool           `mapstructure:"debug" json:"debug" yaml:"debug"`
-	ProjectName      string         `mapstructure:"project_name" json:"project_name" yaml:"project_name"`
-	GinAddr          string         `mapstructure:"gin_addr" json:"gin_addr" yaml:"gin_addr"`
-	Domain           string         `mapstructure:"domain" json:"domain" yaml:"domain"`
-	StaticDir        string         `mapstructure:"static_dir" json:"static_dir" yaml:"static_dir"`
-	GinLogPath       string         `mapstructure:"gin_log_path" json:"gin_log_path" yaml:"gin_log_path"`
-	RunLogPath       string         `mapstructure:"run_log_path" json:"run_log_path" yaml:"run_log_path"`
-	LogRetentionDays uint           `mapstructure:"log_retention_days" json:"log_retention_days" yaml:"log_retention_days"`
-	Tz               string         `mapstructure:"tz" json:"tz" yaml:"tz"`
-	Cors             []string       `mapstructure:"cors" json:"cors" yaml:"cors"`
-	Database         Database       `mapstructure:"database" json:"database" yaml:"database"`
-	Redis            Redis          `mapstructure:"redis" json:"redis" yaml:"redis"`
-	Chatwoot         Chatwoot       `mapstructure:"chatwoot" json:"chatwoot" yaml:"chatwoot"`
-	Llm              []Llm          `mapstructure:"llm" json:"llm" yaml:"llm"`
-	LlmEmbedding     LlmEmbedding   `mapstructure:"llm_embedding" json:"llm_embedding" yaml:"llm_embedding"`
-	VectorDb         VectorDb       `mapstructure:"vector_db" json:"vector_db" yaml:"vector_db"`
-	Ai               Ai             `mapstructure:"ai" json:"ai" yaml:"ai"`
-	McpServers       map[string]Mcp `mapstructure:"mcp_servers" json:"mcp_servers" yaml:"mcp_servers"`
-	Oss              Oss            `mapstructure:"oss" json:"oss" yaml:"oss"`
+	Debug             bool              `mapstructure:"debug" json:"debug" yaml:"debug"`
+	ProjectName       string            `mapstructure:"project_name" json:"project_name" yaml:"project_name"`
+	GinAddr           string            `mapstructure:"gin_addr" json:"gin_addr" yaml:"gin_addr"`
+	Domain            string            `mapstructure:"domain" json:"domain" yaml:"domain"`
+	StaticDir         string            `mapstructure:"static_dir" json:"static_dir" yaml:"static_dir"`
+	GinLogPath        string            `mapstructure:"gin_log_path" json:"gin_log_path" yaml:"gin_log_path"`
+	RunLogPath        string            `mapstructure:"run_log_path" json:"run_log_path" yaml:"run_log_path"`
+	LogRetentionDays  uint              `mapstructure:"log_retention_days" json:"log_retention_days" yaml:"log_retention_days"`
+	Tz                string            `mapstructure:"tz" json:"tz" yaml:"tz"`
+	Cors              []string          `mapstructure:"cors" json:"cors" yaml:"cors"`
+	Database          Database          `mapstructure:"database" json:"database" yaml:"database"`
+	Redis             Redis             `mapstructure:"redis" json:"redis" yaml:"redis"`
+	Chatwoot          Chatwoot          `mapstructure:"chatwoot" json:"chatwoot" yaml:"chatwoot"`
+	AttachmentHosts   []string          `mapstructure:"attachment_hosts" json:"attachment_hosts" yaml:"attachment_hosts"`
+	Llm               []Llm             `mapstructure:"llm" json:"llm" yaml:"llm"`
+	LlmEmbedding      LlmEmbedding      `mapstructure:"llm_embedding" json:"llm_embedding" yaml:"llm_embedding"`
+	LlmVision         LlmVision         `mapstructure:"llm_vision" json:"llm_vision" yaml:"llm_vision"`
+	LlmAudio          LlmAudio          `mapstructure:"llm_audio" json:"llm_audio" yaml:"llm_audio"`
+	VectorDb          VectorDb          `mapstructure:"vector_db" json:"vector_db" yaml:"vector_db"`
+	Ai                Ai                `mapstructure:"ai" json:"ai" yaml:"ai"`
+	McpServers        map[string]Mcp    `mapstructure:"mcp_servers" json:"mcp_servers" yaml:"mcp_servers"`
+	Tenants           []Tenant          `mapstructure:"tenants" json:"tenants" yaml:"tenants"`
+	RateLimit         RateLimit         `mapstructure:"rate_limit" json:"rate_limit" yaml:"rate_limit"`
+	JobQueue          JobQueue          `mapstructure:"job_queue" json:"job_queue" yaml:"job_queue"`
+	Guard             Guard             `mapstructure:"guard" json:"guard" yaml:"guard"`
+	Pii               Pii               `mapstructure:"pii" json:"pii" yaml:"pii"`
+	AnswerCheck       AnswerCheck       `mapstructure:"answer_check" json:"answer_check" yaml:"answer_check"`
+	Prompt            Prompt            `mapstructure:"prompt" json:"prompt" yaml:"prompt"`
+	Experiment        Experiment        `mapstructure:"experiment" json:"experiment" yaml:"experiment"`
+	Csat              Csat              `mapstructure:"csat" json:"csat" yaml:"csat"`
+	KnowledgeMining   KnowledgeMining   `mapstructure:"knowledge_mining" json:"knowledge_mining" yaml:"knowledge_mining"`
+	AgentAssist       AgentAssist       `mapstructure:"agent_assist" json:"agent_assist" yaml:"agent_assist"`
+	CoverageGap       CoverageGap       `mapstructure:"coverage_gap" json:"coverage_gap" yaml:"coverage_gap"`
+	KnowledgeConflict KnowledgeConflict `mapstructure:"knowledge_conflict" json:"knowledge_conflict" yaml:"knowledge_conflict"`
+	Routing           Routing           `mapstructure:"routing" json:"routing" yaml:"routing"`
+	BusinessHours     BusinessHours     `mapstructure:"business_hours" json:"business_hours" yaml:"business_hours"`
+	Oss               Oss               `mapstructure:"oss" json:"oss" yaml:"oss"`
 }
 
 // DeepCopy 使用JSON序列化和反序列化实现Config对象的深度拷贝
@@ -0,0 +1,18 @@
+package db
+
+// KnowledgeHistory 知识条目的一次变更记录
+type KnowledgeHistory struct {
+	BaseField
+	Tenant       string `db:"tenant" json:"tenant"`
+	ItemID       string `db:"item_id" json:"item_id"`           // 变更后的条目ID(答案的哈希值), 删除时为被删除的条目ID
+	PrevItemID   string `db:"prev_item_id" json:"prev_item_id"` // 变更前的条目ID, 新增时为空; 修改答案后条目ID会变化
+	Action       string `db:"action" json:"action"`
+	Actor        string `db:"actor" json:"actor"`
+	RestoredFrom uint   `db:"restored_from" json:"restored_from"` // 恢复操作所依据的变更记录ID
+	Diff         string `db:"diff" json:"diff"`                   // JSON格式的 dto.KnowledgeDiff
+	Snapshot     string `db:"snapshot" json:"snapshot"`           // JSON格式的 dto.KnowledgeItem, 删除时为删除前的内容
+}
+
+func (KnowledgeHistory) TableName() string {
+	return "knowledge_history"
+}
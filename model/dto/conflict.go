@@ -0,0 +1,41 @@
+package dto
+
+// ConflictEntry 冲突涉及的一个问题, 保存前检查时待保存的问题 QuestionID 为0
+type ConflictEntry struct {
+	ItemID           string `json:"item_id"`
+	QuestionID       int    `json:"question_id"`
+	Question         string `json:"question"`
+	Type             string `json:"type"`
+	StandardQuestion string `json:"standard_question,omitempty"` // 语义条目生成的标准问题
+	Answer           string `json:"answer"`
+}
+
+// KeywordCollision 多个条目使用了同一个精确匹配关键词, 缓存中只会保留其中一个
+type KeywordCollision struct {
+	Keyword string           `json:"keyword"` // 小写后的关键词
+	Entries []*ConflictEntry `json:"entries"`
+}
+
+// SemanticDuplicate 两个答案不同的语义条目, 标准问题几乎相同
+type SemanticDuplicate struct {
+	Similarity float32          `json:"similarity"`
+	Entries    []*ConflictEntry `json:"entries"`
+}
+
+// QuestionDrift 语义条目生成的标准问题与种子问题差异过大, 检索时可能匹配到无关的问题
+type QuestionDrift struct {
+	Similarity float32        `json:"similarity"`
+	Entry      *ConflictEntry `json:"entry"`
+}
+
+// ConflictReport 知识条目的冲突检测结果
+type ConflictReport struct {
+	KeywordCollisions  []*KeywordCollision  `json:"keyword_collisions"`
+	SemanticDuplicates []*SemanticDuplicate `json:"semantic_duplicates"`
+	QuestionDrifts     []*QuestionDrift     `json:"question_drifts"`
+}
+
+// HasConflicts 是否存在任何冲突
+func (r *ConflictReport) HasConflicts() bool {
+	return len(r.KeywordCollisions) > 0 || len(r.SemanticDuplicates) > 0 || len(r.QuestionDrifts) > 0
+}
@@ -0,0 +1,20 @@
+package dto
+
+// CoverageGap 一类知识库未能回答的相似问题
+type CoverageGap struct {
+	ID        string         `json:"id"`
+	Count     int            `json:"count"`   // 累计出现的次数
+	Reasons   map[string]int `json:"reasons"` // 按原因(unsure/off_topic/low_similarity)统计的次数
+	Samples   []string       `json:"samples"` // 最近的问题示例
+	Embedding []float32      `json:"embedding,omitempty"`
+	FirstSeen int64          `json:"first_seen"`
+	LastSeen  int64          `json:"last_seen"`
+	// Draft 预填的知识条目, 以问题示例作为AI语义问题, 仅在报告中返回
+	Draft *UpsertKnowledgeItemRequest `json:"draft,omitempty"`
+}
+
+// CoverageGapReport 知识库空白报告, 按出现次数从多到少排列
+type CoverageGapReport struct {
+	Total int            `json:"total"` // 类别总数
+	Gaps  []*CoverageGap `json:"gaps"`
+}
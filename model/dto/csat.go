@@ -0,0 +1,40 @@
+package dto
+
+// CsatLowRatedReport 是低分评价及其关联知识条目的统计
+type CsatLowRatedReport struct {
+	LowScore int                  `json:"low_score"` // 不高于该分数视为低分
+	Total    int                  `json:"total"`     // 统计范围内的评价数
+	LowRated int                  `json:"low_rated"` // 其中低分评价数
+	Ratings  []*CsatLowRating     `json:"ratings"`   // 最近的低分评价, 最新的在前
+	Items    []*CsatKnowledgeItem `json:"items"`     // 低分评价涉及的知识条目, 低分次数多的在前
+}
+
+// CsatLowRating 代表一次低分评价
+type CsatLowRating struct {
+	ConversationID uint          `json:"conversation_id"`
+	Score          int           `json:"score"`
+	Feedback       string        `json:"feedback"`
+	Channel        string        `json:"channel"`
+	HumanHandled   bool          `json:"human_handled"`
+	Answers        []*CsatAnswer `json:"answers"` // 评价前AI发出的回复
+	CreatedAt      int64         `json:"created_at"`
+}
+
+// CsatAnswer 代表评价前AI发出的一条回复及其依据的快捷回复
+type CsatAnswer struct {
+	Question         string      `json:"question"`
+	Answer           string      `json:"answer"`
+	Source           string      `json:"source"`             // canned, knowledge 或 llm
+	KnowledgeItemIDs []string    `json:"knowledge_item_ids"` // 依据的知识条目ID(答案内容的哈希值)
+	CannedResponses  []*Question `json:"canned_responses"`   // 依据的快捷回复
+}
+
+// CsatKnowledgeItem 代表低分评价涉及的知识条目
+type CsatKnowledgeItem struct {
+	ID            string      `json:"id"` // 与知识库管理页面中的条目ID一致
+	Answer        string      `json:"answer"`
+	Questions     []*Question `json:"questions"`
+	RatedCount    int         `json:"rated_count"`     // 关联的评价数
+	LowRatedCount int         `json:"low_rated_count"` // 关联的低分评价数
+	AverageScore  float64     `json:"average_score"`   // 关联评价的平均分
+}
@@ -0,0 +1,21 @@
+package dto
+
+// ExperimentReport 是A/B实验各分组的效果对比
+type ExperimentReport struct {
+	Name     string                    `json:"name"`
+	Enable   bool                      `json:"enable"`
+	Variants []*ExperimentVariantStats `json:"variants"`
+}
+
+// ExperimentVariantStats 代表单个实验分组的统计数据
+type ExperimentVariantStats struct {
+	Name           string  `json:"name"`
+	Weight         uint    `json:"weight"`
+	Conversations  int64   `json:"conversations"`   // 处理过的会话数
+	Turns          int64   `json:"turns"`           // 处理过的用户消息数
+	Transferred    int64   `json:"transferred"`     // 转人工的会话数
+	TransferRate   float64 `json:"transfer_rate"`   // 转人工率
+	DeflectionRate float64 `json:"deflection_rate"` // 自助解决率, 即未转人工的会话占比
+	CsatCount      int64   `json:"csat_count"`      // 满意度评价数
+	CsatAverage    float64 `json:"csat_average"`    // 平均满意度评分
+}
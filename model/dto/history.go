@@ -0,0 +1,28 @@
+package dto
+
+// KnowledgeDiff 知识条目一次变更的差异, 问题按类型和内容比较
+type KnowledgeDiff struct {
+	AnswerBefore     string      `json:"answer_before,omitempty"` // 答案未变化时为空
+	AnswerAfter      string      `json:"answer_after,omitempty"`
+	AddedQuestions   []*Question `json:"added_questions,omitempty"`
+	RemovedQuestions []*Question `json:"removed_questions,omitempty"`
+}
+
+// KnowledgeHistory 是知识条目变更记录的列表项
+type KnowledgeHistory struct {
+	ID           uint           `json:"id"`
+	ItemID       string         `json:"item_id"`
+	PrevItemID   string         `json:"prev_item_id,omitempty"`
+	Action       string         `json:"action"`
+	Actor        string         `json:"actor"`
+	RestoredFrom uint           `json:"restored_from,omitempty"`
+	Diff         *KnowledgeDiff `json:"diff"`
+	Snapshot     *KnowledgeItem `json:"snapshot"`
+	CreatedAt    int64          `json:"created_at"`
+}
+
+// RestoreKnowledgeRequest 是恢复历史版本的请求体
+type RestoreKnowledgeRequest struct {
+	// ItemID 要被替换的当前条目ID, 为空时按历史版本新建条目(如恢复已删除的条目)
+	ItemID string `json:"item_id"`
+}
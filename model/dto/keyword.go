@@ -21,6 +21,7 @@ type UpsertKnowledgeItemRequest struct {
 	ID        string      `json:"id"`
 	Answer    string      `json:"answer" binding:"required"`
 	Questions []*Question `json:"questions" binding:"required,min=1,dive"`
+	Force     bool        `json:"force"` // 确认冲突后仍要保存时跳过冲突检查
 }
 
 // GenerateQuestionRequest 是 AI 问题生成助手的请求体。
@@ -0,0 +1,40 @@
+package dto
+
+// PromptVersion 代表提示词的一个版本, 第0版为内置版本
+type PromptVersion struct {
+	Version   int    `json:"version"`
+	Content   string `json:"content"`
+	Note      string `json:"note"`       // 修改说明
+	CreatedAt int64  `json:"created_at"` // 内置版本为0
+}
+
+// PromptItem 是提示词列表中的单个提示词及其当前启用的版本
+type PromptItem struct {
+	Name          string `json:"name"`
+	ActiveVersion int    `json:"active_version"`
+	LatestVersion int    `json:"latest_version"`
+	Content       string `json:"content"` // 启用版本的模板内容(未替换变量)
+}
+
+// CreatePromptVersionRequest 是新增提示词版本的请求体
+type CreatePromptVersionRequest struct {
+	Content  string `json:"content" binding:"required"`
+	Note     string `json:"note"`
+	Activate bool   `json:"activate"` // 是否立即启用
+}
+
+// ActivatePromptRequest 是启用(或回滚到)指定版本的请求体
+type ActivatePromptRequest struct {
+	Version *int `json:"version" binding:"required,min=0"`
+}
+
+// PreviewPromptRequest 是预览提示词的请求体
+type PreviewPromptRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// PreviewPromptResponse 是预览结果: 替换变量后的内容和校验问题
+type PreviewPromptResponse struct {
+	Rendered string   `json:"rendered"`
+	Problems []string `json:"problems"`
+}
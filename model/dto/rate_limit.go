@@ -0,0 +1,17 @@
+package dto
+
+// RateLimitStatus 是限流配置与当前用量的查询结果。
+type RateLimitStatus struct {
+	Enable  bool               `json:"enable"`
+	Action  string             `json:"action"`
+	MaxWait int64              `json:"max_wait"`
+	Buckets []*RateLimitBucket `json:"buckets"`
+}
+
+// RateLimitBucket 代表单个令牌桶的限额与剩余令牌。
+type RateLimitBucket struct {
+	Scope     string  `json:"scope"`     // 维度, 如 contact:12、conversation:34、model:large
+	Rate      float64 `json:"rate"`      // 每秒补充的令牌数
+	Burst     int64   `json:"burst"`     // 桶容量
+	Remaining float64 `json:"remaining"` // 当前剩余令牌
+}
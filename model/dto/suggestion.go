@@ -0,0 +1,28 @@
+package dto
+
+// KnowledgeSuggestion 是从人工客服回复中挖掘出的知识条目建议, 审核通过后才会写入 Chatwoot
+type KnowledgeSuggestion struct {
+	ID        string            `json:"id"`
+	Status    string            `json:"status"`    // pending 或 rejected
+	Questions []string          `json:"questions"` // 小模型归纳的标准问题
+	Answer    string            `json:"answer"`    // 小模型起草的答案
+	Count     int               `json:"count"`     // 该类问题累计出现的次数
+	Samples   []KnowledgeSample `json:"samples"`   // 最近的问答示例
+	Embedding []float32         `json:"embedding,omitempty"`
+	CreatedAt int64             `json:"created_at"`
+	UpdatedAt int64             `json:"updated_at"`
+}
+
+// KnowledgeSample 代表一组客户问题和人工客服的回复
+type KnowledgeSample struct {
+	ConversationID uint   `json:"conversation_id"`
+	Question       string `json:"question"`
+	Answer         string `json:"answer"`
+}
+
+// ApproveSuggestionRequest 是审核通过知识条目建议的请求体, 未填写的字段使用建议中的内容
+type ApproveSuggestionRequest struct {
+	Answer    string      `json:"answer"`
+	Questions []*Question `json:"questions" binding:"omitempty,dive"`
+	Force     bool        `json:"force"` // 跳过冲突检查
+}
@@ -0,0 +1,49 @@
+package dto
+
+// KnowledgeRow 是导入文件中的一行, 每行对应知识条目的一个问题
+type KnowledgeRow struct {
+	Row      int    `json:"row"` // 文件中的行号, 用于提示错误位置
+	ItemID   string `json:"item_id"`
+	Answer   string `json:"answer"`
+	Question string `json:"question"`
+	Type     string `json:"type"`
+}
+
+// ImportRowError 导入文件中一行数据的校验错误, Row 为0表示与具体行无关
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// ImportItemChange 导入会对一个知识条目做的变更
+type ImportItemChange struct {
+	Action string         `json:"action"`            // create 或 update
+	ItemID string         `json:"item_id,omitempty"` // 被更新的现有条目ID
+	Rows   []int          `json:"rows"`
+	Diff   *KnowledgeDiff `json:"diff"`
+	Item   *KnowledgeItem `json:"-"` // 导入后的内容
+}
+
+// ImportPreview 是导入的预览结果(dry-run), 存在错误时不允许导入
+type ImportPreview struct {
+	Rows      int                 `json:"rows"`
+	Created   int                 `json:"created"`
+	Updated   int                 `json:"updated"`
+	Unchanged int                 `json:"unchanged"`
+	Errors    []ImportRowError    `json:"errors"`
+	Changes   []*ImportItemChange `json:"changes"`
+}
+
+// ImportJob 是一次导入任务的进度, 按批次更新
+type ImportJob struct {
+	ID         string   `json:"id"`
+	Status     string   `json:"status"` // running、done 或 interrupted
+	Actor      string   `json:"actor"`
+	Total      int      `json:"total"`     // 需要变更的条目数
+	Processed  int      `json:"processed"` // 已处理的条目数, 含失败的
+	Failed     int      `json:"failed"`
+	Errors     []string `json:"errors"`
+	StartedAt  int64    `json:"started_at"`
+	UpdatedAt  int64    `json:"updated_at"` // 最近一次更新进度的时间, 用于发现中断的任务
+	FinishedAt int64    `json:"finished_at,omitempty"`
+}
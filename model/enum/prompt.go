@@ -0,0 +1,70 @@
+package enum
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var promptVarPattern = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// ValidatePrompt 检查提示词是否满足代码对其输出格式的依赖, 启用新版本前必须通过
+// 例如分诊提示词必须列出代码中定义的所有分类标签, 否则大模型返回的结果将无法被正确识别
+func ValidatePrompt(name PromptName, content string) error {
+	if _, ok := BuiltinPrompts[name]; !ok {
+		return fmt.Errorf("未知的提示词: %s", name)
+	}
+	if strings.TrimSpace(content) == "" {
+		return errors.New("提示词内容不能为空")
+	}
+
+	var errs []error
+	requireAll := func(what string, expected ...string) {
+		for _, e := range expected {
+			if !strings.Contains(content, e) {
+				errs = append(errs, fmt.Errorf("缺少%s: %s", what, e))
+			}
+		}
+	}
+
+	for _, v := range promptVarPattern.FindAllString(content, -1) {
+		switch v {
+		case PromptVarShopName, PromptVarTone, PromptVarDate:
+		case PromptVarTools:
+			if name != PromptToolUser {
+				errs = append(errs, fmt.Errorf("变量 %s 只能用于 %s", v, PromptToolUser))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("未知的变量: %s", v))
+		}
+	}
+
+	switch name {
+	case PromptTriage:
+		// 为了精确匹配, 检查带引号的字符串, 例如 "product_inquiry"
+		requireAll("JSON字段", `"intent"`, `"emotion"`, `"urgency"`)
+		for _, intent := range TriageIntents {
+			requireAll("意图常量", `"`+string(intent)+`"`)
+		}
+		for _, emotion := range TriageEmotions {
+			requireAll("情绪常量", `"`+string(emotion)+`"`)
+		}
+		for _, urgency := range TriageUrgencies {
+			requireAll("紧急度常量", `"`+string(urgency)+`"`)
+		}
+	case PromptToolUser:
+		requireAll("工具调用格式", "<tool_code>", "</tool_code>", `"name"`, `"arguments"`, PromptVarTools)
+	case PromptDefault, PromptRAG, PromptSynthesizeToolResult, PromptReviseAnswer:
+		// 无法回答时依赖该信号转人工
+		requireAll("转人工信号", LlmUnsureTransferSignal)
+	case PromptGuard:
+		requireAll("JSON字段", `"score"`, `"reason"`)
+	case PromptKnowledgeSuggestion:
+		requireAll("JSON字段", `"questions"`, `"answer"`)
+	case PromptInteractive:
+		requireAll("交互组件标签", "<interactive>", "</interactive>")
+	}
+
+	return errors.Join(errs...)
+}
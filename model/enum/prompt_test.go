@@ -8,55 +8,17 @@ import (
 // TestTriagePromptConsistency 单元测试，用于确保分诊台(Triage)的系统提示词中
 // 使用的分类标签与代码中定义的常量保持严格一致。
 // 这可以防止因修改常量而忘记更新提示词导致的潜在BUG。
+// 具体的检查由 ValidatePrompt 完成, 管理后台启用新版本前也会执行同样的检查。
 func TestTriagePromptConsistency(t *testing.T) {
-	prompt := string(SystemPromptTriage)
-
-	// 1. 定义所有需要被检查的常量值
-	intents := []TriageIntent{
-		TriageIntentProductInquiry,
-		TriageIntentOrderInquiry,
-		TriageIntentAfterSales,
-		TriageIntentRequestHuman,
-		TriageIntentOffTopic,
-		TriageIntentOtherInquiry,
-	}
-
-	emotions := []TriageEmotion{
-		TriageEmotionAngry,
-		TriageEmotionFrustrated,
-		TriageEmotionAnxious,
-		TriageEmotionConfused,
-		TriageEmotionNeutral,
-		TriageEmotionPositive,
-	}
-
-	urgencies := []TriageUrgency{
-		TriageUrgencyCritical,
-		TriageUrgencyHigh,
-		TriageUrgencyMedium,
-		TriageUrgencyLow,
-	}
-
-	// 2. 遍历并断言每个常量的值都存在于Prompt中
-	// 为了精确匹配，我们检查带引号的字符串，例如 "product_inquiry"
-	for _, intent := range intents {
-		expectedSubstring := `"` + string(intent) + `"`
-		if !strings.Contains(prompt, expectedSubstring) {
-			t.Errorf("SystemPromptTriage应包含意图常量: %s", expectedSubstring)
-		}
-	}
-
-	for _, emotion := range emotions {
-		expectedSubstring := `"` + string(emotion) + `"`
-		if !strings.Contains(prompt, expectedSubstring) {
-			t.Errorf("SystemPromptTriage应包含情绪常量: %s", expectedSubstring)
-		}
+	if err := ValidatePrompt(PromptTriage, string(SystemPromptTriage)); err != nil {
+		t.Errorf("SystemPromptTriage未通过校验: %v", err)
 	}
 
-	for _, urgency := range urgencies {
-		expectedSubstring := `"` + string(urgency) + `"`
-		if !strings.Contains(prompt, expectedSubstring) {
-			t.Errorf("SystemPromptTriage应包含紧急度常量: %s", expectedSubstring)
+	// 缺少任意一个常量都应校验失败
+	for _, intent := range TriageIntents {
+		prompt := strings.ReplaceAll(string(SystemPromptTriage), `"`+string(intent)+`"`, "")
+		if err := ValidatePrompt(PromptTriage, prompt); err == nil {
+			t.Errorf("缺少意图常量 %s 的分诊提示词应校验失败", intent)
 		}
 	}
 }
@@ -66,19 +28,22 @@ func TestTriagePromptConsistency(t *testing.T) {
 func TestSystemPromptToolUserConsistency(t *testing.T) {
 	prompt := string(SystemPromptToolUser)
 
-	// 检查关键标签和占位符
-	expectedStrings := []string{
-		"<tool_code>",
-		"</tool_code>",
-		`"name"`,
-		`"arguments"`,
-		`"default.查询物流"`,
-		"{tools}",
+	if err := ValidatePrompt(PromptToolUser, prompt); err != nil {
+		t.Errorf("SystemPromptToolUser未通过校验: %v", err)
 	}
+	if !strings.Contains(prompt, `"mall.query_order"`) {
+		t.Errorf("SystemPromptToolUser应包含字符串: %s", `"mall.query_order"`)
+	}
+	if err := ValidatePrompt(PromptToolUser, strings.ReplaceAll(prompt, PromptVarTools, "")); err == nil {
+		t.Errorf("缺少 %s 的工具调用提示词应校验失败", PromptVarTools)
+	}
+}
 
-	for _, expected := range expectedStrings {
-		if !strings.Contains(prompt, expected) {
-			t.Errorf("SystemPromptToolUser应包含字符串: %s", expected)
+// TestBuiltinPromptsValid 所有内置提示词都必须通过校验, 否则无法回滚到内置版本
+func TestBuiltinPromptsValid(t *testing.T) {
+	for name, prompt := range BuiltinPrompts {
+		if err := ValidatePrompt(name, string(prompt)); err != nil {
+			t.Errorf("内置提示词 %s 未通过校验: %v", name, err)
 		}
 	}
 }
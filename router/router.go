@@ -62,8 +62,31 @@ func Start(ginServer *gin.Engine) {
 				keywordRoutes.DELETE("/:id", controller.Api.AdminApiGroup.KeywordApi.DeleteItem)
 				keywordRoutes.POST("/generate-questions", controller.Api.AdminApiGroup.KeywordApi.GenerateQuestions)
 				keywordRoutes.POST("/force-sync", controller.Api.AdminApiGroup.KeywordApi.ForceSync)
+				keywordRoutes.GET("/suggestions", controller.Api.AdminApiGroup.SuggestionApi.ListSuggestions)
+				keywordRoutes.POST("/suggestions/:id/approve", controller.Api.AdminApiGroup.SuggestionApi.ApproveSuggestion)
+				keywordRoutes.POST("/suggestions/:id/reject", controller.Api.AdminApiGroup.SuggestionApi.RejectSuggestion)
+				keywordRoutes.GET("/gaps", controller.Api.AdminApiGroup.CoverageApi.GetGaps)
+				keywordRoutes.DELETE("/gaps/:id", controller.Api.AdminApiGroup.CoverageApi.DismissGap)
+				keywordRoutes.GET("/history", controller.Api.AdminApiGroup.HistoryApi.ListHistory)
+				keywordRoutes.POST("/history/:id/restore", controller.Api.AdminApiGroup.HistoryApi.RestoreVersion)
+				keywordRoutes.GET("/export", controller.Api.AdminApiGroup.TransferApi.Export)
+				keywordRoutes.POST("/import", controller.Api.AdminApiGroup.TransferApi.Import)
+				keywordRoutes.GET("/import/progress", controller.Api.AdminApiGroup.TransferApi.ImportProgress)
+				keywordRoutes.GET("/conflicts", controller.Api.AdminApiGroup.ConflictApi.GetReport)
+			}
+			promptRoutes := adminRoutes.Group("/prompts")
+			{
+				promptRoutes.GET("", controller.Api.AdminApiGroup.PromptApi.ListPrompts)
+				promptRoutes.GET("/:name/versions", controller.Api.AdminApiGroup.PromptApi.ListVersions)
+				promptRoutes.POST("/:name/versions", controller.Api.AdminApiGroup.PromptApi.CreateVersion)
+				promptRoutes.POST("/:name/activate", controller.Api.AdminApiGroup.PromptApi.Activate)
+				promptRoutes.POST("/:name/preview", controller.Api.AdminApiGroup.PromptApi.Preview)
 			}
 			adminRoutes.POST("/upload/image", controller.Api.AdminApiGroup.UploadApi.UploadImage)
+			adminRoutes.GET("/rate-limits", controller.Api.AdminApiGroup.RateLimitApi.GetStatus)
+			adminRoutes.GET("/guard-detections", controller.Api.AdminApiGroup.GuardApi.ListDetections)
+			adminRoutes.GET("/experiments", controller.Api.AdminApiGroup.ExperimentApi.GetReport)
+			adminRoutes.GET("/csat/low-rated", controller.Api.AdminApiGroup.CsatApi.GetLowRated)
 		}
 	}
 
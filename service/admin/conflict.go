@@ -0,0 +1,295 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/task"
+	"gitee.com/taoJie_1/mall-agent/utils"
+)
+
+// KnowledgeConflictService 定义知识库冲突检测接口。
+type KnowledgeConflictService interface {
+	// Report 检测整个知识库: 精确关键词冲突、答案不同的语义近似重复、标准问题偏离种子问题。
+	Report(ctx context.Context) (*dto.ConflictReport, error)
+	// CheckItem 检测待保存的知识条目与其他条目的冲突, 标准问题在保存后才生成, 因此不检测偏离。
+	CheckItem(ctx context.Context, req *dto.UpsertKnowledgeItemRequest) (*dto.ConflictReport, error)
+}
+
+// ConflictError 保存知识条目时检测到冲突, 携带冲突报告以便调用方展示后强制保存
+type ConflictError struct {
+	Report *dto.ConflictReport
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("知识条目存在冲突: %d 个精确关键词冲突, %d 个语义近似重复, 确认无误后可强制保存",
+		len(e.Report.KeywordCollisions), len(e.Report.SemanticDuplicates))
+}
+
+type knowledgeConflictService struct {
+	keywords *keywordService
+}
+
+// NewKnowledgeConflictService 创建 KnowledgeConflictService 实例。
+func NewKnowledgeConflictService(tm *task.Manager) KnowledgeConflictService {
+	return &knowledgeConflictService{keywords: &keywordService{taskManager: tm}}
+}
+
+func (s *knowledgeConflictService) Report(ctx context.Context) (*dto.ConflictReport, error) {
+	items, err := s.keywords.ListItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &dto.ConflictReport{KeywordCollisions: s.keywordCollisions(items, nil)}
+
+	documents, err := s.semanticDocuments(ctx, items)
+	if err != nil {
+		// 向量数据库不可用时仍返回精确关键词的检测结果
+		global.Log.Warnf("获取语义条目失败, 跳过语义冲突检测: %v", err)
+		return report, nil
+	}
+	report.SemanticDuplicates = s.semanticDuplicates(documents)
+	if report.QuestionDrifts, err = s.questionDrifts(ctx, documents); err != nil {
+		global.Log.Warnf("检测标准问题偏离失败: %v", err)
+	}
+	return report, nil
+}
+
+func (s *knowledgeConflictService) CheckItem(ctx context.Context, req *dto.UpsertKnowledgeItemRequest) (*dto.ConflictReport, error) {
+	items, err := s.keywords.ListItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 更新时排除条目自身, 答案相同的条目保存后会合并为同一个条目, 也不算冲突
+	answerID := utils.Hash(req.Answer)
+	others := make([]*dto.KnowledgeItem, 0, len(items))
+	for _, item := range items {
+		if item.ID != req.ID && item.ID != answerID {
+			others = append(others, item)
+		}
+	}
+
+	pending := make([]*dto.ConflictEntry, 0, len(req.Questions))
+	for _, q := range req.Questions {
+		pending = append(pending, &dto.ConflictEntry{ItemID: req.ID, Question: q.Question, Type: q.Type, Answer: req.Answer})
+	}
+	report := &dto.ConflictReport{KeywordCollisions: s.keywordCollisions(others, pending)}
+
+	var seeds []*dto.ConflictEntry
+	for _, entry := range pending {
+		if qType := enum.KeywordType(entry.Type); qType == enum.KeywordTypeSemantic || qType == enum.KeywordTypeHybrid {
+			seeds = append(seeds, entry)
+		}
+	}
+	if len(seeds) == 0 {
+		return report, nil
+	}
+
+	documents, err := s.semanticDocuments(ctx, others)
+	if err != nil {
+		global.Log.Warnf("获取语义条目失败, 跳过语义冲突检测: %v", err)
+		return report, nil
+	}
+	if len(documents) == 0 {
+		return report, nil
+	}
+	texts := make([]string, len(seeds))
+	for i, entry := range seeds {
+		texts[i] = entry.Question
+	}
+	embeddings, err := s.createEmbeddings(ctx, texts)
+	if err != nil {
+		global.Log.Warnf("创建待保存问题的向量失败, 跳过语义冲突检测: %v", err)
+		return report, nil
+	}
+
+	threshold := global.Config.KnowledgeConflict.DuplicateThreshold
+	for i, entry := range seeds {
+		for _, doc := range documents {
+			if similarity := utils.CosineSimilarity(embeddings[i], doc.embedding); similarity >= threshold {
+				report.SemanticDuplicates = append(report.SemanticDuplicates, &dto.SemanticDuplicate{
+					Similarity: similarity,
+					Entries:    []*dto.ConflictEntry{entry, doc.entry},
+				})
+			}
+		}
+	}
+	sortDuplicates(report.SemanticDuplicates)
+	return report, nil
+}
+
+// keywordCollisions 找出被多个条目使用的精确匹配关键词, 关键词按小写比较, 与精确匹配缓存一致; pending 为待保存的问题
+func (s *knowledgeConflictService) keywordCollisions(items []*dto.KnowledgeItem, pending []*dto.ConflictEntry) []*dto.KeywordCollision {
+	type group struct {
+		entries []*dto.ConflictEntry
+		items   map[string]bool
+	}
+	groups := make(map[string]*group)
+	var keys []string
+	add := func(entry *dto.ConflictEntry, itemKey string) {
+		if qType := enum.KeywordType(entry.Type); qType != enum.KeywordTypeExact && qType != enum.KeywordTypeHybrid {
+			return
+		}
+		key := strings.ToLower(strings.TrimSpace(entry.Question))
+		if key == "" {
+			return
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{items: make(map[string]bool)}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.entries = append(g.entries, entry)
+		g.items[itemKey] = true
+	}
+
+	for _, item := range items {
+		for _, q := range item.Questions {
+			add(&dto.ConflictEntry{ItemID: item.ID, QuestionID: q.ID, Question: q.Question, Type: q.Type, Answer: item.Answer}, item.ID)
+		}
+	}
+	// 待保存的问题属于同一个条目, 新条目没有ID, 使用一个不会与条目ID重复的键
+	for _, entry := range pending {
+		add(entry, "\x00pending")
+	}
+
+	sort.Strings(keys)
+	var collisions []*dto.KeywordCollision
+	for _, key := range keys {
+		if g := groups[key]; len(g.items) > 1 {
+			collisions = append(collisions, &dto.KeywordCollision{Keyword: key, Entries: g.entries})
+		}
+	}
+	return collisions
+}
+
+type semanticDocument struct {
+	entry     *dto.ConflictEntry
+	embedding []float32
+}
+
+// semanticDocuments 获取向量数据库中属于给定条目的语义文档, 已不存在于 Chatwoot 的文档会被忽略
+func (s *knowledgeConflictService) semanticDocuments(ctx context.Context, items []*dto.KnowledgeItem) ([]*semanticDocument, error) {
+	type question struct {
+		item *dto.KnowledgeItem
+		q    *dto.Question
+	}
+	questions := make(map[int64]question)
+	for _, item := range items {
+		for _, q := range item.Questions {
+			if qType := enum.KeywordType(q.Type); qType == enum.KeywordTypeSemantic || qType == enum.KeywordTypeHybrid {
+				questions[int64(q.ID)] = question{item: item, q: q}
+			}
+		}
+	}
+	if len(questions) == 0 {
+		return nil, nil
+	}
+
+	vectorDocs, err := dao.App.VectorDb.ListCannedDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	documents := make([]*semanticDocument, 0, len(vectorDocs))
+	for _, doc := range vectorDocs {
+		found, ok := questions[doc.SourceID]
+		if !ok {
+			continue
+		}
+		documents = append(documents, &semanticDocument{
+			entry: &dto.ConflictEntry{
+				ItemID:           found.item.ID,
+				QuestionID:       found.q.ID,
+				Question:         found.q.Question,
+				Type:             found.q.Type,
+				StandardQuestion: doc.Question,
+				Answer:           found.item.Answer,
+			},
+			embedding: doc.Embedding,
+		})
+	}
+	return documents, nil
+}
+
+// semanticDuplicates 两两比较不同条目的标准问题向量, 相似度达到阈值即视为近似重复
+func (s *knowledgeConflictService) semanticDuplicates(documents []*semanticDocument) []*dto.SemanticDuplicate {
+	threshold := global.Config.KnowledgeConflict.DuplicateThreshold
+	var duplicates []*dto.SemanticDuplicate
+	for i := 0; i < len(documents); i++ {
+		for j := i + 1; j < len(documents); j++ {
+			a, b := documents[i], documents[j]
+			if a.entry.ItemID == b.entry.ItemID {
+				continue
+			}
+			if similarity := utils.CosineSimilarity(a.embedding, b.embedding); similarity >= threshold {
+				duplicates = append(duplicates, &dto.SemanticDuplicate{
+					Similarity: similarity,
+					Entries:    []*dto.ConflictEntry{a.entry, b.entry},
+				})
+			}
+		}
+	}
+	sortDuplicates(duplicates)
+	return duplicates
+}
+
+// questionDrifts 比较种子问题与生成的标准问题的向量, 相似度低于阈值即视为偏离
+func (s *knowledgeConflictService) questionDrifts(ctx context.Context, documents []*semanticDocument) ([]*dto.QuestionDrift, error) {
+	var candidates []*semanticDocument
+	var texts []string
+	for _, doc := range documents {
+		if doc.entry.StandardQuestion == "" || doc.entry.StandardQuestion == doc.entry.Question {
+			continue
+		}
+		candidates = append(candidates, doc)
+		texts = append(texts, doc.entry.Question)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	embeddings, err := s.createEmbeddings(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := global.Config.KnowledgeConflict.DriftThreshold
+	var drifts []*dto.QuestionDrift
+	for i, doc := range candidates {
+		if similarity := utils.CosineSimilarity(embeddings[i], doc.embedding); similarity < threshold {
+			drifts = append(drifts, &dto.QuestionDrift{Similarity: similarity, Entry: doc.entry})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Similarity < drifts[j].Similarity })
+	return drifts, nil
+}
+
+func (s *knowledgeConflictService) createEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	if global.EmbeddingService == nil {
+		return nil, errors.New("Embedding服务未初始化")
+	}
+	embedCtx, cancel := context.WithTimeout(ctx, time.Duration(global.Config.LlmEmbedding.BatchTimeout)*time.Second)
+	defer cancel()
+	embeddings, err := global.EmbeddingService.CreateEmbeddings(embedCtx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("批量创建向量失败: %w", err)
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("向量数量 %d 与文本数量 %d 不一致", len(embeddings), len(texts))
+	}
+	return embeddings, nil
+}
+
+func sortDuplicates(duplicates []*dto.SemanticDuplicate) {
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i].Similarity > duplicates[j].Similarity })
+}
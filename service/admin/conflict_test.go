@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"reflect"
+	"testing"
+
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+)
+
+func TestKeywordCollisions(t *testing.T) {
+	items := []*dto.KnowledgeItem{
+		{ID: "a", Answer: "答案A", Questions: []*dto.Question{
+			{ID: 1, Question: "运费", Type: "EXACT"},
+			{ID: 2, Question: "发货时间", Type: "HYBRID"},
+			{ID: 3, Question: "怎么退货", Type: "AI_SEMANTIC"},
+		}},
+		{ID: "b", Answer: "答案B", Questions: []*dto.Question{
+			{ID: 4, Question: " 发货时间 ", Type: "EXACT"},
+			{ID: 5, Question: "怎么退货", Type: "AI_SEMANTIC"},
+			{ID: 6, Question: "VIP", Type: "EXACT"},
+		}},
+		// 同一条目内的重复问题不算冲突
+		{ID: "c", Answer: "答案C", Questions: []*dto.Question{
+			{ID: 7, Question: "包邮", Type: "EXACT"},
+			{ID: 8, Question: "包邮", Type: "HYBRID"},
+		}},
+	}
+	pending := []*dto.ConflictEntry{
+		{Question: "vip", Type: "EXACT", Answer: "新答案"},
+		{Question: "运费", Type: "AI_SEMANTIC", Answer: "新答案"},
+	}
+	s := &knowledgeConflictService{}
+
+	keywords := func(collisions []*dto.KeywordCollision) []string {
+		var keys []string
+		for _, c := range collisions {
+			keys = append(keys, c.Keyword)
+		}
+		return keys
+	}
+
+	// 语义问题不参与精确匹配, 关键词忽略大小写和首尾空格, 结果按关键词排序
+	if got := keywords(s.keywordCollisions(items, nil)); !reflect.DeepEqual(got, []string{"发货时间"}) {
+		t.Errorf("keywordCollisions(items) = %v, want [发货时间]", got)
+	}
+
+	collisions := s.keywordCollisions(items, pending)
+	if got := keywords(collisions); !reflect.DeepEqual(got, []string{"vip", "发货时间"}) {
+		t.Fatalf("keywordCollisions(items, pending) = %v, want [vip 发货时间]", got)
+	}
+	if entries := collisions[0].Entries; len(entries) != 2 || entries[0].QuestionID != 6 || entries[1] != pending[0] {
+		t.Errorf("vip 冲突条目 = %+v", entries)
+	}
+}
@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"context"
+	"sort"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+)
+
+// maxDraftQuestions 预填知识条目的问题数上限, 与 UpsertItem 中每个条目的问题数上限一致
+const maxDraftQuestions = 20
+
+// CoverageService 定义知识库空白报告接口。
+type CoverageService interface {
+	// GapReport 列出出现次数最多的 limit 类知识库未能回答的问题, 每类附带预填的知识条目。
+	GapReport(ctx context.Context, limit int) (*dto.CoverageGapReport, error)
+	// DismissGap 忽略一类问题, 例如已为其创建知识条目或确实无需回答。
+	DismissGap(ctx context.Context, id string) error
+}
+
+type coverageService struct{}
+
+// NewCoverageService 创建 CoverageService 实例。
+func NewCoverageService() CoverageService {
+	return &coverageService{}
+}
+
+func (s *coverageService) GapReport(ctx context.Context, limit int) (*dto.CoverageGapReport, error) {
+	gaps, err := dao.App.CoverageDb.ListGaps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].Count != gaps[j].Count {
+			return gaps[i].Count > gaps[j].Count
+		}
+		return gaps[i].LastSeen > gaps[j].LastSeen
+	})
+
+	report := &dto.CoverageGapReport{Total: len(gaps), Gaps: gaps[:min(limit, len(gaps))]}
+	for _, gap := range report.Gaps {
+		// 向量只用于聚类, 不返回给前端
+		gap.Embedding = nil
+		draft := &dto.UpsertKnowledgeItemRequest{Questions: make([]*dto.Question, 0, len(gap.Samples))}
+		for _, sample := range gap.Samples[:min(maxDraftQuestions, len(gap.Samples))] {
+			draft.Questions = append(draft.Questions, &dto.Question{Question: sample, Type: "AI_SEMANTIC"})
+		}
+		gap.Draft = draft
+	}
+	return report, nil
+}
+
+func (s *coverageService) DismissGap(ctx context.Context, id string) error {
+	return dao.App.CoverageDb.DeleteGaps(ctx, id)
+}
@@ -0,0 +1,175 @@
+package admin
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/task"
+	"gitee.com/taoJie_1/mall-agent/utils"
+)
+
+// CsatService 定义满意度评价统计接口。
+type CsatService interface {
+	// LowRatedReport 列出最近的低分评价及其关联的知识条目, limit 为返回的低分评价数上限。
+	LowRatedReport(ctx context.Context, limit int) (*dto.CsatLowRatedReport, error)
+}
+
+type csatService struct {
+	keywords KeywordService
+}
+
+// NewCsatService 创建 CsatService 实例。
+func NewCsatService(tm *task.Manager) CsatService {
+	return &csatService{keywords: NewKeywordService(tm)}
+}
+
+// csatItemStats 知识条目关联评价的累计数据
+type csatItemStats struct {
+	item     *dto.KnowledgeItem
+	rated    int
+	lowRated int
+	scoreSum int
+}
+
+func (s *csatService) LowRatedReport(ctx context.Context, limit int) (*dto.CsatLowRatedReport, error) {
+	lowScore := global.Config.Csat.LowScore
+	ratings, err := dao.App.CsatDb.ListRatings(ctx, global.Config.Csat.LogMaxLen)
+	if err != nil {
+		return nil, err
+	}
+
+	// 知识条目与管理页面使用相同的分组方式, 获取失败时仍返回评价, 只是不关联条目
+	items, err := s.keywords.ListItems(ctx)
+	if err != nil {
+		global.Log.Warnf("获取知识条目失败, 低分评价将不关联知识条目: %v", err)
+	}
+	itemsByID := make(map[string]*dto.KnowledgeItem, len(items))
+	itemsByQuestion := make(map[int]*dto.KnowledgeItem)
+	for _, item := range items {
+		itemsByID[item.ID] = item
+		for _, q := range item.Questions {
+			itemsByQuestion[q.ID] = item
+		}
+	}
+
+	report := &dto.CsatLowRatedReport{LowScore: lowScore, Total: len(ratings), Ratings: []*dto.CsatLowRating{}, Items: []*dto.CsatKnowledgeItem{}}
+	stats := make(map[string]*csatItemStats)
+	for _, rating := range ratings {
+		isLow := rating.Score <= lowScore
+		if isLow {
+			report.LowRated++
+		}
+
+		answers := make([]*dto.CsatAnswer, 0, len(rating.Answers))
+		linked := make(map[string]struct{})
+		for _, record := range rating.Answers {
+			answer := s.linkAnswer(record, itemsByID, itemsByQuestion)
+			for _, id := range answer.KnowledgeItemIDs {
+				linked[id] = struct{}{}
+			}
+			answers = append(answers, answer)
+		}
+
+		// 同一次评价中多次引用同一条目只计一次
+		for id := range linked {
+			st, ok := stats[id]
+			if !ok {
+				st = &csatItemStats{item: itemsByID[id]}
+				stats[id] = st
+			}
+			st.rated++
+			st.scoreSum += rating.Score
+			if isLow {
+				st.lowRated++
+			}
+		}
+
+		if isLow && len(report.Ratings) < limit {
+			report.Ratings = append(report.Ratings, &dto.CsatLowRating{
+				ConversationID: rating.ConversationID,
+				Score:          rating.Score,
+				Feedback:       rating.Feedback,
+				Channel:        string(rating.Channel),
+				HumanHandled:   rating.HumanHandled,
+				Answers:        answers,
+				CreatedAt:      rating.CreatedAt,
+			})
+		}
+	}
+
+	for id, st := range stats {
+		if st.lowRated == 0 {
+			continue
+		}
+		report.Items = append(report.Items, &dto.CsatKnowledgeItem{
+			ID:            id,
+			Answer:        st.item.Answer,
+			Questions:     st.item.Questions,
+			RatedCount:    st.rated,
+			LowRatedCount: st.lowRated,
+			AverageScore:  float64(st.scoreSum) / float64(st.rated),
+		})
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		if report.Items[i].LowRatedCount != report.Items[j].LowRatedCount {
+			return report.Items[i].LowRatedCount > report.Items[j].LowRatedCount
+		}
+		return report.Items[i].AverageScore < report.Items[j].AverageScore
+	})
+	return report, nil
+}
+
+// linkAnswer 找出回复所依据的知识条目和快捷回复: 知识库检索命中的按快捷回复ID关联, 直接发送的知识库答案按内容关联
+func (s *csatService) linkAnswer(record common.AnswerRecord, itemsByID map[string]*dto.KnowledgeItem, itemsByQuestion map[int]*dto.KnowledgeItem) *dto.CsatAnswer {
+	answer := &dto.CsatAnswer{
+		Question:         record.Question,
+		Answer:           record.Answer,
+		Source:           string(record.Source),
+		KnowledgeItemIDs: []string{},
+		CannedResponses:  []*dto.Question{},
+	}
+	addItem := func(item *dto.KnowledgeItem) {
+		if !slices.Contains(answer.KnowledgeItemIDs, item.ID) {
+			answer.KnowledgeItemIDs = append(answer.KnowledgeItemIDs, item.ID)
+		}
+	}
+
+	for _, sourceID := range record.SourceIDs {
+		item, ok := itemsByQuestion[int(sourceID)]
+		if !ok {
+			continue
+		}
+		addItem(item)
+		for _, q := range item.Questions {
+			if int64(q.ID) == sourceID {
+				answer.CannedResponses = append(answer.CannedResponses, q)
+			}
+		}
+	}
+
+	if record.Source == enum.AnswerSourceLlm {
+		return answer
+	}
+	item, ok := itemsByID[utils.Hash(record.Answer)]
+	if !ok {
+		return answer
+	}
+	addItem(item)
+	// 关键词精确匹配时, 用户的问题即快捷回复的关键词
+	if record.Source == enum.AnswerSourceCanned {
+		question := strings.ToLower(strings.TrimSpace(record.Question))
+		for _, q := range item.Questions {
+			if strings.ToLower(q.Question) == question {
+				answer.CannedResponses = append(answer.CannedResponses, q)
+			}
+		}
+	}
+	return answer
+}
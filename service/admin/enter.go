@@ -3,13 +3,27 @@ package admin
 import "gitee.com/taoJie_1/mall-agent/task"
 
 type ServiceGroup struct {
-	KeywordService KeywordService
-	UploadService  UploadService
+	KeywordService    KeywordService
+	UploadService     UploadService
+	PromptService     PromptService
+	CsatService       CsatService
+	SuggestionService SuggestionService
+	CoverageService   CoverageService
+	HistoryService    KnowledgeHistoryService
+	TransferService   KnowledgeTransferService
+	ConflictService   KnowledgeConflictService
 }
 
 func NewServiceGroup(taskManager *task.Manager) ServiceGroup {
 	return ServiceGroup{
-		KeywordService: NewKeywordService(taskManager),
-		UploadService:  NewUploadService(),
+		KeywordService:    NewKeywordService(taskManager),
+		UploadService:     NewUploadService(),
+		PromptService:     NewPromptService(taskManager),
+		CsatService:       NewCsatService(taskManager),
+		SuggestionService: NewSuggestionService(taskManager),
+		CoverageService:   NewCoverageService(),
+		HistoryService:    NewKnowledgeHistoryService(taskManager),
+		TransferService:   NewKnowledgeTransferService(taskManager),
+		ConflictService:   NewKnowledgeConflictService(taskManager),
 	}
 }
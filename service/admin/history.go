@@ -0,0 +1,214 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/db"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/task"
+)
+
+type actorCtxKey struct{}
+type restoredFromCtxKey struct{}
+
+// WithActor 将操作人放入上下文, 知识条目的变更记录通过它记录操作人
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+func actorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorCtxKey{}).(string)
+	if actor == "" {
+		return "system"
+	}
+	return actor
+}
+
+// KnowledgeHistoryService 定义知识条目变更记录的查询与恢复接口。
+type KnowledgeHistoryService interface {
+	// ListHistory 按时间倒序列出变更记录; itemID 不为空时沿修改答案前的条目ID追溯该条目的全部历史, 否则列出租户最近的变更。
+	ListHistory(ctx context.Context, itemID string, limit int) ([]*dto.KnowledgeHistory, error)
+	// RestoreVersion 将知识条目恢复为变更记录中的快照, 与编辑保存走相同的 Chatwoot 和缓存更新流程。
+	RestoreVersion(ctx context.Context, historyID uint, req *dto.RestoreKnowledgeRequest) error
+}
+
+// errDatabaseNotConfigured 未配置或无法连接数据库时, 变更记录不可用
+var errDatabaseNotConfigured = errors.New("数据库未配置或不可用, 无法使用知识条目的变更记录")
+
+type knowledgeHistoryService struct {
+	keywords KeywordService
+}
+
+// NewKnowledgeHistoryService 创建 KnowledgeHistoryService 实例。
+func NewKnowledgeHistoryService(tm *task.Manager) KnowledgeHistoryService {
+	return &knowledgeHistoryService{keywords: NewKeywordService(tm)}
+}
+
+func (s *knowledgeHistoryService) ListHistory(ctx context.Context, itemID string, limit int) ([]*dto.KnowledgeHistory, error) {
+	if dao.DB == nil {
+		return nil, errDatabaseNotConfigured
+	}
+	tenant := global.TenantFrom(ctx).Name
+
+	var records []*db.KnowledgeHistory
+	if itemID == "" {
+		var err error
+		if records, err = dao.App.KnowledgeHistoryDb.ListRecentHistory(ctx, tenant, limit); err != nil {
+			return nil, err
+		}
+	} else {
+		// 修改答案会改变条目ID, 同一ID最早一条记录的变更前ID即为上一个条目ID
+		visited := make(map[string]bool)
+		for id := itemID; id != "" && !visited[id] && len(records) < limit; {
+			visited[id] = true
+			list, err := dao.App.KnowledgeHistoryDb.ListHistoryByItem(ctx, tenant, id)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, list...)
+			id = ""
+			if len(list) > 0 {
+				id = list[len(list)-1].PrevItemID
+			}
+		}
+		sort.Slice(records, func(i, j int) bool { return records[i].Id > records[j].Id })
+		if len(records) > limit {
+			records = records[:limit]
+		}
+	}
+
+	history := make([]*dto.KnowledgeHistory, 0, len(records))
+	for _, record := range records {
+		item := &dto.KnowledgeHistory{
+			ID:           record.Id,
+			ItemID:       record.ItemID,
+			PrevItemID:   record.PrevItemID,
+			Action:       record.Action,
+			Actor:        record.Actor,
+			RestoredFrom: record.RestoredFrom,
+			CreatedAt:    record.CreatedAt,
+		}
+		if err := json.Unmarshal([]byte(record.Diff), &item.Diff); err != nil {
+			global.Log.Warnf("解析知识条目变更记录 #%d 的差异失败: %v", record.Id, err)
+		}
+		if err := json.Unmarshal([]byte(record.Snapshot), &item.Snapshot); err != nil {
+			global.Log.Warnf("解析知识条目变更记录 #%d 的快照失败: %v", record.Id, err)
+		}
+		history = append(history, item)
+	}
+	return history, nil
+}
+
+func (s *knowledgeHistoryService) RestoreVersion(ctx context.Context, historyID uint, req *dto.RestoreKnowledgeRequest) error {
+	if dao.DB == nil {
+		return errDatabaseNotConfigured
+	}
+	record, err := dao.App.KnowledgeHistoryDb.GetHistory(ctx, global.TenantFrom(ctx).Name, historyID)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return errors.New("变更记录不存在")
+	}
+
+	var snapshot dto.KnowledgeItem
+	if err := json.Unmarshal([]byte(record.Snapshot), &snapshot); err != nil {
+		return errors.New("变更记录的快照已损坏")
+	}
+	if snapshot.Answer == "" || len(snapshot.Questions) == 0 {
+		return errors.New("该版本没有可恢复的内容")
+	}
+
+	// 快照中的ID是当时的预设回复ID, 恢复时重新创建
+	questions := make([]*dto.Question, len(snapshot.Questions))
+	for i, q := range snapshot.Questions {
+		questions[i] = &dto.Question{Question: q.Question, Type: q.Type}
+	}
+	ctx = context.WithValue(ctx, restoredFromCtxKey{}, record.Id)
+	// 恢复的是曾经保存过的版本, 不再做冲突检查
+	return s.keywords.UpsertItem(ctx, &dto.UpsertKnowledgeItemRequest{ID: req.ItemID, Answer: snapshot.Answer, Questions: questions, Force: true})
+}
+
+// recordHistory 记录知识条目的一次变更, before 或 after 为nil分别表示新增和删除; 记录失败只打印日志, 不影响变更本身
+func recordHistory(ctx context.Context, action enum.KnowledgeAction, before, after *dto.KnowledgeItem) {
+	if dao.DB == nil {
+		return
+	}
+
+	record := &db.KnowledgeHistory{
+		Tenant: global.TenantFrom(ctx).Name,
+		Action: string(action),
+		Actor:  actorFrom(ctx),
+	}
+	if restoredFrom, ok := ctx.Value(restoredFromCtxKey{}).(uint); ok {
+		record.Action = string(enum.KnowledgeActionRestore)
+		record.RestoredFrom = restoredFrom
+	}
+
+	snapshot := after
+	if after == nil {
+		snapshot = before
+	} else if before != nil {
+		record.PrevItemID = before.ID
+	}
+	if snapshot == nil {
+		return
+	}
+	record.ItemID = snapshot.ID
+
+	diff, err := json.Marshal(diffKnowledgeItems(before, after))
+	if err != nil {
+		global.Log.Errorf("序列化知识条目 %s 的变更差异失败: %v", record.ItemID, err)
+		return
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		global.Log.Errorf("序列化知识条目 %s 的快照失败: %v", record.ItemID, err)
+		return
+	}
+	record.Diff, record.Snapshot = string(diff), string(data)
+
+	if err := dao.App.KnowledgeHistoryDb.InsertHistory(context.WithoutCancel(ctx), record); err != nil {
+		global.Log.Errorf("记录知识条目 %s 的变更失败: %v", record.ItemID, err)
+	}
+}
+
+// diffKnowledgeItems 比较变更前后的答案和问题, 问题按类型和内容比较
+func diffKnowledgeItems(before, after *dto.KnowledgeItem) *dto.KnowledgeDiff {
+	if before == nil {
+		before = &dto.KnowledgeItem{}
+	}
+	if after == nil {
+		after = &dto.KnowledgeItem{}
+	}
+
+	diff := &dto.KnowledgeDiff{}
+	if before.Answer != after.Answer {
+		diff.AnswerBefore, diff.AnswerAfter = before.Answer, after.Answer
+	}
+
+	questionKey := func(q *dto.Question) string { return q.Type + "\x00" + q.Question }
+	beforeKeys := make(map[string]bool, len(before.Questions))
+	for _, q := range before.Questions {
+		beforeKeys[questionKey(q)] = true
+	}
+	afterKeys := make(map[string]bool, len(after.Questions))
+	for _, q := range after.Questions {
+		afterKeys[questionKey(q)] = true
+		if !beforeKeys[questionKey(q)] {
+			diff.AddedQuestions = append(diff.AddedQuestions, q)
+		}
+	}
+	for _, q := range before.Questions {
+		if !afterKeys[questionKey(q)] {
+			diff.RemovedQuestions = append(diff.RemovedQuestions, q)
+		}
+	}
+	return diff
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -34,6 +35,9 @@ type KeywordService interface {
 	ForceSync(ctx context.Context) error
 }
 
+// maxQuestionsPerItem 每个知识条目最多关联的问题数
+const maxQuestionsPerItem = 20
+
 type keywordService struct {
 	taskManager *task.Manager
 }
@@ -44,11 +48,12 @@ func NewKeywordService(tm *task.Manager) KeywordService {
 }
 
 func (s *keywordService) ListItems(ctx context.Context) ([]*dto.KnowledgeItem, error) {
-	if global.ChatwootService == nil {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
 		return nil, errors.New("chatwoot 服务未初始化")
 	}
 
-	responses, err := global.ChatwootService.GetCannedResponses()
+	responses, err := chatwootClient.GetCannedResponses()
 	if err != nil {
 		return nil, fmt.Errorf("从 Chatwoot 获取预设回复失败: %w", err)
 	}
@@ -115,24 +120,59 @@ func (s *keywordService) ListItems(ctx context.Context) ([]*dto.KnowledgeItem, e
 }
 
 func (s *keywordService) UpsertItem(ctx context.Context, req *dto.UpsertKnowledgeItemRequest) error {
-	if global.ChatwootService == nil {
-		return errors.New("chatwoot 服务未初始化")
+	if global.Config.KnowledgeConflict.CheckOnUpsert && !req.Force {
+		report, err := (&knowledgeConflictService{keywords: s}).CheckItem(ctx, req)
+		if err != nil {
+			// 冲突检测失败不阻止保存
+			global.Log.Warnf("保存知识条目前检测冲突失败: %v", err)
+		} else if report.HasConflicts() {
+			return &ConflictError{Report: report}
+		}
+	}
+
+	createdResponses, err := s.upsert(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// 3. 对刚刚创建的条目执行“实时精准更新”
+	if len(createdResponses) > 0 {
+		go func() {
+			if err := s.taskManager.ProcessAndCacheResponses(context.WithoutCancel(ctx), createdResponses); err != nil {
+				global.Log.Errorf("UpsertItem后执行实时精准缓存失败: %v", err)
+			}
+		}()
+	}
+
+	// 4. 调度一个延迟后的“延迟重同步校准”任务
+	debounceDelay := time.Duration(global.Config.Ai.KeywordReloadDebounce) * time.Second
+	s.taskManager.DebounceKeywordReload(debounceDelay)
+
+	return nil
+}
+
+// upsert 在 Chatwoot 中替换知识条目的预设回复并记录变更, 返回新创建的预设回复; 新条目的缓存由调用方负责
+func (s *keywordService) upsert(ctx context.Context, req *dto.UpsertKnowledgeItemRequest) ([]chatwoot.CannedResponse, error) {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
+		return nil, errors.New("chatwoot 服务未初始化")
 	}
 
-	const maxQuestionsPerItem = 20
 	if len(req.Questions) > maxQuestionsPerItem {
-		return fmt.Errorf("每个知识条目最多只能关联 %d 个问题", maxQuestionsPerItem)
+		return nil, fmt.Errorf("每个知识条目最多只能关联 %d 个问题", maxQuestionsPerItem)
 	}
 
 	// 1. 对于更新操作，先删除 Chatwoot 上的旧条目
+	var deletedResponses []chatwoot.CannedResponse
 	if req.ID != "" {
-		deletedResponses, err := s.findAndDeleteByGroupID(ctx, req.ID)
+		var err error
+		deletedResponses, err = s.findAndDeleteByGroupID(ctx, req.ID)
 		if err != nil {
-			return fmt.Errorf("更新时删除旧条目失败: %w", err)
+			return nil, fmt.Errorf("更新时删除旧条目失败: %w", err)
 		}
 		// 异步执行旧条目的缓存清理
 		if len(deletedResponses) > 0 {
-			go s.purgeLocalCaches(context.Background(), deletedResponses)
+			go s.purgeLocalCaches(context.WithoutCancel(ctx), deletedResponses)
 		}
 	}
 
@@ -146,7 +186,7 @@ func (s *keywordService) UpsertItem(ctx context.Context, req *dto.UpsertKnowledg
 		q := q
 		g.Go(func() error {
 			shortCode := s.buildShortCode(q.Type, q.Question)
-			newResp, err := global.ChatwootService.CreateCannedResponse(shortCode, req.Answer)
+			newResp, err := chatwootClient.CreateCannedResponse(shortCode, req.Answer)
 			if err != nil {
 				return fmt.Errorf("为问题 '%s' 创建预设回复失败: %w", q.Question, err)
 			}
@@ -157,24 +197,14 @@ func (s *keywordService) UpsertItem(ctx context.Context, req *dto.UpsertKnowledg
 		})
 	}
 
-	if err := g.Wait(); err != nil {
-		return err
-	}
-
-	// 3. 对刚刚创建的条目执行“实时精准更新”
-	if len(createdResponses) > 0 {
-		go func() {
-			if err := s.taskManager.ProcessAndCacheResponses(context.Background(), createdResponses); err != nil {
-				global.Log.Errorf("UpsertItem后执行实时精准缓存失败: %v", err)
-			}
-		}()
+	// 旧条目已删除, 即便部分问题创建失败也按实际结果记录变更
+	err := g.Wait()
+	action := enum.KnowledgeActionCreate
+	if req.ID != "" {
+		action = enum.KnowledgeActionUpdate
 	}
-
-	// 4. 调度一个延迟后的“延迟重同步校准”任务
-	debounceDelay := time.Duration(global.Config.Ai.KeywordReloadDebounce) * time.Second
-	s.taskManager.DebounceKeywordReload(debounceDelay)
-
-	return nil
+	recordHistory(ctx, action, s.toKnowledgeItem(deletedResponses), s.toKnowledgeItem(createdResponses))
+	return createdResponses, err
 }
 
 func (s *keywordService) DeleteItem(ctx context.Context, itemID string) error {
@@ -188,6 +218,8 @@ func (s *keywordService) DeleteItem(ctx context.Context, itemID string) error {
 		return nil
 	}
 
+	recordHistory(ctx, enum.KnowledgeActionDelete, s.toKnowledgeItem(deletedResponses), nil)
+
 	// 2. 对本地所有缓存进行精准清理
 	if err := s.purgeLocalCaches(ctx, deletedResponses); err != nil {
 		// 即便缓存清理失败，也不应阻塞主流程，因为有每日同步任务作为兜底
@@ -204,9 +236,9 @@ func (s *keywordService) GenerateQuestions(ctx context.Context, req *dto.Generat
 
 	var prompt enum.SystemPrompt
 	if req.Type == "keyword" {
-		prompt = enum.SystemPromptGenQuestionFromKeyword
+		prompt = global.Prompt(ctx, enum.PromptGenQuestionFromKeyword)
 	} else {
-		prompt = enum.SystemPromptGenQuestionFromContent
+		prompt = global.Prompt(ctx, enum.PromptGenQuestionFromContent)
 	}
 
 	// 要求 LLM 返回换行分隔的列表，便于解析。
@@ -238,10 +270,11 @@ func (s *keywordService) ForceSync(ctx context.Context) error {
 
 // findAndDeleteByGroupID 根据分组 ID 查找并删除所有关联的预设回复，并返回被删除的条目列表。
 func (s *keywordService) findAndDeleteByGroupID(ctx context.Context, groupID string) ([]chatwoot.CannedResponse, error) {
-	if global.ChatwootService == nil {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
 		return nil, errors.New("chatwoot 服务未初始化")
 	}
-	allResponses, err := global.ChatwootService.GetCannedResponses()
+	allResponses, err := chatwootClient.GetCannedResponses()
 	if err != nil {
 		return nil, fmt.Errorf("删除时获取全量预设回复失败: %w", err)
 	}
@@ -262,7 +295,7 @@ func (s *keywordService) findAndDeleteByGroupID(ctx context.Context, groupID str
 	for _, r := range responsesToDelete {
 		resp := r
 		g.Go(func() error {
-			return global.ChatwootService.DeleteCannedResponse(resp.Id)
+			return chatwootClient.DeleteCannedResponse(resp.Id)
 		})
 	}
 
@@ -301,11 +334,12 @@ func (s *keywordService) purgeLocalCaches(ctx context.Context, deletedResponses
 	if len(shortCodesToDel) > 0 {
 		g.Go(func() error {
 			// 清理内存 Map
-			global.CannedResponses.Lock()
+			cannedResponses := global.TenantFrom(ctx).CannedResponses
+			cannedResponses.Lock()
 			for _, code := range shortCodesToDel {
-				delete(global.CannedResponses.Data, code)
+				delete(cannedResponses.Data, code)
 			}
-			global.CannedResponses.Unlock()
+			cannedResponses.Unlock()
 			global.Log.Debugf("精准清理内存缓存: %v", shortCodesToDel)
 
 			// 清理 Redis
@@ -331,6 +365,27 @@ func (s *keywordService) purgeLocalCaches(ctx context.Context, deletedResponses
 	return g.Wait()
 }
 
+// toKnowledgeItem 将同一答案下的预设回复合并为知识条目, 用于记录变更; 没有预设回复时返回nil
+func (s *keywordService) toKnowledgeItem(responses []chatwoot.CannedResponse) *dto.KnowledgeItem {
+	if len(responses) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(responses)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+
+	item := &dto.KnowledgeItem{
+		ID:        utils.Hash(sorted[0].Content),
+		Answer:    sorted[0].Content,
+		Questions: make([]*dto.Question, 0, len(sorted)),
+		UpdatedAt: time.Now().Unix(),
+	}
+	for _, resp := range sorted {
+		qType, qText := s.parseShortCode(resp.ShortCode)
+		item.Questions = append(item.Questions, &dto.Question{ID: resp.Id, Question: qText, Type: string(qType)})
+	}
+	return item
+}
+
 // parseShortCode 从 short_code 字符串中解析类型和文本。
 func (s *keywordService) parseShortCode(shortCode string) (qType enum.KeywordType, qText string) {
 	hybridPrefix := global.Config.Ai.HybridPrefix
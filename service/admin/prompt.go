@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/task"
+)
+
+// PromptService 定义提示词管理接口, 提示词按版本保存, 启用前必须通过校验
+type PromptService interface {
+	// ListPrompts 列出所有提示词及其启用的版本。
+	ListPrompts(ctx context.Context) ([]*dto.PromptItem, error)
+	// ListVersions 列出提示词的所有版本(含第0版内置版本), 从新到旧排序。
+	ListVersions(ctx context.Context, name string) ([]dto.PromptVersion, error)
+	// CreateVersion 保存提示词的新版本, 可选择立即启用。
+	CreateVersion(ctx context.Context, name string, req *dto.CreatePromptVersionRequest) (*dto.PromptVersion, error)
+	// Activate 启用指定版本, 用于发布或回滚; 版本号0为内置版本。
+	Activate(ctx context.Context, name string, version int) error
+	// Preview 校验提示词并返回替换变量后的内容, 不保存。
+	Preview(ctx context.Context, name string, req *dto.PreviewPromptRequest) (*dto.PreviewPromptResponse, error)
+}
+
+type promptService struct {
+	taskManager *task.Manager
+}
+
+// NewPromptService 创建 PromptService 实例。
+func NewPromptService(tm *task.Manager) PromptService {
+	return &promptService{taskManager: tm}
+}
+
+func (s *promptService) ListPrompts(ctx context.Context) ([]*dto.PromptItem, error) {
+	active, err := dao.App.PromptsDb.GetActivePromptVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*dto.PromptItem, 0, len(enum.BuiltinPrompts))
+	for name, builtin := range enum.BuiltinPrompts {
+		versions, err := dao.App.PromptsDb.ListPromptVersions(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		item := &dto.PromptItem{Name: string(name), Content: string(builtin)}
+		if len(versions) > 0 {
+			item.LatestVersion = versions[0].Version
+		}
+		for _, v := range versions {
+			if v.Version == active[name] {
+				item.ActiveVersion = v.Version
+				item.Content = v.Content
+				break
+			}
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	return items, nil
+}
+
+func (s *promptService) ListVersions(ctx context.Context, name string) ([]dto.PromptVersion, error) {
+	promptName, err := s.parseName(name)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := dao.App.PromptsDb.ListPromptVersions(ctx, promptName)
+	if err != nil {
+		return nil, err
+	}
+	return append(versions, dto.PromptVersion{Content: string(enum.BuiltinPrompts[promptName]), Note: "内置版本"}), nil
+}
+
+func (s *promptService) CreateVersion(ctx context.Context, name string, req *dto.CreatePromptVersionRequest) (*dto.PromptVersion, error) {
+	promptName, err := s.parseName(name)
+	if err != nil {
+		return nil, err
+	}
+	// 需要立即启用时先校验, 避免保存一个无法启用的版本
+	if req.Activate {
+		if err := enum.ValidatePrompt(promptName, req.Content); err != nil {
+			return nil, fmt.Errorf("提示词未通过校验: %w", err)
+		}
+	}
+
+	version, err := dao.App.PromptsDb.AddPromptVersion(ctx, promptName, req.Content, req.Note)
+	if err != nil {
+		return nil, err
+	}
+	global.Log.Infof("提示词 %s 已保存第%d版: %s", promptName, version.Version, req.Note)
+
+	if req.Activate {
+		if err := s.Activate(ctx, name, version.Version); err != nil {
+			return version, err
+		}
+	}
+	return version, nil
+}
+
+func (s *promptService) Activate(ctx context.Context, name string, version int) error {
+	promptName, err := s.parseName(name)
+	if err != nil {
+		return err
+	}
+
+	if version != 0 {
+		versions, err := dao.App.PromptsDb.ListPromptVersions(ctx, promptName)
+		if err != nil {
+			return err
+		}
+		idx := -1
+		for i, v := range versions {
+			if v.Version == version {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("提示词 %s 的第%d版不存在", promptName, version)
+		}
+		if err := enum.ValidatePrompt(promptName, versions[idx].Content); err != nil {
+			return fmt.Errorf("提示词未通过校验: %w", err)
+		}
+	}
+
+	if err := dao.App.PromptsDb.SetActivePromptVersion(ctx, promptName, version); err != nil {
+		return err
+	}
+	global.Log.Infof("提示词 %s 已启用第%d版", promptName, version)
+
+	// 本实例立即生效, 其他实例通过广播重新加载
+	if err := s.taskManager.PromptReloader(); err != nil {
+		global.Log.Errorf("启用提示词后重新加载失败: %v", err)
+	}
+	if err := s.taskManager.PublishPromptReload(ctx); err != nil {
+		global.Log.Warnf("广播提示词变更失败, 其他实例将在重启后生效: %v", err)
+	}
+	return nil
+}
+
+func (s *promptService) Preview(ctx context.Context, name string, req *dto.PreviewPromptRequest) (*dto.PreviewPromptResponse, error) {
+	promptName, err := s.parseName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.PreviewPromptResponse{Problems: []string{}}
+	if err := enum.ValidatePrompt(promptName, req.Content); err != nil {
+		resp.Problems = strings.Split(err.Error(), "\n")
+	}
+	// 工具列表在对话时按实际可用的工具生成, 预览时用说明文字代替
+	resp.Rendered = strings.ReplaceAll(global.RenderPrompt(req.Content), enum.PromptVarTools, "(此处为当前可用的工具列表)")
+	return resp, nil
+}
+
+// parseName 检查提示词名称是否存在
+func (s *promptService) parseName(name string) (enum.PromptName, error) {
+	promptName := enum.PromptName(name)
+	if _, ok := enum.BuiltinPrompts[promptName]; !ok {
+		return "", errors.New("提示词不存在")
+	}
+	return promptName, nil
+}
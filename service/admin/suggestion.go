@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/task"
+)
+
+// SuggestionService 定义知识条目建议的审核接口。
+type SuggestionService interface {
+	// ListSuggestions 列出指定状态的建议, status 为空时列出待审核的建议。
+	ListSuggestions(ctx context.Context, status string) ([]*dto.KnowledgeSuggestion, error)
+	// ApproveSuggestion 审核通过建议, 以AI语义类型的问题创建知识条目后删除该建议。
+	ApproveSuggestion(ctx context.Context, id string, req *dto.ApproveSuggestionRequest) error
+	// RejectSuggestion 拒绝建议; 已拒绝的建议会保留, 避免同类问题被再次提出。
+	RejectSuggestion(ctx context.Context, id string) error
+}
+
+type suggestionService struct {
+	keywords KeywordService
+}
+
+// NewSuggestionService 创建 SuggestionService 实例。
+func NewSuggestionService(tm *task.Manager) SuggestionService {
+	return &suggestionService{keywords: NewKeywordService(tm)}
+}
+
+func (s *suggestionService) ListSuggestions(ctx context.Context, status string) ([]*dto.KnowledgeSuggestion, error) {
+	if status == "" {
+		status = string(enum.KnowledgeSuggestionPending)
+	}
+	all, err := dao.App.SuggestionsDb.ListSuggestions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]*dto.KnowledgeSuggestion, 0, len(all))
+	for _, suggestion := range all {
+		if suggestion.Status != status {
+			continue
+		}
+		// 向量只用于聚类, 不返回给前端
+		suggestion.Embedding = nil
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
+
+func (s *suggestionService) ApproveSuggestion(ctx context.Context, id string, req *dto.ApproveSuggestionRequest) error {
+	suggestion, err := s.getPending(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	item := &dto.UpsertKnowledgeItemRequest{Answer: strings.TrimSpace(req.Answer), Questions: req.Questions, Force: req.Force}
+	if item.Answer == "" {
+		item.Answer = suggestion.Answer
+	}
+	if len(item.Questions) == 0 {
+		for _, q := range suggestion.Questions {
+			item.Questions = append(item.Questions, &dto.Question{Question: q, Type: "AI_SEMANTIC"})
+		}
+	}
+	if item.Answer == "" || len(item.Questions) == 0 {
+		return errors.New("建议缺少问题或答案, 请编辑后再通过")
+	}
+
+	if err := s.keywords.UpsertItem(ctx, item); err != nil {
+		return fmt.Errorf("创建知识条目失败: %w", err)
+	}
+	if err := dao.App.SuggestionsDb.DeleteSuggestion(ctx, id); err != nil {
+		global.Log.Warnf("知识条目已创建, 但删除建议 %s 失败: %v", id, err)
+	}
+	return nil
+}
+
+func (s *suggestionService) RejectSuggestion(ctx context.Context, id string) error {
+	suggestion, err := s.getPending(ctx, id)
+	if err != nil {
+		return err
+	}
+	suggestion.Status = string(enum.KnowledgeSuggestionRejected)
+	return dao.App.SuggestionsDb.SaveSuggestion(ctx, suggestion)
+}
+
+func (s *suggestionService) getPending(ctx context.Context, id string) (*dto.KnowledgeSuggestion, error) {
+	suggestion, err := dao.App.SuggestionsDb.GetSuggestion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if suggestion == nil {
+		return nil, errors.New("建议不存在")
+	}
+	if suggestion.Status != string(enum.KnowledgeSuggestionPending) {
+		return nil, errors.New("该建议已处理")
+	}
+	return suggestion, nil
+}
@@ -0,0 +1,444 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/internal/xlsx"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/task"
+	"gitee.com/taoJie_1/mall-agent/utils"
+)
+
+const (
+	maxImportRows    = 5000             // 单个导入文件的最大行数
+	importBatchSize  = 20               // 每批导入的条目数, 每批完成后更新一次进度
+	importLockExpiry = 10 * time.Minute // 导入任务锁的过期时间, 每批完成后续期; 实例异常退出后锁过期即可再次导入, 进度显示为已中断
+)
+
+// 导入导出文件的表头, 导入时也接受对应的中文表头
+var (
+	transferHeader  = []string{"item_id", "answer", "question", "type"}
+	transferAliases = map[string]string{"条目id": "item_id", "答案": "answer", "问题": "question", "类型": "type"}
+)
+
+// KnowledgeTransferService 定义知识库批量导入导出接口。
+type KnowledgeTransferService interface {
+	// Export 导出所有知识条目; csv 和 xlsx 每行一个问题, jsonl 每行一个知识条目。
+	Export(ctx context.Context, format enum.KnowledgeFileFormat) ([]byte, error)
+	// Preview 校验导入文件并与现有知识条目比较(dry-run), 不做任何修改。
+	Preview(ctx context.Context, format enum.KnowledgeFileFormat, data []byte) (*dto.ImportPreview, error)
+	// Import 校验通过后在后台分批导入, 所有批次完成后只触发一次防抖的知识库重同步。
+	// 批量导入只检测精确关键词冲突, 不做逐条的语义近似检测(需要为每个问题创建向量), 导入后可在冲突报告中查看。
+	Import(ctx context.Context, format enum.KnowledgeFileFormat, data []byte) (*dto.ImportJob, error)
+	// ImportProgress 查询最近一次导入任务的进度, 没有导入任务时返回nil; 长时间未更新进度的任务显示为已中断。
+	ImportProgress(ctx context.Context) (*dto.ImportJob, error)
+}
+
+type knowledgeTransferService struct {
+	keywords *keywordService
+}
+
+// NewKnowledgeTransferService 创建 KnowledgeTransferService 实例。
+func NewKnowledgeTransferService(tm *task.Manager) KnowledgeTransferService {
+	return &knowledgeTransferService{keywords: &keywordService{taskManager: tm}}
+}
+
+func (s *knowledgeTransferService) Export(ctx context.Context, format enum.KnowledgeFileFormat) ([]byte, error) {
+	items, err := s.keywords.ListItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if format == enum.KnowledgeFileJsonl {
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				return nil, fmt.Errorf("序列化知识条目失败: %w", err)
+			}
+		}
+		return buf.Bytes(), nil
+	}
+
+	rows := [][]string{transferHeader}
+	for _, item := range items {
+		for _, q := range item.Questions {
+			rows = append(rows, []string{item.ID, item.Answer, q.Question, q.Type})
+		}
+	}
+	switch format {
+	case enum.KnowledgeFileXlsx:
+		if err := xlsx.Write(&buf, "知识库", rows); err != nil {
+			return nil, fmt.Errorf("生成Excel文件失败: %w", err)
+		}
+	case enum.KnowledgeFileCsv:
+		// 带BOM, 避免Excel打开时中文乱码
+		buf.WriteString("\ufeff")
+		w := csv.NewWriter(&buf)
+		if err := w.WriteAll(rows); err != nil {
+			return nil, fmt.Errorf("生成CSV文件失败: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的文件格式: %s", format)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *knowledgeTransferService) Preview(ctx context.Context, format enum.KnowledgeFileFormat, data []byte) (*dto.ImportPreview, error) {
+	rows, err := parseKnowledgeRows(format, data)
+	if err != nil {
+		return nil, err
+	}
+	return s.plan(ctx, rows)
+}
+
+func (s *knowledgeTransferService) Import(ctx context.Context, format enum.KnowledgeFileFormat, data []byte) (*dto.ImportJob, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+	preview, err := s.Preview(ctx, format, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(preview.Errors) > 0 {
+		return nil, fmt.Errorf("导入文件有 %d 处错误, 请预览并修正后再导入", len(preview.Errors))
+	}
+	if len(preview.Changes) == 0 {
+		return nil, errors.New("导入文件与现有知识库一致, 没有需要导入的变更")
+	}
+
+	now := time.Now().Unix()
+	job := &dto.ImportJob{
+		ID:        strconv.FormatInt(time.Now().UnixNano(), 10),
+		Status:    string(enum.ImportJobRunning),
+		Actor:     actorFrom(ctx),
+		Total:     len(preview.Changes),
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	lockKey := global.TenantFrom(ctx).Key(redis.KeyKnowledgeImportLock)
+	locked, err := global.RedisClient.SetNX(ctx, lockKey, job.ID, importLockExpiry).Result()
+	if err != nil {
+		return nil, fmt.Errorf("获取导入锁失败: %w", err)
+	}
+	if !locked {
+		return nil, errors.New("已有导入任务正在进行, 请等待完成后再导入")
+	}
+	if err := dao.App.KnowledgeImportDb.SaveImportJob(ctx, job); err != nil {
+		global.RedisClient.Del(ctx, lockKey)
+		return nil, err
+	}
+
+	// 后台任务修改的是副本, 返回给调用方的任务不会被并发修改
+	go s.run(context.WithoutCancel(ctx), *job, preview.Changes)
+	return job, nil
+}
+
+func (s *knowledgeTransferService) ImportProgress(ctx context.Context) (*dto.ImportJob, error) {
+	job, err := dao.App.KnowledgeImportDb.GetImportJob(ctx)
+	if err != nil || job == nil {
+		return job, err
+	}
+	// 执行导入的实例异常退出后进度不再更新, 超过锁的过期时间即视为中断
+	if job.Status == string(enum.ImportJobRunning) && time.Since(time.Unix(job.UpdatedAt, 0)) > importLockExpiry {
+		job.Status = string(enum.ImportJobInterrupted)
+	}
+	return job, nil
+}
+
+// run 分批导入条目并更新进度, 每批完成后续期导入锁; 导入时不逐条缓存新条目, 全部完成后统一由防抖的重同步任务更新缓存
+func (s *knowledgeTransferService) run(ctx context.Context, job dto.ImportJob, changes []*dto.ImportItemChange) {
+	lockKey := global.TenantFrom(ctx).Key(redis.KeyKnowledgeImportLock)
+	defer func() {
+		// 锁已过期并被新的导入任务获取时不能释放
+		if owner, err := global.RedisClient.Get(ctx, lockKey).Result(); err == nil && owner == job.ID {
+			global.RedisClient.Del(ctx, lockKey)
+		}
+	}()
+
+	for start := 0; start < len(changes); start += importBatchSize {
+		for _, change := range changes[start:min(start+importBatchSize, len(changes))] {
+			req := &dto.UpsertKnowledgeItemRequest{ID: change.ItemID, Answer: change.Item.Answer, Questions: change.Item.Questions}
+			if _, err := s.keywords.upsert(ctx, req); err != nil {
+				job.Failed++
+				job.Errors = append(job.Errors, fmt.Sprintf("第 %d 行: %v", change.Rows[0], err))
+			}
+			job.Processed++
+		}
+		job.UpdatedAt = time.Now().Unix()
+		if job.Processed == job.Total {
+			job.Status = string(enum.ImportJobDone)
+			job.FinishedAt = job.UpdatedAt
+		}
+		if err := dao.App.KnowledgeImportDb.SaveImportJob(ctx, &job); err != nil {
+			global.Log.Warnf("更新导入任务 %s 的进度失败: %v", job.ID, err)
+		}
+		global.RedisClient.Expire(ctx, lockKey, importLockExpiry)
+	}
+
+	debounceDelay := time.Duration(global.Config.Ai.KeywordReloadDebounce) * time.Second
+	s.keywords.taskManager.DebounceKeywordReload(debounceDelay)
+	global.Log.Infof("知识库导入任务 %s 完成: 共 %d 个条目, 失败 %d 个, 操作人 %s", job.ID, job.Total, job.Failed, job.Actor)
+}
+
+// importGroup 导入文件中属于同一知识条目的行
+type importGroup struct {
+	key       string // 指定的条目ID, 未指定时为答案的哈希值
+	explicit  bool   // 是否在文件中指定了条目ID
+	answer    string
+	rows      []int
+	questions []*dto.Question
+}
+
+// plan 校验导入的行, 按条目分组后与现有知识条目比较
+func (s *knowledgeTransferService) plan(ctx context.Context, rows []dto.KnowledgeRow) (*dto.ImportPreview, error) {
+	existing, err := s.keywords.ListItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.planChanges(rows, existing), nil
+}
+
+// planChanges 校验导入的行并与现有知识条目比较; 只检测精确关键词冲突, 语义近似检测见冲突报告
+func (s *knowledgeTransferService) planChanges(rows []dto.KnowledgeRow, existing []*dto.KnowledgeItem) *dto.ImportPreview {
+	existingByID := make(map[string]*dto.KnowledgeItem, len(existing))
+	for _, item := range existing {
+		existingByID[item.ID] = item
+	}
+
+	preview := &dto.ImportPreview{Rows: len(rows), Errors: []dto.ImportRowError{}, Changes: []*dto.ImportItemChange{}}
+	addError := func(row int, format string, args ...any) {
+		preview.Errors = append(preview.Errors, dto.ImportRowError{Row: row, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(rows) > maxImportRows {
+		addError(0, "导入文件最多 %d 行, 当前 %d 行", maxImportRows, len(rows))
+		return preview
+	}
+
+	groups := make(map[string]*importGroup)
+	var order []*importGroup
+	for _, row := range rows {
+		if row.Answer == "" {
+			addError(row.Row, "答案不能为空")
+			continue
+		}
+		if row.Question == "" {
+			addError(row.Row, "问题不能为空")
+			continue
+		}
+		qType := enum.KeywordType(strings.ToUpper(row.Type))
+		switch qType {
+		case "":
+			qType = enum.KeywordTypeExact
+		case enum.KeywordTypeExact, enum.KeywordTypeHybrid, enum.KeywordTypeSemantic:
+		default:
+			addError(row.Row, "类型 %q 无效, 应为 %s、%s 或 %s", row.Type, enum.KeywordTypeExact, enum.KeywordTypeHybrid, enum.KeywordTypeSemantic)
+			continue
+		}
+		if n := utf8.RuneCountInString(s.keywords.buildShortCode(string(qType), row.Question)); n > int(global.Config.Ai.MaxShortCodeLength) {
+			addError(row.Row, "问题加上类型前缀后共 %d 个字符, 超过 %d 个字符的限制", n, global.Config.Ai.MaxShortCodeLength)
+			continue
+		}
+
+		key := row.ItemID
+		if key == "" {
+			key = utils.Hash(row.Answer)
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &importGroup{key: key, answer: row.Answer}
+			groups[key] = group
+			order = append(order, group)
+		} else if group.answer != row.Answer {
+			addError(row.Row, "与第 %d 行属于同一条目, 但答案不一致", group.rows[0])
+			continue
+		}
+		group.explicit = group.explicit || row.ItemID != ""
+
+		duplicated := false
+		for i, q := range group.questions {
+			if q.Type == string(qType) && q.Question == row.Question {
+				addError(row.Row, "问题与第 %d 行重复", group.rows[i])
+				duplicated = true
+				break
+			}
+		}
+		if duplicated {
+			continue
+		}
+		group.rows = append(group.rows, row.Row)
+		group.questions = append(group.questions, &dto.Question{Question: row.Question, Type: string(qType)})
+	}
+
+	// 精确匹配的关键词在缓存中以小写为键, 重复时后者会覆盖前者
+	keywordRows := make(map[string]int)
+	for _, group := range order {
+		if group.explicit && existingByID[group.key] == nil {
+			addError(group.rows[0], "条目 %s 不存在, 新增条目请将条目ID留空", group.key)
+		}
+		if len(group.questions) > maxQuestionsPerItem {
+			addError(group.rows[0], "每个知识条目最多只能关联 %d 个问题, 该条目有 %d 个", maxQuestionsPerItem, len(group.questions))
+		}
+		for i, q := range group.questions {
+			if q.Type == string(enum.KeywordTypeSemantic) {
+				continue
+			}
+			keyword := strings.ToLower(q.Question)
+			if row, ok := keywordRows[keyword]; ok {
+				addError(group.rows[i], "精确匹配关键词与第 %d 行重复", row)
+				continue
+			}
+			keywordRows[keyword] = group.rows[i]
+		}
+	}
+	for _, item := range existing {
+		if groups[item.ID] != nil {
+			continue
+		}
+		for _, q := range item.Questions {
+			if q.Type == string(enum.KeywordTypeSemantic) {
+				continue
+			}
+			if row, ok := keywordRows[strings.ToLower(q.Question)]; ok {
+				addError(row, "精确匹配关键词与现有条目 %s 重复", item.ID)
+			}
+		}
+	}
+
+	for _, group := range order {
+		before := existingByID[group.key]
+		after := &dto.KnowledgeItem{ID: utils.Hash(group.answer), Answer: group.answer, Questions: group.questions}
+		diff := diffKnowledgeItems(before, after)
+		if before != nil && diff.AnswerBefore == diff.AnswerAfter && len(diff.AddedQuestions) == 0 && len(diff.RemovedQuestions) == 0 {
+			preview.Unchanged++
+			continue
+		}
+
+		change := &dto.ImportItemChange{Action: string(enum.KnowledgeActionCreate), Rows: group.rows, Diff: diff, Item: after}
+		if before != nil {
+			change.Action = string(enum.KnowledgeActionUpdate)
+			change.ItemID = before.ID
+			preview.Updated++
+		} else {
+			preview.Created++
+		}
+		preview.Changes = append(preview.Changes, change)
+	}
+	return preview
+}
+
+// parseKnowledgeRows 解析导入文件, 跳过空行; csv 和 xlsx 的第一行为表头
+func parseKnowledgeRows(format enum.KnowledgeFileFormat, data []byte) ([]dto.KnowledgeRow, error) {
+	var records [][]string
+	switch format {
+	case enum.KnowledgeFileJsonl:
+		return parseJsonlRows(data)
+	case enum.KnowledgeFileCsv:
+		r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+		r.FieldsPerRecord = -1
+		var err error
+		if records, err = r.ReadAll(); err != nil {
+			return nil, fmt.Errorf("解析CSV文件失败: %w", err)
+		}
+	case enum.KnowledgeFileXlsx:
+		// 多读一行表头
+		var err error
+		if records, err = xlsx.Read(bytes.NewReader(data), int64(len(data)), maxImportRows+1); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("不支持的文件格式: %s", format)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("导入文件为空")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if alias, ok := transferAliases[name]; ok {
+			name = alias
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"answer", "question"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("导入文件缺少 %s 列, 表头应为 %s", name, strings.Join(transferHeader, ","))
+		}
+	}
+	cell := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := make([]dto.KnowledgeRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		rows = append(rows, dto.KnowledgeRow{
+			Row:      i + 2,
+			ItemID:   cell(record, "item_id"),
+			Answer:   cell(record, "answer"),
+			Question: cell(record, "question"),
+			Type:     cell(record, "type"),
+		})
+	}
+	return rows, nil
+}
+
+// parseJsonlRows 解析每行一个知识条目的 jsonl 文件, 条目的每个问题展开为一行, 行号为文件中的行号
+func parseJsonlRows(data []byte) ([]dto.KnowledgeRow, error) {
+	var rows []dto.KnowledgeRow
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var item dto.KnowledgeItem
+		if err := json.Unmarshal([]byte(text), &item); err != nil {
+			return nil, fmt.Errorf("第 %d 行不是有效的JSON: %w", line, err)
+		}
+		answer := strings.TrimSpace(item.Answer)
+		if len(item.Questions) == 0 {
+			// 保留一行空问题, 由校验提示该条目没有问题
+			rows = append(rows, dto.KnowledgeRow{Row: line, ItemID: item.ID, Answer: answer})
+			continue
+		}
+		for _, q := range item.Questions {
+			if q == nil {
+				continue
+			}
+			rows = append(rows, dto.KnowledgeRow{Row: line, ItemID: item.ID, Answer: answer, Question: strings.TrimSpace(q.Question), Type: strings.TrimSpace(q.Type)})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取JSONL文件失败: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("导入文件为空")
+	}
+	return rows, nil
+}
@@ -0,0 +1,125 @@
+package admin
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/xlsx"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/utils"
+)
+
+func TestParseKnowledgeRows(t *testing.T) {
+	want := []dto.KnowledgeRow{
+		{Row: 2, Answer: "支持7天无理由退货", Question: "怎么退货", Type: "EXACT"},
+		{Row: 4, ItemID: "abc", Answer: "48小时内发货", Question: "什么时候发货"},
+	}
+
+	// csv 带BOM、中文表头, 并跳过空行
+	csvData := "\ufeff答案,问题,类型,条目ID\n支持7天无理由退货, 怎么退货 ,EXACT,\n,,,\n48小时内发货,什么时候发货,,abc\n"
+	if got, err := parseKnowledgeRows(enum.KnowledgeFileCsv, []byte(csvData)); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("csv: parseKnowledgeRows() = %+v, %v; want %+v", got, err, want)
+	}
+
+	var buf bytes.Buffer
+	if err := xlsx.Write(&buf, "知识库", [][]string{
+		{"item_id", "answer", "question", "type"},
+		{"", "支持7天无理由退货", "怎么退货", "EXACT"},
+		{},
+		{"abc", "48小时内发货", "什么时候发货", ""},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := parseKnowledgeRows(enum.KnowledgeFileXlsx, buf.Bytes()); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("xlsx: parseKnowledgeRows() = %+v, %v; want %+v", got, err, want)
+	}
+
+	jsonl := `{"answer":"支持7天无理由退货","questions":[{"question":"怎么退货","type":"EXACT"}]}` + "\n\n" +
+		`{"id":"abc","answer":"48小时内发货","questions":[{"question":"什么时候发货"}]}`
+	jsonlWant := []dto.KnowledgeRow{want[0], want[1]}
+	jsonlWant[0].Row, jsonlWant[1].Row = 1, 3
+	if got, err := parseKnowledgeRows(enum.KnowledgeFileJsonl, []byte(jsonl)); err != nil || !reflect.DeepEqual(got, jsonlWant) {
+		t.Errorf("jsonl: parseKnowledgeRows() = %+v, %v; want %+v", got, err, jsonlWant)
+	}
+
+	for name, data := range map[enum.KnowledgeFileFormat]string{
+		enum.KnowledgeFileCsv:   "问题,类型\n怎么退货,EXACT\n",
+		enum.KnowledgeFileJsonl: "{not json}",
+		enum.KnowledgeFileXlsx:  "not a zip",
+	} {
+		if _, err := parseKnowledgeRows(name, []byte(data)); err == nil {
+			t.Errorf("%s: 无效文件应返回错误", name)
+		}
+	}
+}
+
+func TestPlanChanges(t *testing.T) {
+	global.Config.Ai.MaxShortCodeLength = 20
+	global.Config.Ai.HybridPrefix = "ai+@"
+	global.Config.Ai.SemanticPrefix = "ai@"
+	defer func() {
+		global.Config.Ai.MaxShortCodeLength, global.Config.Ai.HybridPrefix, global.Config.Ai.SemanticPrefix = 0, "", ""
+	}()
+
+	existing := []*dto.KnowledgeItem{
+		{ID: "ship", Answer: "48小时内发货", Questions: []*dto.Question{{Question: "什么时候发货", Type: "EXACT"}}},
+		{ID: "refund", Answer: "3个工作日内退款", Questions: []*dto.Question{{Question: "退款多久到账", Type: "EXACT"}}},
+	}
+	s := &knowledgeTransferService{keywords: &keywordService{}}
+
+	t.Run("新增更新和不变", func(t *testing.T) {
+		preview := s.planChanges([]dto.KnowledgeRow{
+			{Row: 2, ItemID: "ship", Answer: "48小时内发货", Question: "什么时候发货"},
+			{Row: 3, ItemID: "refund", Answer: "3个工作日内退款", Question: "退款多久到账"},
+			{Row: 4, ItemID: "refund", Answer: "3个工作日内退款", Question: "钱什么时候退回", Type: "ai_semantic"},
+			{Row: 5, Answer: "支持7天无理由退货", Question: "怎么退货"},
+			{Row: 6, Answer: "支持7天无理由退货", Question: "能退货吗", Type: "HYBRID"},
+		}, existing)
+		if len(preview.Errors) != 0 {
+			t.Fatalf("不应有错误: %+v", preview.Errors)
+		}
+		if preview.Unchanged != 1 || preview.Updated != 1 || preview.Created != 1 || len(preview.Changes) != 2 {
+			t.Fatalf("preview = %+v", preview)
+		}
+		update, create := preview.Changes[0], preview.Changes[1]
+		if update.Action != string(enum.KnowledgeActionUpdate) || update.ItemID != "refund" || !reflect.DeepEqual(update.Rows, []int{3, 4}) {
+			t.Errorf("更新 = %+v", update)
+		}
+		if create.Action != string(enum.KnowledgeActionCreate) || create.Item.ID != utils.Hash("支持7天无理由退货") || len(create.Item.Questions) != 2 {
+			t.Errorf("新增 = %+v", create)
+		}
+	})
+
+	t.Run("校验错误", func(t *testing.T) {
+		preview := s.planChanges([]dto.KnowledgeRow{
+			{Row: 2, Question: "没有答案"},
+			{Row: 3, Answer: "答案", Question: "类型错误", Type: "FUZZY"},
+			{Row: 4, Answer: "答案", Question: strings.Repeat("长", 18), Type: "HYBRID"},
+			{Row: 5, ItemID: "missing", Answer: "答案二", Question: "条目不存在"},
+			{Row: 6, Answer: "答案三", Question: "重复问题"},
+			{Row: 7, Answer: "答案三", Question: "重复问题"},
+			{Row: 8, Answer: "答案四", Question: "退款多久到账"},
+			{Row: 9, ItemID: "ship", Answer: "答案五", Question: "答案不一致"},
+			{Row: 10, ItemID: "ship", Answer: "48小时内发货", Question: "发货时间"},
+		}, existing)
+		rows := make([]int, 0, len(preview.Errors))
+		for _, e := range preview.Errors {
+			rows = append(rows, e.Row)
+		}
+		// 同一条目答案不一致时以先出现的行为准; 条目级和与现有条目比较的错误在逐行校验之后
+		if want := []int{2, 3, 4, 7, 10, 5, 8}; !reflect.DeepEqual(rows, want) {
+			t.Errorf("错误行 = %v, want %v; errors = %+v", rows, want, preview.Errors)
+		}
+	})
+
+	t.Run("行数超限", func(t *testing.T) {
+		preview := s.planChanges(make([]dto.KnowledgeRow, maxImportRows+1), existing)
+		if len(preview.Errors) != 1 || preview.Errors[0].Row != 0 {
+			t.Errorf("errors = %+v", preview.Errors)
+		}
+	})
+}
@@ -2,7 +2,9 @@ package user
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"gitee.com/taoJie_1/mall-agent/model/common"
 	"gitee.com/taoJie_1/mall-agent/model/enum"
 	"gitee.com/taoJie_1/mall-agent/utils"
+	"github.com/sashabaranov/go-openai"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -19,23 +22,59 @@ type ActionService interface {
 	// 检查并发送商品/订单卡片
 	CheckAndSendProductCard(ctx context.Context, conversationID uint, attrs common.CustomAttributes)
 	// 转接人工客服
-	TransferToHuman(ConversationID uint, remark enum.TransferToHuman, message ...string) error
+	TransferToHuman(ctx context.Context, ConversationID uint, remark enum.TransferToHuman, message ...string) error
 	// 将会话状态设置为机器人处理
-	SetConversationPending(conversationID uint) error
+	SetConversationPending(ctx context.Context, conversationID uint) error
 	// 切换输入状态
-	ToggleTyping(conversationID uint, status bool)
+	ToggleTyping(ctx context.Context, conversationID uint, status bool)
 	// 发送消息
-	SendMessage(conversationID uint, content string)
+	SendMessage(ctx context.Context, conversationID uint, content string)
+	// 发送LLM回复，若包含 <interactive> 标签则渲染为交互式消息，返回应计入历史记录的文本
+	SendReply(ctx context.Context, conversationID uint, answer string) (string, error)
+	// 通过MCP查询订单并发送订单卡片，返回卡片的文本描述
+	SendOrderCard(ctx context.Context, conversationID uint, orderID string) (string, error)
 	// 匹配预设回复或执行特殊动作（如转人工）
-	MatchCannedResponse(chatRequest *common.ChatRequest) (string, bool, error)
+	MatchCannedResponse(ctx context.Context, chatRequest *common.ChatRequest) (string, bool, error)
 	// 设置人工模式宽限期
 	ActivateHumanModeGracePeriod(ctx context.Context, conversationID uint)
 	// 刷新人工模式宽限期
 	RefreshHumanModeGracePeriod(ctx context.Context, conversationID uint)
+	// 保存用户在联系方式表单中提交的信息, 返回false表示提交的不是联系方式表单
+	SaveContactDetails(ctx context.Context, conversationID uint, values []common.SubmittedValue) bool
+	// 将到期的预约转人工会话重新打开
+	ReopenScheduledConversations(ctx context.Context) error
+	// 取消会话的预约转人工
+	CancelScheduledReopen(ctx context.Context, conversationID uint)
 }
 
+const (
+	// maxInteractiveItems 交互式消息中最多展示的选项/文章数量
+	maxInteractiveItems = 10
+	// maxOrderCards 一次回复中最多发送的订单卡片数量
+	maxOrderCards = 3
+	// interactiveTypeOrderCard 是LLM请求发送订单卡片时使用的交互类型，不对应Chatwoot原生类型
+	interactiveTypeOrderCard chatwoot.ContentType = "order_card"
+)
+
 type actionService struct {
 	transferKeywords map[string]struct{}
+	dashboard        DashboardService
+	routing          RoutingService
+	availability     AvailabilityService
+	tool             ToolService
+	llm              LlmService
+	experiment       ExperimentService
+	csat             CsatService
+}
+
+// handoffHistoryLimit 交接摘要中参考的最近对话条数
+const handoffHistoryLimit = 20
+
+// contactFormItems 非工作时间转人工时收集用户联系方式的表单
+var contactFormItems = []chatwoot.FormItem{
+	{Name: "contact_name", Label: "称呼", Type: "text", Placeholder: "请输入您的称呼"},
+	{Name: "contact_phone", Label: "联系电话", Type: "text", Placeholder: "请输入您的手机号"},
+	{Name: "contact_note", Label: "问题描述", Type: "text_area", Placeholder: "请简要描述您的问题(选填)"},
 }
 
 // noGracePeriodReasons 定义了哪些转人工原因不需要设置宽限期，应立即转接
@@ -56,43 +95,68 @@ func NewActionService() ActionService {
 
 	return &actionService{
 		transferKeywords: transferSet,
+		dashboard:        NewDashboardService(),
+		routing:          NewRoutingService(),
+		availability:     NewAvailabilityService(),
+		tool:             NewToolService(),
+		llm:              NewLlmService(),
+		experiment:       NewExperimentService(),
+		csat:             NewCsatService(),
 	}
 }
 
-func (a *actionService) TransferToHuman(ConversationID uint, remark enum.TransferToHuman, message ...string) error {
-	if global.ChatwootService == nil {
+func (a *actionService) TransferToHuman(ctx context.Context, ConversationID uint, remark enum.TransferToHuman, message ...string) error {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
 		return fmt.Errorf("Chatwoot客户端未初始化")
 	}
+	// 转人工通常发生在AI流程超时或出错之后, 不受调用方上下文取消的影响
+	ctx = context.WithoutCancel(ctx)
+
+	// 非工作时间或无客服在线时, 由AI继续接待并预约稍后转人工; 延后的转接在实际转接时才计入实验
+	if a.deferTransfer(ctx, ConversationID, remark) {
+		return nil
+	}
+	a.experiment.RecordTransfer(ctx, ConversationID)
+	a.csat.MarkHumanHandled(ctx, ConversationID)
+	a.tool.DiscardPending(ctx, ConversationID)
+
+	// 交接摘要依赖LLM, 异步生成, 不阻塞转接
+	go a.sendHandoffSummary(ctx, ConversationID, remark)
 
 	// 同步设置宽限期标志
 	gracePeriod := time.Duration(global.Config.Ai.TransferGracePeriod) * time.Second
 	if gracePeriod > 0 && utils.InSlice(noGracePeriodReasons, remark) == -1 {
 		if global.RedisClient != nil {
-			key := fmt.Sprintf("%s%d", redis.KeyPrefixTransferGracePeriod, ConversationID)
-			if err := global.RedisClient.Set(context.Background(), key, "1", gracePeriod).Err(); err != nil {
+			key := global.ConversationKey(ctx, redis.KeyPrefixTransferGracePeriod, ConversationID)
+			if err := global.RedisClient.Set(ctx, key, "1", gracePeriod).Err(); err != nil {
 				global.Log.Warnf("[action]为会话 %d 设置转人工宽限期标志失败: %v", ConversationID, err)
 			}
 		}
 	}
 
-	g, _ := errgroup.WithContext(context.Background())
+	g, _ := errgroup.WithContext(ctx)
 
 	// 创建私信备注
 	if remark != "" {
 		g.Go(func() error {
-			if err := global.ChatwootService.CreatePrivateNote(ConversationID, string(remark)); err != nil {
+			if err := chatwootClient.CreatePrivateNote(ConversationID, string(remark)); err != nil {
 				global.Log.Warnf("[action]为会话 %d 创建转人工备注失败: %v", ConversationID, err)
 			}
 			return nil
 		})
 	}
 	g.Go(func() error {
-		if err := global.ChatwootService.SetConversationStatus(ConversationID, chatwoot.ConversationStatusOpen); err != nil {
+		if err := chatwootClient.SetConversationStatus(ConversationID, chatwoot.ConversationStatusOpen); err != nil {
 			global.Log.Errorf("[action]转接会话 %d 至人工客服失败: %v", ConversationID, err)
 			return err
 		}
 		return nil
 	})
+	g.Go(func() error {
+		a.routing.Route(ctx, ConversationID)
+		return nil
+	})
 
 	userMessage := ""
 	if utils.InSlice(noGracePeriodReasons, remark) != -1 {
@@ -107,7 +171,7 @@ func (a *actionService) TransferToHuman(ConversationID uint, remark enum.Transfe
 
 	if userMessage != "" {
 		g.Go(func() error {
-			if err := global.ChatwootService.CreateMessage(ConversationID, userMessage); err != nil {
+			if err := chatwootClient.CreateMessage(ConversationID, userMessage); err != nil {
 				global.Log.Warnf("[action]为会话 %d 发送转人工提示失败: %v", ConversationID, err)
 			}
 			return nil
@@ -117,39 +181,439 @@ func (a *actionService) TransferToHuman(ConversationID uint, remark enum.Transfe
 	return g.Wait()
 }
 
-func (a *actionService) SetConversationPending(conversationID uint) error {
-	if global.ChatwootService == nil {
+// deferTransfer 人工客服不可接待时预约转人工, 返回true表示已预约, 无需立即转接
+func (a *actionService) deferTransfer(ctx context.Context, conversationID uint, remark enum.TransferToHuman) bool {
+	if !global.Config.BusinessHours.Enable || global.RedisClient == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	routingCtx := a.routing.GetContext(ctx, conversationID)
+	availability := a.availability.Check(ctx, routingCtx.InboxID)
+	if availability.Available {
+		return false
+	}
+
+	added, err := global.RedisClient.ZAddNX(ctx, redis.KeyScheduledReopen, &redis.Z{Score: float64(availability.ResumeAt.Unix()), Member: tenantConversationMember(ctx, conversationID)}).Result()
+	if err != nil {
+		// 无法预约时按原逻辑立即转人工
+		global.Log.Warnf("[action]为会话 %d 预约转人工失败: %v", conversationID, err)
+		return false
+	}
+
+	resumeAt := availability.ResumeAt.In(global.Tz).Format("01月02日 15:04")
+	global.Log.Debugf("[action]会话 %d 当前无人工客服可接待(非工作时间: %v)，已预约于 %s 转人工", conversationID, availability.OffHours, resumeAt)
+
+	if remark != "" {
+		note := fmt.Sprintf("%s（当前无人工客服可接待，已预约于 %s 转人工）", remark, resumeAt)
+		if err := global.TenantFrom(ctx).Chatwoot().CreatePrivateNote(conversationID, note); err != nil {
+			global.Log.Warnf("[action]为会话 %d 创建预约转人工备注失败: %v", conversationID, err)
+		}
+	}
+
+	replyMsg := enum.ReplyMsgNoAgentOnline
+	if availability.OffHours {
+		replyMsg = enum.ReplyMsgOffHours
+	}
+	content := fmt.Sprintf(string(replyMsg), resumeAt)
+
+	// 同一次预约只发送一次联系方式表单
+	if added == 0 {
+		a.SendMessage(ctx, conversationID, content)
+	} else if err := global.TenantFrom(ctx).Chatwoot().CreateFormMessage(conversationID, content, contactFormItems); err != nil {
+		global.Log.Warnf("[action]向会话 %d 发送联系方式表单失败: %v", conversationID, err)
+		a.SendMessage(ctx, conversationID, content)
+	}
+	return true
+}
+
+// sendHandoffSummary 汇总会话资料, 生成交接摘要并以私信备注发送给人工客服
+func (a *actionService) sendHandoffSummary(ctx context.Context, conversationID uint, remark enum.TransferToHuman) {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	material := a.buildHandoffMaterial(ctx, conversationID, remark)
+	summary, err := a.llm.SummarizeHandoff(ctx, material)
+	if err != nil || summary == "" {
+		// 摘要生成失败时直接附上整理好的原始资料
+		global.Log.Warnf("[action]为会话 %d 生成交接摘要失败: %v", conversationID, err)
+		summary = material
+	}
+
+	if err := chatwootClient.CreatePrivateNote(conversationID, "### 转人工交接摘要\n"+summary); err != nil {
+		global.Log.Warnf("[action]为会话 %d 发送交接摘要失败: %v", conversationID, err)
+	}
+}
+
+// buildHandoffMaterial 整理转人工原因、分诊结果、单号、工具调用和最近对话, 以Markdown形式提供给LLM
+func (a *actionService) buildHandoffMaterial(ctx context.Context, conversationID uint, remark enum.TransferToHuman) string {
+	var builder strings.Builder
+	if remark != "" {
+		fmt.Fprintf(&builder, "**转人工原因**：%s\n", remark)
+	}
+
+	routingCtx := a.routing.GetContext(ctx, conversationID)
+	if routingCtx.Intent != "" {
+		fmt.Fprintf(&builder, "**分诊**：%s / %s / %s\n", routingCtx.Intent, routingCtx.Emotion, routingCtx.Urgency)
+	} else {
+		builder.WriteString("**分诊**：未分诊\n")
+	}
+
+	if global.RedisClient != nil {
+		goodsID, _ := global.RedisClient.Get(ctx, global.ConversationKey(ctx, redis.KeyPrefixLastProductSent, conversationID)).Result()
+		orderID, _ := global.RedisClient.Get(ctx, global.ConversationKey(ctx, redis.KeyPrefixLastOrderSent, conversationID)).Result()
+		if goodsID != "" {
+			fmt.Fprintf(&builder, "**咨询商品ID**：%s\n", goodsID)
+		}
+		if orderID != "" {
+			fmt.Fprintf(&builder, "**咨询订单号**：%s\n", orderID)
+		}
+	}
+
+	if records := a.tool.GetCallLog(ctx, conversationID); len(records) > 0 {
+		builder.WriteString("**已调用工具**：\n")
+		for _, record := range records {
+			fmt.Fprintf(&builder, "- %s(%s)：%s\n", record.Name, string(record.Arguments), record.Result)
+		}
+	}
+
+	if global.RedisClient != nil {
+		history, err := global.RedisClient.GetConversationHistory(ctx, global.TenantFrom(ctx).ConversationScope(conversationID))
+		if err != nil && err != redis.ErrNil {
+			global.Log.Warnf("[action]获取会话 %d 历史记录失败: %v", conversationID, err)
+		}
+		if len(history) > handoffHistoryLimit {
+			history = history[len(history)-handoffHistoryLimit:]
+		}
+		if len(history) > 0 {
+			// 资料也会在摘要失败时直接发给人工客服, 历史中的占位符还原为真实值
+			vault := loadHistoryVault(ctx, conversationID)
+			builder.WriteString("**最近对话**：\n")
+			for _, msg := range history {
+				role := "客服"
+				if msg.Role == openai.ChatMessageRoleUser {
+					role = "客户"
+				}
+				fmt.Fprintf(&builder, "- %s：%s\n", role, truncateRunes(vault.Restore(msg.Content), 300))
+			}
+		}
+	}
+
+	if routingCtx.Question != "" {
+		fmt.Fprintf(&builder, "**客户最新消息**：%s\n", routingCtx.Question)
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+func (a *actionService) SaveContactDetails(ctx context.Context, conversationID uint, values []common.SubmittedValue) bool {
+	fields := make(map[string]string, len(values))
+	for _, v := range values {
+		fields[v.Name] = strings.TrimSpace(v.Value)
+	}
+	if _, ok := fields["contact_phone"]; !ok {
+		return false
+	}
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
+		return true
+	}
+
+	var note strings.Builder
+	note.WriteString("用户留下的联系方式：")
+	for _, item := range contactFormItems {
+		if val := fields[item.Name]; val != "" {
+			fmt.Fprintf(&note, "\n%s: %s", item.Label, val)
+		}
+	}
+	if err := chatwootClient.CreatePrivateNote(conversationID, note.String()); err != nil {
+		global.Log.Warnf("[action]为会话 %d 保存联系方式失败: %v", conversationID, err)
+	}
+	a.SendMessage(ctx, conversationID, string(enum.ReplyMsgContactSaved))
+	return true
+}
+
+func (a *actionService) ReopenScheduledConversations(ctx context.Context) error {
+	if global.RedisClient == nil {
+		return nil
+	}
+
+	members, err := global.RedisClient.ZRangeByScore(ctx, redis.KeyScheduledReopen, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("获取到期的预约转人工会话失败: %w", err)
+	}
+
+	for _, member := range members {
+		// ZRem 成功的实例才负责处理, 避免多实例重复转接
+		removed, err := global.RedisClient.ZRem(ctx, redis.KeyScheduledReopen, member).Result()
+		if err != nil || removed == 0 {
+			continue
+		}
+		tenant, conversationID, ok := parseTenantConversationMember(member)
+		if !ok {
+			global.Log.Warnf("[action]预约转人工记录 %s 无效或所属租户已移除, 已忽略", member)
+			continue
+		}
+		tenantCtx := global.WithTenant(ctx, tenant)
+		chatwootClient := tenant.Chatwoot()
+		if chatwootClient == nil {
+			continue
+		}
+
+		if err := chatwootClient.SetConversationStatus(conversationID, chatwoot.ConversationStatusOpen); err != nil {
+			global.Log.Errorf("[action]重新打开预约转人工的会话 %d 失败: %v", conversationID, err)
+			continue
+		}
+		if err := chatwootClient.CreatePrivateNote(conversationID, string(enum.TransferToHuman7)); err != nil {
+			global.Log.Warnf("[action]为会话 %d 创建转人工备注失败: %v", conversationID, err)
+		}
+		a.experiment.RecordTransfer(tenantCtx, conversationID)
+		a.routing.Route(tenantCtx, conversationID)
+		a.SendMessage(tenantCtx, conversationID, string(enum.ReplyMsgTransferResumed))
+		go a.sendHandoffSummary(context.WithoutCancel(tenantCtx), conversationID, enum.TransferToHuman7)
+		global.Log.Debugf("[action]预约转人工的会话 %d 已重新打开", conversationID)
+	}
+	return nil
+}
+
+func (a *actionService) CancelScheduledReopen(ctx context.Context, conversationID uint) {
+	if global.RedisClient == nil {
+		return
+	}
+	if err := global.RedisClient.ZRem(ctx, redis.KeyScheduledReopen, tenantConversationMember(ctx, conversationID)).Err(); err != nil {
+		global.Log.Warnf("[action]取消会话 %d 的预约转人工失败: %v", conversationID, err)
+	}
+}
+
+// tenantConversationMember 跨租户的会话集合(预约转人工、待挖掘会话)中的成员: 默认租户为会话ID, 其他租户为 "租户名:会话ID"
+func tenantConversationMember(ctx context.Context, conversationID uint) string {
+	id := strconv.FormatUint(uint64(conversationID), 10)
+	if tenant := global.TenantFrom(ctx); !tenant.IsDefault() {
+		return tenant.Name + ":" + id
+	}
+	return id
+}
+
+func parseTenantConversationMember(member string) (*global.Tenant, uint, bool) {
+	name, idStr := "", member
+	if i := strings.LastIndex(member, ":"); i >= 0 {
+		name, idStr = member[:i], member[i+1:]
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, 0, false
+	}
+	tenant, ok := global.Tenants.Get(name)
+	return tenant, uint(id), ok
+}
+
+func (a *actionService) SetConversationPending(ctx context.Context, conversationID uint) error {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
 		return fmt.Errorf("Chatwoot客户端未初始化")
 	}
-	return global.ChatwootService.SetConversationStatus(conversationID, chatwoot.ConversationStatusPending)
+	return chatwootClient.SetConversationStatus(conversationID, chatwoot.ConversationStatusPending)
 }
 
-func (a *actionService) ToggleTyping(conversationID uint, status bool) {
-	if global.ChatwootService == nil {
+func (a *actionService) ToggleTyping(ctx context.Context, conversationID uint, status bool) {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
 		return
 	}
 	statusStr := "off"
 	if status {
 		statusStr = "on"
 	}
-	if err := global.ChatwootService.ToggleTypingStatus(conversationID, statusStr); err != nil {
+	if err := chatwootClient.ToggleTypingStatus(conversationID, statusStr); err != nil {
 		global.Log.Warnf("[action]为会话 %d 切换typing状态失败: %v", conversationID, err)
 	}
 }
 
-func (a *actionService) SendMessage(conversationID uint, content string) {
-	if global.ChatwootService == nil {
+func (a *actionService) SendMessage(ctx context.Context, conversationID uint, content string) {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
 		return
 	}
-	if err := global.ChatwootService.CreateMessage(conversationID, content); err != nil {
+	if err := chatwootClient.CreateMessage(conversationID, content); err != nil {
 		global.Log.Errorf("[action]向会话 %d 发送消息失败: %v", conversationID, err)
 	}
 }
 
+func (a *actionService) SendReply(ctx context.Context, conversationID uint, answer string) (string, error) {
+	start := strings.Index(answer, "<interactive>")
+	end := strings.Index(answer, "</interactive>")
+	if start == -1 || end < start {
+		a.SendMessage(ctx, conversationID, answer)
+		return answer, nil
+	}
+
+	text := strings.TrimSpace(answer[:start] + answer[end+len("</interactive>"):])
+	block := strings.TrimSpace(answer[start+len("<interactive>") : end])
+
+	var reply common.InteractiveReply
+	if err := json.Unmarshal([]byte(block), &reply); err != nil {
+		if text == "" {
+			return "", fmt.Errorf("解析交互式回复JSON失败: %w", err)
+		}
+		// 交互组件无效时，退化为只发送标签外的文本
+		global.Log.Warnf("[action]会话 %d 解析交互式回复失败，仅发送文本: %v", conversationID, err)
+		a.SendMessage(ctx, conversationID, text)
+		return text, nil
+	}
+	if reply.Content == "" {
+		reply.Content, text = text, ""
+	}
+
+	// 标签外的文本作为补充说明，先于交互组件发送
+	if text != "" {
+		a.SendMessage(ctx, conversationID, text)
+	}
+
+	historyText, err := a.sendInteractive(ctx, conversationID, reply)
+	if err != nil {
+		if text == "" {
+			return "", err
+		}
+		global.Log.Warnf("[action]会话 %d 发送交互式消息失败，仅保留文本: %v", conversationID, err)
+		return text, nil
+	}
+	if text != "" {
+		historyText = text + "\n" + historyText
+	}
+	return historyText, nil
+}
+
+// sendInteractive 校验并发送交互式消息，返回用于历史记录的文本描述
+func (a *actionService) sendInteractive(ctx context.Context, conversationID uint, reply common.InteractiveReply) (string, error) {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
+		return "", fmt.Errorf("Chatwoot客户端未初始化")
+	}
+
+	var builder strings.Builder
+	builder.WriteString(reply.Content)
+
+	switch reply.Type {
+	case chatwoot.ContentTypeInputSelect:
+		var items []chatwoot.SelectOption
+		if err := json.Unmarshal(reply.Items, &items); err != nil {
+			return "", fmt.Errorf("解析选项失败: %w", err)
+		}
+		options := make([]chatwoot.SelectOption, 0, len(items))
+		for _, item := range items {
+			if len(options) == maxInteractiveItems {
+				break
+			}
+			if item.Title == "" {
+				continue
+			}
+			if item.Value == "" {
+				item.Value = item.Title
+			}
+			options = append(options, item)
+			fmt.Fprintf(&builder, "\n- %s", item.Title)
+		}
+		if len(options) == 0 {
+			return "", fmt.Errorf("选项消息没有有效选项")
+		}
+		return builder.String(), chatwootClient.CreateInputSelectMessage(conversationID, reply.Content, options)
+
+	case chatwoot.ContentTypeForm:
+		var items []chatwoot.FormItem
+		if err := json.Unmarshal(reply.Items, &items); err != nil {
+			return "", fmt.Errorf("解析表单项失败: %w", err)
+		}
+		fields := make([]chatwoot.FormItem, 0, len(items))
+		for _, item := range items {
+			if item.Name == "" {
+				continue
+			}
+			if item.Label == "" {
+				item.Label = item.Name
+			}
+			if item.Type != "text_area" && item.Type != "email" {
+				item.Type = "text"
+			}
+			fields = append(fields, item)
+			fmt.Fprintf(&builder, "\n- %s", item.Label)
+		}
+		if len(fields) == 0 {
+			return "", fmt.Errorf("表单消息没有有效字段")
+		}
+		return builder.String(), chatwootClient.CreateFormMessage(conversationID, reply.Content, fields)
+
+	case chatwoot.ContentTypeArticle:
+		var items []chatwoot.ArticleItem
+		if err := json.Unmarshal(reply.Items, &items); err != nil {
+			return "", fmt.Errorf("解析文章列表失败: %w", err)
+		}
+		articles := make([]chatwoot.ArticleItem, 0, len(items))
+		for _, item := range items {
+			if len(articles) == maxInteractiveItems {
+				break
+			}
+			// 只接受http(s)链接，避免渲染出无效或危险的地址
+			if item.Title == "" || !(strings.HasPrefix(item.Link, "https://") || strings.HasPrefix(item.Link, "http://")) {
+				continue
+			}
+			articles = append(articles, item)
+			fmt.Fprintf(&builder, "\n- %s: %s", item.Title, item.Link)
+		}
+		if len(articles) == 0 {
+			return "", fmt.Errorf("文章消息没有有效链接")
+		}
+		return builder.String(), chatwootClient.CreateArticleMessage(conversationID, reply.Content, articles)
+
+	case interactiveTypeOrderCard:
+		var items []struct {
+			OrderID string `json:"order_id"`
+		}
+		if err := json.Unmarshal(reply.Items, &items); err != nil {
+			return "", fmt.Errorf("解析订单列表失败: %w", err)
+		}
+		if reply.Content != "" {
+			a.SendMessage(ctx, conversationID, reply.Content)
+		}
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		sent := 0
+		for _, item := range items {
+			if sent == maxOrderCards {
+				break
+			}
+			if item.OrderID == "" {
+				continue
+			}
+			cardText, err := a.SendOrderCard(ctx, conversationID, item.OrderID)
+			if err != nil {
+				global.Log.Warnf("[action]会话 %d 发送订单 %s 卡片失败: %v", conversationID, item.OrderID, err)
+				continue
+			}
+			builder.WriteString("\n" + cardText)
+			sent++
+		}
+		if sent == 0 {
+			return "", fmt.Errorf("没有成功发送任何订单卡片")
+		}
+		return builder.String(), nil
+
+	default:
+		return "", fmt.Errorf("不支持的交互式消息类型: %s", reply.Type)
+	}
+}
+
 // answer: 如果是普通回复，则为回复内容
 // isAction: 如果匹配到特殊动作（如转人工），则为true
 // err: 如果在匹配过程中发生错误
-func (a *actionService) MatchCannedResponse(chatRequest *common.ChatRequest) (string, bool, error) {
+func (a *actionService) MatchCannedResponse(ctx context.Context, chatRequest *common.ChatRequest) (string, bool, error) {
 	content := strings.ToLower(strings.TrimSpace(chatRequest.Content))
 	if content == "" {
 		return "", false, nil
@@ -161,9 +625,10 @@ func (a *actionService) MatchCannedResponse(chatRequest *common.ChatRequest) (st
 	}
 
 	// 匹配"预设回复"的关键字
-	global.CannedResponses.RLock()
-	answer, ok := global.CannedResponses.Data[content]
-	global.CannedResponses.RUnlock()
+	cannedResponses := global.TenantFrom(ctx).CannedResponses
+	cannedResponses.RLock()
+	answer, ok := cannedResponses.Data[content]
+	cannedResponses.RUnlock()
 
 	if ok {
 		return answer, false, nil
@@ -171,8 +636,9 @@ func (a *actionService) MatchCannedResponse(chatRequest *common.ChatRequest) (st
 	return "", false, nil
 }
 
-func (a *actionService) sendProductCard(conversationID uint, attrs common.CustomAttributes) {
-	if global.ChatwootService == nil {
+func (a *actionService) sendProductCard(ctx context.Context, conversationID uint, attrs common.CustomAttributes) {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
 		global.Log.Warnf("[action] Chatwoot客户端未初始化，无法为会话 %d 发送商品卡片", conversationID)
 		return
 	}
@@ -192,11 +658,141 @@ func (a *actionService) sendProductCard(conversationID uint, attrs common.Custom
 	content := fmt.Sprintf("商品名称：**%s**\n价格：%s\ngoods_id：%s\n[![%s](%s)](%s)", attrs.GoodsTitle, attrs.GoodsPrice, attrs.GoodsID, attrs.GoodsTitle, attrs.GoodsImage, attrs.GoodsUrl)
 
 	//(当前消息不加入缓存)
-	if err := global.ChatwootService.CreateCardMessage(conversationID, content, []chatwoot.CardItem{cardItem}); err != nil {
+	if err := chatwootClient.CreateCardMessage(conversationID, content, []chatwoot.CardItem{cardItem}); err != nil {
 		global.Log.Errorf("[action]向会话 %d 发送商品卡片失败: %v", conversationID, err)
 	}
 }
 
+func (a *actionService) SendOrderCard(ctx context.Context, conversationID uint, orderID string) (string, error) {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
+		return "", fmt.Errorf("Chatwoot客户端未初始化")
+	}
+
+	details, err := a.dashboard.GetOrderDetails(ctx, orderID)
+	if err != nil {
+		return "", err
+	}
+
+	cardItem, content := a.renderOrderCard(orderID, details)
+	if err := chatwootClient.CreateCardMessage(conversationID, content, []chatwoot.CardItem{cardItem}); err != nil {
+		return "", fmt.Errorf("发送订单卡片失败: %w", err)
+	}
+	return content, nil
+}
+
+// renderOrderCard 将 query_order 工具返回的订单详情渲染为卡片及其文本描述
+// MCP返回的字段名因商城实现而异，这里按常见命名依次尝试
+func (a *actionService) renderOrderCard(orderID string, details map[string]interface{}) (chatwoot.CardItem, string) {
+	// 部分实现会将订单包在 data/order 字段中
+	for _, k := range []string{"data", "order"} {
+		if inner, ok := details[k].(map[string]interface{}); ok {
+			details = inner
+			break
+		}
+	}
+
+	status := pickString(details, "status_text", "status_name", "order_status", "status")
+	amount := pickString(details, "pay_amount", "total_amount", "amount", "total_price", "price")
+	orderURL := pickString(details, "order_url", "detail_url", "url")
+
+	var goodsNames []string
+	var mediaURL string
+	for _, k := range []string{"items", "goods", "goods_list", "order_goods", "products"} {
+		list, ok := details[k].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, v := range list {
+			item, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := pickString(item, "goods_title", "title", "goods_name", "name")
+			if name == "" {
+				continue
+			}
+			if qty := pickString(item, "quantity", "num", "count"); qty != "" {
+				name = fmt.Sprintf("%s x%s", name, qty)
+			}
+			goodsNames = append(goodsNames, name)
+			if mediaURL == "" {
+				mediaURL = pickString(item, "goods_image", "image", "pic", "thumb")
+			}
+		}
+		break
+	}
+
+	logistics := details
+	if inner, ok := details["logistics"].(map[string]interface{}); ok {
+		logistics = inner
+	}
+	company := pickString(logistics, "express_company", "company", "shipping_company")
+	trackingNo := pickString(logistics, "express_no", "tracking_no", "tracking_number", "waybill_no")
+	logisticsStatus := pickString(logistics, "logistics_status", "latest_trace", "trace")
+
+	var desc strings.Builder
+	if status != "" {
+		fmt.Fprintf(&desc, "状态：%s\n", status)
+	}
+	if len(goodsNames) > 0 {
+		fmt.Fprintf(&desc, "商品：%s\n", strings.Join(goodsNames, "，"))
+	}
+	if amount != "" {
+		fmt.Fprintf(&desc, "金额：%s\n", amount)
+	}
+	if company != "" || trackingNo != "" {
+		fmt.Fprintf(&desc, "物流：%s %s\n", company, trackingNo)
+	}
+	if logisticsStatus != "" {
+		fmt.Fprintf(&desc, "最新动态：%s\n", logisticsStatus)
+	}
+	description := strings.TrimSpace(desc.String())
+
+	// postback 按钮点击后会作为用户消息回到对话流程中
+	actions := []chatwoot.CardAction{
+		{Type: "postback", Text: "查看物流", Payload: fmt.Sprintf("查询订单%s的物流", orderID)},
+		{Type: "postback", Text: "申请售后", Payload: fmt.Sprintf("订单%s申请售后", orderID)},
+	}
+	if orderURL != "" {
+		actions = append([]chatwoot.CardAction{{Type: "link", Text: "查看订单", URI: orderURL}}, actions...)
+	}
+
+	cardItem := chatwoot.CardItem{
+		MediaURL:    mediaURL,
+		Title:       fmt.Sprintf("订单 %s", orderID),
+		Description: description,
+		Actions:     actions,
+	}
+	content := fmt.Sprintf("订单号：**%s**\n%s", orderID, description)
+	return cardItem, content
+}
+
+// pickString 按顺序返回第一个非空字段的字符串形式
+func pickString(m map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		v, ok := m[k]
+		if !ok || v == nil {
+			continue
+		}
+		var str string
+		switch val := v.(type) {
+		case string:
+			str = val
+		case float64:
+			str = strconv.FormatFloat(val, 'f', -1, 64)
+		case bool, json.Number:
+			str = fmt.Sprint(val)
+		default:
+			continue
+		}
+		if str = strings.TrimSpace(str); str != "" {
+			return str
+		}
+	}
+	return ""
+}
+
 // CheckAndSendProductCard 逻辑: 引入分布式锁防止并发下的重复发送
 func (a *actionService) CheckAndSendProductCard(ctx context.Context, conversationID uint, attrs common.CustomAttributes) {
 	// 快速检查：如果没有相关ID，直接返回，避免不必要的锁竞争
@@ -210,7 +806,7 @@ func (a *actionService) CheckAndSendProductCard(ctx context.Context, conversatio
 
 	// 1. 获取分布式锁
 	// 目的：如果 WebWidgetTriggered 和 MessageCreated 同时触发，只有一个能获得锁执行检查
-	lockKey := fmt.Sprintf("%s%d", redis.KeyPrefixProductCardLock, conversationID)
+	lockKey := global.ConversationKey(ctx, redis.KeyPrefixProductCardLock, conversationID)
 	// 尝试获取锁，设置5秒过期，防止死锁
 	acquired, err := global.RedisClient.SetNX(ctx, lockKey, 1, 5*time.Second).Result()
 
@@ -231,7 +827,7 @@ func (a *actionService) CheckAndSendProductCard(ctx context.Context, conversatio
 
 	// --- 商品卡片逻辑 ---
 	if attrs.GoodsID != "" {
-		key := fmt.Sprintf("%s%d", redis.KeyPrefixLastProductSent, conversationID)
+		key := global.ConversationKey(ctx, redis.KeyPrefixLastProductSent, conversationID)
 
 		// 获取该会话上次发送的商品ID
 		lastSentGoodsID, err := global.RedisClient.Get(ctx, key).Result()
@@ -244,7 +840,7 @@ func (a *actionService) CheckAndSendProductCard(ctx context.Context, conversatio
 			global.Log.Debugf("为会话 %d 发送商品 %s 的信息卡片 (上次: %s)", conversationID, attrs.GoodsID, lastSentGoodsID)
 
 			// 发送卡片
-			a.sendProductCard(conversationID, attrs)
+			a.sendProductCard(ctx, conversationID, attrs)
 
 			// 更新Redis记录，设置24小时过期
 			if err := global.RedisClient.Set(ctx, key, attrs.GoodsID, 24*time.Hour).Err(); err != nil {
@@ -253,9 +849,9 @@ func (a *actionService) CheckAndSendProductCard(ctx context.Context, conversatio
 		}
 	}
 
-	// --- 订单卡片逻辑 (预留) ---
+	// --- 订单卡片逻辑 ---
 	if attrs.OrderID != "" {
-		key := fmt.Sprintf("%s%d", redis.KeyPrefixLastOrderSent, conversationID)
+		key := global.ConversationKey(ctx, redis.KeyPrefixLastOrderSent, conversationID)
 
 		lastSentOrderID, err := global.RedisClient.Get(ctx, key).Result()
 		if err != nil && err != redis.ErrNil {
@@ -264,8 +860,10 @@ func (a *actionService) CheckAndSendProductCard(ctx context.Context, conversatio
 
 		if lastSentOrderID != attrs.OrderID {
 			global.Log.Debugf("为会话 %d 发送订单 %s 的信息卡片 (上次: %s)", conversationID, attrs.OrderID, lastSentOrderID)
-			// a.sendOrderCard(conversationID, attrs) // 预留
-			if err := global.RedisClient.Set(ctx, key, attrs.OrderID, 24*time.Hour).Err(); err != nil {
+			// 订单查询失败时不记录，下次触发时重试
+			if _, err := a.SendOrderCard(ctx, conversationID, attrs.OrderID); err != nil {
+				global.Log.Errorf("[action]向会话 %d 发送订单 %s 卡片失败: %v", conversationID, attrs.OrderID, err)
+			} else if err := global.RedisClient.Set(ctx, key, attrs.OrderID, 24*time.Hour).Err(); err != nil {
 				global.Log.Warnf("更新会话 %d 最后发送订单ID失败: %v", conversationID, err)
 			}
 		}
@@ -276,7 +874,7 @@ func (a *actionService) ActivateHumanModeGracePeriod(ctx context.Context, conver
 	if global.Config.Ai.HumanModeGracePeriod <= 0 {
 		return
 	}
-	key := fmt.Sprintf("%s%d", redis.KeyPrefixHumanModeActive, conversationID)
+	key := global.ConversationKey(ctx, redis.KeyPrefixHumanModeActive, conversationID)
 	ttl := time.Duration(global.Config.Ai.HumanModeGracePeriod) * time.Second
 	err := global.RedisClient.Set(ctx, key, "1", ttl).Err()
 	if err != nil {
@@ -288,7 +886,7 @@ func (a *actionService) RefreshHumanModeGracePeriod(ctx context.Context, convers
 	if global.Config.Ai.HumanModeGracePeriod <= 0 {
 		return
 	}
-	key := fmt.Sprintf("%s%d", redis.KeyPrefixHumanModeActive, conversationID)
+	key := global.ConversationKey(ctx, redis.KeyPrefixHumanModeActive, conversationID)
 	ttl := time.Duration(global.Config.Ai.HumanModeGracePeriod) * time.Second
 	// 使用 Expire 刷新过期时间，仅当 key 存在时生效
 	updated, err := global.RedisClient.Expire(ctx, key, ttl).Result()
@@ -0,0 +1,177 @@
+package user
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+)
+
+var (
+	// answerNumberRe 回复中的数字, 只核对带价格/比例/时效单位的; 年份、单号等不带单位的数字不核对
+	answerNumberRe = regexp.MustCompile(`([¥￥]\s*)?(\d[\d,]*(?:\.\d+)?)\s*(元|块|%|％|折|天|小时|个?工作日)?`)
+	// evidenceNumberRe 依据中出现的所有数字
+	evidenceNumberRe = regexp.MustCompile(`\d[\d,]*(?:\.\d+)?`)
+)
+
+// AnswerEvidence 大模型生成回复时所依据的资料
+type AnswerEvidence struct {
+	Question    string
+	History     []common.LlmMessage
+	Docs        []string // 知识库参考资料
+	ToolResults []string // 工具执行结果
+	SourceIDs   []int64  // 参考资料对应的快捷回复ID
+	// PendingToolCalls 辅助模式下未执行的有副作用的工具调用
+	PendingToolCalls common.ToolCalls
+}
+
+// AnswerCheckService 回复发送前的审核: 数字、价格和政策说法必须有依据, 不得包含禁用语或承诺赔偿; 配置每次从 global.Config 读取
+type AnswerCheckService interface {
+	// Check 返回回复未通过审核的原因, 为空表示通过; 未启用时始终通过
+	Check(answer string, evidence *AnswerEvidence) []string
+}
+
+type answerCheckService struct{}
+
+func NewAnswerCheckService() AnswerCheckService {
+	return &answerCheckService{}
+}
+
+func (s *answerCheckService) Check(answer string, evidence *AnswerEvidence) []string {
+	cfg := global.Config.AnswerCheck
+	if !cfg.Enable || strings.TrimSpace(answer) == "" {
+		return nil
+	}
+
+	var problems []string
+	lowerAnswer := strings.ToLower(answer)
+	for _, phrase := range cfg.BannedPhrases {
+		if phrase != "" && strings.Contains(lowerAnswer, strings.ToLower(phrase)) {
+			problems = append(problems, fmt.Sprintf("包含禁用语 “%s”", phrase))
+		}
+	}
+
+	corpus := evidence.corpus()
+	toolCorpus := strings.Join(evidence.ToolResults, "\n")
+
+	if numbers := ungroundedNumbers(answer, corpus); len(numbers) > 0 {
+		problems = append(problems, fmt.Sprintf("数字或价格 %s 在参考资料和工具结果中找不到依据", strings.Join(numbers, "、")))
+	}
+
+	for _, keyword := range cfg.PolicyKeywords {
+		if keyword != "" && strings.Contains(answer, keyword) && !strings.Contains(corpus, keyword) {
+			problems = append(problems, fmt.Sprintf("政策说法 “%s” 在参考资料和工具结果中找不到依据", keyword))
+		}
+	}
+
+	if promise := compensationPromise(answer, toolCorpus, cfg.CompensationTerms); promise != "" {
+		problems = append(problems, fmt.Sprintf("承诺了赔偿或补偿 “%s”", promise))
+	}
+	return problems
+}
+
+// corpus 将知识库资料和工具结果拼接为一段文本; 客户的提问和对话历史不作为依据, 避免复述客户自称的价格或承诺
+func (e *AnswerEvidence) corpus() string {
+	if e == nil {
+		return ""
+	}
+	return strings.Join(append(append([]string{}, e.Docs...), e.ToolResults...), "\n")
+}
+
+// ungroundedNumbers 返回回复中在依据里找不到的数字, 比较时忽略千分位和小数末尾的0
+func ungroundedNumbers(answer, corpus string) []string {
+	known := make(map[string]struct{})
+	for _, n := range evidenceNumberRe.FindAllString(corpus, -1) {
+		known[normalizeNumber(n)] = struct{}{}
+	}
+
+	var missing []string
+	seen := make(map[string]struct{})
+	for _, m := range answerNumberRe.FindAllStringSubmatch(answer, -1) {
+		if m[1] == "" && m[3] == "" {
+			continue // 年份、单号、序号等不带单位的数字不核对
+		}
+		number := normalizeNumber(m[2])
+		if _, ok := known[number]; ok {
+			continue
+		}
+		if _, ok := seen[number]; ok {
+			continue
+		}
+		seen[number] = struct{}{}
+		missing = append(missing, strings.TrimSpace(m[0]))
+	}
+	return missing
+}
+
+func normalizeNumber(n string) string {
+	n = strings.ReplaceAll(n, ",", "")
+	if strings.Contains(n, ".") {
+		n = strings.TrimRight(strings.TrimRight(n, "0"), ".")
+	}
+	return n
+}
+
+// compensationPromise 查找回复中对赔偿/补偿的承诺, 工具结果表明已完成或已批准的补偿(如已办理的退款)除外
+func compensationPromise(answer, toolCorpus string, terms []string) string {
+	var checked []string
+	for _, term := range terms {
+		if term != "" && !compensationCompleted(toolCorpus, term) {
+			checked = append(checked, regexp.QuoteMeta(term))
+		}
+	}
+	if len(checked) == 0 {
+		return ""
+	}
+	termsPattern := strings.Join(checked, "|")
+	re, err := regexp.Compile(`(给|为|帮|向)您[^。！？!?\n]{0,8}(` + termsPattern + `)|(会|将|承诺|保证|一定|马上|立即)[^。！？!?\n]{0,8}(` + termsPattern + `)`)
+	if err != nil {
+		return ""
+	}
+	for _, loc := range re.FindAllStringSubmatchIndex(answer, -1) {
+		termStart := loc[4]
+		if termStart < 0 {
+			termStart = loc[8]
+		}
+		// "不会给您退款"、"无法为您补偿"、"将无退款" 等否定说法不是承诺
+		if isNegated(lastRunes(answer[:loc[0]], 2)) || isNegated(lastRunes(answer[loc[0]:termStart], 3)) {
+			continue
+		}
+		return answer[loc[0]:loc[1]]
+	}
+	return ""
+}
+
+// completedStatusPattern 匹配工具结果中表示已完成或已批准的结构化状态字段
+var completedStatusPattern = regexp.MustCompile(`(?i)"?(status|state|状态)"?\s*[:：=]\s*"?(approved|completed|succeeded|success|refunded|paid|已完成|已通过|已批准|成功)`)
+
+// compensationCompleted 工具结果表明该项补偿已完成或已批准, 如 "已退款"、"退款成功"、"退款已到账", 或与该词同一行的完成状态;
+// 仅出现该词(如 "退款申请已提交"、"可申请退款")不算
+func compensationCompleted(toolCorpus, term string) bool {
+	if !strings.Contains(toolCorpus, term) {
+		return false
+	}
+	quoted := regexp.QuoteMeta(term)
+	re := regexp.MustCompile(`已(经)?(为您)?(办理|完成|发放|到账|通过|批准)?` + quoted + `|` + quoted + `[^。！？!?\n，,未不没]{0,6}(成功|已完成|已办理|已发放|已到账|已通过|已批准)`)
+	if re.MatchString(toolCorpus) {
+		return true
+	}
+	for _, line := range strings.Split(toolCorpus, "\n") {
+		if strings.Contains(line, term) && completedStatusPattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNegated(s string) bool {
+	return strings.ContainsAny(s, "不无没未别")
+}
+
+// lastRunes 返回 s 末尾的 n 个字符
+func lastRunes(s string, n int) string {
+	runes := []rune(s)
+	return string(runes[max(0, len(runes)-n):])
+}
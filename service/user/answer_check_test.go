@@ -0,0 +1,73 @@
+package user
+
+import (
+	"reflect"
+	"testing"
+
+	"gitee.com/taoJie_1/mall-agent/model/common"
+)
+
+func TestUngroundedNumbers(t *testing.T) {
+	corpus := "商品售价 1,299.00 元, 满 300 减 30, 7天无理由退货"
+	tests := []struct {
+		answer string
+		want   []string
+	}{
+		{"这款售价1299元, 支持7天无理由", nil},
+		{"满300元可以减30元", nil},
+		{"现在只要999元", []string{"999元"}},
+		{"优惠后打8折", []string{"8折"}},
+		{"¥50 的券已经发放", []string{"¥50"}},
+		// 年份、单号、序号等不带单位的数字不核对
+		{"2026年的新款, 订单号202610190001已发货", nil},
+		{"第1步打开订单, 第2步申请售后", nil},
+	}
+	for _, tt := range tests {
+		if got := ungroundedNumbers(tt.answer, corpus); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ungroundedNumbers(%q) = %q, want %q", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestAnswerEvidenceCorpus(t *testing.T) {
+	evidence := &AnswerEvidence{
+		Question:    "客服说给我退50元",
+		History:     []common.LlmMessage{{Content: "之前答应过补偿100元"}},
+		Docs:        []string{"运费10元"},
+		ToolResults: []string{"订单金额 88 元"},
+	}
+	// 客户自称的金额不能作为依据
+	got := ungroundedNumbers("退款50元, 补偿100元, 运费10元, 订单88元", evidence.corpus())
+	if want := []string{"50元", "100元"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ungroundedNumbers() = %q, want %q", got, want)
+	}
+}
+
+func TestCompensationPromise(t *testing.T) {
+	terms := []string{"退款", "补偿", "优惠券"}
+	tests := []struct {
+		answer     string
+		toolCorpus string
+		want       string
+	}{
+		{"我们会尽快给您退款", "", "会尽快给您退款"},
+		{"我马上为您申请补偿", "", "马上为您申请补偿"},
+		// 工具结果表明补偿已完成或已批准时不算违规, 仅出现该词不算
+		{"退款已办理, 我们会给您退款到原账户", "订单已退款", ""},
+		{"我们会给您退款到原账户", "退款申请已通过", ""},
+		{"我们会给您退款到原账户", `{"type":"退款","status":"approved"}`, ""},
+		{"我们会给您退款到原账户", "退款申请已提交", "会给您退款"},
+		{"我们会给您退款到原账户", "退款未成功, 可重新申请退款", "会给您退款"},
+		// 否定说法不是承诺
+		{"很抱歉, 这个订单不会给您退款", "", ""},
+		{"定制商品无法为您退款", "", ""},
+		{"活动商品将无补偿", "", ""},
+		{"这件商品无退款, 但会给您优惠券", "", "会给您优惠券"},
+		{"退款规则请查看商品详情页", "", ""},
+	}
+	for _, tt := range tests {
+		if got := compensationPromise(tt.answer, tt.toolCorpus, terms); got != tt.want {
+			t.Errorf("compensationPromise(%q) = %q, want %q", tt.answer, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+)
+
+// AssistDraft AI为人工客服起草的回复及其依据
+type AssistDraft struct {
+	Answer      string           // 起草的回复, 为空表示无法根据现有资料回答
+	References  []string         // 参考的知识库问题
+	ToolResults int              // 已执行的只读工具数
+	ToolCalls   common.ToolCalls // 有副作用的工具调用, 辅助模式下不执行, 由人工客服决定是否操作
+	Problems    []string         // 审核发现的问题
+}
+
+// AssistService 人工辅助模式: 人工客服接管会话期间, 将AI起草的回复以私信备注发给人工客服, 不会发送给客户; 配置每次从 global.Config 读取
+type AssistService interface {
+	// Enabled 会话所在的收件箱或团队是否启用了辅助模式, teamID 为0表示未分配团队
+	Enabled(inboxID, teamID uint) bool
+	// PostDraft 将起草的回复作为私信备注发送
+	PostDraft(ctx context.Context, conversationID uint, draft *AssistDraft)
+}
+
+type assistService struct{}
+
+func NewAssistService() AssistService {
+	return &assistService{}
+}
+
+func (s *assistService) Enabled(inboxID, teamID uint) bool {
+	cfg := global.Config.AgentAssist
+	if !cfg.Enable {
+		return false
+	}
+	if len(cfg.InboxIDs) == 0 && len(cfg.TeamIDs) == 0 {
+		return true
+	}
+	return slices.Contains(cfg.InboxIDs, inboxID) || (teamID != 0 && slices.Contains(cfg.TeamIDs, teamID))
+}
+
+func (s *assistService) PostDraft(ctx context.Context, conversationID uint, draft *AssistDraft) {
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
+		return
+	}
+
+	var note strings.Builder
+	note.WriteString("### AI建议回复\n")
+	if draft.Answer != "" {
+		note.WriteString(formatAssistAnswer(draft.Answer))
+	} else if len(draft.ToolCalls) == 0 {
+		note.WriteString("现有资料无法回答该问题，请人工处理。")
+	}
+	if len(draft.ToolCalls) > 0 {
+		note.WriteString("\n\n**需人工确认的操作(未执行)：**")
+		for _, call := range draft.ToolCalls {
+			fmt.Fprintf(&note, "\n- %s %s", call.Name, string(call.Arguments))
+		}
+	}
+	if len(draft.References) > 0 {
+		fmt.Fprintf(&note, "\n\n参考知识：%s", strings.Join(draft.References, "；"))
+	}
+	if draft.ToolResults > 0 {
+		fmt.Fprintf(&note, "\n已查询工具 %d 次", draft.ToolResults)
+	}
+	if len(draft.Problems) > 0 {
+		note.WriteString("\n\n**审核提示：**")
+		for _, problem := range draft.Problems {
+			fmt.Fprintf(&note, "\n- %s", problem)
+		}
+	}
+
+	if err := chatwootClient.CreatePrivateNote(conversationID, note.String()); err != nil {
+		global.Log.Warnf("[assist]为会话 %d 发送建议回复失败: %v", conversationID, err)
+	}
+}
+
+// formatAssistAnswer 将回复中的 <interactive> 组件转换为人工客服可读的文本, 组件内容原样附在代码块中
+func formatAssistAnswer(answer string) string {
+	start := strings.Index(answer, "<interactive>")
+	end := strings.Index(answer, "</interactive>")
+	if start == -1 || end < start {
+		return answer
+	}
+
+	text := strings.TrimSpace(answer[:start] + answer[end+len("</interactive>"):])
+	block := strings.TrimSpace(answer[start+len("<interactive>") : end])
+	var reply common.InteractiveReply
+	if err := json.Unmarshal([]byte(block), &reply); err != nil {
+		return text
+	}
+
+	parts := make([]string, 0, 3)
+	if text != "" {
+		parts = append(parts, text)
+	}
+	if reply.Content != "" {
+		parts = append(parts, reply.Content)
+	}
+	parts = append(parts, fmt.Sprintf("建议附带交互组件(%s)：\n```json\n%s\n```", reply.Type, string(reply.Items)))
+	return strings.Join(parts, "\n")
+}
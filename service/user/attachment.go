@@ -0,0 +1,191 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+)
+
+// ErrUnsupportedAttachment 表示附件类型不受支持或未启用对应的识别模型
+var ErrUnsupportedAttachment = errors.New("不支持的附件类型")
+
+type AttachmentService interface {
+	// Describe 下载并识别附件, 返回可作为用户消息补充上下文的文本
+	Describe(ctx context.Context, attachments []common.Attachment) (string, error)
+	// Transcribe 转写附件中的语音并以私信备注留存给客服, 返回转写文本和其余未处理的附件
+	Transcribe(ctx context.Context, conversationID uint, attachments []common.Attachment) (string, []common.Attachment, error)
+}
+
+type attachmentService struct {
+	httpClient *http.Client
+}
+
+func NewAttachmentService() AttachmentService {
+	s := &attachmentService{}
+	s.httpClient = &http.Client{
+		Timeout: 15 * time.Second,
+		// Chatwoot 会将附件重定向到对象存储, 重定向的目标同样需要校验
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("附件下载重定向次数过多")
+			}
+			return s.checkURL(req.Context(), req.URL)
+		},
+	}
+	return s
+}
+
+func (s *attachmentService) Describe(ctx context.Context, attachments []common.Attachment) (string, error) {
+	cfg := global.Config.LlmVision
+	if len(attachments) > cfg.MaxAttachments {
+		global.Log.Warnf("[attachment]单条消息包含 %d 个附件，仅识别前 %d 个", len(attachments), cfg.MaxAttachments)
+		attachments = attachments[:cfg.MaxAttachments]
+	}
+
+	var builder strings.Builder
+	for i, attachment := range attachments {
+		if attachment.FileType != "image" || global.VisionService == nil {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedAttachment, attachment.FileType)
+		}
+
+		data, mimeType, err := s.download(ctx, attachment.DataURL, cfg.MaxFileSize)
+		if err != nil {
+			return "", err
+		}
+		if !slices.Contains(cfg.AllowedTypes, mimeType) {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedAttachment, mimeType)
+		}
+
+		description, err := global.VisionService.DescribeImage(ctx, string(global.Prompt(ctx, enum.PromptVision)), mimeType, data)
+		if err != nil {
+			return "", fmt.Errorf("识别第 %d 张图片失败: %w", i+1, err)
+		}
+		fmt.Fprintf(&builder, "[用户发送的图片%d]: %s\n", i+1, description)
+	}
+	return strings.TrimSpace(builder.String()), nil
+}
+
+func (s *attachmentService) Transcribe(ctx context.Context, conversationID uint, attachments []common.Attachment) (string, []common.Attachment, error) {
+	cfg := global.Config.LlmAudio
+
+	var transcripts []string
+	var others []common.Attachment
+	for _, attachment := range attachments {
+		if attachment.FileType != "audio" {
+			others = append(others, attachment)
+			continue
+		}
+		if global.TranscriptionService == nil {
+			return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedAttachment, attachment.FileType)
+		}
+
+		data, mimeType, err := s.download(ctx, attachment.DataURL, cfg.MaxFileSize)
+		if err != nil {
+			return "", nil, err
+		}
+		if !slices.Contains(cfg.AllowedTypes, mimeType) {
+			return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedAttachment, mimeType)
+		}
+
+		text, err := global.TranscriptionService.Transcribe(ctx, audioFileName(attachment.DataURL, mimeType), data)
+		if err != nil {
+			return "", nil, fmt.Errorf("转写第 %d 段语音失败: %w", len(transcripts)+1, err)
+		}
+		if text == "" {
+			return "", nil, fmt.Errorf("第 %d 段语音未识别到内容", len(transcripts)+1)
+		}
+		transcripts = append(transcripts, text)
+	}
+
+	transcript := strings.Join(transcripts, "\n")
+	if chatwootClient := global.TenantFrom(ctx).Chatwoot(); transcript != "" && chatwootClient != nil {
+		if err := chatwootClient.CreatePrivateNote(conversationID, "### 语音转写\n"+transcript); err != nil {
+			global.Log.Warnf("[attachment]会话 %d 发送语音转写备注失败: %v", conversationID, err)
+		}
+	}
+	return transcript, others, nil
+}
+
+// audioFileName 取下载地址中的文件名, 语音识别服务依赖扩展名判断音频格式
+func audioFileName(dataURL, mimeType string) string {
+	if u, err := url.Parse(dataURL); err == nil {
+		if name := path.Base(u.Path); path.Ext(name) != "" {
+			return name
+		}
+	}
+	if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+		return "audio" + exts[0]
+	}
+	return "audio.mp3"
+}
+
+// checkURL 附件只允许从租户的 Chatwoot 和配置的存储域名下载, 防止伪造的附件地址访问内网服务
+func (s *attachmentService) checkURL(ctx context.Context, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("不支持的附件地址协议: %s", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	allowed := slices.Clone(global.Config.AttachmentHosts)
+	if chatwootUrl, err := url.Parse(global.TenantFrom(ctx).ChatwootUrl()); err == nil {
+		allowed = append(allowed, chatwootUrl.Hostname())
+	}
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if pattern == host || (strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("附件域名 %s 不在允许下载的范围内", host)
+}
+
+// download 下载附件, 超出大小限制时返回错误; 响应未声明类型时根据内容推断
+func (s *attachmentService) download(ctx context.Context, url string, maxSize int64) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("创建附件下载请求失败: %w", err)
+	}
+	if err := s.checkURL(ctx, req.URL); err != nil {
+		return nil, "", err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("下载附件失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("下载附件返回非200状态码: %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, "", fmt.Errorf("附件大小 %d 超过限制 %d", resp.ContentLength, maxSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("读取附件内容失败: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", fmt.Errorf("附件大小超过限制 %d", maxSize)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	return data, mimeType, nil
+}
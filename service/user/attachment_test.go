@@ -0,0 +1,43 @@
+package user
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+)
+
+func TestAttachmentCheckURL(t *testing.T) {
+	global.Config.Chatwoot.Url = "https://chat.example.com"
+	global.Config.AttachmentHosts = []string{"*.oss-cn-guangzhou.aliyuncs.com", "files.example.com"}
+	defer func() {
+		global.Config.Chatwoot.Url = ""
+		global.Config.AttachmentHosts = nil
+	}()
+
+	s := &attachmentService{}
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://chat.example.com/rails/active_storage/blobs/1/a.png", true},
+		{"https://CHAT.example.com:443/a.png", true},
+		{"https://bucket.oss-cn-guangzhou.aliyuncs.com/a.png", true},
+		{"https://files.example.com/a.png", true},
+		{"https://oss-cn-guangzhou.aliyuncs.com.evil.com/a.png", false},
+		{"http://127.0.0.1:6379/", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"file:///etc/passwd", false},
+		{"http:///a.png", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatalf("解析 %s 失败: %v", tt.url, err)
+		}
+		if err := s.checkURL(context.Background(), u); (err == nil) != tt.allowed {
+			t.Errorf("checkURL(%s) err = %v, want allowed = %v", tt.url, err, tt.allowed)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package user
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/config"
+)
+
+// Availability 描述人工客服当前能否接待
+type Availability struct {
+	Available bool
+	OffHours  bool      // true: 非工作时间; false: 工作时间内无客服在线
+	ResumeAt  time.Time // 预计恢复人工接待的时间
+}
+
+type AvailabilityService interface {
+	// Check 按收件箱的工作时间和客服在线状态判断当前能否转人工
+	Check(ctx context.Context, inboxID uint) Availability
+}
+
+type availabilityService struct{}
+
+func NewAvailabilityService() AvailabilityService {
+	return &availabilityService{}
+}
+
+func (s *availabilityService) Check(ctx context.Context, inboxID uint) Availability {
+	cfg := global.Config.BusinessHours
+	if !cfg.Enable {
+		return Availability{Available: true}
+	}
+
+	schedule := cfg.Schedule
+	if inboxSchedule, ok := cfg.Inboxes[strconv.FormatUint(uint64(inboxID), 10)]; ok {
+		schedule = inboxSchedule
+	}
+
+	start, errStart := time.Parse("15:04", schedule.Start)
+	end, errEnd := time.Parse("15:04", schedule.End)
+	// 下班时间早于上班时间表示跨零点的夜班, 如 22:00-06:00; 两者相同视为配置无效
+	if errStart != nil || errEnd != nil || start.Equal(end) {
+		global.Log.Warnf("[availability]收件箱 %d 的工作时间配置无效(%s-%s)，视为全天可接待", inboxID, schedule.Start, schedule.End)
+		return Availability{Available: true}
+	}
+
+	now := time.Now().In(global.Tz)
+	shiftEnd, onShift := s.currentShift(schedule, now, start, end)
+	if !onShift {
+		return Availability{OffHours: true, ResumeAt: s.nextOpen(schedule, now, start)}
+	}
+
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if !cfg.CheckAgentAvailability || chatwootClient == nil {
+		return Availability{Available: true}
+	}
+
+	agents, err := chatwootClient.ListAgents(inboxID)
+	if err != nil {
+		// 查询失败时不阻断转人工
+		global.Log.Warnf("[availability]获取收件箱 %d 客服列表失败: %v", inboxID, err)
+		return Availability{Available: true}
+	}
+	for _, agent := range agents {
+		if agent.AvailabilityStatus == "online" {
+			return Availability{Available: true}
+		}
+	}
+
+	resumeAt := now.Add(time.Duration(cfg.AgentRecheckInterval) * time.Second)
+	if !resumeAt.Before(shiftEnd) {
+		resumeAt = s.nextOpen(schedule, shiftEnd, start)
+	}
+	return Availability{ResumeAt: resumeAt}
+}
+
+// currentShift 判断 t 是否在某个班次内并返回该班次的下班时间; 班次属于上班当天, 跨零点的夜班在次日下班
+func (s *availabilityService) currentShift(schedule config.Schedule, t time.Time, start, end time.Time) (time.Time, bool) {
+	// 夜班可能是前一天开始的, 需要同时检查前一天的班次
+	for _, offset := range []int{0, -1} {
+		day := t.AddDate(0, 0, offset)
+		if !s.isWorkday(schedule, day) {
+			continue
+		}
+		open := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, t.Location())
+		closeAt := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, t.Location())
+		if !closeAt.After(open) {
+			closeAt = closeAt.AddDate(0, 0, 1)
+		}
+		if !t.Before(open) && t.Before(closeAt) {
+			return closeAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// isWorkday 调休上班日优先于节假日, 节假日优先于每周工作日
+func (s *availabilityService) isWorkday(schedule config.Schedule, t time.Time) bool {
+	date := t.Format("2006-01-02")
+	if slices.Contains(schedule.Workdays, date) {
+		return true
+	}
+	if slices.Contains(schedule.Holidays, date) {
+		return false
+	}
+	return slices.Contains(schedule.Weekdays, int(t.Weekday()))
+}
+
+// nextOpen 返回 from 之后最近一个工作日的上班时间
+func (s *availabilityService) nextOpen(schedule config.Schedule, from time.Time, start time.Time) time.Time {
+	// 最长向后查找60天, 足以跨过长假
+	for i := 0; i <= 60; i++ {
+		day := from.AddDate(0, 0, i)
+		if !s.isWorkday(schedule, day) {
+			continue
+		}
+		open := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, from.Location())
+		if open.After(from) {
+			return open
+		}
+	}
+	return from.Add(24 * time.Hour)
+}
@@ -0,0 +1,46 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/model/config"
+)
+
+func TestCurrentShift(t *testing.T) {
+	s := &availabilityService{}
+	// 2026-10-16 为周五, 10-17 为周六
+	schedule := config.Schedule{Weekdays: []int{1, 2, 3, 4, 5}}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	clock := func(v string) time.Time {
+		t, _ := time.Parse("15:04", v)
+		return t
+	}
+
+	tests := []struct {
+		name       string
+		start, end string
+		now        time.Time
+		onShift    bool
+		shiftEnd   time.Time
+	}{
+		{"白班内", "09:00", "18:00", at(16, 10, 0), true, at(16, 18, 0)},
+		{"白班下班时刻", "09:00", "18:00", at(16, 18, 0), false, time.Time{}},
+		{"白班周末", "09:00", "18:00", at(17, 10, 0), false, time.Time{}},
+		{"夜班当天开始", "22:00", "06:00", at(16, 23, 0), true, at(17, 6, 0)},
+		{"夜班次日凌晨", "22:00", "06:00", at(17, 5, 59), true, at(17, 6, 0)},
+		{"夜班次日下班后", "22:00", "06:00", at(17, 6, 0), false, time.Time{}},
+		{"夜班上班前", "22:00", "06:00", at(16, 21, 0), false, time.Time{}},
+		{"夜班周六开始不上班", "22:00", "06:00", at(17, 23, 0), false, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shiftEnd, onShift := s.currentShift(schedule, tt.now, clock(tt.start), clock(tt.end))
+			if onShift != tt.onShift || !shiftEnd.Equal(tt.shiftEnd) {
+				t.Errorf("currentShift() = (%v, %v), want (%v, %v)", shiftEnd, onShift, tt.shiftEnd, tt.onShift)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+)
+
+// MessageBatchService 在短时间窗口内聚合同一会话的连续消息, 状态存放于Redis以支持多实例
+type MessageBatchService interface {
+	// Window 返回当前配置的聚合窗口, 为0表示不聚合
+	Window() time.Duration
+	// Add 将消息放入会话的聚合列表, 返回该消息的序号
+	Add(ctx context.Context, req common.ChatRequest) (int64, error)
+	// Collect 窗口结束后取出并合并聚合列表; 若 seq 已不是最新消息则返回nil, 由更新的消息负责处理
+	Collect(ctx context.Context, conversationID uint, seq int64) (*common.ChatRequest, error)
+}
+
+type messageBatchService struct{}
+
+func NewMessageBatchService() MessageBatchService {
+	return &messageBatchService{}
+}
+
+func (s *messageBatchService) Window() time.Duration {
+	if global.RedisClient == nil {
+		return 0
+	}
+	return time.Duration(global.Config.Ai.MessageBatchWindow) * time.Millisecond
+}
+
+func (s *messageBatchService) Add(ctx context.Context, req common.ChatRequest) (int64, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return 0, fmt.Errorf("序列化消息失败: %w", err)
+	}
+	// 过期时间需覆盖聚合窗口, 兜底清理未被取出的消息
+	ttl := s.Window() + time.Minute
+	return global.RedisClient.PushMessageBatch(ctx, global.TenantFrom(ctx).ConversationScope(req.Conversation.ID), string(data), ttl)
+}
+
+func (s *messageBatchService) Collect(ctx context.Context, conversationID uint, seq int64) (*common.ChatRequest, error) {
+	messages, err := global.RedisClient.PopMessageBatch(ctx, global.TenantFrom(ctx).ConversationScope(conversationID), seq)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+
+	// 以最后一条消息为准, 按顺序合并文本和附件
+	var merged common.ChatRequest
+	var contents []string
+	var attachments []common.Attachment
+	for _, message := range messages {
+		var req common.ChatRequest
+		if err := json.Unmarshal([]byte(message), &req); err != nil {
+			global.Log.Warnf("[batch]会话 %d 反序列化聚合消息失败: %v", conversationID, err)
+			continue
+		}
+		if content := strings.TrimSpace(req.Content); content != "" {
+			contents = append(contents, content)
+		}
+		attachments = append(attachments, req.Attachments...)
+		merged = req
+	}
+	if merged.Conversation.ID == 0 {
+		return nil, fmt.Errorf("会话 %d 的聚合消息均无法解析", conversationID)
+	}
+
+	merged.Content = strings.Join(contents, "\n")
+	merged.Attachments = attachments
+	if len(messages) > 1 {
+		global.Log.Debugf("[batch]会话 %d 合并了 %d 条连续消息", conversationID, len(messages))
+	}
+	return &merged, nil
+}
@@ -0,0 +1,47 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/utils"
+)
+
+// runExclusive 获取以主机名为持有者的分布式锁后执行 fn, 锁被其他实例持有时跳过; 用于先读后写的定时聚类任务
+func runExclusive(ctx context.Context, lockKey string, expiry time.Duration, fn func() error) error {
+	agentID, _ := os.Hostname()
+	if agentID == "" {
+		agentID = "unknown-agent"
+	}
+	locked, err := global.RedisClient.SetNX(ctx, lockKey, agentID, expiry).Result()
+	if err != nil {
+		return fmt.Errorf("获取分布式锁 %s 失败: %w", lockKey, err)
+	}
+	if !locked {
+		return nil
+	}
+	defer global.RedisClient.Del(context.WithoutCancel(ctx), lockKey)
+	return fn()
+}
+
+// nearestCluster 返回与向量最相似的类别下标及相似度, 没有类别时下标为-1
+func nearestCluster[T any](embedding []float32, clusters []T, embeddingOf func(T) []float32) (int, float32) {
+	best, bestSimilarity := -1, float32(0)
+	for i, cluster := range clusters {
+		if similarity := utils.CosineSimilarity(embedding, embeddingOf(cluster)); similarity > bestSimilarity {
+			best, bestSimilarity = i, similarity
+		}
+	}
+	return best, bestSimilarity
+}
+
+// keepLast 只保留最近的 n 个样本
+func keepLast[T any](samples []T, n int) []T {
+	if len(samples) > n {
+		return samples[len(samples)-n:]
+	}
+	return samples
+}
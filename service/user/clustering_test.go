@@ -0,0 +1,31 @@
+package user
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNearestCluster(t *testing.T) {
+	clusters := [][]float32{{1, 0}, {0.6, 0.8}, {0, 1}}
+	identity := func(v []float32) []float32 { return v }
+
+	if index, similarity := nearestCluster([]float32{0.8, 0.6}, clusters, identity); index != 1 || similarity < 0.95 {
+		t.Errorf("nearestCluster() = (%d, %.2f), want (1, 0.96)", index, similarity)
+	}
+	// 没有类别或方向完全相反时不归入任何类别
+	if index, _ := nearestCluster([]float32{1, 0}, nil, identity); index != -1 {
+		t.Errorf("nearestCluster(nil) = %d, want -1", index)
+	}
+	if index, _ := nearestCluster([]float32{-1, -1}, clusters, identity); index != -1 {
+		t.Errorf("nearestCluster(opposite) = %d, want -1", index)
+	}
+}
+
+func TestKeepLast(t *testing.T) {
+	if got := keepLast([]int{1, 2, 3, 4}, 2); !reflect.DeepEqual(got, []int{3, 4}) {
+		t.Errorf("keepLast() = %v, want [3 4]", got)
+	}
+	if got := keepLast([]int{1}, 2); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("keepLast() = %v, want [1]", got)
+	}
+}
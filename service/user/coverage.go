@@ -0,0 +1,153 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/utils"
+)
+
+// coverageLockExpiry 聚类任务锁的过期时间, 小于定时任务的间隔
+const coverageLockExpiry = 9 * time.Minute
+
+// CoverageGapService 知识库空白统计: 收集知识库未能回答的问题, 定时按向量相似度聚类; 配置每次从 global.Config 读取
+type CoverageGapService interface {
+	// Record 记录一个未能回答的问题, 未启用时忽略
+	Record(ctx context.Context, conversationID uint, question string, reason enum.CoverageGapReason)
+	// ClusterUnanswered 将所有租户待聚类的问题归入相似的类别, 由定时任务调用
+	ClusterUnanswered(ctx context.Context) error
+}
+
+type coverageGapService struct {
+	rateLimit RateLimitService
+}
+
+func NewCoverageGapService() CoverageGapService {
+	return &coverageGapService{rateLimit: NewRateLimitService()}
+}
+
+func (s *coverageGapService) Record(ctx context.Context, conversationID uint, question string, reason enum.CoverageGapReason) {
+	if !global.Config.CoverageGap.Enable || global.RedisClient == nil || question == "" {
+		return
+	}
+	if global.Config.Pii.MaskRecords {
+		question = utils.MaskPII(question)
+	}
+	record := common.UnansweredQuestion{ConversationID: conversationID, Question: question, Reason: reason, CreatedAt: time.Now().Unix()}
+	if err := dao.App.CoverageDb.PushQuestion(ctx, record); err != nil {
+		global.Log.Warnf("[coverage]记录会话 %d 未回答的问题失败: %v", conversationID, err)
+	}
+}
+
+func (s *coverageGapService) ClusterUnanswered(ctx context.Context) error {
+	if !global.Config.CoverageGap.Enable || global.RedisClient == nil {
+		return nil
+	}
+
+	// 类别的更新是先读后写, 同一时间只允许一个实例处理
+	return runExclusive(ctx, redis.KeyCoverageGapLock, coverageLockExpiry, func() error {
+		for _, tenant := range global.Tenants.All() {
+			if err := s.cluster(global.WithTenant(ctx, tenant)); err != nil {
+				global.Log.Errorf("[coverage]租户 %s 聚类未回答的问题失败: %v", tenant.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// cluster 处理当前租户的一批问题, 并清理长时间未再出现的类别
+func (s *coverageGapService) cluster(ctx context.Context) error {
+	cfg := global.Config.CoverageGap
+	gaps, err := dao.App.CoverageDb.ListGaps(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	expireBefore := now - int64(cfg.RetentionDays)*24*3600
+	var expired []string
+	gaps = slices.DeleteFunc(gaps, func(gap *dto.CoverageGap) bool {
+		if gap.LastSeen < expireBefore {
+			expired = append(expired, gap.ID)
+			return true
+		}
+		return false
+	})
+	if err := dao.App.CoverageDb.DeleteGaps(ctx, expired...); err != nil {
+		global.Log.Warnf("[coverage]清理过期的知识库空白失败: %v", err)
+	}
+
+	questions, err := dao.App.CoverageDb.TakeQuestions(ctx, cfg.BatchSize)
+	if err != nil || len(questions) == 0 {
+		return err
+	}
+
+	texts := make([]string, len(questions))
+	for i, q := range questions {
+		texts[i] = q.Question
+	}
+	embeddings, err := s.embed(ctx, texts)
+	if err != nil {
+		// 问题已从队列取出, 放回后由下次聚类重试
+		if returnErr := dao.App.CoverageDb.ReturnQuestions(context.WithoutCancel(ctx), questions); returnErr != nil {
+			global.Log.Errorf("[coverage]放回未回答的问题失败, 丢弃 %d 个问题: %v", len(questions), returnErr)
+		}
+		return err
+	}
+
+	touched := make(map[string]*dto.CoverageGap)
+	for i, q := range questions {
+		var best *dto.CoverageGap
+		index, similarity := nearestCluster(embeddings[i], gaps, func(g *dto.CoverageGap) []float32 { return g.Embedding })
+		switch {
+		case index >= 0 && similarity >= cfg.ClusterThreshold:
+			best = gaps[index]
+		case len(gaps) >= cfg.MaxClusters:
+			continue
+		default:
+			best = &dto.CoverageGap{
+				ID:        strconv.FormatInt(time.Now().UnixNano(), 10),
+				Reasons:   make(map[string]int),
+				Embedding: embeddings[i],
+				FirstSeen: q.CreatedAt,
+			}
+			gaps = append(gaps, best)
+		}
+
+		best.Count++
+		best.Reasons[string(q.Reason)]++
+		if !slices.Contains(best.Samples, q.Question) {
+			best.Samples = keepLast(append(best.Samples, q.Question), cfg.MaxSamples)
+		}
+		best.LastSeen = max(best.LastSeen, q.CreatedAt)
+		touched[best.ID] = best
+	}
+
+	for _, gap := range touched {
+		if err := dao.App.CoverageDb.SaveGap(ctx, gap); err != nil {
+			global.Log.Errorf("[coverage]保存知识库空白 %s 失败: %v", gap.ID, err)
+		}
+	}
+	global.Log.Infof("[coverage]聚类未回答的问题 %d 个, 更新类别 %d 个", len(questions), len(touched))
+	return nil
+}
+
+func (s *coverageGapService) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := s.rateLimit.AcquireModel(ctx, "embedding"); err != nil {
+		return nil, err
+	}
+	embeddings, err := global.EmbeddingService.CreateEmbeddings(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("创建问题向量失败: %w", err)
+	}
+	return embeddings, nil
+}
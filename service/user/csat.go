@@ -0,0 +1,225 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/chatwoot"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/utils"
+)
+
+// maxAnswerLog 每个会话保留的回复记录数
+const maxAnswerLog = 20
+
+// csatOptions 评分选项, 从高到低排列
+var csatOptions = []chatwoot.SelectOption{
+	{Title: "5分 非常满意", Value: enum.CsatOptionPrefix + "5"},
+	{Title: "4分 满意", Value: enum.CsatOptionPrefix + "4"},
+	{Title: "3分 一般", Value: enum.CsatOptionPrefix + "3"},
+	{Title: "2分 不满意", Value: enum.CsatOptionPrefix + "2"},
+	{Title: "1分 非常不满意", Value: enum.CsatOptionPrefix + "1"},
+}
+
+// CsatService 满意度评价: 记录AI发出的回复, 在会话解决时询问评分, 并将评分与这些回复及其依据的快捷回复关联; 配置每次从 global.Config 读取
+type CsatService interface {
+	// RecordAnswer 记录AI发给用户的一条回复, 未启用时忽略
+	RecordAnswer(ctx context.Context, conversationID uint, record common.AnswerRecord)
+	// MarkHumanHandled 标记会话经过人工客服处理
+	MarkHumanHandled(ctx context.Context, conversationID uint)
+	// AskRating 会话解决时向用户发送评分选项; 会话中没有AI回复, 或经过人工处理且未配置询问时不发送
+	AskRating(ctx context.Context, conversationID uint)
+	// HandleSubmission 处理用户点选的评分选项, 返回false表示提交的不是评分
+	HandleSubmission(ctx context.Context, conversationID, messageID uint, values []common.SubmittedValue) bool
+	// RecordSurvey 记录 Chatwoot 满意度调查的结果, 用户补充反馈时更新同一条评价
+	RecordSurvey(ctx context.Context, conversationID, messageID uint, score int, feedback string)
+}
+
+type csatService struct {
+	experiment ExperimentService
+}
+
+func NewCsatService() CsatService {
+	return &csatService{experiment: NewExperimentService()}
+}
+
+func (s *csatService) RecordAnswer(ctx context.Context, conversationID uint, record common.AnswerRecord) {
+	if !global.Config.Csat.Enable || global.RedisClient == nil {
+		return
+	}
+	record.CreatedAt = time.Now().Unix()
+	if global.Config.Pii.MaskRecords {
+		record.Question = utils.MaskPII(record.Question)
+		record.Answer = utils.MaskPII(record.Answer)
+	}
+
+	all := append(s.getAnswerLog(ctx, conversationID), record)
+	if len(all) > maxAnswerLog {
+		all = all[len(all)-maxAnswerLog:]
+	}
+	data, err := json.Marshal(all)
+	if err != nil {
+		return
+	}
+	key := global.ConversationKey(ctx, redis.KeyPrefixAnswerLog, conversationID)
+	if err := global.RedisClient.Set(ctx, key, data, s.ttl()).Err(); err != nil {
+		global.Log.Warnf("[csat]保存会话 %d 回复记录失败: %v", conversationID, err)
+	}
+}
+
+func (s *csatService) MarkHumanHandled(ctx context.Context, conversationID uint) {
+	if !global.Config.Csat.Enable || global.RedisClient == nil {
+		return
+	}
+	key := global.ConversationKey(ctx, redis.KeyPrefixCsatHumanHandled, conversationID)
+	if err := global.RedisClient.Set(ctx, key, "1", s.ttl()).Err(); err != nil {
+		global.Log.Warnf("[csat]标记会话 %d 经人工处理失败: %v", conversationID, err)
+	}
+}
+
+func (s *csatService) AskRating(ctx context.Context, conversationID uint) {
+	cfg := global.Config.Csat
+	if !cfg.Enable || !cfg.AskOnResolved || global.RedisClient == nil {
+		return
+	}
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
+		return
+	}
+
+	if len(s.getAnswerLog(ctx, conversationID)) == 0 {
+		return
+	}
+	if !cfg.IncludeHumanHandled && s.isHumanHandled(ctx, conversationID) {
+		global.Log.Debugf("[csat]会话 %d 经过人工处理，不发送评分选项", conversationID)
+		return
+	}
+
+	// 同一轮对话只询问一次, 用户评价后清除标记
+	askedKey := global.ConversationKey(ctx, redis.KeyPrefixCsatAsked, conversationID)
+	ok, err := global.RedisClient.SetNX(ctx, askedKey, "1", s.ttl()).Result()
+	if err != nil {
+		global.Log.Warnf("[csat]设置会话 %d 评分询问标记失败: %v", conversationID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	if err := chatwootClient.CreateInputSelectMessage(conversationID, string(enum.ReplyMsgCsatAsk), csatOptions); err != nil {
+		global.RedisClient.Del(ctx, askedKey)
+		global.Log.Errorf("[csat]向会话 %d 发送评分选项失败: %v", conversationID, err)
+	}
+}
+
+func (s *csatService) HandleSubmission(ctx context.Context, conversationID, messageID uint, values []common.SubmittedValue) bool {
+	if len(values) == 0 || !strings.HasPrefix(values[0].Value, enum.CsatOptionPrefix) {
+		return false
+	}
+	score, err := strconv.Atoi(strings.TrimPrefix(values[0].Value, enum.CsatOptionPrefix))
+	if err != nil || score < 1 || score > 5 {
+		global.Log.Warnf("[csat]会话 %d 提交的评分无效: %s", conversationID, values[0].Value)
+		return true
+	}
+
+	if s.saveRating(ctx, conversationID, messageID, score, "", enum.CsatChannelBot) {
+		if chatwootClient := global.TenantFrom(ctx).Chatwoot(); chatwootClient != nil {
+			if err := chatwootClient.CreateMessage(conversationID, string(enum.ReplyMsgCsatThanks)); err != nil {
+				global.Log.Errorf("[csat]向会话 %d 发送感谢消息失败: %v", conversationID, err)
+			}
+		}
+	}
+	return true
+}
+
+func (s *csatService) RecordSurvey(ctx context.Context, conversationID, messageID uint, score int, feedback string) {
+	if score < 1 || score > 5 {
+		return
+	}
+	s.saveRating(ctx, conversationID, messageID, score, feedback, enum.CsatChannelChatwoot)
+}
+
+// saveRating 保存评价; 首次评价时附上评价前的回复记录并计入实验指标, 之后清空本轮的回复记录和标记. 返回是否为首次评价
+func (s *csatService) saveRating(ctx context.Context, conversationID, messageID uint, score int, feedback string, channel enum.CsatChannel) bool {
+	cfg := global.Config.Csat
+	if !cfg.Enable || global.RedisClient == nil {
+		return false
+	}
+	if global.Config.Pii.MaskRecords {
+		feedback = utils.MaskPII(feedback)
+	}
+
+	id := strconv.FormatUint(uint64(messageID), 10)
+	rating, err := dao.App.CsatDb.GetRating(ctx, id)
+	if err != nil {
+		global.Log.Warnf("[csat]获取会话 %d 的评价失败: %v", conversationID, err)
+		return false
+	}
+
+	isNew := rating == nil
+	if isNew {
+		rating = &common.CsatRating{
+			ID:             id,
+			ConversationID: conversationID,
+			Channel:        channel,
+			HumanHandled:   s.isHumanHandled(ctx, conversationID),
+			Answers:        s.getAnswerLog(ctx, conversationID),
+			CreatedAt:      time.Now().Unix(),
+		}
+	}
+	rating.Score = score
+	if feedback != "" {
+		rating.Feedback = feedback
+	}
+
+	if err := dao.App.CsatDb.SaveRating(ctx, rating, isNew, cfg.LogMaxLen); err != nil {
+		global.Log.Errorf("[csat]保存会话 %d 的评价失败: %v", conversationID, err)
+		return false
+	}
+	if !isNew {
+		return false
+	}
+
+	global.Log.Infof("[csat]会话 %d 收到评价: %d分, 关联回复 %d 条", conversationID, score, len(rating.Answers))
+	s.experiment.RecordCsat(ctx, conversationID, score)
+	global.RedisClient.Del(ctx,
+		global.ConversationKey(ctx, redis.KeyPrefixAnswerLog, conversationID),
+		global.ConversationKey(ctx, redis.KeyPrefixCsatHumanHandled, conversationID),
+		global.ConversationKey(ctx, redis.KeyPrefixCsatAsked, conversationID),
+	)
+	return true
+}
+
+// getAnswerLog 获取会话中尚未评价的回复记录
+func (s *csatService) getAnswerLog(ctx context.Context, conversationID uint) []common.AnswerRecord {
+	key := global.ConversationKey(ctx, redis.KeyPrefixAnswerLog, conversationID)
+	val, err := global.RedisClient.Get(ctx, key).Result()
+	if err != nil {
+		if err != redis.ErrNil {
+			global.Log.Warnf("[csat]获取会话 %d 回复记录失败: %v", conversationID, err)
+		}
+		return nil
+	}
+	var records []common.AnswerRecord
+	if err := json.Unmarshal([]byte(val), &records); err != nil {
+		global.Log.Warnf("[csat]解析会话 %d 回复记录失败: %v", conversationID, err)
+		return nil
+	}
+	return records
+}
+
+func (s *csatService) isHumanHandled(ctx context.Context, conversationID uint) bool {
+	key := global.ConversationKey(ctx, redis.KeyPrefixCsatHumanHandled, conversationID)
+	return global.RedisClient.Get(ctx, key).Err() == nil
+}
+
+// ttl 回复记录和标记的过期时间与会话历史一致
+func (s *csatService) ttl() time.Duration {
+	return time.Duration(global.Config.Redis.ConversationHistoryTTL) * time.Second
+}
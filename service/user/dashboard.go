@@ -34,6 +34,8 @@ const (
 type DashboardService interface {
 	// GetDetails 调用MCP服务获取用户、商品或订单的聚合详情
 	GetDetails(ctx context.Context, userID, goodsID, orderID string) (map[string]interface{}, error)
+	// GetOrderDetails 调用MCP服务获取单个订单的详情
+	GetOrderDetails(ctx context.Context, orderID string) (map[string]interface{}, error)
 }
 
 type dashboardService struct{}
@@ -42,14 +44,15 @@ func NewDashboardService() DashboardService {
 	return &dashboardService{}
 }
 
-func (s *dashboardService) getClientName() (string, error) {
+func (s *dashboardService) getClientName(ctx context.Context) (string, error) {
 	var clientName string
+	mcpServers := global.TenantFrom(ctx).McpServers()
 	// 优先使用PreferredMcpClient客户端
-	if _, ok := global.Config.McpServers[PreferredMcpClient]; ok {
+	if _, ok := mcpServers[PreferredMcpClient]; ok {
 		clientName = PreferredMcpClient
 	} else {
 		// 如果找不到，则回退到选择第一个可用的客户端，并发出警告
-		for name := range global.Config.McpServers {
+		for name := range mcpServers {
 			clientName = name // 使用第一个可用的客户端名称
 			global.Log.Warnf("未找到首选的MCP客户端 '%s'，已回退到使用第一个可用的客户端 '%s'", PreferredMcpClient, clientName)
 			break
@@ -63,8 +66,11 @@ func (s *dashboardService) getClientName() (string, error) {
 }
 
 func (s *dashboardService) _getGoodsDetails(ctx context.Context, clientName, goodsID string) (map[string]interface{}, error) {
-	arguments := json.RawMessage(fmt.Sprintf(`{"%s": "%s"}`, MCP_ARG_GOODS_ID, goodsID))
-	resultStr, err := global.McpService.ExecuteTool(ctx, clientName, MCPP_TOOL_GET_GOODS_DETAILS, arguments)
+	arguments, err := toolArguments(MCP_ARG_GOODS_ID, goodsID)
+	if err != nil {
+		return nil, err
+	}
+	resultStr, err := global.TenantFrom(ctx).Mcp().ExecuteTool(ctx, clientName, MCPP_TOOL_GET_GOODS_DETAILS, arguments)
 	if err != nil {
 		return nil, fmt.Errorf("调用MCP工具 %s 失败: %w", MCPP_TOOL_GET_GOODS_DETAILS, err)
 	}
@@ -77,8 +83,11 @@ func (s *dashboardService) _getGoodsDetails(ctx context.Context, clientName, goo
 }
 
 func (s *dashboardService) _getOrderDetails(ctx context.Context, clientName, orderID string) (map[string]interface{}, error) {
-	arguments := json.RawMessage(fmt.Sprintf(`{"%s": "%s"}`, MCP_ARG_ORDER_ID, orderID))
-	resultStr, err := global.McpService.ExecuteTool(ctx, clientName, MCP_TOOL_GET_ORDER_DETAILS, arguments)
+	arguments, err := toolArguments(MCP_ARG_ORDER_ID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	resultStr, err := global.TenantFrom(ctx).Mcp().ExecuteTool(ctx, clientName, MCP_TOOL_GET_ORDER_DETAILS, arguments)
 	if err != nil {
 		return nil, fmt.Errorf("调用MCP工具 %s 失败: %w", MCP_TOOL_GET_ORDER_DETAILS, err)
 	}
@@ -90,9 +99,23 @@ func (s *dashboardService) _getOrderDetails(ctx context.Context, clientName, ord
 	return details, nil
 }
 
+func (s *dashboardService) GetOrderDetails(ctx context.Context, orderID string) (map[string]interface{}, error) {
+	if global.TenantFrom(ctx).Mcp() == nil {
+		return nil, errors.New("MCP服务未初始化")
+	}
+	clientName, err := s.getClientName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s._getOrderDetails(ctx, clientName, orderID)
+}
+
 func (s *dashboardService) _getUserDetails(ctx context.Context, clientName, userID string) (map[string]interface{}, error) {
-	arguments := json.RawMessage(fmt.Sprintf(`{"%s": "%s"}`, MCP_ARG_USER_ID, userID))
-	resultStr, err := global.McpService.ExecuteTool(ctx, clientName, MCP_TOOL_GET_USER_DETAILS, arguments)
+	arguments, err := toolArguments(MCP_ARG_USER_ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	resultStr, err := global.TenantFrom(ctx).Mcp().ExecuteTool(ctx, clientName, MCP_TOOL_GET_USER_DETAILS, arguments)
 	if err != nil {
 		return nil, fmt.Errorf("调用MCP工具 %s 失败: %w", MCP_TOOL_GET_USER_DETAILS, err)
 	}
@@ -105,7 +128,7 @@ func (s *dashboardService) _getUserDetails(ctx context.Context, clientName, user
 }
 
 func (s *dashboardService) GetDetails(ctx context.Context, userID, goodsID, orderID string) (map[string]interface{}, error) {
-	if global.McpService == nil {
+	if global.TenantFrom(ctx).Mcp() == nil {
 		return nil, errors.New("MCP服务未初始化")
 	}
 
@@ -113,7 +136,7 @@ func (s *dashboardService) GetDetails(ctx context.Context, userID, goodsID, orde
 		return nil, errors.New("用户ID、商品ID和订单ID不能同时为空")
 	}
 
-	clientName, err := s.getClientName()
+	clientName, err := s.getClientName(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -174,3 +197,12 @@ func (s *dashboardService) GetDetails(ctx context.Context, userID, goodsID, orde
 
 	return allDetails, nil
 }
+
+// toolArguments 构造只有一个参数的工具调用参数, 参数值可能来自大模型或用户输入, 必须经过JSON编码
+func toolArguments(name, value string) (json.RawMessage, error) {
+	arguments, err := json.Marshal(map[string]string{name: value})
+	if err != nil {
+		return nil, fmt.Errorf("构造工具参数失败: %w", err)
+	}
+	return arguments, nil
+}
@@ -3,21 +3,49 @@ package user
 import "gitee.com/taoJie_1/mall-agent/task"
 
 type ServiceGroup struct {
-	ActionService    ActionService
-	LlmService       LlmService
-	VectorService    VectorService
-	HistoryService   HistoryService
-	DashboardService DashboardService
-	Validator        Validator
+	ActionService          ActionService
+	LlmService             LlmService
+	VectorService          VectorService
+	HistoryService         HistoryService
+	DashboardService       DashboardService
+	ToolService            ToolService
+	RoutingService         RoutingService
+	AttachmentService      AttachmentService
+	BatchService           MessageBatchService
+	TaskRegistry           TaskRegistry
+	JobQueue               JobQueue
+	RateLimitService       RateLimitService
+	GuardService           GuardService
+	AnswerCheckService     AnswerCheckService
+	ExperimentService      ExperimentService
+	CsatService            CsatService
+	KnowledgeMiningService KnowledgeMiningService
+	AssistService          AssistService
+	CoverageGapService     CoverageGapService
+	Validator              Validator
 }
 
 func NewServiceGroup(taskManager *task.Manager) ServiceGroup {
 	return ServiceGroup{
-		ActionService:    NewActionService(),
-		LlmService:       NewLlmService(),
-		VectorService:    NewVectorService(),
-		HistoryService:   NewHistoryService(),
-		DashboardService: NewDashboardService(),
-		Validator:        &validator{},
+		ActionService:          NewActionService(),
+		LlmService:             NewLlmService(),
+		VectorService:          NewVectorService(),
+		HistoryService:         NewHistoryService(),
+		DashboardService:       NewDashboardService(),
+		ToolService:            NewToolService(),
+		RoutingService:         NewRoutingService(),
+		AttachmentService:      NewAttachmentService(),
+		BatchService:           NewMessageBatchService(),
+		TaskRegistry:           NewTaskRegistry(),
+		JobQueue:               NewJobQueue(),
+		RateLimitService:       NewRateLimitService(),
+		GuardService:           NewGuardService(),
+		AnswerCheckService:     NewAnswerCheckService(),
+		ExperimentService:      NewExperimentService(),
+		CsatService:            NewCsatService(),
+		KnowledgeMiningService: NewKnowledgeMiningService(),
+		AssistService:          NewAssistService(),
+		CoverageGapService:     NewCoverageGapService(),
+		Validator:              &validator{},
 	}
 }
@@ -0,0 +1,172 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/llm"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/config"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+)
+
+// 实验统计数据的保留时间, 每次写入时续期
+const experimentStatsTTL = 90 * 24 * time.Hour
+
+// ExperimentService A/B实验: 按会话ID的稳定哈希分配分组, 并按分组记录效果指标
+type ExperimentService interface {
+	// Assign 返回会话所在的实验分组, 未启用实验时返回nil
+	Assign(conversationID uint) *config.ExperimentVariant
+	// WithVariant 将会话的实验分组及其覆盖项放入上下文
+	WithVariant(ctx context.Context, conversationID uint) context.Context
+	// RecordTurn 记录分组处理了会话的一条用户消息
+	RecordTurn(ctx context.Context, conversationID uint)
+	// RecordTransfer 记录会话转人工
+	RecordTransfer(ctx context.Context, conversationID uint)
+	// RecordCsat 记录会话的满意度评分
+	RecordCsat(ctx context.Context, conversationID uint, score int)
+	// Report 对比当前实验各分组的指标
+	Report(ctx context.Context) (*dto.ExperimentReport, error)
+}
+
+type experimentService struct{}
+
+func NewExperimentService() ExperimentService {
+	return &experimentService{}
+}
+
+func (s *experimentService) Assign(conversationID uint) *config.ExperimentVariant {
+	experiment := global.Config.Experiment
+	if !experiment.Enable || len(experiment.Variants) == 0 {
+		return nil
+	}
+
+	var total uint64
+	for _, v := range experiment.Variants {
+		total += uint64(v.Weight)
+	}
+	if total == 0 {
+		return nil
+	}
+
+	// 哈希中加入实验名称, 不同实验的分组互不相关
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%s:%d", experiment.Name, conversationID)
+	point := h.Sum64() % total
+	for i := range experiment.Variants {
+		if point < uint64(experiment.Variants[i].Weight) {
+			return &experiment.Variants[i]
+		}
+		point -= uint64(experiment.Variants[i].Weight)
+	}
+	return nil
+}
+
+func (s *experimentService) WithVariant(ctx context.Context, conversationID uint) context.Context {
+	variant := s.Assign(conversationID)
+	if variant == nil {
+		return ctx
+	}
+	ctx = global.WithVariant(ctx, variant)
+	return llm.WithModels(ctx, variant.Models)
+}
+
+func (s *experimentService) RecordTurn(ctx context.Context, conversationID uint) {
+	variant := s.Assign(conversationID)
+	if variant == nil || global.RedisClient == nil {
+		return
+	}
+	global.Log.Debugf("[experiment] 会话 %d 在实验 '%s' 的分组 '%s'", conversationID, global.Config.Experiment.Name, variant.Name)
+
+	suffix := s.keySuffix(variant.Name)
+	statsKey := s.key(ctx, redis.KeyPrefixExperimentStats, suffix)
+	convsKey := s.key(ctx, redis.KeyPrefixExperimentConvs, suffix)
+	if err := global.RedisClient.HIncrBy(ctx, statsKey, "turns", 1).Err(); err != nil {
+		global.Log.Warnf("[experiment] 会话 %d 记录实验数据失败: %v", conversationID, err)
+		return
+	}
+	global.RedisClient.SAdd(ctx, convsKey, conversationID)
+	global.RedisClient.Expire(ctx, statsKey, experimentStatsTTL)
+	global.RedisClient.Expire(ctx, convsKey, experimentStatsTTL)
+}
+
+func (s *experimentService) RecordTransfer(ctx context.Context, conversationID uint) {
+	variant := s.Assign(conversationID)
+	if variant == nil || global.RedisClient == nil {
+		return
+	}
+	// 未经AI处理直接转人工的会话(如提问过长)同样计入分组的会话数
+	suffix := s.keySuffix(variant.Name)
+	for _, key := range []string{s.key(ctx, redis.KeyPrefixExperimentTransfers, suffix), s.key(ctx, redis.KeyPrefixExperimentConvs, suffix)} {
+		if err := global.RedisClient.SAdd(ctx, key, conversationID).Err(); err != nil {
+			global.Log.Warnf("[experiment] 会话 %d 记录转人工失败: %v", conversationID, err)
+			return
+		}
+		global.RedisClient.Expire(ctx, key, experimentStatsTTL)
+	}
+}
+
+func (s *experimentService) RecordCsat(ctx context.Context, conversationID uint, score int) {
+	variant := s.Assign(conversationID)
+	if variant == nil || global.RedisClient == nil {
+		return
+	}
+	key := s.key(ctx, redis.KeyPrefixExperimentStats, s.keySuffix(variant.Name))
+	if err := global.RedisClient.HIncrBy(ctx, key, "csat_count", 1).Err(); err != nil {
+		global.Log.Warnf("[experiment] 会话 %d 记录满意度失败: %v", conversationID, err)
+		return
+	}
+	global.RedisClient.HIncrBy(ctx, key, "csat_sum", int64(score))
+	global.RedisClient.Expire(ctx, key, experimentStatsTTL)
+}
+
+func (s *experimentService) Report(ctx context.Context) (*dto.ExperimentReport, error) {
+	if global.RedisClient == nil {
+		return nil, fmt.Errorf("Redis客户端未初始化")
+	}
+	experiment := global.Config.Experiment
+	report := &dto.ExperimentReport{Name: experiment.Name, Enable: experiment.Enable, Variants: []*dto.ExperimentVariantStats{}}
+
+	for _, v := range experiment.Variants {
+		suffix := s.keySuffix(v.Name)
+		stats := &dto.ExperimentVariantStats{Name: v.Name, Weight: v.Weight}
+
+		counters, err := global.RedisClient.HGetAll(ctx, s.key(ctx, redis.KeyPrefixExperimentStats, suffix)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("获取实验分组 '%s' 的数据失败: %w", v.Name, err)
+		}
+		stats.Turns, _ = strconv.ParseInt(counters["turns"], 10, 64)
+		stats.CsatCount, _ = strconv.ParseInt(counters["csat_count"], 10, 64)
+		csatSum, _ := strconv.ParseInt(counters["csat_sum"], 10, 64)
+		if stats.CsatCount > 0 {
+			stats.CsatAverage = float64(csatSum) / float64(stats.CsatCount)
+		}
+
+		if stats.Conversations, err = global.RedisClient.SCard(ctx, s.key(ctx, redis.KeyPrefixExperimentConvs, suffix)).Result(); err != nil {
+			return nil, fmt.Errorf("获取实验分组 '%s' 的会话数失败: %w", v.Name, err)
+		}
+		if stats.Transferred, err = global.RedisClient.SCard(ctx, s.key(ctx, redis.KeyPrefixExperimentTransfers, suffix)).Result(); err != nil {
+			return nil, fmt.Errorf("获取实验分组 '%s' 的转人工数失败: %w", v.Name, err)
+		}
+		if stats.Conversations > 0 {
+			stats.TransferRate = float64(stats.Transferred) / float64(stats.Conversations)
+			stats.DeflectionRate = 1 - stats.TransferRate
+		}
+		report.Variants = append(report.Variants, stats)
+	}
+	return report, nil
+}
+
+// keySuffix 实验分组统计数据的Key后缀
+func (s *experimentService) keySuffix(variant string) string {
+	return global.Config.Experiment.Name + ":" + variant
+}
+
+// key 实验统计数据的Key, 按租户区分, 各租户的实验数据互不影响
+func (s *experimentService) key(ctx context.Context, prefix, suffix string) string {
+	return global.TenantFrom(ctx).Key(prefix + suffix)
+}
@@ -0,0 +1,210 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/utils"
+)
+
+const (
+	// maxGuardLogContent 检测记录中保存的原文长度上限
+	maxGuardLogContent = 500
+	// guardFilteredText 工具结果中注入内容的替换文本
+	guardFilteredText = "[已过滤]"
+)
+
+// GuardVerdict 用户消息的注入检测结果
+type GuardVerdict struct {
+	Flagged bool
+	Score   float64
+	Rules   []string
+	Reason  string
+}
+
+// GuardService 提示词注入/越狱防护, 结合启发式规则和小模型分类器打分; 配置每次从 global.Config 读取, 支持热重载
+type GuardService interface {
+	// Inspect 对用户消息打分, 达到阈值时 Flagged 为true并记录检测; 未启用时返回nil
+	Inspect(ctx context.Context, conversationID uint, content string) (*GuardVerdict, error)
+	// SanitizeToolResult 过滤工具返回结果中的注入内容和伪造标签, 命中时记录检测
+	SanitizeToolResult(ctx context.Context, conversationID uint, toolName, result string) string
+	// ListDetections 获取最近的检测记录, 最新的在前
+	ListDetections(ctx context.Context, limit int64) ([]common.GuardDetection, error)
+}
+
+type guardRule struct {
+	name  string
+	score float64
+	re    *regexp.Regexp
+}
+
+// builtinGuardRules 内置启发式规则; 分数达到阈值的规则同时用于过滤工具结果
+var builtinGuardRules = []guardRule{
+	{"ignore_instructions", 0.9, regexp.MustCompile(`(?i)(忽略|无视|忘记|忘掉|跳过|不要遵守|不要理会)(掉)?(你)?(之前|以上|上面|前面|上述|先前|刚才|所有|全部|一切)的?(所有|全部)?(指令|指示|提示|提示词|规则|设定|要求|限制|约束)`)},
+	{"ignore_instructions", 0.9, regexp.MustCompile(`(?i)(ignore|disregard|forget)\s+(all\s+|any\s+)?(the\s+|your\s+)?(previous|prior|above|earlier|preceding)\s+(instructions|prompts|rules|directions)`)},
+	// 手机越狱、开发者模式也是正常的商品咨询, 只有针对AI本身的说法才直接判定; 单独提到模式名称时交由分类器判断
+	{"jailbreak", 0.9, regexp.MustCompile(`(?i)你(现在|已经|已|被)+越狱|越狱(模式|提示词|指令|版的?你)|(进入|开启|切换到|启用)(无限制|越狱|DAN)\s*模式|jailbreak\s+(mode|prompt)|you\s+(are|have\s+been)\s+jailbroken|\bDAN\s+mode|do\s+anything\s+now`)},
+	{"jailbreak", 0.5, regexp.MustCompile(`(?i)越狱|jailbreak|(开发者|上帝|无限制)模式|developer\s+mode`)},
+	{"fake_tag", 0.9, regexp.MustCompile(`(?i)</?\s*(tool_code|interactive|system)\s*>|<\|im_(start|end)\|>`)},
+	{"prompt_leak", 0.6, regexp.MustCompile(`(?i)(输出|打印|告诉我|泄露|重复|显示|给我看)(一下)?(你的)?(系统|初始|原始)?(提示词|系统指令|系统设定)|(reveal|show|print|repeat)\s+(me\s+)?(your\s+)?(system\s+)?(prompt|instructions)`)},
+	{"role_override", 0.6, regexp.MustCompile(`(?i)(从现在(起|开始)|现在)你(就是|将扮演|要扮演|扮演)|you\s+are\s+now|pretend\s+(to\s+be|you\s+are)`)},
+	{"fake_role", 0.6, regexp.MustCompile(`(?im)^\s*(system|assistant|系统)\s*[:：]`)},
+}
+
+type guardService struct {
+	llm LlmService
+
+	mu          sync.Mutex
+	customKey   string
+	customRules []guardRule
+}
+
+func NewGuardService() GuardService {
+	return &guardService{llm: NewLlmService()}
+}
+
+func (s *guardService) Inspect(ctx context.Context, conversationID uint, content string) (*GuardVerdict, error) {
+	cfg := global.Config.Guard
+	if !cfg.Enable || strings.TrimSpace(content) == "" {
+		return nil, nil
+	}
+
+	verdict := &GuardVerdict{}
+	for _, rule := range s.rules() {
+		if rule.re.MatchString(content) {
+			verdict.Rules = append(verdict.Rules, rule.name)
+			verdict.Score = max(verdict.Score, rule.score)
+		}
+	}
+
+	// 启发式规则已足以判定时不再调用分类器; 分类器失败时仅依据启发式结果, 不影响正常服务
+	if cfg.Classifier && verdict.Score < cfg.Threshold {
+		result, err := s.llm.ClassifyInjection(ctx, content)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			global.Log.Warnf("[guard]会话 %d 注入分类失败, 仅使用启发式结果: %v", conversationID, err)
+		} else {
+			verdict.Reason = result.Reason
+			verdict.Score = max(verdict.Score, result.Score)
+		}
+	}
+
+	verdict.Flagged = verdict.Score >= cfg.Threshold
+	if verdict.Flagged {
+		s.record(ctx, common.GuardDetection{
+			ConversationID: conversationID,
+			Source:         "message",
+			Score:          verdict.Score,
+			Rules:          verdict.Rules,
+			Reason:         verdict.Reason,
+			Action:         cfg.Action,
+			Content:        truncateRunes(content, maxGuardLogContent),
+		})
+	}
+	return verdict, nil
+}
+
+func (s *guardService) SanitizeToolResult(ctx context.Context, conversationID uint, toolName, result string) string {
+	cfg := global.Config.Guard
+	if !cfg.Enable || result == "" {
+		return result
+	}
+
+	var hits []string
+	sanitized := result
+	for _, rule := range s.rules() {
+		if rule.score < cfg.Threshold || !rule.re.MatchString(sanitized) {
+			continue
+		}
+		hits = append(hits, rule.name)
+		sanitized = rule.re.ReplaceAllString(sanitized, guardFilteredText)
+	}
+	if len(hits) == 0 {
+		return result
+	}
+
+	global.Log.Warnf("[guard]会话 %d 工具 %s 的返回结果疑似包含注入内容, 已过滤: %v", conversationID, toolName, hits)
+	s.record(ctx, common.GuardDetection{
+		ConversationID: conversationID,
+		Source:         "tool:" + toolName,
+		Score:          1,
+		Rules:          hits,
+		Action:         "sanitize",
+		Content:        truncateRunes(result, maxGuardLogContent),
+	})
+	return sanitized
+}
+
+func (s *guardService) ListDetections(ctx context.Context, limit int64) ([]common.GuardDetection, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+	entries, err := global.RedisClient.LRange(ctx, redis.KeyGuardDetections, 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取注入检测记录失败: %w", err)
+	}
+
+	detections := make([]common.GuardDetection, 0, len(entries))
+	for _, entry := range entries {
+		var detection common.GuardDetection
+		if err := json.Unmarshal([]byte(entry), &detection); err != nil {
+			global.Log.Warnf("[guard]解析注入检测记录失败: %v", err)
+			continue
+		}
+		detections = append(detections, detection)
+	}
+	return detections, nil
+}
+
+// record 写入检测记录, 失败时只记日志
+func (s *guardService) record(ctx context.Context, detection common.GuardDetection) {
+	detection.CreatedAt = time.Now().Unix()
+	if global.Config.Pii.MaskRecords {
+		detection.Content = utils.MaskPII(detection.Content)
+	}
+	global.Log.Warnf("[guard]会话 %d 检测到疑似提示词注入: source=%s, score=%.2f, rules=%v, reason=%s",
+		detection.ConversationID, detection.Source, detection.Score, detection.Rules, detection.Reason)
+	if global.RedisClient == nil {
+		return
+	}
+
+	entry, _ := json.Marshal(detection)
+	if err := global.RedisClient.LPush(ctx, redis.KeyGuardDetections, entry).Err(); err != nil {
+		global.Log.Errorf("[guard]写入注入检测记录失败: %v", err)
+		return
+	}
+	global.RedisClient.LTrim(ctx, redis.KeyGuardDetections, 0, global.Config.Guard.LogMaxLen-1)
+}
+
+// rules 返回内置规则和配置中追加的规则; 追加规则按配置内容缓存, 配置变更后重新编译
+func (s *guardService) rules() []guardRule {
+	patterns := global.Config.Guard.Patterns
+	key := strings.Join(patterns, "\x00")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key != s.customKey {
+		s.customKey = key
+		s.customRules = s.customRules[:0:0]
+		for _, pattern := range patterns {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				global.Log.Errorf("[guard]注入规则 %q 无效, 已忽略: %v", pattern, err)
+				continue
+			}
+			s.customRules = append(s.customRules, guardRule{name: "custom:" + pattern, score: 1, re: re})
+		}
+	}
+	return append(builtinGuardRules[:len(builtinGuardRules):len(builtinGuardRules)], s.customRules...)
+}
@@ -26,8 +26,16 @@ type HistoryService interface {
 
 	// Set 直接用给定的历史记录覆盖Redis中的缓存。
 	Set(ctx context.Context, conversationID uint, history []common.LlmMessage) error
+
+	// WithVault 将会话关联到上下文, 构建提示词时据此读取历史中个人信息占位符的映射。
+	WithVault(ctx context.Context, conversationID uint) context.Context
 }
 
+// piiVaultRetries 并发写入导致占位符冲突时, 重新读取映射后重试的次数
+const piiVaultRetries = 3
+
+type vaultCtxKey struct{}
+
 type historyService struct{}
 
 // NewHistoryService 创建一个新的 HistoryService 实例
@@ -41,7 +49,7 @@ func (s *historyService) GetOrFetch(ctx context.Context, accountID, conversation
 	}
 
 	// 1. 尝试从Redis获取聊天记录
-	history, err := global.RedisClient.GetConversationHistory(ctx, conversationID)
+	history, err := global.RedisClient.GetConversationHistory(ctx, global.TenantFrom(ctx).ConversationScope(conversationID))
 	if err != nil && err != redis.ErrNil { // Redis error other than miss
 		global.Log.Warnf("从Redis获取会话 %d 历史记录失败: %v, 将尝试从Chatwoot获取", conversationID, err)
 	} else if history != nil { // Cache hit
@@ -51,12 +59,12 @@ func (s *historyService) GetOrFetch(ctx context.Context, accountID, conversation
 
 	// --- 缓存未命中，进入回源逻辑 ---
 
-	if global.ChatwootService == nil {
+	if global.TenantFrom(ctx).Chatwoot() == nil {
 		return nil, fmt.Errorf("Chatwoot客户端未初始化")
 	}
 
 	// 2. 使用分布式锁防止缓存击穿
-	lockKey := fmt.Sprintf("%s%d", redis.KeyPrefixHistoryLock, conversationID)
+	lockKey := global.ConversationKey(ctx, redis.KeyPrefixHistoryLock, conversationID)
 	lockExpiry := time.Duration(global.Config.Redis.HistoryLockExpiry) * time.Second
 	agentID, _ := os.Hostname()
 	if agentID == "" {
@@ -80,7 +88,7 @@ func (s *historyService) GetOrFetch(ctx context.Context, accountID, conversation
 			}
 		}()
 		// 在获取锁后，再次检查缓存，防止在获取锁的过程中，已有其他请求完成了缓存填充（双重检查锁定）
-		history, err := global.RedisClient.GetConversationHistory(ctx, conversationID)
+		history, err := global.RedisClient.GetConversationHistory(ctx, global.TenantFrom(ctx).ConversationScope(conversationID))
 		if err == nil && history != nil {
 			global.Log.Debugf("获取锁后发现会话 %d 缓存已存在", conversationID)
 			return history, nil
@@ -92,7 +100,7 @@ func (s *historyService) GetOrFetch(ctx context.Context, accountID, conversation
 	global.Log.Debugf("会话 %d 历史记录锁被占用，等待后重试", conversationID)
 	time.Sleep(200 * time.Millisecond) // 短暂等待
 
-	history, err = global.RedisClient.GetConversationHistory(ctx, conversationID)
+	history, err = global.RedisClient.GetConversationHistory(ctx, global.TenantFrom(ctx).ConversationScope(conversationID))
 	if err == nil && history != nil {
 		global.Log.Debugf("等待后，会话 %d 历史记录从Redis缓存命中", conversationID)
 		return history, nil
@@ -112,7 +120,8 @@ func (s *historyService) Append(ctx context.Context, conversationID uint, messag
 	}
 
 	ttl := utils.GetTTLWithJitter(global.Config.Redis.ConversationHistoryTTL)
-	err := global.RedisClient.AppendToConversationHistory(ctx, conversationID, ttl, messages...)
+	messages = s.redact(ctx, conversationID, messages, ttl)
+	err := global.RedisClient.AppendToConversationHistory(ctx, global.TenantFrom(ctx).ConversationScope(conversationID), ttl, messages...)
 	if err != nil {
 		global.Log.Errorf("追加消息到会话 %d 历史记录失败: %v", conversationID, err)
 	}
@@ -124,17 +133,90 @@ func (s *historyService) Set(ctx context.Context, conversationID uint, history [
 		return fmt.Errorf("Redis客户端未初始化")
 	}
 	ttl := utils.GetTTLWithJitter(global.Config.Redis.ConversationHistoryTTL)
-	err := global.RedisClient.SetConversationHistory(ctx, conversationID, history, ttl)
+	return s.store(ctx, conversationID, s.redact(ctx, conversationID, history, ttl), ttl)
+}
+
+// store 保存已脱敏的历史记录
+func (s *historyService) store(ctx context.Context, conversationID uint, history []common.LlmMessage, ttl time.Duration) error {
+	err := global.RedisClient.SetConversationHistory(ctx, global.TenantFrom(ctx).ConversationScope(conversationID), history, ttl)
 	if err != nil {
 		global.Log.Errorf("设置会话 %d 历史记录失败: %v", conversationID, err)
 	}
 	return err
 }
 
+func (s *historyService) WithVault(ctx context.Context, conversationID uint) context.Context {
+	return withHistoryVault(ctx, conversationID)
+}
+
+// withHistoryVault 将会话关联到上下文, 见 historyVault
+func withHistoryVault(ctx context.Context, conversationID uint) context.Context {
+	if !global.Config.Pii.MaskRecords {
+		return ctx
+	}
+	return context.WithValue(ctx, vaultCtxKey{}, conversationID)
+}
+
+// historyVault 读取上下文所关联会话的占位符映射; 未开启脱敏、未关联会话或读取失败时返回nil
+// 每次构建提示词时重新读取, 以包含本轮回源或追加历史时新分配的占位符
+func historyVault(ctx context.Context) *utils.PIIVault {
+	conversationID, ok := ctx.Value(vaultCtxKey{}).(uint)
+	if !ok {
+		return nil
+	}
+	return loadHistoryVault(ctx, conversationID)
+}
+
+// loadHistoryVault 读取会话历史的占位符映射; 未开启脱敏或读取失败时返回nil
+func loadHistoryVault(ctx context.Context, conversationID uint) *utils.PIIVault {
+	if !global.Config.Pii.MaskRecords || global.RedisClient == nil {
+		return nil
+	}
+	values, err := global.RedisClient.GetPIIVault(ctx, global.TenantFrom(ctx).ConversationScope(conversationID))
+	if err != nil {
+		global.Log.Warnf("读取会话 %d 的个人信息占位符映射失败: %v", conversationID, err)
+		return nil
+	}
+	return utils.LoadPIIVault(values)
+}
+
+// redact 开启记录脱敏时, 将历史消息中的个人信息替换为可还原的占位符, 映射与历史使用相同的过期时间
+// 无法保存映射时退回不可还原的掩码, 不保存原文
+func (s *historyService) redact(ctx context.Context, conversationID uint, messages []common.LlmMessage, ttl time.Duration) []common.LlmMessage {
+	if !global.Config.Pii.MaskRecords {
+		return messages
+	}
+	scope := global.TenantFrom(ctx).ConversationScope(conversationID)
+	for i := 0; i < piiVaultRetries; i++ {
+		values, err := global.RedisClient.GetPIIVault(ctx, scope)
+		if err != nil {
+			global.Log.Warnf("读取会话 %d 的个人信息占位符映射失败: %v", conversationID, err)
+			break
+		}
+		vault := utils.LoadPIIVault(values)
+		redacted := redactHistory(vault, messages)
+		saved, err := global.RedisClient.SavePIIVault(ctx, scope, vault.Values(), ttl)
+		if err != nil {
+			global.Log.Warnf("保存会话 %d 的个人信息占位符映射失败: %v", conversationID, err)
+			break
+		}
+		if saved {
+			return redacted
+		}
+	}
+
+	masked := make([]common.LlmMessage, len(messages))
+	for i, msg := range messages {
+		msg.Content = utils.MaskPII(msg.Content)
+		masked[i] = msg
+	}
+	return masked
+}
+
 // fetchAndCache 是一个私有辅助方法，用于从Chatwoot获取数据、格式化并存入Redis
 func (s *historyService) fetchAndCache(ctx context.Context, accountID, conversationID uint, currentMessage string) ([]common.LlmMessage, error) {
 	// 从Chatwoot API获取完整的历史记录
-	chatwootMessages, err := global.ChatwootService.GetConversationMessages(accountID, conversationID)
+	chatwootMessages, err := global.TenantFrom(ctx).Chatwoot().GetConversationMessages(accountID, conversationID)
 	if err != nil {
 		return nil, fmt.Errorf("从Chatwoot API获取会话 %d 消息失败: %w", conversationID, err)
 	}
@@ -163,8 +245,12 @@ func (s *historyService) fetchAndCache(ctx context.Context, accountID, conversat
 		formattedHistory = append(formattedHistory, common.LlmMessage{Role: role, Content: msg.Content})
 	}
 
-	// 将格式化后的历史记录存入Redis
-	if err := s.Set(context.Background(), conversationID, formattedHistory); err != nil {
+	// 将格式化后的历史记录脱敏后存入Redis, 返回与缓存一致的脱敏内容
+	// 保留上下文中的租户, 缓存写入该租户的键; 不随请求取消
+	storeCtx := context.WithoutCancel(ctx)
+	ttl := utils.GetTTLWithJitter(global.Config.Redis.ConversationHistoryTTL)
+	formattedHistory = s.redact(storeCtx, conversationID, formattedHistory, ttl)
+	if err := s.store(storeCtx, conversationID, formattedHistory, ttl); err != nil {
 		// 只记录错误，不阻塞返回
 		global.Log.Errorf("将会话 %d 历史记录存入Redis失败: %v", conversationID, err)
 	}
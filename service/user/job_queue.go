@@ -0,0 +1,410 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+)
+
+const (
+	jobConsumerGroup   = "agent-workers"
+	jobStreamMaxLen    = 10000
+	jobReadBlock       = 2 * time.Second
+	jobClaimInterval   = 10 * time.Second
+	jobDelayedBatch    = 50
+	jobPromoteInterval = 200 * time.Millisecond
+	jobHandBackWindow  = 3 * time.Second
+)
+
+// Job 队列中的一个任务, Attempt 为已失败的次数
+type Job struct {
+	ID      string          `json:"-"`
+	Payload json.RawMessage `json:"payload"`
+	Attempt int             `json:"attempt"`
+}
+
+// JobHandler 处理任务; 返回错误表示本次处理失败, 将按退避策略重试
+type JobHandler func(ctx context.Context, job Job) error
+
+// DeadLetterHandler 任务重试耗尽后的兜底处理
+type DeadLetterHandler func(job Job, err error)
+
+// JobQueue 基于Redis Stream消费者组的持久化任务队列, 支持确认、退避重试、死信和实例宕机后的任务接管
+type JobQueue interface {
+	// Enabled 是否启用队列; 未启用或Redis不可用时调用方应在本实例内直接处理
+	Enabled() bool
+	// Enqueue 将任务写入队列
+	Enqueue(ctx context.Context, payload any) error
+	// EnqueueDelayed 将任务写入队列, delay 之后才会被领取
+	EnqueueDelayed(ctx context.Context, payload any, delay time.Duration) error
+	// Start 启动本实例的消费者, 并发数由配置决定
+	Start(handler JobHandler, onDeadLetter DeadLetterHandler)
+	// Shutdown 停止领取新任务并等待处理中的任务完成; 超时后取消它们并交回队列, 由其他实例继续处理
+	Shutdown(ctx context.Context)
+}
+
+type jobQueue struct {
+	consumer     string
+	handler      JobHandler
+	onDeadLetter DeadLetterHandler
+
+	stop      context.CancelFunc
+	workers   sync.WaitGroup
+	inflight  sync.WaitGroup
+	lastClaim atomic.Int64
+	stopping  atomic.Bool
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+func NewJobQueue() JobQueue {
+	hostname, _ := os.Hostname()
+	return &jobQueue{
+		consumer: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		running:  make(map[string]context.CancelFunc),
+	}
+}
+
+func (q *jobQueue) Enabled() bool {
+	return global.Config.JobQueue.Enable && global.RedisClient != nil && q.handler != nil && !q.stopping.Load()
+}
+
+func (q *jobQueue) Enqueue(ctx context.Context, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("序列化任务失败: %w", err)
+	}
+	return q.add(ctx, Job{Payload: data})
+}
+
+func (q *jobQueue) EnqueueDelayed(ctx context.Context, payload any, delay time.Duration) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("序列化任务失败: %w", err)
+	}
+	return q.schedule(ctx, Job{Payload: data}, delay)
+}
+
+// schedule 将任务放入延迟队列, 到期后由 promoteDelayed 移回队列
+func (q *jobQueue) schedule(ctx context.Context, job Job, delay time.Duration) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("序列化任务失败: %w", err)
+	}
+	member := &redis.Z{Score: float64(time.Now().Add(delay).UnixMilli()), Member: string(data)}
+	if err := global.RedisClient.ZAddNX(ctx, redis.KeyJobDelayed, member).Err(); err != nil {
+		return fmt.Errorf("写入延迟队列失败: %w", err)
+	}
+	return nil
+}
+
+func (q *jobQueue) Start(handler JobHandler, onDeadLetter DeadLetterHandler) {
+	q.handler = handler
+	q.onDeadLetter = onDeadLetter
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q.stop = cancel
+
+	concurrency := global.Config.JobQueue.Concurrency
+	for n := 0; n < concurrency; n++ {
+		q.workers.Add(1)
+		go q.work(ctx)
+	}
+	q.workers.Add(1)
+	go q.promoteDelayed(ctx)
+	global.Log.Infof("任务队列消费者已启动, 并发数: %d", concurrency)
+}
+
+func (q *jobQueue) Shutdown(ctx context.Context) {
+	if q.stop == nil {
+		return
+	}
+	q.stopping.Store(true)
+	q.stop()
+	q.workers.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		q.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		global.Log.Info("任务队列已排空")
+		return
+	case <-ctx.Done():
+	}
+
+	// 等待超时, 取消剩余任务, 由 process 将其交回队列
+	q.mu.Lock()
+	global.Log.Warnf("任务队列关闭超时, %d 个处理中的任务将交回队列", len(q.running))
+	for _, cancel := range q.running {
+		cancel()
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(jobHandBackWindow):
+		global.Log.Warn("部分任务未能在关闭前交回队列, 将在超时后由其他实例接管")
+	}
+}
+
+// work 单个消费者循环: 优先接管宕机实例遗留的任务, 再领取新任务
+func (q *jobQueue) work(ctx context.Context) {
+	defer q.workers.Done()
+	for ctx.Err() == nil {
+		client := global.RedisClient
+		if client == nil || !global.Config.JobQueue.Enable {
+			sleepContext(ctx, 5*time.Second)
+			continue
+		}
+
+		messages, err := q.next(ctx, client)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if strings.Contains(err.Error(), "NOGROUP") {
+				q.createGroup(ctx, client)
+				continue
+			}
+			global.Log.Warnf("[job]领取任务失败: %v", err)
+			sleepContext(ctx, time.Second)
+			continue
+		}
+		for _, message := range messages {
+			q.process(message)
+		}
+	}
+}
+
+// next 领取一个任务; 每隔 jobClaimInterval 先尝试接管空闲超时的任务
+func (q *jobQueue) next(ctx context.Context, client redis.Service) ([]redis.XMessage, error) {
+	now := time.Now().UnixNano()
+	last := q.lastClaim.Load()
+	if now-last >= int64(jobClaimInterval) && q.lastClaim.CompareAndSwap(last, now) {
+		claimed, _, err := client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   redis.KeyJobStream,
+			Group:    jobConsumerGroup,
+			Consumer: q.consumer,
+			MinIdle:  time.Duration(global.Config.JobQueue.ClaimIdle) * time.Second,
+			Start:    "0-0",
+			Count:    1,
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(claimed) > 0 {
+			global.Log.Warnf("[job]接管了空闲超时的任务 %s", claimed[0].ID)
+			return claimed, nil
+		}
+	}
+
+	streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    jobConsumerGroup,
+		Consumer: q.consumer,
+		Streams:  []string{redis.KeyJobStream, ">"},
+		Count:    1,
+		Block:    jobReadBlock,
+	}).Result()
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var messages []redis.XMessage
+	for _, stream := range streams {
+		messages = append(messages, stream.Messages...)
+	}
+	return messages, nil
+}
+
+func (q *jobQueue) createGroup(ctx context.Context, client redis.Service) {
+	err := client.XGroupCreateMkStream(ctx, redis.KeyJobStream, jobConsumerGroup, "0").Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		global.Log.Warnf("[job]创建消费者组失败: %v", err)
+		sleepContext(ctx, time.Second)
+	}
+}
+
+// process 执行任务并根据结果确认、重试或交回队列
+func (q *jobQueue) process(message redis.XMessage) {
+	q.inflight.Add(1)
+	defer q.inflight.Done()
+
+	job, err := decodeJob(message)
+	if err != nil {
+		global.Log.Errorf("[job]任务 %s 格式错误, 已丢弃: %v", message.ID, err)
+		q.ack(message.ID)
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+	}()
+
+	err = q.handle(jobCtx, job)
+
+	switch {
+	case q.stopping.Load() && jobCtx.Err() != nil:
+		// 关闭超时被取消, 原样交回队列
+		if err := q.add(context.Background(), job); err != nil {
+			global.Log.Errorf("[job]任务 %s 交回队列失败, 将在超时后由其他实例接管: %v", job.ID, err)
+			return
+		}
+	case err != nil:
+		q.retryOrBury(job, err)
+	}
+	q.ack(job.ID)
+}
+
+// handle 调用处理函数, 将panic视为一次失败
+func (q *jobQueue) handle(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return q.handler(ctx, job)
+}
+
+// retryOrBury 按指数退避安排重试, 重试耗尽后写入死信列表
+func (q *jobQueue) retryOrBury(job Job, cause error) {
+	cfg := global.Config.JobQueue
+	job.Attempt++
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if job.Attempt <= cfg.MaxRetries {
+		backoff := time.Duration(cfg.RetryBackoff) * time.Second << (job.Attempt - 1)
+		global.Log.Warnf("[job]任务 %s 第 %d 次处理失败, %v 后重试: %v", job.ID, job.Attempt, backoff, cause)
+		err := q.schedule(ctx, job, backoff)
+		if err == nil {
+			return
+		}
+		global.Log.Errorf("[job]任务 %s 安排重试失败, 转入死信: %v", job.ID, err)
+	}
+
+	global.Log.Errorf("[job]任务 %s 重试耗尽, 写入死信列表: %v", job.ID, cause)
+	entry, _ := json.Marshal(map[string]any{
+		"job":       job,
+		"error":     cause.Error(),
+		"failed_at": time.Now().In(global.Tz).Format(time.DateTime),
+	})
+	if err := global.RedisClient.LPush(ctx, redis.KeyJobDeadLetter, entry).Err(); err != nil {
+		global.Log.Errorf("[job]写入死信列表失败: %v", err)
+	} else {
+		global.RedisClient.LTrim(ctx, redis.KeyJobDeadLetter, 0, cfg.DeadLetterMaxLen-1)
+	}
+	if q.onDeadLetter != nil {
+		q.onDeadLetter(job, cause)
+	}
+}
+
+// promoteDelayed 将到期的延迟任务移回队列; 通过ZRem的返回值确保多实例下只移动一次
+func (q *jobQueue) promoteDelayed(ctx context.Context) {
+	defer q.workers.Done()
+	// 消息聚合窗口以毫秒计, 检查间隔不宜过长
+	ticker := time.NewTicker(jobPromoteInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		client := global.RedisClient
+		if client == nil || !global.Config.JobQueue.Enable {
+			continue
+		}
+
+		members, err := client.ZRangeByScore(ctx, redis.KeyJobDelayed, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+			Count: jobDelayedBatch,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				global.Log.Warnf("[job]读取待重试任务失败: %v", err)
+			}
+			continue
+		}
+		// 成员即任务JSON, 原样移入队列; 格式错误的任务由消费者丢弃
+		for _, member := range members {
+			if _, err := client.MoveToStream(ctx, redis.KeyJobDelayed, member, redis.KeyJobStream, "job", jobStreamMaxLen); err != nil && ctx.Err() == nil {
+				global.Log.Errorf("[job]重试任务移回队列失败: %v", err)
+			}
+		}
+	}
+}
+
+func (q *jobQueue) add(ctx context.Context, job Job) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("序列化任务失败: %w", err)
+	}
+	err = global.RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: redis.KeyJobStream,
+		MaxLen: jobStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"job": string(data)},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("写入任务队列失败: %w", err)
+	}
+	return nil
+}
+
+// ack 确认并删除任务, 已处理的任务不再保留在Stream中
+func (q *jobQueue) ack(id string) {
+	if global.RedisClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := global.RedisClient.XAck(ctx, redis.KeyJobStream, jobConsumerGroup, id).Err(); err != nil {
+		global.Log.Warnf("[job]确认任务 %s 失败: %v", id, err)
+		return
+	}
+	global.RedisClient.XDel(ctx, redis.KeyJobStream, id)
+}
+
+func decodeJob(message redis.XMessage) (Job, error) {
+	var job Job
+	raw, ok := message.Values["job"].(string)
+	if !ok {
+		return job, errors.New("缺少任务内容")
+	}
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		return job, err
+	}
+	job.ID = message.ID
+	return job, nil
+}
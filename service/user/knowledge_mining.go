@@ -0,0 +1,288 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/chatwoot"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/utils"
+)
+
+const (
+	// miningLockExpiry 挖掘任务锁的过期时间, 小于定时任务的间隔
+	miningLockExpiry = 9 * time.Minute
+	// miningRetryDelay 获取会话消息失败后推迟重试的时间
+	miningRetryDelay = 30 * time.Minute
+)
+
+// KnowledgeMiningService 知识挖掘: 从已解决会话中人工客服的回复里找出知识库未覆盖的问题, 聚类后起草知识条目建议供审核; 配置每次从 global.Config 读取
+type KnowledgeMiningService interface {
+	// MarkCandidate 标记会话中有人工客服回复, 未启用时忽略
+	MarkCandidate(ctx context.Context, conversationID uint)
+	// EnqueueResolved 会话解决时, 将有人工客服回复的会话加入挖掘队列
+	EnqueueResolved(ctx context.Context, conversationID uint)
+	// MineResolvedConversations 处理一批挖掘队列中的会话, 由定时任务调用
+	MineResolvedConversations(ctx context.Context) error
+}
+
+type knowledgeMiningService struct {
+	llm       LlmService
+	vector    VectorService
+	rateLimit RateLimitService
+}
+
+func NewKnowledgeMiningService() KnowledgeMiningService {
+	return &knowledgeMiningService{
+		llm:       NewLlmService(),
+		vector:    NewVectorService(),
+		rateLimit: NewRateLimitService(),
+	}
+}
+
+func (s *knowledgeMiningService) MarkCandidate(ctx context.Context, conversationID uint) {
+	if !global.Config.KnowledgeMining.Enable || global.RedisClient == nil {
+		return
+	}
+	key := global.ConversationKey(ctx, redis.KeyPrefixMiningCandidate, conversationID)
+	ttl := time.Duration(global.Config.Redis.ConversationHistoryTTL) * time.Second
+	if err := global.RedisClient.Set(ctx, key, "1", ttl).Err(); err != nil {
+		global.Log.Warnf("[mining]标记会话 %d 待挖掘失败: %v", conversationID, err)
+	}
+}
+
+func (s *knowledgeMiningService) EnqueueResolved(ctx context.Context, conversationID uint) {
+	if !global.Config.KnowledgeMining.Enable || global.RedisClient == nil {
+		return
+	}
+	key := global.ConversationKey(ctx, redis.KeyPrefixMiningCandidate, conversationID)
+	if err := global.RedisClient.GetDel(ctx, key).Err(); err != nil {
+		if err != redis.ErrNil {
+			global.Log.Warnf("[mining]获取会话 %d 待挖掘标记失败: %v", conversationID, err)
+		}
+		return
+	}
+	member := &redis.Z{Score: float64(time.Now().Unix()), Member: tenantConversationMember(ctx, conversationID)}
+	if err := global.RedisClient.ZAddNX(ctx, redis.KeyMiningQueue, member).Err(); err != nil {
+		global.Log.Warnf("[mining]会话 %d 加入挖掘队列失败: %v", conversationID, err)
+	}
+}
+
+func (s *knowledgeMiningService) MineResolvedConversations(ctx context.Context) error {
+	if !global.Config.KnowledgeMining.Enable || global.RedisClient == nil {
+		return nil
+	}
+	// 建议的更新是先读后写, 同一时间只允许一个实例处理
+	return runExclusive(ctx, redis.KeyKnowledgeMiningLock, miningLockExpiry, func() error {
+		return s.mineBatch(ctx)
+	})
+}
+
+// mineBatch 取出一批到期的待挖掘会话; 获取消息失败的会话推迟后重试, 成功获取后才从队列中移除
+func (s *knowledgeMiningService) mineBatch(ctx context.Context) error {
+	members, err := global.RedisClient.ZRangeByScore(ctx, redis.KeyMiningQueue, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().Unix(), 10),
+		Count: global.Config.KnowledgeMining.BatchSize,
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("获取待挖掘会话失败: %w", err)
+	}
+
+	tenants := make(map[string]*global.Tenant)
+	samplesByTenant := make(map[string][]dto.KnowledgeSample)
+	for _, member := range members {
+		tenant, conversationID, ok := parseTenantConversationMember(member)
+		if !ok || tenant.Chatwoot() == nil {
+			global.Log.Warnf("[mining]待挖掘会话 %s 无效或所属租户已移除, 已忽略", member)
+			global.RedisClient.ZRem(ctx, redis.KeyMiningQueue, member)
+			continue
+		}
+		messages, err := tenant.Chatwoot().GetConversationMessages(tenant.AccountID(), conversationID)
+		if err != nil {
+			global.Log.Warnf("[mining]获取会话 %d 消息失败, %v 后重试: %v", conversationID, miningRetryDelay, err)
+			retry := &redis.Z{Score: float64(time.Now().Add(miningRetryDelay).Unix()), Member: member}
+			if err := global.RedisClient.ZAddXX(ctx, redis.KeyMiningQueue, retry).Err(); err != nil {
+				global.Log.Warnf("[mining]推迟会话 %d 的挖掘失败: %v", conversationID, err)
+			}
+			continue
+		}
+		if err := global.RedisClient.ZRem(ctx, redis.KeyMiningQueue, member).Err(); err != nil {
+			global.Log.Warnf("[mining]从挖掘队列移除会话 %d 失败: %v", conversationID, err)
+			continue
+		}
+		tenants[tenant.Name] = tenant
+		samplesByTenant[tenant.Name] = append(samplesByTenant[tenant.Name], extractHumanAnswers(conversationID, messages)...)
+	}
+
+	for name, samples := range samplesByTenant {
+		tenantCtx := global.WithTenant(ctx, tenants[name])
+		if err := s.mine(tenantCtx, s.filterUncovered(tenantCtx, samples)); err != nil {
+			global.Log.Errorf("[mining]租户 %s 挖掘知识条目失败: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// extractHumanAnswers 从会话消息中提取客户问题和人工客服回复: 连续的客户消息合并为一个问题, 其后连续的人工回复合并为一个答案
+func extractHumanAnswers(conversationID uint, messages []chatwoot.Message) []dto.KnowledgeSample {
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].CreatedAt < messages[j].CreatedAt })
+
+	var samples []dto.KnowledgeSample
+	var question, answer []string
+	flush := func() {
+		if len(question) > 0 && len(answer) > 0 {
+			samples = append(samples, dto.KnowledgeSample{
+				ConversationID: conversationID,
+				Question:       strings.Join(question, "\n"),
+				Answer:         strings.Join(answer, "\n"),
+			})
+		}
+		question, answer = nil, nil
+	}
+
+	for _, msg := range messages {
+		content := strings.TrimSpace(msg.Content)
+		if msg.Private || content == "" {
+			continue
+		}
+		switch {
+		case msg.MessageType == chatwoot.MessageDirectionIncoming && msg.Sender.Type == chatwoot.SenderContact:
+			if len(answer) > 0 {
+				flush()
+			}
+			question = append(question, content)
+		case msg.MessageType == chatwoot.MessageDirectionOutgoing && msg.Sender.Type == chatwoot.SenderUser:
+			// 机器人的回复不影响配对, 只有客户提问后的人工回复才计入
+			if len(question) > 0 {
+				answer = append(answer, content)
+			}
+		}
+	}
+	flush()
+	return samples
+}
+
+// filterUncovered 过滤掉关键词精确匹配或知识库检索能够命中的问题
+func (s *knowledgeMiningService) filterUncovered(ctx context.Context, samples []dto.KnowledgeSample) []dto.KnowledgeSample {
+	cannedResponses := global.TenantFrom(ctx).CannedResponses
+	minSimilarity := global.VectorSearchMinSimilarity(ctx)
+
+	uncovered := make([]dto.KnowledgeSample, 0, len(samples))
+	for _, sample := range samples {
+		cannedResponses.RLock()
+		_, ok := cannedResponses.Data[strings.ToLower(sample.Question)]
+		cannedResponses.RUnlock()
+		if ok {
+			continue
+		}
+		results, err := s.vector.Search(ctx, sample.Question)
+		if err != nil {
+			global.Log.Warnf("[mining]检索问题失败, 跳过该问题: %v", err)
+			continue
+		}
+		if len(results) > 0 && results[0].Similarity >= minSimilarity {
+			continue
+		}
+		uncovered = append(uncovered, sample)
+	}
+	return uncovered
+}
+
+// mine 将问答归入相似的建议, 没有相似建议时新建; 之后为有变化的待审核建议重新起草
+func (s *knowledgeMiningService) mine(ctx context.Context, samples []dto.KnowledgeSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	cfg := global.Config.KnowledgeMining
+
+	suggestions, err := dao.App.SuggestionsDb.ListSuggestions(ctx)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, suggestion := range suggestions {
+		if suggestion.Status == string(enum.KnowledgeSuggestionPending) {
+			pending++
+		}
+	}
+
+	questions := make([]string, len(samples))
+	for i, sample := range samples {
+		questions[i] = sample.Question
+	}
+	if err := s.rateLimit.AcquireModel(ctx, "embedding"); err != nil {
+		return err
+	}
+	embeddings, err := global.EmbeddingService.CreateEmbeddings(ctx, questions)
+	if err != nil {
+		return fmt.Errorf("创建问题向量失败: %w", err)
+	}
+
+	now := time.Now().Unix()
+	touched := make(map[string]*dto.KnowledgeSuggestion)
+	for i, sample := range samples {
+		if global.Config.Pii.MaskRecords {
+			sample.Question = utils.MaskPII(sample.Question)
+			sample.Answer = utils.MaskPII(sample.Answer)
+		}
+
+		// 已拒绝的建议也参与聚类, 避免同类问题被反复提出
+		if index, similarity := nearestCluster(embeddings[i], suggestions, func(s *dto.KnowledgeSuggestion) []float32 { return s.Embedding }); index >= 0 && similarity >= cfg.ClusterThreshold {
+			best := suggestions[index]
+			best.Count++
+			best.Samples = keepLast(append(best.Samples, sample), cfg.MaxSamples)
+			best.UpdatedAt = now
+			touched[best.ID] = best
+			continue
+		}
+
+		if pending >= cfg.MaxSuggestions {
+			continue
+		}
+		suggestion := &dto.KnowledgeSuggestion{
+			ID:        strconv.FormatInt(time.Now().UnixNano(), 10),
+			Status:    string(enum.KnowledgeSuggestionPending),
+			Count:     1,
+			Samples:   []dto.KnowledgeSample{sample},
+			Embedding: embeddings[i],
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		suggestions = append(suggestions, suggestion)
+		touched[suggestion.ID] = suggestion
+		pending++
+	}
+
+	for _, suggestion := range touched {
+		if suggestion.Status == string(enum.KnowledgeSuggestionPending) {
+			draft, err := s.llm.DraftKnowledgeSuggestion(ctx, suggestion.Samples)
+			switch {
+			case err != nil:
+				// 起草失败时保留原有内容, 下次有新的问答归入时重试
+				global.Log.Warnf("[mining]起草知识条目建议 %s 失败: %v", suggestion.ID, err)
+			case len(draft.Questions) == 0 || draft.Answer == "":
+				if suggestion.Answer == "" {
+					global.Log.Debugf("[mining]问题 %q 的人工回复无法整理为通用答案, 已忽略", suggestion.Samples[0].Question)
+					continue
+				}
+			default:
+				suggestion.Questions = draft.Questions
+				suggestion.Answer = draft.Answer
+			}
+		}
+		if err := dao.App.SuggestionsDb.SaveSuggestion(ctx, suggestion); err != nil {
+			global.Log.Errorf("[mining]保存知识条目建议 %s 失败: %v", suggestion.ID, err)
+		}
+	}
+	global.Log.Infof("[mining]处理问答 %d 组, 更新知识条目建议 %d 条", len(samples), len(touched))
+	return nil
+}
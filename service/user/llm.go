@@ -9,7 +9,9 @@ import (
 	"gitee.com/taoJie_1/mall-agent/dao"
 	"gitee.com/taoJie_1/mall-agent/global"
 	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
 	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/utils"
 )
 
 type LlmService interface {
@@ -19,13 +21,22 @@ type LlmService interface {
 	GenerateResponseOrToolCall(ctx context.Context, param *common.ChatRequest, referenceDocs []dao.SearchResult, history []common.LlmMessage) (string, error)
 	// SynthesizeToolResult 在工具调用后，综合所有信息（包括工具结果）生成最终的自然语言回复, 不需要知识库(向量)数据了
 	SynthesizeToolResult(ctx context.Context, history []common.LlmMessage) (string, error)
+	// SummarizeHandoff 使用小型LLM根据转人工资料生成Markdown格式的交接摘要
+	SummarizeHandoff(ctx context.Context, material string) (string, error)
+	// ReviseAnswer 回复未通过审核时, 使用大型LLM根据审核问题和依据资料修改回复
+	ReviseAnswer(ctx context.Context, answer string, problems []string, evidence *AnswerEvidence) (string, error)
+	// ClassifyInjection 使用小型LLM判断用户消息是提示词注入的可能性
+	ClassifyInjection(ctx context.Context, content string) (*common.GuardClassification, error)
+	// DraftKnowledgeSuggestion 使用小型LLM根据一组相似的客户问题和人工回复起草知识条目
+	DraftKnowledgeSuggestion(ctx context.Context, samples []dto.KnowledgeSample) (*common.KnowledgeDraft, error)
 }
 
 type llmService struct {
+	rateLimit RateLimitService
 }
 
 func NewLlmService() *llmService {
-	return &llmService{}
+	return &llmService{rateLimit: NewRateLimitService()}
 }
 
 func (s *llmService) Triage(ctx context.Context, content string, history []common.LlmMessage, retrievedQuestions []string) (*common.TriageResult, error) {
@@ -36,9 +47,10 @@ func (s *llmService) Triage(ctx context.Context, content string, history []commo
 	// 构建发送给小模型的prompt
 	var prompt strings.Builder
 
+	vault := promptVault(ctx)
 	if len(history) > 0 {
 		prompt.WriteString("最近的对话历史:\n")
-		for _, msg := range history {
+		for _, msg := range promptHistory(ctx, vault, history) {
 			// 为保证prompt简洁，只显示最核心信息
 			fmt.Fprintf(&prompt, "- %s: %s\n", msg.Role, msg.Content)
 		}
@@ -57,21 +69,16 @@ func (s *llmService) Triage(ctx context.Context, content string, history []commo
 	prompt.WriteString("请结合以上所有信息进行综合判断。")
 
 	// 使用小模型和专用的Triage Prompt
-	triageResultJSON, err := global.LlmService.GetCompletion(ctx, enum.ModelSmall, enum.SystemPromptTriage, prompt.String(), 0.2)
+	if err := s.rateLimit.AcquireModel(ctx, string(enum.ModelSmall)); err != nil {
+		return nil, err
+	}
+	triageResultJSON, err := global.LlmService.GetCompletion(ctx, enum.ModelSmall, global.Prompt(ctx, enum.PromptTriage), vault.Redact(prompt.String()), 0.2)
 	if err != nil {
 		return nil, fmt.Errorf("分诊台LLM调用失败: %w", err)
 	}
 
 	var triageResult common.TriageResult
-	// 尝试从LLM可能返回的Markdown代码块中提取纯JSON
-	cleanJSON := strings.TrimSpace(triageResultJSON)
-	if strings.HasPrefix(cleanJSON, "```json") {
-		cleanJSON = strings.TrimPrefix(cleanJSON, "```json")
-		cleanJSON = strings.TrimSuffix(cleanJSON, "```")
-		cleanJSON = strings.TrimSpace(cleanJSON)
-	}
-
-	if err := json.Unmarshal([]byte(cleanJSON), &triageResult); err != nil {
+	if err := json.Unmarshal([]byte(cleanLlmJSON(triageResultJSON)), &triageResult); err != nil {
 		return nil, fmt.Errorf("解析分诊台返回的JSON失败: %w, 原始返回: %s", err, triageResultJSON)
 	}
 
@@ -83,7 +90,9 @@ func (s *llmService) GenerateResponseOrToolCall(ctx context.Context, param *comm
 		return "", fmt.Errorf("LLM客户端未初始化")
 	}
 
-	hasTools := global.McpService != nil && len(global.McpService.GetAvailableTools()) > 0
+	tenant := global.TenantFrom(ctx)
+	mcpClient := tenant.Mcp()
+	hasTools := mcpClient != nil && len(mcpClient.GetAvailableTools()) > 0
 	hasDocs := len(referenceDocs) > 0
 
 	var systemPromptBuilder strings.Builder
@@ -92,19 +101,19 @@ func (s *llmService) GenerateResponseOrToolCall(ctx context.Context, param *comm
 	// 1. 根据场景动态构建System Prompt
 	// 使用 if-else 选择基础Prompt，避免重复
 	if hasDocs {
-		systemPromptBuilder.WriteString(string(enum.SystemPromptRAG))
+		systemPromptBuilder.WriteString(string(global.Prompt(ctx, enum.PromptRAG)))
 	} else {
-		systemPromptBuilder.WriteString(string(enum.SystemPromptDefault))
+		systemPromptBuilder.WriteString(string(global.Prompt(ctx, enum.PromptDefault)))
 	}
 
 	// 2. 如果有可用工具，则追加工具使用说明
 	if hasTools {
-		// 从 enum 中获取工具使用的模板
-		toolPromptTemplate := string(enum.SystemPromptToolUser)
+		// 获取当前启用的工具使用模板
+		toolPromptTemplate := string(global.Prompt(ctx, enum.PromptToolUser))
 
 		// 构建可用工具列表的字符串
 		var toolsListBuilder strings.Builder
-		availableClients := global.McpService.GetAvailableToolsWithClient()
+		availableClients := mcpClient.GetAvailableToolsWithClient()
 		for clientName, tools := range availableClients {
 			for _, tool := range tools {
 				var argsSchema string
@@ -115,20 +124,47 @@ func (s *llmService) GenerateResponseOrToolCall(ctx context.Context, param *comm
 					}
 				}
 
+				description := tool.Description
+				if mcpClient.IsMutatingTool(clientName, tool.Name) {
+					description += "(执行前系统会请用户确认)"
+				}
+
 				if argsSchema != "" {
-					toolsListBuilder.WriteString(fmt.Sprintf("- %s.%s: %s. Arguments: %s\n", clientName, tool.Name, tool.Description, argsSchema))
+					toolsListBuilder.WriteString(fmt.Sprintf("- %s.%s: %s. Arguments: %s\n", clientName, tool.Name, description, argsSchema))
 				} else {
-					toolsListBuilder.WriteString(fmt.Sprintf("- %s.%s: %s\n", clientName, tool.Name, tool.Description))
+					toolsListBuilder.WriteString(fmt.Sprintf("- %s.%s: %s\n", clientName, tool.Name, description))
 				}
 			}
 		}
 
 		// 将工具列表替换到模板中
-		finalToolPrompt := strings.Replace(toolPromptTemplate, "{tools}", toolsListBuilder.String(), 1)
+		finalToolPrompt := strings.Replace(toolPromptTemplate, enum.PromptVarTools, toolsListBuilder.String(), 1)
 		systemPromptBuilder.WriteString("\n\n") // 添加换行符以分隔
 		systemPromptBuilder.WriteString(finalToolPrompt)
 	}
 
+	// 语音转写的问题提示模型容忍识别错误
+	if param.IsTranscription {
+		systemPromptBuilder.WriteString("\n")
+		systemPromptBuilder.WriteString(string(global.Prompt(ctx, enum.PromptTranscription)))
+	}
+
+	// 追加交互式回复(选项/表单/文章)的输出约定
+	systemPromptBuilder.WriteString("\n")
+	systemPromptBuilder.WriteString(string(global.Prompt(ctx, enum.PromptInteractive)))
+
+	// 追加租户专属的说明(品牌、语气等)
+	if tenantPrompt := tenant.SystemPrompt(); tenantPrompt != "" {
+		systemPromptBuilder.WriteString("\n")
+		systemPromptBuilder.WriteString(tenantPrompt)
+	}
+
+	vault := promptVault(ctx)
+	if vault != nil {
+		systemPromptBuilder.WriteString("\n")
+		systemPromptBuilder.WriteString(string(global.Prompt(ctx, enum.PromptPIIPlaceholder)))
+	}
+
 	// 3. 构建最终发送给LLM的 content
 	if hasDocs {
 		finalContent.WriteString("--- 参考资料 ---\n")
@@ -145,14 +181,22 @@ func (s *llmService) GenerateResponseOrToolCall(ctx context.Context, param *comm
 
 	finalContent.WriteString(param.Content)
 
-	return global.LlmService.ChatCompletionWithHistory(
+	if err := s.rateLimit.AcquireModel(ctx, string(enum.ModelLarge)); err != nil {
+		return "", err
+	}
+	answer, err := global.LlmService.ChatCompletionWithHistory(
 		ctx,
 		enum.ModelLarge,
 		enum.SystemPrompt(systemPromptBuilder.String()),
-		finalContent.String(),
-		history,
+		vault.Redact(finalContent.String()),
+		promptHistory(ctx, vault, history),
 		0.5,
 	)
+	if err != nil {
+		return "", err
+	}
+	// 还原回复和工具参数中的占位符
+	return vault.Restore(answer), nil
 }
 
 func (s *llmService) SynthesizeToolResult(ctx context.Context, history []common.LlmMessage) (string, error) {
@@ -162,12 +206,191 @@ func (s *llmService) SynthesizeToolResult(ctx context.Context, history []common.
 
 	// 在这个阶段，我们使用一个干净、简单的系统提示，因为LLM的任务只是根据现有对话（包括工具结果）进行总结。
 	// 无需再次提供复杂的RAG或工具调用指令。
-	return global.LlmService.ChatCompletionWithHistory(
+	if err := s.rateLimit.AcquireModel(ctx, string(enum.ModelLarge)); err != nil {
+		return "", err
+	}
+	systemPrompt := global.Prompt(ctx, enum.PromptSynthesizeToolResult) + global.Prompt(ctx, enum.PromptInteractive) // 使用专用的提示词进行结果合成，工具结果常需要用户从中做选择
+	if tenantPrompt := global.TenantFrom(ctx).SystemPrompt(); tenantPrompt != "" {
+		systemPrompt += enum.SystemPrompt("\n" + tenantPrompt)
+	}
+	vault := promptVault(ctx)
+	if vault != nil {
+		systemPrompt += "\n" + global.Prompt(ctx, enum.PromptPIIPlaceholder)
+	}
+	answer, err := global.LlmService.ChatCompletionWithHistory(
 		ctx,
 		enum.ModelLarge,
-		enum.SystemPromptSynthesizeToolResult, // 使用专用的提示词进行结果合成
+		systemPrompt,
 		"", // content为空，因为所有上下文都在history中
-		history,
+		promptHistory(ctx, vault, history),
 		0.6,
 	)
+	if err != nil {
+		return "", err
+	}
+	return vault.Restore(answer), nil
+}
+
+func (s *llmService) SummarizeHandoff(ctx context.Context, material string) (string, error) {
+	if global.LlmService == nil {
+		return "", fmt.Errorf("LLM客户端未初始化")
+	}
+	if err := s.rateLimit.AcquireModel(ctx, string(enum.ModelSmall)); err != nil {
+		return "", err
+	}
+	vault := newPromptVault()
+	summary, err := global.LlmService.GetCompletion(ctx, enum.ModelSmall, global.Prompt(ctx, enum.PromptHandoffSummary), vault.Redact(material), 0.2)
+	if err != nil {
+		return "", fmt.Errorf("交接摘要LLM调用失败: %w", err)
+	}
+	// 摘要给人工客服看, 还原为真实值
+	return strings.TrimSpace(vault.Restore(summary)), nil
+}
+
+func (s *llmService) ReviseAnswer(ctx context.Context, answer string, problems []string, evidence *AnswerEvidence) (string, error) {
+	if global.LlmService == nil {
+		return "", fmt.Errorf("LLM客户端未初始化")
+	}
+
+	var content strings.Builder
+	if len(evidence.Docs) > 0 {
+		content.WriteString("--- 参考资料 ---\n")
+		for _, doc := range evidence.Docs {
+			content.WriteString(doc)
+			content.WriteString("\n---\n")
+		}
+	}
+	if len(evidence.ToolResults) > 0 {
+		content.WriteString("--- 工具结果 ---\n")
+		for _, result := range evidence.ToolResults {
+			content.WriteString(result)
+			content.WriteString("\n---\n")
+		}
+	}
+	fmt.Fprintf(&content, "--- 用户问题 ---\n%s\n\n--- 原回复 ---\n%s\n\n--- 审核问题 ---\n", evidence.Question, answer)
+	for _, problem := range problems {
+		fmt.Fprintf(&content, "- %s\n", problem)
+	}
+
+	systemPrompt := global.Prompt(ctx, enum.PromptReviseAnswer) + "\n" + global.Prompt(ctx, enum.PromptInteractive)
+	if tenantPrompt := global.TenantFrom(ctx).SystemPrompt(); tenantPrompt != "" {
+		systemPrompt += enum.SystemPrompt("\n" + tenantPrompt)
+	}
+	vault := promptVault(ctx)
+	if vault != nil {
+		systemPrompt += "\n" + global.Prompt(ctx, enum.PromptPIIPlaceholder)
+	}
+	if err := s.rateLimit.AcquireModel(ctx, string(enum.ModelLarge)); err != nil {
+		return "", err
+	}
+	revised, err := global.LlmService.ChatCompletionWithHistory(ctx, enum.ModelLarge, systemPrompt, vault.Redact(content.String()), promptHistory(ctx, vault, evidence.History), 0.3)
+	if err != nil {
+		return "", fmt.Errorf("修改回复LLM调用失败: %w", err)
+	}
+	return vault.Restore(revised), nil
+}
+
+func (s *llmService) ClassifyInjection(ctx context.Context, content string) (*common.GuardClassification, error) {
+	if global.LlmService == nil {
+		return nil, fmt.Errorf("LLM客户端未初始化")
+	}
+	if err := s.rateLimit.AcquireModel(ctx, string(enum.ModelSmall)); err != nil {
+		return nil, err
+	}
+	resultJSON, err := global.LlmService.GetCompletion(ctx, enum.ModelSmall, global.Prompt(ctx, enum.PromptGuard), newPromptVault().Redact(content), 0)
+	if err != nil {
+		return nil, fmt.Errorf("注入分类LLM调用失败: %w", err)
+	}
+
+	var result common.GuardClassification
+	if err := json.Unmarshal([]byte(cleanLlmJSON(resultJSON)), &result); err != nil {
+		return nil, fmt.Errorf("解析注入分类返回的JSON失败: %w, 原始返回: %s", err, resultJSON)
+	}
+	return &result, nil
+}
+
+func (s *llmService) DraftKnowledgeSuggestion(ctx context.Context, samples []dto.KnowledgeSample) (*common.KnowledgeDraft, error) {
+	if global.LlmService == nil {
+		return nil, fmt.Errorf("LLM客户端未初始化")
+	}
+
+	var content strings.Builder
+	for i, sample := range samples {
+		fmt.Fprintf(&content, "--- 示例%d ---\n客户: %s\n人工客服: %s\n", i+1, sample.Question, sample.Answer)
+	}
+	if err := s.rateLimit.AcquireModel(ctx, string(enum.ModelSmall)); err != nil {
+		return nil, err
+	}
+	// 答案会写入知识库, 个人信息不能还原, 由提示词要求删除
+	resultJSON, err := global.LlmService.GetCompletion(ctx, enum.ModelSmall, global.Prompt(ctx, enum.PromptKnowledgeSuggestion), newPromptVault().Redact(content.String()), 0.2)
+	if err != nil {
+		return nil, fmt.Errorf("起草知识条目LLM调用失败: %w", err)
+	}
+
+	var draft common.KnowledgeDraft
+	if err := json.Unmarshal([]byte(cleanLlmJSON(resultJSON)), &draft); err != nil {
+		return nil, fmt.Errorf("解析知识条目草稿返回的JSON失败: %w, 原始返回: %s", err, resultJSON)
+	}
+	draft.Answer = strings.TrimSpace(draft.Answer)
+	return &draft, nil
+}
+
+// newPromptVault 开启提示词脱敏时返回新的占位符映射, 否则返回nil(不做替换)
+func newPromptVault() *utils.PIIVault {
+	if !global.Config.Pii.MaskPrompts {
+		return nil
+	}
+	return utils.NewPIIVault()
+}
+
+// promptVault 与 newPromptVault 相同, 但以上下文所关联会话历史的映射为基础, 历史中已有的占位符与新内容的编号一致, 回复中的占位符都能还原
+func promptVault(ctx context.Context) *utils.PIIVault {
+	if !global.Config.Pii.MaskPrompts {
+		return nil
+	}
+	if vault := historyVault(ctx); vault != nil {
+		return vault
+	}
+	return utils.NewPIIVault()
+}
+
+// promptHistory 准备发送给LLM的历史消息, 不修改传入的切片: vault 非nil时个人信息替换为占位符, 否则还原历史中保存的占位符
+func promptHistory(ctx context.Context, vault *utils.PIIVault, history []common.LlmMessage) []common.LlmMessage {
+	if vault != nil {
+		return redactHistory(vault, history)
+	}
+	stored := historyVault(ctx)
+	if stored == nil {
+		return history
+	}
+	restored := make([]common.LlmMessage, len(history))
+	for i, msg := range history {
+		msg.Content = stored.Restore(msg.Content)
+		restored[i] = msg
+	}
+	return restored
+}
+
+// redactHistory 将历史消息中的个人信息替换为占位符, 不修改传入的切片
+func redactHistory(vault *utils.PIIVault, history []common.LlmMessage) []common.LlmMessage {
+	if vault == nil {
+		return history
+	}
+	redacted := make([]common.LlmMessage, len(history))
+	for i, msg := range history {
+		msg.Content = vault.Redact(msg.Content)
+		redacted[i] = msg
+	}
+	return redacted
+}
+
+// cleanLlmJSON 尝试从LLM可能返回的Markdown代码块中提取纯JSON
+func cleanLlmJSON(raw string) string {
+	cleanJSON := strings.TrimSpace(raw)
+	if strings.HasPrefix(cleanJSON, "```json") {
+		cleanJSON = strings.TrimPrefix(cleanJSON, "```json")
+		cleanJSON = strings.TrimSuffix(cleanJSON, "```")
+		cleanJSON = strings.TrimSpace(cleanJSON)
+	}
+	return cleanJSON
 }
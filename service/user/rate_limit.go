@@ -0,0 +1,177 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/config"
+	"gitee.com/taoJie_1/mall-agent/model/dto"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+)
+
+// ErrRateLimited 表示超出限流额度
+var ErrRateLimited = errors.New("超出限流额度")
+
+// RateLimitError 携带超限的维度和需等待的时间
+type RateLimitError struct {
+	Scope      string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s 超出限流额度, 需等待 %v", e.Scope, e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RateLimitService 基于Redis令牌桶的限流, 所有实例共享额度; 限额每次从 global.Config 读取, 支持热重载
+type RateLimitService interface {
+	// AllowMessage 消耗联系人和会话维度的令牌, 超限时返回 *RateLimitError
+	AllowMessage(ctx context.Context, contactID, conversationID uint) error
+	// AcquireModel 消耗指定模型(small/medium/large/embedding)的全局令牌, 超限时返回 *RateLimitError
+	AcquireModel(ctx context.Context, model string) error
+	// Status 查询限流配置和当前剩余令牌, contactID/conversationID 为0时只返回模型维度
+	Status(ctx context.Context, contactID, conversationID uint) (*dto.RateLimitStatus, error)
+}
+
+type rateLimitService struct{}
+
+func NewRateLimitService() RateLimitService {
+	return &rateLimitService{}
+}
+
+func (s *rateLimitService) AllowMessage(ctx context.Context, contactID, conversationID uint) error {
+	cfg := global.Config.RateLimit
+	if !cfg.Enable {
+		return nil
+	}
+	if contactID != 0 {
+		if err := s.take(ctx, s.contactScope(ctx, contactID), cfg.Contact); err != nil {
+			return err
+		}
+	}
+	return s.take(ctx, s.conversationScope(ctx, conversationID), cfg.Conversation)
+}
+
+func (s *rateLimitService) AcquireModel(ctx context.Context, model string) error {
+	cfg := global.Config.RateLimit
+	if !cfg.Enable {
+		return nil
+	}
+	return s.take(ctx, "model:"+model, cfg.Models[model])
+}
+
+// contactScope 和 conversationScope 返回按租户区分的令牌桶维度, 联系人和会话ID只在租户的 Chatwoot 账户内唯一;
+// 模型维度保护的是共用的模型额度, 不区分租户
+func (s *rateLimitService) contactScope(ctx context.Context, contactID uint) string {
+	return global.TenantFrom(ctx).Key(fmt.Sprintf("contact:%d", contactID))
+}
+
+func (s *rateLimitService) conversationScope(ctx context.Context, conversationID uint) string {
+	return global.TenantFrom(ctx).Key(fmt.Sprintf("conversation:%d", conversationID))
+}
+
+// take 从令牌桶取一个令牌; queue 方式下在 max_wait 内等待令牌补充。Redis异常时放行, 不影响正常服务
+func (s *rateLimitService) take(ctx context.Context, scope string, bucket config.TokenBucket) error {
+	if bucket.Rate <= 0 || bucket.Burst <= 0 || global.RedisClient == nil {
+		return nil
+	}
+
+	cfg := global.Config.RateLimit
+	deadline := time.Now()
+	if enum.RateLimitAction(cfg.Action) == enum.RateLimitActionQueue {
+		deadline = deadline.Add(time.Duration(cfg.MaxWait) * time.Second)
+	}
+
+	for {
+		allowed, _, retryAfter, err := global.RedisClient.TakeToken(ctx, redis.KeyPrefixRateLimit+scope, bucket.Rate, bucket.Burst)
+		if err != nil {
+			global.Log.Warnf("[rate_limit]%s 限流检查失败, 放行: %v", scope, err)
+			return nil
+		}
+		if allowed {
+			return nil
+		}
+		if time.Now().Add(retryAfter).After(deadline) {
+			return &RateLimitError{Scope: scope, RetryAfter: retryAfter}
+		}
+		if !sleepContext(ctx, retryAfter) {
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *rateLimitService) Status(ctx context.Context, contactID, conversationID uint) (*dto.RateLimitStatus, error) {
+	if global.RedisClient == nil {
+		return nil, errors.New("Redis客户端未初始化")
+	}
+
+	cfg := global.Config.RateLimit
+	status := &dto.RateLimitStatus{
+		Enable:  cfg.Enable,
+		Action:  cfg.Action,
+		MaxWait: cfg.MaxWait,
+	}
+
+	var scopes []string
+	buckets := make(map[string]config.TokenBucket)
+	if contactID != 0 {
+		scope := s.contactScope(ctx, contactID)
+		scopes = append(scopes, scope)
+		buckets[scope] = cfg.Contact
+	}
+	if conversationID != 0 {
+		scope := s.conversationScope(ctx, conversationID)
+		scopes = append(scopes, scope)
+		buckets[scope] = cfg.Conversation
+	}
+	models := make([]string, 0, len(cfg.Models))
+	for model := range cfg.Models {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		scope := "model:" + model
+		scopes = append(scopes, scope)
+		buckets[scope] = cfg.Models[model]
+	}
+
+	for _, scope := range scopes {
+		bucket := buckets[scope]
+		remaining, err := s.remaining(ctx, scope, bucket)
+		if err != nil {
+			return nil, err
+		}
+		status.Buckets = append(status.Buckets, &dto.RateLimitBucket{
+			Scope:     scope,
+			Rate:      bucket.Rate,
+			Burst:     bucket.Burst,
+			Remaining: remaining,
+		})
+	}
+	return status, nil
+}
+
+// remaining 按令牌桶的补充规则计算当前剩余令牌, 不消耗令牌
+func (s *rateLimitService) remaining(ctx context.Context, scope string, bucket config.TokenBucket) (float64, error) {
+	values, err := global.RedisClient.HGetAll(ctx, redis.KeyPrefixRateLimit+scope).Result()
+	if err != nil {
+		return 0, fmt.Errorf("读取 %s 令牌桶失败: %w", scope, err)
+	}
+	tokens, err := strconv.ParseFloat(values["tokens"], 64)
+	if err != nil {
+		return float64(bucket.Burst), nil // 桶不存在(未使用或已过期)即为满
+	}
+	ts, _ := strconv.ParseInt(values["ts"], 10, 64)
+	elapsed := time.Since(time.UnixMilli(ts)).Seconds()
+	return math.Min(float64(bucket.Burst), tokens+math.Max(0, elapsed)*bucket.Rate), nil
+}
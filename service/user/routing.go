@@ -0,0 +1,151 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/chatwoot"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/config"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/utils"
+)
+
+// routingContextTTL 路由上下文的保留时间, 覆盖一次会话的正常时长即可
+const routingContextTTL = 24 * time.Hour
+
+type RoutingService interface {
+	// SaveContext 记录会话最新的收件箱与分诊结果, 供转人工时路由使用
+	SaveContext(ctx context.Context, conversationID uint, routingCtx common.RoutingContext)
+	// GetContext 读取会话的路由上下文, 不存在时返回空上下文
+	GetContext(ctx context.Context, conversationID uint) common.RoutingContext
+	// Route 按路由规则为转人工的会话分配团队/客服, 并设置标签和优先级
+	Route(ctx context.Context, conversationID uint)
+}
+
+type routingService struct{}
+
+func NewRoutingService() RoutingService {
+	return &routingService{}
+}
+
+// urgencyPriorities 规则未指定优先级时, 按分诊紧急度映射
+var urgencyPriorities = map[enum.TriageUrgency]chatwoot.ConversationPriority{
+	enum.TriageUrgencyCritical: chatwoot.ConversationPriorityUrgent,
+	enum.TriageUrgencyHigh:     chatwoot.ConversationPriorityHigh,
+	enum.TriageUrgencyMedium:   chatwoot.ConversationPriorityMedium,
+	enum.TriageUrgencyLow:      chatwoot.ConversationPriorityLow,
+}
+
+func (s *routingService) SaveContext(ctx context.Context, conversationID uint, routingCtx common.RoutingContext) {
+	// 工作时间判断和交接摘要同样依赖该上下文, 不受路由开关影响
+	if global.RedisClient == nil {
+		return
+	}
+	if global.Config.Pii.MaskRecords {
+		routingCtx.Question = utils.MaskPII(routingCtx.Question)
+	}
+	data, err := json.Marshal(routingCtx)
+	if err != nil {
+		global.Log.Warnf("[routing]序列化会话 %d 路由上下文失败: %v", conversationID, err)
+		return
+	}
+	key := global.ConversationKey(ctx, redis.KeyPrefixRoutingContext, conversationID)
+	if err := global.RedisClient.Set(ctx, key, data, routingContextTTL).Err(); err != nil {
+		global.Log.Warnf("[routing]保存会话 %d 路由上下文失败: %v", conversationID, err)
+	}
+}
+
+func (s *routingService) Route(ctx context.Context, conversationID uint) {
+	cfg := global.Config.Routing
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if !cfg.Enable || chatwootClient == nil {
+		return
+	}
+
+	routingCtx := s.GetContext(ctx, conversationID)
+
+	teamID := cfg.FallbackTeamID
+	var assigneeID uint
+	var labels []string
+	var priority chatwoot.ConversationPriority
+
+	if rule := s.matchRule(cfg.Rules, routingCtx); rule != nil {
+		global.Log.Debugf("[routing]会话 %d 命中路由规则 '%s'", conversationID, rule.Name)
+		// 只设置标签或优先级的规则不决定分配, 仍交给兜底团队
+		if rule.TeamID != 0 || rule.AssigneeID != 0 {
+			teamID, assigneeID = rule.TeamID, rule.AssigneeID
+		}
+		labels = append(labels, rule.Labels...)
+		priority = chatwoot.ConversationPriority(rule.Priority)
+	} else {
+		global.Log.Debugf("[routing]会话 %d 未命中任何路由规则, 使用兜底团队 %d", conversationID, teamID)
+	}
+
+	if routingCtx.Intent != "" {
+		labels = append(labels, cfg.LabelPrefix+routingCtx.Intent)
+	}
+	if priority == "" {
+		priority = urgencyPriorities[enum.TriageUrgency(routingCtx.Urgency)]
+	}
+
+	if teamID != 0 || assigneeID != 0 {
+		if err := chatwootClient.AssignConversation(conversationID, teamID, assigneeID); err != nil {
+			global.Log.Errorf("[routing]分配会话 %d 失败(团队: %d, 客服: %d): %v", conversationID, teamID, assigneeID, err)
+		}
+	}
+	if len(labels) > 0 {
+		if err := chatwootClient.AddConversationLabels(conversationID, labels); err != nil {
+			global.Log.Warnf("[routing]为会话 %d 添加标签失败: %v", conversationID, err)
+		}
+	}
+	if priority != "" {
+		if err := chatwootClient.SetConversationPriority(conversationID, priority); err != nil {
+			global.Log.Warnf("[routing]设置会话 %d 优先级失败: %v", conversationID, err)
+		}
+	}
+}
+
+func (s *routingService) GetContext(ctx context.Context, conversationID uint) common.RoutingContext {
+	var routingCtx common.RoutingContext
+	if global.RedisClient == nil {
+		return routingCtx
+	}
+	key := global.ConversationKey(ctx, redis.KeyPrefixRoutingContext, conversationID)
+	val, err := global.RedisClient.Get(ctx, key).Result()
+	if err != nil {
+		if err != redis.ErrNil {
+			global.Log.Warnf("[routing]获取会话 %d 路由上下文失败: %v", conversationID, err)
+		}
+		return routingCtx
+	}
+	if err := json.Unmarshal([]byte(val), &routingCtx); err != nil {
+		global.Log.Warnf("[routing]解析会话 %d 路由上下文失败: %v", conversationID, err)
+	}
+	return routingCtx
+}
+
+// matchRule 按顺序返回第一条所有条件都满足的规则, 空条件视为不限
+func (s *routingService) matchRule(rules []config.RoutingRule, routingCtx common.RoutingContext) *config.RoutingRule {
+	for i := range rules {
+		rule := &rules[i]
+		if len(rule.Intents) > 0 && !slices.Contains(rule.Intents, routingCtx.Intent) {
+			continue
+		}
+		if len(rule.Emotions) > 0 && !slices.Contains(rule.Emotions, routingCtx.Emotion) {
+			continue
+		}
+		if len(rule.Urgencies) > 0 && !slices.Contains(rule.Urgencies, routingCtx.Urgency) {
+			continue
+		}
+		if len(rule.InboxIDs) > 0 && !slices.Contains(rule.InboxIDs, routingCtx.InboxID) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
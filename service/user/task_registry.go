@@ -0,0 +1,218 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+)
+
+// taskLeaseTTL 任务租约的有效期, 运行中每隔三分之一有效期续期一次; 实例宕机后租约自然过期
+const taskLeaseTTL = 30 * time.Second
+
+// TaskRegistry 跨实例登记会话的AI任务, 通过Redis租约记录任务归属, 通过发布订阅通知持有者取消
+type TaskRegistry interface {
+	// Register 登记会话的AI任务并取代该会话在任意实例上正在运行的旧任务, 返回任务令牌
+	Register(ctx context.Context, conversationID uint, cancel context.CancelFunc) string
+	// Unregister 任务结束时注销, 仅当令牌仍属于本任务时才释放
+	Unregister(ctx context.Context, conversationID uint, token string)
+	// Cancel 取消会话在任意实例上正在运行的AI任务
+	Cancel(ctx context.Context, conversationID uint)
+	// Listen 订阅取消广播直到ctx结束, Redis连接断开(如热重载)后自动重新订阅
+	Listen(ctx context.Context)
+}
+
+type taskRegistry struct {
+	instanceID string
+	seq        atomic.Uint64
+}
+
+// taskCancelMessage 取消广播的消息体, Conversation 为带租户的会话标识
+type taskCancelMessage struct {
+	Conversation string `json:"conversation"`
+	Token        string `json:"token"`
+}
+
+func NewTaskRegistry() TaskRegistry {
+	hostname, _ := os.Hostname()
+	return &taskRegistry{instanceID: fmt.Sprintf("%s-%d", hostname, os.Getpid())}
+}
+
+func (r *taskRegistry) Register(ctx context.Context, conversationID uint, cancel context.CancelFunc) string {
+	token := fmt.Sprintf("%s-%d", r.instanceID, r.seq.Add(1))
+	scope := global.TenantFrom(ctx).ConversationScope(conversationID)
+
+	// 本实例内的旧任务直接取消
+	global.ActiveLLMTasks.Lock()
+	if old, exists := global.ActiveLLMTasks.Data[scope]; exists {
+		old.Cancel()
+		global.Log.Debugf("会话 %s 的旧AI任务已被新任务取代并取消。", scope)
+	}
+	global.ActiveLLMTasks.Data[scope] = global.ActiveTask{Token: token, Cancel: cancel}
+	global.ActiveLLMTasks.Unlock()
+
+	if global.RedisClient == nil {
+		return token
+	}
+
+	leaseCtx, leaseCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer leaseCancel()
+	prev, err := global.RedisClient.AcquireTaskLease(leaseCtx, scope, token, taskLeaseTTL)
+	if err != nil {
+		global.Log.Warnf("[task]会话 %s 获取任务租约失败, 仅在本实例内生效: %v", scope, err)
+		return token
+	}
+	// 旧任务可能在其他实例上, 广播通知其持有者取消
+	if prev != "" {
+		r.publishCancel(leaseCtx, scope, prev)
+	}
+
+	go r.keepAlive(ctx, scope, token, cancel)
+	return token
+}
+
+func (r *taskRegistry) Unregister(ctx context.Context, conversationID uint, token string) {
+	scope := global.TenantFrom(ctx).ConversationScope(conversationID)
+	global.ActiveLLMTasks.Lock()
+	if task, exists := global.ActiveLLMTasks.Data[scope]; exists && task.Token == token {
+		delete(global.ActiveLLMTasks.Data, scope)
+	}
+	global.ActiveLLMTasks.Unlock()
+
+	if global.RedisClient == nil {
+		return
+	}
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+	if err := global.RedisClient.ReleaseTaskLease(releaseCtx, scope, token); err != nil {
+		global.Log.Warnf("[task]会话 %s 释放任务租约失败: %v", scope, err)
+	}
+}
+
+func (r *taskRegistry) Cancel(ctx context.Context, conversationID uint) {
+	scope := global.TenantFrom(ctx).ConversationScope(conversationID)
+	global.ActiveLLMTasks.Lock()
+	if task, exists := global.ActiveLLMTasks.Data[scope]; exists {
+		task.Cancel()
+		delete(global.ActiveLLMTasks.Data, scope)
+		global.Log.Debugf("会话%s已解决，已终止正在进行的AI任务。", scope)
+	}
+	global.ActiveLLMTasks.Unlock()
+
+	if global.RedisClient == nil {
+		return
+	}
+	cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+	token, err := global.RedisClient.GetDel(cancelCtx, redis.KeyPrefixTaskOwner+scope).Result()
+	if err != nil {
+		if err != redis.ErrNil {
+			global.Log.Warnf("[task]会话 %s 读取任务租约失败: %v", scope, err)
+		}
+		return
+	}
+	r.publishCancel(cancelCtx, scope, token)
+}
+
+func (r *taskRegistry) Listen(ctx context.Context) {
+	for ctx.Err() == nil {
+		client := global.RedisClient
+		if client == nil {
+			if !sleepContext(ctx, 5*time.Second) {
+				return
+			}
+			continue
+		}
+
+		pubsub := client.Subscribe(ctx, redis.ChannelTaskCancel)
+		r.consume(ctx, pubsub)
+		_ = pubsub.Close()
+
+		// 订阅中断后稍作等待再重新订阅
+		if !sleepContext(ctx, time.Second) {
+			return
+		}
+	}
+}
+
+// consume 处理取消广播, 直到订阅通道关闭或ctx结束
+func (r *taskRegistry) consume(ctx context.Context, pubsub *redis.PubSub) {
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var payload taskCancelMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &payload); err != nil {
+				global.Log.Warnf("[task]解析任务取消广播失败: %v", err)
+				continue
+			}
+			r.cancelLocal(payload.Conversation, payload.Token)
+		}
+	}
+}
+
+// cancelLocal 取消本实例上令牌匹配的任务
+func (r *taskRegistry) cancelLocal(scope string, token string) {
+	global.ActiveLLMTasks.Lock()
+	defer global.ActiveLLMTasks.Unlock()
+	if task, exists := global.ActiveLLMTasks.Data[scope]; exists && task.Token == token {
+		task.Cancel()
+		delete(global.ActiveLLMTasks.Data, scope)
+		global.Log.Debugf("会话 %s 的AI任务已被其他实例取消。", scope)
+	}
+}
+
+func (r *taskRegistry) publishCancel(ctx context.Context, scope string, token string) {
+	payload, _ := json.Marshal(taskCancelMessage{Conversation: scope, Token: token})
+	if err := global.RedisClient.Publish(ctx, redis.ChannelTaskCancel, payload).Err(); err != nil {
+		global.Log.Warnf("[task]会话 %s 广播取消任务失败: %v", scope, err)
+	}
+}
+
+// keepAlive 任务运行期间定期续期租约; 租约已被其他任务取代时取消本任务, 防止取消广播丢失
+func (r *taskRegistry) keepAlive(ctx context.Context, scope string, token string, cancel context.CancelFunc) {
+	ticker := time.NewTicker(taskLeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if global.RedisClient == nil {
+				continue
+			}
+			renewCtx, renewCancel := context.WithTimeout(ctx, 3*time.Second)
+			renewed, err := global.RedisClient.RenewTaskLease(renewCtx, scope, token, taskLeaseTTL)
+			renewCancel()
+			if err != nil {
+				global.Log.Warnf("[task]会话 %s 续期任务租约失败: %v", scope, err)
+				continue
+			}
+			if !renewed {
+				global.Log.Debugf("会话 %s 的任务租约已被取代，取消本实例上的任务。", scope)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// sleepContext 等待指定时间, ctx结束时提前返回false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
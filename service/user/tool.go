@@ -0,0 +1,315 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/chatwoot"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/common"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+	"gitee.com/taoJie_1/mall-agent/utils"
+	"github.com/sashabaranov/go-openai"
+	"golang.org/x/sync/errgroup"
+)
+
+type ToolService interface {
+	// ExecuteToolCalls 并发执行工具调用，返回可直接追加到对话历史中的工具结果消息
+	ExecuteToolCalls(ctx context.Context, conversationID uint, toolCalls common.ToolCalls) []common.LlmMessage
+	// GetCallLog 获取会话中最近执行过的工具调用记录
+	GetCallLog(ctx context.Context, conversationID uint) []common.ToolCallRecord
+	// HasMutating 判断一组工具调用中是否包含有副作用的工具
+	HasMutating(ctx context.Context, toolCalls common.ToolCalls) bool
+	// RequestConfirmation 暂存待确认的工具调用，并向用户发送确认消息，返回发送给用户的文本
+	RequestConfirmation(ctx context.Context, conversationID uint, pending *common.PendingToolCall) (string, error)
+	// TakePending 取出并删除会话中待确认的工具调用，不存在时返回nil
+	TakePending(ctx context.Context, conversationID uint) (*common.PendingToolCall, error)
+	// DiscardPending 丢弃会话中待确认的工具调用, 会话转人工或已解决后不能再由用户确认执行
+	DiscardPending(ctx context.Context, conversationID uint)
+	// ParseConfirmation 解析用户对确认消息的回复; recognized为false表示用户没有明确表态
+	ParseConfirmation(content string) (confirmed bool, recognized bool)
+}
+
+type toolService struct {
+	confirmWords map[string]struct{}
+	cancelWords  map[string]struct{}
+	guard        GuardService
+}
+
+func NewToolService() ToolService {
+	confirmWords := []string{string(enum.ToolConfirmOptionYes), "确认", "确认执行", "确定", "是", "是的", "好", "好的", "可以", "同意", "yes", "y", "ok"}
+	cancelWords := []string{string(enum.ToolConfirmOptionNo), "取消", "不", "不要", "不用", "否", "算了", "no", "n"}
+
+	s := &toolService{
+		confirmWords: make(map[string]struct{}, len(confirmWords)),
+		cancelWords:  make(map[string]struct{}, len(cancelWords)),
+		guard:        NewGuardService(),
+	}
+	for _, w := range confirmWords {
+		s.confirmWords[w] = struct{}{}
+	}
+	for _, w := range cancelWords {
+		s.cancelWords[w] = struct{}{}
+	}
+	return s
+}
+
+const (
+	// maxToolCallLog 每个会话保留的工具调用记录数
+	maxToolCallLog = 10
+	// maxToolResultLogLength 工具调用记录中结果的最大字符数
+	maxToolResultLogLength = 500
+)
+
+func (s *toolService) ExecuteToolCalls(ctx context.Context, conversationID uint, toolCalls common.ToolCalls) []common.LlmMessage {
+	mcpClient := global.TenantFrom(ctx).Mcp()
+	if mcpClient == nil || len(toolCalls) == 0 {
+		return nil
+	}
+
+	// 从MCP服务获取所有工具的描述
+	toolDescriptions := mcpClient.GetToolDescriptions()
+
+	var toolResults []common.LlmMessage
+	var records []common.ToolCallRecord
+	var mu sync.Mutex
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(5) // 限制并发数为5，防止过多请求冲击MCP服务
+
+	for _, toolCall := range toolCalls {
+		toolCall := toolCall // 避免闭包陷阱
+		g.Go(func() error {
+			var toolResultContent string
+			clientName, toolName, ok := s.splitName(toolCall.Name)
+			if !ok {
+				toolResultContent = fmt.Sprintf("工具名称格式错误，必须为 '客户端名称.工具名称'，实际为: '%s'", toolCall.Name)
+				global.Log.Errorf("[ToolService] %s", toolResultContent)
+			} else {
+				result, err := mcpClient.ExecuteTool(gCtx, clientName, toolName, toolCall.Arguments)
+				if err != nil {
+					toolResultContent = fmt.Sprintf("工具 '%s' 调用失败: %v", toolCall.Name, err)
+					global.Log.Errorf("[ToolService] %s", toolResultContent)
+				} else {
+					global.Log.Debugf("=================成功获取Mcp数据 for '%s': %s", toolCall.Name, result)
+					// 工具结果可能包含外部写入的数据(如商品描述、用户备注), 过滤其中的注入内容后再交给LLM
+					toolResultContent = s.guard.SanitizeToolResult(gCtx, conversationID, toolCall.Name, result)
+				}
+			}
+
+			toolDescription := "未知工具"
+			if desc, ok := toolDescriptions[toolCall.Name]; ok {
+				toolDescription = desc
+			}
+
+			// 为每个工具结果创建一个结构化的消息
+			finalContent := fmt.Sprintf(
+				"[工具名称]: %s\n[工具作用]: %s\n[返回结果]:\n%s",
+				toolCall.Name,
+				toolDescription,
+				toolResultContent,
+			)
+			mu.Lock()
+			toolResults = append(toolResults, common.LlmMessage{
+				Role:    openai.ChatMessageRoleTool,
+				Content: finalContent,
+			})
+			record := common.ToolCallRecord{
+				Name:      toolCall.Name,
+				Arguments: toolCall.Arguments,
+				Result:    truncateRunes(toolResultContent, maxToolResultLogLength),
+				CreatedAt: time.Now().Unix(),
+			}
+			if global.Config.Pii.MaskRecords {
+				// 掩码中不含引号等JSON特殊字符, 脱敏后的参数仍是合法JSON
+				record.Arguments = json.RawMessage(utils.MaskPII(string(record.Arguments)))
+				record.Result = utils.MaskPII(record.Result)
+			}
+			records = append(records, record)
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		global.Log.Errorf("[ToolService] 执行MCP工具组时发生错误: %v", err)
+	}
+	s.appendCallLog(context.WithoutCancel(ctx), conversationID, records)
+	return toolResults
+}
+
+func (s *toolService) GetCallLog(ctx context.Context, conversationID uint) []common.ToolCallRecord {
+	if global.RedisClient == nil {
+		return nil
+	}
+	key := global.ConversationKey(ctx, redis.KeyPrefixToolCallLog, conversationID)
+	val, err := global.RedisClient.Get(ctx, key).Result()
+	if err != nil {
+		if err != redis.ErrNil {
+			global.Log.Warnf("[ToolService] 获取会话 %d 工具调用记录失败: %v", conversationID, err)
+		}
+		return nil
+	}
+	var records []common.ToolCallRecord
+	if err := json.Unmarshal([]byte(val), &records); err != nil {
+		global.Log.Warnf("[ToolService] 解析会话 %d 工具调用记录失败: %v", conversationID, err)
+		return nil
+	}
+	return records
+}
+
+// appendCallLog 追加工具调用记录, 只保留最近的若干条, 过期时间与会话历史一致; 记录按 ctx 中的租户隔离
+func (s *toolService) appendCallLog(ctx context.Context, conversationID uint, records []common.ToolCallRecord) {
+	if global.RedisClient == nil || len(records) == 0 {
+		return
+	}
+	all := append(s.GetCallLog(ctx, conversationID), records...)
+	if len(all) > maxToolCallLog {
+		all = all[len(all)-maxToolCallLog:]
+	}
+	data, err := json.Marshal(all)
+	if err != nil {
+		return
+	}
+	key := global.ConversationKey(ctx, redis.KeyPrefixToolCallLog, conversationID)
+	ttl := time.Duration(global.Config.Redis.ConversationHistoryTTL) * time.Second
+	if err := global.RedisClient.Set(ctx, key, data, ttl).Err(); err != nil {
+		global.Log.Warnf("[ToolService] 保存会话 %d 工具调用记录失败: %v", conversationID, err)
+	}
+}
+
+func (s *toolService) HasMutating(ctx context.Context, toolCalls common.ToolCalls) bool {
+	mcpClient := global.TenantFrom(ctx).Mcp()
+	if mcpClient == nil {
+		return false
+	}
+	for _, toolCall := range toolCalls {
+		clientName, toolName, ok := s.splitName(toolCall.Name)
+		if ok && mcpClient.IsMutatingTool(clientName, toolName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *toolService) RequestConfirmation(ctx context.Context, conversationID uint, pending *common.PendingToolCall) (string, error) {
+	if global.RedisClient == nil {
+		return "", errors.New("Redis客户端未初始化")
+	}
+	chatwootClient := global.TenantFrom(ctx).Chatwoot()
+	if chatwootClient == nil {
+		return "", errors.New("Chatwoot客户端未初始化")
+	}
+
+	pending.CreatedAt = time.Now().Unix()
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return "", fmt.Errorf("序列化待确认工具调用失败: %w", err)
+	}
+
+	key := global.ConversationKey(ctx, redis.KeyPrefixPendingToolCall, conversationID)
+	ttl := time.Duration(global.Config.Ai.ToolConfirmTimeout) * time.Second
+	if err := global.RedisClient.Set(ctx, key, data, ttl).Err(); err != nil {
+		return "", fmt.Errorf("暂存待确认工具调用失败: %w", err)
+	}
+
+	content := s.buildConfirmationContent(ctx, pending.ToolCalls)
+	options := []chatwoot.SelectOption{
+		{Title: "确认执行", Value: string(enum.ToolConfirmOptionYes)},
+		{Title: "取消", Value: string(enum.ToolConfirmOptionNo)},
+	}
+	if err := chatwootClient.CreateInputSelectMessage(conversationID, content, options); err != nil {
+		// 确认消息发不出去，用户就无从确认，清理掉暂存数据
+		global.RedisClient.Del(context.Background(), key)
+		return "", fmt.Errorf("发送工具调用确认消息失败: %w", err)
+	}
+	return content, nil
+}
+
+func (s *toolService) TakePending(ctx context.Context, conversationID uint) (*common.PendingToolCall, error) {
+	if global.RedisClient == nil {
+		return nil, nil
+	}
+
+	// GetDel 保证多实例下同一个待确认调用只会被取出一次
+	key := global.ConversationKey(ctx, redis.KeyPrefixPendingToolCall, conversationID)
+	val, err := global.RedisClient.GetDel(ctx, key).Result()
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取待确认工具调用失败: %w", err)
+	}
+
+	var pending common.PendingToolCall
+	if err := json.Unmarshal([]byte(val), &pending); err != nil {
+		return nil, fmt.Errorf("反序列化待确认工具调用失败: %w", err)
+	}
+	return &pending, nil
+}
+
+func (s *toolService) DiscardPending(ctx context.Context, conversationID uint) {
+	if global.RedisClient == nil {
+		return
+	}
+	key := global.ConversationKey(ctx, redis.KeyPrefixPendingToolCall, conversationID)
+	if err := global.RedisClient.Del(ctx, key).Err(); err != nil {
+		global.Log.Warnf("丢弃会话 %d 待确认的工具调用失败: %v", conversationID, err)
+	}
+}
+
+func (s *toolService) ParseConfirmation(content string) (confirmed bool, recognized bool) {
+	text := strings.ToLower(strings.Trim(strings.TrimSpace(content), "。.!！~～"))
+	if _, ok := s.confirmWords[text]; ok {
+		return true, true
+	}
+	if _, ok := s.cancelWords[text]; ok {
+		return false, true
+	}
+	return false, false
+}
+
+// buildConfirmationContent 生成给用户看的确认文本，列出每个待执行操作及其参数
+func (s *toolService) buildConfirmationContent(ctx context.Context, toolCalls common.ToolCalls) string {
+	var toolDescriptions map[string]string
+	if mcpClient := global.TenantFrom(ctx).Mcp(); mcpClient != nil {
+		toolDescriptions = mcpClient.GetToolDescriptions()
+	}
+
+	var builder strings.Builder
+	builder.WriteString(string(enum.ReplyMsgToolConfirm))
+	for _, toolCall := range toolCalls {
+		desc, ok := toolDescriptions[toolCall.Name]
+		if !ok || desc == "" {
+			desc = toolCall.Name
+		}
+		fmt.Fprintf(&builder, "\n- %s", desc)
+		if len(toolCall.Arguments) > 0 && string(toolCall.Arguments) != "null" {
+			fmt.Fprintf(&builder, "（%s）", string(toolCall.Arguments))
+		}
+	}
+	return builder.String()
+}
+
+// truncateRunes 按字符截断字符串, 超出部分以省略号代替
+func truncateRunes(str string, max int) string {
+	runes := []rune(str)
+	if len(runes) <= max {
+		return str
+	}
+	return string(runes[:max]) + "..."
+}
+
+// splitName 将 "客户端名称.工具名称" 拆分为两部分
+func (s *toolService) splitName(fullName string) (clientName string, toolName string, ok bool) {
+	parts := strings.SplitN(fullName, ".", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
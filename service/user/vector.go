@@ -13,13 +13,19 @@ type VectorService interface {
 	Search(ctx context.Context, query string) ([]dao.SearchResult, error)
 }
 
-type vectorService struct{}
+type vectorService struct {
+	rateLimit RateLimitService
+}
 
 func NewVectorService() *vectorService {
-	return &vectorService{}
+	return &vectorService{rateLimit: NewRateLimitService()}
 }
 
 func (s *vectorService) Search(ctx context.Context, query string) ([]dao.SearchResult, error) {
+	// 检索前需要调用向量化模型
+	if err := s.rateLimit.AcquireModel(ctx, "embedding"); err != nil {
+		return nil, err
+	}
 	results, err := dao.App.VectorDb.Search(ctx, query, int(global.Config.Ai.VectorSearchTopK))
 	if err != nil {
 		if err == sql.ErrNoRows {
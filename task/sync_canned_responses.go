@@ -18,10 +18,29 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-// KeywordReloader 作为总同步/审计任务，从 Chatwoot 拉取全量数据，
+// KeywordReloader 作为总同步/审计任务，依次为每个租户从 Chatwoot 拉取全量数据，
 // 并与上次同步时间对比，找出增量数据进行处理，同时清理已不存在的旧数据。
 func (m *Manager) KeywordReloader() error {
-	ctx := context.Background()
+	var errs []error
+	for _, tenant := range global.Tenants.All() {
+		if err := m.reloadTenantKeywords(global.WithTenant(context.Background(), tenant)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tenantLabel(tenant), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tenantLabel 日志中使用的租户名称
+func tenantLabel(t *global.Tenant) string {
+	if t.IsDefault() {
+		return "默认租户"
+	}
+	return "租户 '" + t.Name + "'"
+}
+
+// reloadTenantKeywords 同步上下文中租户的快捷回复; 锁、同步时间戳、Redis缓存和向量集合均按租户隔离
+func (m *Manager) reloadTenantKeywords(ctx context.Context) error {
+	tenant := global.TenantFrom(ctx)
 	agentID, _ := os.Hostname()
 	if agentID == "" {
 		agentID = "unknown_agent"
@@ -33,7 +52,7 @@ func (m *Manager) KeywordReloader() error {
 		return fmt.Errorf("检查同步锁失败: %w", err)
 	}
 	if !locked {
-		global.Log.Info("同步锁被其他实例持有，跳过本次同步任务")
+		global.Log.Infof("%s的同步锁被其他实例持有，跳过本次同步任务", tenantLabel(tenant))
 		return nil
 	}
 	// 确保函数退出时释放锁
@@ -43,16 +62,16 @@ func (m *Manager) KeywordReloader() error {
 		}
 	}()
 
-	global.Log.Debugln("获取同步锁成功，开始执行关键词重同步任务...")
+	global.Log.Debugf("获取%s的同步锁成功，开始执行关键词重同步任务...", tenantLabel(tenant))
 
-	if global.ChatwootService == nil {
+	if tenant.Chatwoot() == nil {
 		return errors.New("Chatwoot客户端未初始化")
 	}
 
 	// 1. 获取上次同步的时间戳
 	var lastSyncTime time.Time
 	if global.RedisClient != nil {
-		lastSyncTimeStr, _ := global.RedisClient.Get(ctx, redis.KeyLastSyncCannedResponses).Result()
+		lastSyncTimeStr, _ := global.RedisClient.Get(ctx, tenant.Key(redis.KeyLastSyncCannedResponses)).Result()
 		if parsedTime, err := time.Parse(time.RFC3339Nano, lastSyncTimeStr); err == nil {
 			lastSyncTime = parsedTime
 		}
@@ -66,7 +85,7 @@ func (m *Manager) KeywordReloader() error {
 	}
 
 	// 2. 从Chatwoot拉取全量数据
-	allResponses, err := global.ChatwootService.GetCannedResponses()
+	allResponses, err := tenant.Chatwoot().GetCannedResponses()
 	if err != nil {
 		return fmt.Errorf("从Chatwoot获取预设回复失败: %w", err)
 	}
@@ -130,14 +149,14 @@ func (m *Manager) KeywordReloader() error {
 	// 6. 仅在所有环节都成功时，才更新同步时间戳
 	if processErr == nil && syncErr == nil {
 		if global.RedisClient != nil && newLatestSyncTime.After(lastSyncTime) {
-			global.RedisClient.Set(ctx, redis.KeyLastSyncCannedResponses, newLatestSyncTime.Format(time.RFC3339Nano), 0)
-			global.Log.Debugln("同步时间戳已更新为: %s", newLatestSyncTime.Format(time.RFC3339Nano))
+			global.RedisClient.Set(ctx, tenant.Key(redis.KeyLastSyncCannedResponses), newLatestSyncTime.Format(time.RFC3339Nano), 0)
+			global.Log.Debugf("同步时间戳已更新为: %s", newLatestSyncTime.Format(time.RFC3339Nano))
 		}
 	} else {
 		global.Log.Warn("由于同步过程中发生错误，本次将不更新同步时间戳，以便下次重试")
 	}
 
-	global.Log.Info("关键词重同步任务完成")
+	global.Log.Infof("%s的关键词重同步任务完成", tenantLabel(tenant))
 	return syncErr
 }
 
@@ -193,7 +212,7 @@ func (m *Manager) ProcessAndCacheResponses(ctx context.Context, responses []chat
 					return fmt.Errorf("精准更新精确匹配规则到Redis失败: %w", err)
 				}
 			}
-			m.updateInMemoryMap(exactMatchRules)
+			m.updateInMemoryMap(ctx, exactMatchRules)
 			global.Log.Debugf("成功精准更新 %d 条规则到精确匹配缓存", len(exactMatchRules))
 			return nil
 		})
@@ -225,7 +244,7 @@ func (m *Manager) generateVectorDocs(ctx context.Context, rules []chatwoot.Canne
 				return nil
 			}
 
-			standardQuestion, err := global.LlmService.GenerateStandardQuestion(ctx, enum.SystemPromptGenQuestionFromKeyword, seedQuestion)
+			standardQuestion, err := global.LlmService.GenerateStandardQuestion(ctx, global.Prompt(ctx, enum.PromptGenQuestionFromKeyword), seedQuestion)
 			if err != nil {
 				global.Log.Warnf("为ID %d 的内容生成标准问题失败: %v", r.Id, err)
 				return nil
@@ -288,7 +307,7 @@ func (m *Manager) syncExactMatchCache(ctx context.Context, exactMatchRules []cha
 	// 2. 重建Redis缓存
 	if global.RedisClient != nil {
 		// a. 删除旧的Hash
-		if err := global.RedisClient.Del(ctx, redis.KeyCannedResponsesHash).Err(); err != nil {
+		if err := global.RedisClient.Del(ctx, global.TenantFrom(ctx).Key(redis.KeyCannedResponsesHash)).Err(); err != nil {
 			return fmt.Errorf("重建Redis缓存时删除旧Hash失败: %w", err)
 		}
 		// b. 写入新数据
@@ -300,11 +319,12 @@ func (m *Manager) syncExactMatchCache(ctx context.Context, exactMatchRules []cha
 	}
 
 	// 3. 原子性地替换内存Map
-	global.CannedResponses.Lock()
-	global.CannedResponses.Data = tempMap
-	global.CannedResponses.Unlock()
+	cache := global.TenantFrom(ctx).CannedResponses
+	cache.Lock()
+	cache.Data = tempMap
+	cache.Unlock()
 
-	global.Log.Infof("成功加载 %d 条精确匹配关键词到内存和Redis", len(tempMap))
+	global.Log.Infof("成功为%s加载 %d 条精确匹配关键词到内存和Redis", tenantLabel(global.TenantFrom(ctx)), len(tempMap))
 	return nil
 }
 
@@ -324,12 +344,13 @@ func (m *Manager) pruneVectorDb(ctx context.Context, activeIDs []string) error {
 	return nil
 }
 
-// updateInMemoryMap 原子性地更新或追加内存中的 CannedResponses Map
-func (m *Manager) updateInMemoryMap(responses []chatwoot.CannedResponse) {
-	global.CannedResponses.Lock()
-	defer global.CannedResponses.Unlock()
+// updateInMemoryMap 原子性地更新或追加上下文中租户的 CannedResponses Map
+func (m *Manager) updateInMemoryMap(ctx context.Context, responses []chatwoot.CannedResponse) {
+	cache := global.TenantFrom(ctx).CannedResponses
+	cache.Lock()
+	defer cache.Unlock()
 	for _, resp := range responses {
-		global.CannedResponses.Data[resp.ShortCode] = resp.Content
+		cache.Data[resp.ShortCode] = resp.Content
 	}
 }
 
@@ -348,34 +369,45 @@ func (m *Manager) parseShortCode(shortCode string) (qType enum.KeywordType, qTex
 }
 
 
-// LoadKeywords 从Redis加载关键词到内存，并处理分布式锁
+// LoadKeywords 为每个租户从Redis加载关键词到内存，并处理分布式锁
 func (m *Manager) LoadKeywords() error {
-	ctx := context.Background()
+	var errs []error
+	for _, tenant := range global.Tenants.All() {
+		if err := m.loadTenantKeywords(global.WithTenant(context.Background(), tenant)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tenantLabel(tenant), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadTenantKeywords 从Redis加载上下文中租户的关键词, 缓存为空时执行一次同步
+func (m *Manager) loadTenantKeywords(ctx context.Context) error {
+	tenant := global.TenantFrom(ctx)
 
 	// 1. 尝试从Redis加载数据
 	responses, err := dao.App.KeywordsDb.LoadAllKeywordsFromRedis(ctx)
 	if err == nil && len(responses) > 0 {
 		// 缓存命中，直接加载到内存
-		m.updateInMemoryMap(responses)
-		global.Log.Info("从Redis成功加载快捷回复到内存")
+		m.updateInMemoryMap(ctx, responses)
+		global.Log.Infof("从Redis成功加载%s的快捷回复到内存", tenantLabel(tenant))
 		return nil
 	}
 	if err != nil {
-		global.Log.Errorf("从Redis加载Keywords失败: %v", err)
+		global.Log.Errorf("从Redis加载%s的Keywords失败: %v", tenantLabel(tenant), err)
 	} else {
-		global.Log.Infoln("Redis中没有快捷回复数据，将触发首次同步")
+		global.Log.Infof("Redis中没有%s的快捷回复数据，将触发首次同步", tenantLabel(tenant))
 	}
 
 	// 2. 缓存未命中或加载失败，执行一次同步
-	// KeywordReloader内部会处理分布式锁
-	if err := m.KeywordReloader(); err != nil {
+	// reloadTenantKeywords内部会处理分布式锁
+	if err := m.reloadTenantKeywords(ctx); err != nil {
 		return fmt.Errorf("启动时同步关键词失败: %w", err)
 	}
 
 	// 3. 检查同步后内存中是否已有数据
-	global.CannedResponses.RLock()
-	mapLen := len(global.CannedResponses.Data)
-	global.CannedResponses.RUnlock()
+	tenant.CannedResponses.RLock()
+	mapLen := len(tenant.CannedResponses.Data)
+	tenant.CannedResponses.RUnlock()
 
 	if mapLen == 0 {
 		// 如果执行同步后内存依然为空，可能意味着同步被跳过（其他实例持有锁）但最终数据仍未加载成功。
@@ -6,48 +6,73 @@ import (
 	"sync"
 
 	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/mcp"
 	"gitee.com/taoJie_1/mall-agent/model/config"
 )
 
 // McpCapabilitiesReloader 刷新所有或指定的 MCP 服务的能力。
-// 如果没有提供 mcpName，它会遍历配置中定义的所有 MCP 服务器。
-// 如果提供了 mcpName，它只会刷新指定的 MCP 服务。
+// 如果没有提供 mcpName，它会遍历默认租户和配置了独立MCP服务的租户中定义的所有 MCP 服务器。
+// 如果提供了 mcpName，它只会刷新各租户中名为 mcpName 的 MCP 服务。
 // 这确保了代理对 MCP 能力的了解是最新的。
 func (m *Manager) McpCapabilitiesReloader(mcpName ...string) error {
-	if global.McpService == nil {
-		global.Log.Info("MCP服务未启用，跳过能力刷新任务")
-		return nil
-	}
+	var errs []error
+	found := false
+	for _, tenant := range global.Tenants.All() {
+		if !tenant.IsDefault() && tenant.McpClient == nil {
+			continue // 沿用默认租户的MCP服务
+		}
+		service, mcpConfigs := tenant.Mcp(), tenant.McpServers()
+		if service == nil || len(mcpConfigs) == 0 {
+			continue
+		}
 
-	mcpConfigs := global.Config.McpServers
-	if len(mcpConfigs) == 0 {
-		global.Log.Info("未配置任何MCP服务，跳过能力刷新任务")
-		return nil
+		if len(mcpName) > 0 && mcpName[0] != "" {
+			cfg, ok := mcpConfigs[mcpName[0]]
+			if !ok {
+				continue
+			}
+			found = true
+			global.Log.Infof("开始刷新%s的MCP服务 '%s' 的能力...", tenantLabel(tenant), mcpName[0])
+			if err := service.AddOrUpdateClient(mcpName[0], cfg); err != nil {
+				errs = append(errs, fmt.Errorf("刷新%s的MCP '%s' 能力时发生错误: %w", tenantLabel(tenant), mcpName[0], err))
+				continue
+			}
+			global.Log.Infof("%s的MCP服务 '%s' 的能力刷新完成", tenantLabel(tenant), mcpName[0])
+			continue
+		}
+
+		found = true
+		global.Log.Infof("开始刷新%s的所有MCP服务能力...", tenantLabel(tenant))
+		errs = append(errs, m.reloadMcpServices(tenant, service, mcpConfigs)...)
 	}
 
-	// 如果提供了mcpName且不为空，则只刷新指定的MCP
-	if len(mcpName) > 0 && mcpName[0] != "" {
-		targetMcpName := mcpName[0]
-		cfg, ok := mcpConfigs[targetMcpName]
-		if !ok {
-			err := fmt.Errorf("未在配置中找到名为 '%s' 的MCP服务,刷新操作已中止", targetMcpName)
+	if !found {
+		if len(mcpName) > 0 && mcpName[0] != "" {
+			err := fmt.Errorf("未在配置中找到名为 '%s' 的MCP服务,刷新操作已中止", mcpName[0])
 			global.Log.Warn(err)
 			return err
 		}
+		global.Log.Info("MCP服务未启用或未配置任何MCP服务，跳过能力刷新任务")
+		return nil
+	}
 
-		global.Log.Infof("开始刷新MCP服务 '%s' 的能力...", targetMcpName)
-		if err := global.McpService.AddOrUpdateClient(targetMcpName, cfg); err != nil {
-			finalError := fmt.Errorf("刷新MCP '%s' 能力时发生错误: %w", targetMcpName, err)
-			global.Log.Warn(finalError)
-			return finalError
+	if len(errs) > 0 {
+		var combinedErr strings.Builder
+		for _, e := range errs {
+			combinedErr.WriteString(e.Error())
+			combinedErr.WriteString("; ")
 		}
-		global.Log.Infof("MCP服务 '%s' 的能力刷新完成", targetMcpName)
-		return nil
+		finalError := fmt.Errorf("刷新MCP能力时发生错误: %s", strings.TrimRight(combinedErr.String(), "; "))
+		global.Log.Error(finalError)
+		return finalError
 	}
 
-	// 否则，刷新所有MCP服务
-	global.Log.Info("开始刷新所有MCP服务能力...")
+	global.Log.Info("所有MCP服务能力刷新完成")
+	return nil
+}
 
+// reloadMcpServices 并发刷新一个租户的所有MCP服务
+func (m *Manager) reloadMcpServices(tenant *global.Tenant, service mcp.Service, mcpConfigs map[string]config.Mcp) []error {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var errs []error
@@ -57,28 +82,15 @@ func (m *Manager) McpCapabilitiesReloader(mcpName ...string) error {
 		go func(name string, cfg config.Mcp) {
 			defer wg.Done()
 			// AddOrUpdateClient 是线程安全的，它处理连接、发现工具和更新内部缓存的逻辑。
-			err := global.McpService.AddOrUpdateClient(name, cfg)
+			err := service.AddOrUpdateClient(name, cfg)
 			if err != nil {
 				mu.Lock()
-				errs = append(errs, fmt.Errorf("刷新MCP客户端 '%s' 失败: %w", name, err))
+				errs = append(errs, fmt.Errorf("刷新%s的MCP客户端 '%s' 失败: %w", tenantLabel(tenant), name, err))
 				mu.Unlock()
 			}
 		}(name, cfg)
 	}
 
 	wg.Wait()
-
-	if len(errs) > 0 {
-		var combinedErr strings.Builder
-		for _, e := range errs {
-			combinedErr.WriteString(e.Error())
-			combinedErr.WriteString("; ")
-		}
-		finalError := fmt.Errorf("刷新MCP能力时发生错误: %s", strings.TrimRight(combinedErr.String(), "; "))
-		global.Log.Error(finalError)
-		return finalError
-	}
-
-	global.Log.Info("所有MCP服务能力刷新完成")
-	return nil
+	return errs
 }
@@ -0,0 +1,129 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/taoJie_1/mall-agent/dao"
+	"gitee.com/taoJie_1/mall-agent/global"
+	"gitee.com/taoJie_1/mall-agent/internal/redis"
+	"gitee.com/taoJie_1/mall-agent/model/enum"
+)
+
+// PromptReloader 从Redis加载各提示词启用的版本及A/B实验分组引用的版本到内存, 加载失败或未通过校验的提示词使用内置版本
+func (m *Manager) PromptReloader() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	active, err := dao.App.PromptsDb.GetActivePromptVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	data := make(map[enum.PromptName]string, len(active))
+	for name, version := range active {
+		if _, ok := enum.BuiltinPrompts[name]; !ok || version == 0 {
+			continue
+		}
+		content, err := m.loadPromptVersion(ctx, name, version)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("提示词 %s: %w", name, err))
+			continue
+		}
+		data[name] = content
+	}
+
+	versions := make(map[enum.PromptName]map[int]string)
+	if experiment := global.Config.Experiment; experiment.Enable {
+		for _, variant := range experiment.Variants {
+			for n, version := range variant.Prompts {
+				name := enum.PromptName(n)
+				if _, ok := enum.BuiltinPrompts[name]; !ok {
+					errs = append(errs, fmt.Errorf("实验分组 '%s' 引用了未知的提示词: %s", variant.Name, n))
+					continue
+				}
+				if version == 0 || versions[name][version] != "" {
+					continue
+				}
+				content, err := m.loadPromptVersion(ctx, name, version)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("实验分组 '%s' 的提示词 %s: %w", variant.Name, name, err))
+					continue
+				}
+				if versions[name] == nil {
+					versions[name] = make(map[int]string)
+				}
+				versions[name][version] = content
+			}
+		}
+	}
+
+	global.Prompts.Lock()
+	global.Prompts.Data = data
+	global.Prompts.Versions = versions
+	global.Prompts.Unlock()
+
+	global.Log.Infof("已加载 %d 个自定义提示词, 其余使用内置版本", len(data))
+	return errors.Join(errs...)
+}
+
+// loadPromptVersion 获取提示词指定版本的内容, 并在启用前再次校验
+func (m *Manager) loadPromptVersion(ctx context.Context, name enum.PromptName, version int) (string, error) {
+	versions, err := dao.App.PromptsDb.ListPromptVersions(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if v.Version != version {
+			continue
+		}
+		if err := enum.ValidatePrompt(name, v.Content); err != nil {
+			return "", fmt.Errorf("第%d版未通过校验: %w", version, err)
+		}
+		return v.Content, nil
+	}
+	return "", fmt.Errorf("第%d版不存在", version)
+}
+
+// PublishPromptReload 通知所有实例重新加载提示词
+func (m *Manager) PublishPromptReload(ctx context.Context) error {
+	if global.RedisClient == nil {
+		return errors.New("Redis客户端未初始化")
+	}
+	return global.RedisClient.Publish(ctx, redis.ChannelPromptReload, time.Now().Unix()).Err()
+}
+
+// ListenPromptReload 订阅提示词变更广播, 收到后重新加载; 订阅中断时自动重新订阅
+func (m *Manager) ListenPromptReload(ctx context.Context) {
+	for ctx.Err() == nil {
+		if client := global.RedisClient; client != nil {
+			pubsub := client.Subscribe(ctx, redis.ChannelPromptReload)
+			ch := pubsub.Channel()
+		consume:
+			for {
+				select {
+				case <-ctx.Done():
+					break consume
+				case _, ok := <-ch:
+					if !ok {
+						break consume
+					}
+					if err := m.PromptReloader(); err != nil {
+						global.Log.Errorf("重新加载提示词失败: %v", err)
+					}
+				}
+			}
+			_ = pubsub.Close()
+		}
+
+		// 订阅中断或Redis未就绪时稍作等待再重新订阅
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
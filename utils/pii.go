@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// piiRule 一类个人信息的识别与脱敏规则
+type piiRule struct {
+	label   string                  // 占位符中的类别名称
+	re      *regexp.Regexp          // 候选匹配
+	bounded bool                    // 要求匹配前后不能紧邻数字或字母, 避免截取长数字串的一部分
+	valid   func(value string) bool // 对候选匹配的进一步校验, 为nil表示不校验
+	trim    func(value string) int  // 返回候选匹配开头需要跳过的字节数, 为nil表示不跳过
+	mask    func(value string) string
+}
+
+// piiRules 按顺序匹配, 先匹配的规则优先, 如邮箱中的数字不会再被识别为手机号
+var piiRules = []piiRule{
+	{
+		label: "邮箱",
+		re:    regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		mask: func(v string) string {
+			at := strings.LastIndex(v, "@")
+			return v[:1] + "***" + v[at:]
+		},
+	},
+	{
+		label:   "身份证号",
+		re:      regexp.MustCompile(`[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]`),
+		bounded: true,
+		mask:    func(v string) string { return v[:3] + strings.Repeat("*", len(v)-7) + v[len(v)-4:] },
+	},
+	{
+		label:   "银行卡号",
+		re:      regexp.MustCompile(`[3-6]\d{3}(?:[ \-]?\d{4}){3}(?:[ \-]?\d{1,3})?`),
+		bounded: true,
+		valid:   func(v string) bool { return luhnValid(digitsOnly(v)) },
+		mask: func(v string) string {
+			digits := digitsOnly(v)
+			return "****" + digits[len(digits)-4:]
+		},
+	},
+	{
+		label:   "手机号",
+		re:      regexp.MustCompile(`(?:\+?86[ \-]?)?1[3-9]\d{9}`),
+		bounded: true,
+		mask:    func(v string) string { return v[:len(v)-8] + "****" + v[len(v)-4:] },
+	},
+	{
+		label: "地址",
+		re:    regexp.MustCompile(`\p{Han}{2,}(?:路|街|大道|巷|弄|村)\p{Han}{0,10}\d+(?:号楼|号院|号|栋|幢)(?:[\dA-Za-z\-]*(?:号楼|号|栋|幢|单元|室|层|楼))*`),
+		trim:  trimAddressLeadIn,
+		mask:  maskAddress,
+	},
+}
+
+// MaskPII 将文本中的手机号、身份证号、银行卡号、邮箱和地址替换为不可还原的掩码, 用于日志和持久化记录
+func MaskPII(s string) string {
+	for _, rule := range piiRules {
+		s = rule.replace(s, rule.mask)
+	}
+	return s
+}
+
+// PIIVault 将个人信息替换为可还原的占位符(如 [手机号_1]), 同一值始终对应同一占位符
+// 用于会话历史和发送给LLM的提示词: LLM回复和工具参数中的占位符可还原为真实值。nil 的 PIIVault 不做任何替换
+type PIIVault struct {
+	values  map[string]string // 占位符 -> 真实值
+	holders map[string]string // 真实值 -> 占位符
+	counts  map[string]int
+}
+
+func NewPIIVault() *PIIVault {
+	return &PIIVault{
+		values:  make(map[string]string),
+		holders: make(map[string]string),
+		counts:  make(map[string]int),
+	}
+}
+
+// placeholderPattern 匹配 PIIVault 生成的占位符, 用于恢复各类别的编号
+var placeholderPattern = regexp.MustCompile(`^\[(.+)_(\d+)\]$`)
+
+// LoadPIIVault 根据保存的映射(占位符 -> 真实值)恢复 PIIVault, 之后新出现的个人信息接着已有编号分配占位符
+func LoadPIIVault(values map[string]string) *PIIVault {
+	v := NewPIIVault()
+	for holder, value := range values {
+		v.values[holder] = value
+		v.holders[value] = holder
+		if m := placeholderPattern.FindStringSubmatch(holder); m != nil {
+			if n, err := strconv.Atoi(m[2]); err == nil && n > v.counts[m[1]] {
+				v.counts[m[1]] = n
+			}
+		}
+	}
+	return v
+}
+
+// Values 返回占位符到真实值的映射, 用于保存后通过 LoadPIIVault 恢复
+func (v *PIIVault) Values() map[string]string {
+	if v == nil {
+		return nil
+	}
+	values := make(map[string]string, len(v.values))
+	for holder, value := range v.values {
+		values[holder] = value
+	}
+	return values
+}
+
+// Redact 将文本中的个人信息替换为占位符
+func (v *PIIVault) Redact(s string) string {
+	if v == nil {
+		return s
+	}
+	for _, rule := range piiRules {
+		s = rule.replace(s, func(value string) string {
+			if holder, ok := v.holders[value]; ok {
+				return holder
+			}
+			v.counts[rule.label]++
+			holder := fmt.Sprintf("[%s_%d]", rule.label, v.counts[rule.label])
+			v.holders[value] = holder
+			v.values[holder] = value
+			return holder
+		})
+	}
+	return s
+}
+
+// Restore 将文本中的占位符还原为真实值
+func (v *PIIVault) Restore(s string) string {
+	if v == nil || len(v.values) == 0 {
+		return s
+	}
+	pairs := make([]string, 0, len(v.values)*2)
+	for holder, value := range v.values {
+		pairs = append(pairs, holder, value)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// replace 对通过校验的匹配调用 fn 替换
+func (r piiRule) replace(s string, fn func(string) string) string {
+	matches := r.re.FindAllStringIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if r.trim != nil {
+			m[0] += r.trim(s[m[0]:m[1]])
+		}
+		value := s[m[0]:m[1]]
+		if r.bounded && (isAlnumBefore(s, m[0]) || isAlnumAfter(s, m[1])) {
+			continue
+		}
+		if r.valid != nil && !r.valid(value) {
+			continue
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(fn(value))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func isAlnumBefore(s string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return isASCIIAlnum(r)
+}
+
+func isAlnumAfter(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return isASCIIAlnum(r)
+}
+
+func isASCIIAlnum(r rune) bool {
+	return r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+func digitsOnly(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// luhnValid 银行卡号的Luhn校验, 用于排除订单号等普通长数字
+func luhnValid(digits string) bool {
+	if len(digits) < 16 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// trimAddressLeadIn 跳过地址前的引导语, 如 "收货地址改为北京市..." 中的 "收货地址改为"
+func trimAddressLeadIn(v string) int {
+	end := strings.IndexFunc(v, func(r rune) bool { return strings.ContainsRune("省市区县路街巷弄村", r) })
+	if end <= 0 {
+		return 0
+	}
+	cut := strings.LastIndexFunc(v[:end], func(r rune) bool { return strings.ContainsRune("址是为到至成在", r) })
+	if cut < 0 {
+		return 0
+	}
+	_, size := utf8.DecodeRuneInString(v[cut:])
+	// 引导语之后至少保留两个字作为地名
+	if utf8.RuneCountInString(v[cut+size:end]) < 2 {
+		return 0
+	}
+	return cut + size
+}
+
+// maskAddress 保留到省/市/区/县一级, 隐去街道和门牌
+func maskAddress(v string) string {
+	runes := []rune(v)
+	keep := 0
+	for i, r := range runes {
+		if strings.ContainsRune("省市区县", r) {
+			keep = i + 1
+		}
+		if strings.ContainsRune("路街巷弄村", r) {
+			break
+		}
+	}
+	if keep == 0 {
+		keep = min(2, len(runes))
+	}
+	return string(runes[:keep]) + "***"
+}
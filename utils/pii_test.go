@@ -0,0 +1,73 @@
+package utils
+
+import "testing"
+
+func TestMaskPII(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"手机号", "我的电话13812345678", "我的电话138****5678"},
+		{"带区号手机号", "联系+8613812345678", "联系+86138****5678"},
+		{"长数字串中的手机号", "订单号813812345678901", "订单号813812345678901"},
+		{"身份证号", "身份证110101199003071234", "身份证110***********1234"},
+		{"银行卡号", "卡号6222 0212 3456 7894", "卡号****7894"},
+		{"未通过Luhn校验的长数字", "订单6222021234567890", "订单6222021234567890"},
+		{"邮箱", "邮箱 alice.wang@example.com", "邮箱 a***@example.com"},
+		{"地址", "收货地址改为北京市朝阳区建国路88号", "收货地址改为北京市朝阳区***"},
+		{"无个人信息", "退货规则是什么", "退货规则是什么"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaskPII(tt.in); got != tt.want {
+				t.Errorf("MaskPII(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPIIVault(t *testing.T) {
+	v := NewPIIVault()
+	redacted := v.Redact("电话13812345678, 备用13987654321, 再确认一次13812345678")
+	want := "电话[手机号_1], 备用[手机号_2], 再确认一次[手机号_1]"
+	if redacted != want {
+		t.Fatalf("Redact() = %q, want %q", redacted, want)
+	}
+
+	// 同一 vault 的后续文本复用已有占位符
+	if got := v.Redact("邮箱a@b.com 电话13987654321"); got != "邮箱[邮箱_1] 电话[手机号_2]" {
+		t.Errorf("Redact() = %q", got)
+	}
+
+	if got := v.Restore("已为 [手机号_2] 和 [邮箱_1] 登记, [手机号_9] 不存在"); got != "已为 13987654321 和 a@b.com 登记, [手机号_9] 不存在" {
+		t.Errorf("Restore() = %q", got)
+	}
+
+	var nilVault *PIIVault
+	if got := nilVault.Redact("13812345678"); got != "13812345678" {
+		t.Errorf("nil Redact() = %q", got)
+	}
+	if got := nilVault.Restore("[手机号_1]"); got != "[手机号_1]" {
+		t.Errorf("nil Restore() = %q", got)
+	}
+}
+
+func TestLoadPIIVault(t *testing.T) {
+	v := NewPIIVault()
+	v.Redact("电话13812345678, 备用13987654321, 邮箱a@b.com")
+
+	// 恢复后已有的值沿用原占位符, 新值接着已有编号分配
+	loaded := LoadPIIVault(v.Values())
+	if got := loaded.Redact("13987654321 和 13700001111"); got != "[手机号_2] 和 [手机号_3]" {
+		t.Errorf("Redact() = %q", got)
+	}
+	if got := loaded.Restore("[邮箱_1] [手机号_3]"); got != "a@b.com 13700001111" {
+		t.Errorf("Restore() = %q", got)
+	}
+
+	var nilVault *PIIVault
+	if values := nilVault.Values(); values != nil {
+		t.Errorf("nil Values() = %v", values)
+	}
+}
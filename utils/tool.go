@@ -204,3 +204,20 @@ func ParseDateFromLogFileName(filename string, loc *time.Location) (time.Time, b
 	}
 	return t, true
 }
+
+// CosineSimilarity 计算两个向量的余弦相似度, 长度不同或存在零向量时返回0
+func CosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}